@@ -0,0 +1,27 @@
+// Command alpaca_mock serves an in-memory Alpaca trading API for exercising
+// order execution without an Alpaca account. Point the server at it with
+// ALPACA_TRADING_URL and any APCA_API_KEY_ID.
+//
+//	go run ./cmd/alpaca_mock -addr :8092 -fill-steps 2
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"vibetrade-claude/internal/execution/alpacamock"
+)
+
+func main() {
+	addr := flag.String("addr", ":8092", "address to listen on")
+	fillSteps := flag.Int("fill-steps", 2, "polls an order takes to fill; 0 never fills")
+	flag.Parse()
+
+	fmt.Printf("Mock Alpaca trading API listening on %s\n", *addr)
+	if err := http.ListenAndServe(*addr, alpacamock.NewServer(*fillSteps)); err != nil {
+		fmt.Fprintf(os.Stderr, "Mock server failed: %v\n", err)
+		os.Exit(1)
+	}
+}
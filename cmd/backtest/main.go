@@ -0,0 +1,174 @@
+// Command backtest replays stored market snapshots through the AI
+// recommendation pipeline and reports how each configured variant of the
+// prompt and risk policy would have traded.
+//
+//	go run ./cmd/backtest -config backtest.yaml -out data/backtest_result.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/backtest"
+	"vibetrade-claude/internal/events"
+	"vibetrade-claude/internal/snapshots"
+)
+
+// LLM modes
+const (
+	llmBaseline = "baseline" // The rule-based stand-in
+	llmReplay   = "replay"   // Recorded responses only
+	llmRecord   = "record"   // Recorded responses, asking Claude for the rest
+)
+
+type config struct {
+	DataDir      string               `yaml:"data_dir"`
+	ArchiveDir   string               `yaml:"archive_dir"` // The server's SNAPSHOT_DIR, instead of data_dir
+	Symbols      []string             `yaml:"symbols"`
+	From         string               `yaml:"from"`
+	To           string               `yaml:"to"`
+	StartingCash float64              `yaml:"starting_cash"`
+	Slippage     *float64             `yaml:"slippage"`
+	Commission   *float64             `yaml:"commission"`
+	EventsFile   string               `yaml:"events_file"`
+	LLM          string               `yaml:"llm"`
+	Recording    string               `yaml:"recording"`
+	Management   *backtest.Management `yaml:"management"`
+	Variants     []variantConfig      `yaml:"variants"`
+}
+
+type variantConfig struct {
+	Name       string `yaml:"name"`
+	PromptFile string `yaml:"prompt_file"`
+	RiskPolicy string `yaml:"risk_policy"`
+}
+
+func main() {
+	configPath := flag.String("config", "backtest.yaml", "backtest configuration")
+	outPath := flag.String("out", "", "file to write the full results to as JSON")
+	flag.Parse()
+
+	logger := logrus.New()
+	if err := run(*configPath, *outPath, logger); err != nil {
+		fmt.Fprintf(os.Stderr, "Backtest failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath, outPath string, logger *logrus.Logger) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	runConfig := backtest.DefaultConfig()
+	runConfig.Symbols = cfg.Symbols
+	if runConfig.From, err = time.Parse("2006-01-02", cfg.From); err != nil {
+		return fmt.Errorf("invalid from date %q: %w", cfg.From, err)
+	}
+	if runConfig.To, err = time.Parse("2006-01-02", cfg.To); err != nil {
+		return fmt.Errorf("invalid to date %q: %w", cfg.To, err)
+	}
+	if cfg.StartingCash > 0 {
+		runConfig.StartingCash = cfg.StartingCash
+	}
+	if cfg.Slippage != nil {
+		runConfig.Slippage = *cfg.Slippage
+	}
+	if cfg.Commission != nil {
+		runConfig.Commission = *cfg.Commission
+	}
+	if cfg.Management != nil {
+		runConfig.Management = *cfg.Management
+	}
+	if len(runConfig.Symbols) == 0 {
+		return fmt.Errorf("no symbols configured")
+	}
+
+	var store backtest.Store
+	if cfg.ArchiveDir != "" {
+		archive, err := snapshots.NewStore(cfg.ArchiveDir, 0, logger)
+		if err != nil {
+			return err
+		}
+		store = backtest.NewArchiveStore(archive)
+	} else if store, err = backtest.NewDirStore(cfg.DataDir); err != nil {
+		return err
+	}
+
+	var llm ai_assistant.LLMProvider
+	var recording *backtest.RecordedLLM
+	switch cfg.LLM {
+	case "", llmBaseline:
+		llm = backtest.BaselineLLM{}
+	case llmReplay, llmRecord:
+		if cfg.Recording == "" {
+			return fmt.Errorf("llm %q needs a recording file", cfg.LLM)
+		}
+		var live ai_assistant.LLMProvider
+		if cfg.LLM == llmRecord {
+			live = ai_assistant.NewClaudeClient("")
+		}
+		if recording, err = backtest.NewRecordedLLM(cfg.Recording, live); err != nil {
+			return err
+		}
+		llm = recording
+	default:
+		return fmt.Errorf("unknown llm %q; use baseline, replay or record", cfg.LLM)
+	}
+
+	runner := backtest.NewRunner(store, llm, runConfig, logger)
+	if cfg.EventsFile != "" {
+		provider, err := events.NewFileProvider(cfg.EventsFile)
+		if err != nil {
+			return fmt.Errorf("failed to load events: %w", err)
+		}
+		runner.SetEventProvider(provider)
+	}
+
+	if len(cfg.Variants) == 0 {
+		cfg.Variants = []variantConfig{{Name: "default"}}
+	}
+	variants := make([]backtest.Variant, 0, len(cfg.Variants))
+	for _, vc := range cfg.Variants {
+		variant, err := backtest.LoadVariant(vc.Name, vc.PromptFile, vc.RiskPolicy)
+		if err != nil {
+			return err
+		}
+		variants = append(variants, variant)
+	}
+
+	comparison, err := runner.Compare(context.Background(), variants...)
+	if recording != nil {
+		// Keep whatever was recorded, even from a run that failed part way
+		if saveErr := recording.Save(); saveErr != nil {
+			logger.WithError(saveErr).Error("Failed to save LLM recording")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(comparison.Table())
+	if outPath != "" {
+		out, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode results: %w", err)
+		}
+		if err := os.WriteFile(outPath, out, 0644); err != nil {
+			return fmt.Errorf("failed to write results: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Command migrate_users imports users from the legacy JSON file store into
+// the embedded bbolt user store.
+//
+//	go run ./cmd/migrate_users -from data/users.json -to data/users.db
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"vibetrade-claude/internal/userstore"
+)
+
+func main() {
+	from := flag.String("from", "data/users.json", "legacy JSON user file to import")
+	to := flag.String("to", "data/users.db", "bbolt user database to write")
+	overwrite := flag.Bool("overwrite", false, "replace users that already exist in the destination")
+	dryRun := flag.Bool("dry-run", false, "parse and convert without opening or writing the destination")
+	flag.Parse()
+
+	if err := run(*from, *to, *overwrite, *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run performs the import, returning rather than exiting so the destination
+// store is always closed cleanly. A dry run only reads the legacy file and
+// never opens, or creates, the destination.
+func run(from, to string, overwrite, dryRun bool) error {
+	var store userstore.UserStore
+	if !dryRun {
+		bolt, err := userstore.NewBoltUserStore(to)
+		if err != nil {
+			return fmt.Errorf("failed to open destination: %w", err)
+		}
+		defer bolt.Close()
+		store = bolt
+	}
+
+	report, err := userstore.ImportLegacyFile(from, store, overwrite, dryRun)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d users failed to import", len(report.Errors))
+	}
+	return nil
+}
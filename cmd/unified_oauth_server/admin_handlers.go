@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/auth"
+)
+
+// AdminHandlers serves the operator endpoints for halting AI trading
+type AdminHandlers struct {
+	breaker     *ai_assistant.CircuitBreaker
+	reconciler  *TradeReconciler
+	performance *ai_assistant.PerformanceTracker
+	riskPolicy  *ai_assistant.RiskPolicy
+	logger      *logrus.Logger
+}
+
+func NewAdminHandlers(breaker *ai_assistant.CircuitBreaker, reconciler *TradeReconciler, performance *ai_assistant.PerformanceTracker, riskPolicy *ai_assistant.RiskPolicy, logger *logrus.Logger) *AdminHandlers {
+	return &AdminHandlers{
+		breaker:     breaker,
+		reconciler:  reconciler,
+		performance: performance,
+		riskPolicy:  riskPolicy,
+		logger:      logger,
+	}
+}
+
+// KillSwitchRequest engages or releases the global kill switch
+type KillSwitchRequest struct {
+	Engaged bool   `json:"engaged"`
+	Reason  string `json:"reason"`
+}
+
+// HandleKillSwitch returns (GET) or sets (PUT) the global kill switch
+func (h *AdminHandlers) HandleKillSwitch(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		sendJSONResponse(w, h.breaker.KillSwitch())
+
+	case http.MethodPut:
+		var req KillSwitchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		actor := auth.UserIDFromContext(r.Context())
+		state, err := h.breaker.SetKillSwitch(req.Engaged, actor, strings.TrimSpace(req.Reason))
+		if err != nil {
+			// The switch is already applied in memory; only persistence failed
+			h.logger.WithError(err).Error("Failed to persist kill switch state")
+			sendJSONError(w, "Kill switch changed but could not be saved", http.StatusInternalServerError)
+			return
+		}
+
+		h.logger.WithFields(logrus.Fields{
+			"engaged": state.Engaged,
+			"actor":   actor,
+			"reason":  state.Reason,
+		}).Warn("AI trading kill switch changed")
+
+		sendJSONResponse(w, state)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleResetCircuitBreaker clears a user's tripped daily loss breaker
+func (h *AdminHandlers) HandleResetCircuitBreaker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		UserID string `json:"userId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID == "" {
+		sendJSONError(w, "userId is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.breaker.Reset(req.UserID); err != nil {
+		h.logger.WithError(err).Error("Failed to persist circuit breaker reset")
+		sendJSONError(w, "Circuit breaker reset but could not be saved", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"user_id": req.UserID,
+		"actor":   auth.UserIDFromContext(r.Context()),
+	}).Warn("Daily loss circuit breaker reset")
+
+	sendJSONResponse(w, h.breaker.Status(req.UserID))
+}
+
+// HandleReconciliation returns the last reconciliation of recommendation
+// records with positions (GET), or runs one now (POST)
+func (h *AdminHandlers) HandleReconciliation(w http.ResponseWriter, r *http.Request) {
+	if h.reconciler == nil {
+		sendJSONError(w, "Reconciliation needs EXECUTION_BROKER=paper or VIBETRADE_API_URL", http.StatusNotImplemented)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		report := h.reconciler.LastReport()
+		if report == nil {
+			sendJSONError(w, "No reconciliation has run yet", http.StatusNotFound)
+			return
+		}
+		sendJSONResponse(w, report)
+
+	case http.MethodPost:
+		report, err := h.reconciler.Reconcile(r.Context())
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to reconcile recommendation records")
+			sendJSONError(w, "Failed to reconcile recommendation records", http.StatusInternalServerError)
+			return
+		}
+		sendJSONResponse(w, report)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleScoringCalibration fits the risk scoring weights and strategy class
+// risks to the trades recommended between from and to (RFC 3339 or
+// YYYY-MM-DD; the last year by default) that have closed or expired. The
+// fit is returned for the operator to copy into the policy's scoring
+// section; the running policy is not changed.
+func (h *AdminHandlers) HandleScoringCalibration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	to, err := parseTimeParam(query.Get("to"), time.Now(), true)
+	if err != nil {
+		sendJSONError(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), to.AddDate(-1, 0, 0), false)
+	if err != nil {
+		sendJSONError(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.performance.ClosedRecords(from, to)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to read closed recommendation records")
+		sendJSONError(w, "Failed to read closed recommendation records", http.StatusInternalServerError)
+		return
+	}
+
+	calibration, err := ai_assistant.CalibrateScoring(records, h.riskPolicy.Scoring, h.riskPolicy.Limits.MaxPositionSize)
+	if err != nil {
+		// Too few trades, or no wins or no losses, to fit
+		sendJSONError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	sendJSONResponse(w, map[string]interface{}{
+		"calibration": calibration,
+		"from":        from,
+		"to":          to,
+	})
+}
+
+// parseTimeParam parses an RFC 3339 time or a date, which is taken as
+// the end of that day when endOfDay is set
+func parseTimeParam(value string, fallback time.Time, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -1,20 +1,35 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
-	
+
+	"github.com/sirupsen/logrus"
 	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/auth"
+	"vibetrade-claude/internal/snapshots"
+	"vibetrade-claude/internal/turnstile"
+	"vibetrade-claude/internal/userstore"
+	"vibetrade-claude/internal/vault"
 )
 
 type AIHandlers struct {
-	aiAssistant   *ai_assistant.TradingAssistant
+	assistants     *ai_assistant.AssistantRegistry
 	dataAggregator *ai_assistant.MarketDataAggregator
-	userStore     *snaptrade.FileUserStore
-	encryptor     *snaptrade.Encryptor
+	userStore      userstore.UserStore
+	vault          *vault.Vault
+	riskPolicy     *ai_assistant.RiskPolicy
+	breaker        *ai_assistant.CircuitBreaker
+	proposals      *ai_assistant.ProposalBook
+	performance    *ai_assistant.PerformanceTracker
+	snapshots      *snapshots.Store
+	portfolios     *PortfolioService
+	turnstile      *turnstile.Verifier
+	logger         *logrus.Logger
 }
 
 type ConnectClaudeRequest struct {
@@ -23,21 +38,37 @@ type ConnectClaudeRequest struct {
 }
 
 type ClaudeConnectionStatus struct {
-	IsConnected bool      `json:"isConnected"`
-	ConnectedAt *time.Time `json:"connectedAt,omitempty"`
-	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+	IsConnected         bool       `json:"isConnected"`
+	ConnectedAt         *time.Time `json:"connectedAt,omitempty"`
+	LastUsedAt          *time.Time `json:"lastUsedAt,omitempty"`
+	KeyHealth           string     `json:"keyHealth,omitempty"`
+	LastValidatedAt     *time.Time `json:"lastValidatedAt,omitempty"`
+	LastValidationError string     `json:"lastValidationError,omitempty"`
 }
 
 type TradeRecommendationsResponse struct {
-	Trades    []ai_assistant.TradeRecommendation `json:"trades"`
-	Timestamp time.Time                          `json:"timestamp"`
-	Message   string                             `json:"message,omitempty"`
+	Trades     []ai_assistant.TradeRecommendation `json:"trades"`
+	Rejected   []ai_assistant.TradeRecommendation `json:"rejected,omitempty"`    // Failed the user's risk policy
+	Greeks     *ai_assistant.BasketGreeks         `json:"greeks,omitempty"`      // Net Greeks with the approved trades
+	SnapshotID string                             `json:"snapshot_id,omitempty"` // The market data the trades were based on
+	Timestamp  time.Time                          `json:"timestamp"`
+	Message    string                             `json:"message,omitempty"`
 }
 
-func NewAIHandlers(userStore *snaptrade.FileUserStore, encryptor *snaptrade.Encryptor) *AIHandlers {
+func NewAIHandlers(userStore userstore.UserStore, credentials *vault.Vault, dataAggregator *ai_assistant.MarketDataAggregator, assistants *ai_assistant.AssistantRegistry, riskPolicy *ai_assistant.RiskPolicy, breaker *ai_assistant.CircuitBreaker, proposals *ai_assistant.ProposalBook, performance *ai_assistant.PerformanceTracker, snapshotStore *snapshots.Store, portfolios *PortfolioService, verifier *turnstile.Verifier, logger *logrus.Logger) *AIHandlers {
 	return &AIHandlers{
-		userStore: userStore,
-		encryptor: encryptor,
+		riskPolicy:     riskPolicy,
+		breaker:        breaker,
+		proposals:      proposals,
+		performance:    performance,
+		snapshots:      snapshotStore,
+		portfolios:     portfolios,
+		turnstile:      verifier,
+		assistants:     assistants,
+		userStore:      userStore,
+		vault:          credentials,
+		dataAggregator: dataAggregator,
+		logger:         logger,
 	}
 }
 
@@ -54,54 +85,88 @@ func (h *AIHandlers) HandleClaudeConnect(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Validate turnstile token (simplified for now)
-	if req.TurnstileToken == "" {
-		sendJSONError(w, "Turnstile verification required", http.StatusBadRequest)
+	// Verify the Turnstile challenge before touching the key
+	if err := h.turnstile.Verify(r.Context(), req.TurnstileToken, h.turnstile.RemoteIP(r)); err != nil {
+		var verr *turnstile.VerificationError
+		if errors.As(err, &verr) {
+			status := http.StatusBadRequest
+			if verr.Code == turnstile.CodeUnavailable {
+				h.logger.WithError(err).Error("Turnstile verification unavailable")
+				status = http.StatusServiceUnavailable
+			}
+			sendJSONErrorCode(w, verr.Message, verr.Code, status)
+			return
+		}
+		sendJSONError(w, "Turnstile verification failed", http.StatusBadRequest)
 		return
 	}
 
 	// Get user ID from session
-	userID := r.Context().Value("userID").(string)
+	userID := auth.UserIDFromContext(r.Context())
 	if userID == "" {
 		sendJSONError(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
+	if req.APIKey == "" {
+		sendJSONErrorCode(w, "API key is required", "claude_key_missing", http.StatusBadRequest)
+		return
+	}
+
+	// Probe the key before storing it so typos surface now rather than on first use
+	validation, err := ai_assistant.NewClaudeClient(req.APIKey).ValidateKey(r.Context())
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to validate Claude API key")
+		sendJSONErrorCode(w, "Could not verify API key with Anthropic, please try again", "claude_key_unverified", http.StatusBadGateway)
+		return
+	}
+	if validation.Health == ai_assistant.KeyHealthInvalid {
+		sendJSONErrorCode(w, "Anthropic rejected this API key", "claude_key_invalid", http.StatusBadRequest)
+		return
+	}
+
 	// Encrypt the API key
-	encryptedKey, err := h.encryptor.Encrypt(req.APIKey)
+	encryptedKey, err := h.vault.Seal(claudeKeyRecordID(userID), []byte(req.APIKey))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to encrypt API key")
 		sendJSONError(w, "Failed to secure API key", http.StatusInternalServerError)
 		return
 	}
 
-	// Store the encrypted key in user data
-	user, err := h.userStore.GetUser(userID)
-	if err != nil {
-		sendJSONError(w, "User not found", http.StatusNotFound)
+	// Store the encrypted key on the user, creating the user on first connect
+	if _, err := userstore.GetOrCreate(h.userStore, userID); err != nil {
+		h.logger.WithError(err).Error("Failed to load user")
+		sendJSONError(w, "Failed to load user", http.StatusInternalServerError)
 		return
 	}
 
-	// Add Claude connection to user metadata
-	if user.Metadata == nil {
-		user.Metadata = make(map[string]interface{})
-	}
-	
-	user.Metadata["claude_api_key"] = encryptedKey
-	user.Metadata["claude_connected_at"] = time.Now()
-	
-	if err := h.userStore.UpdateUser(user); err != nil {
+	_, err = userstore.Modify(h.userStore, userID, func(user *userstore.User) error {
+		now := time.Now()
+		user.Claude = userstore.ClaudeConnection{
+			EncryptedAPIKey: encryptedKey,
+			ConnectedAt:     &now,
+		}
+		recordKeyValidation(user, validation)
+		return nil
+	})
+	if err != nil {
 		h.logger.WithError(err).Error("Failed to update user")
 		sendJSONError(w, "Failed to save connection", http.StatusInternalServerError)
 		return
 	}
 
-	// Initialize AI assistant with the API key
-	h.aiAssistant = ai_assistant.NewTradingAssistant(req.APIKey)
+	// Start the new key with fresh assistant state
+	h.assistants.Invalidate(userID)
+
+	message := "Claude API connected successfully"
+	if validation.Health == ai_assistant.KeyHealthNoQuota {
+		message = "Claude API connected, but the account has no remaining credit"
+	}
 
 	sendJSONResponse(w, map[string]interface{}{
-		"success": true,
-		"message": "Claude API connected successfully",
+		"success":   true,
+		"message":   message,
+		"keyHealth": validation.Health,
 	})
 }
 
@@ -112,7 +177,7 @@ func (h *AIHandlers) HandleClaudeStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
+	userID := auth.UserIDFromContext(r.Context())
 	user, err := h.userStore.GetUser(userID)
 	if err != nil {
 		sendJSONResponse(w, ClaudeConnectionStatus{IsConnected: false})
@@ -120,23 +185,19 @@ func (h *AIHandlers) HandleClaudeStatus(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Check if Claude is connected
-	if apiKey, ok := user.Metadata["claude_api_key"].(string); ok && apiKey != "" {
-		status := ClaudeConnectionStatus{
-			IsConnected: true,
-		}
-		
-		if connectedAt, ok := user.Metadata["claude_connected_at"].(time.Time); ok {
-			status.ConnectedAt = &connectedAt
-		}
-		
-		if lastUsed, ok := user.Metadata["claude_last_used_at"].(time.Time); ok {
-			status.LastUsedAt = &lastUsed
-		}
-		
-		sendJSONResponse(w, status)
-	} else {
+	if !user.Claude.IsConnected() {
 		sendJSONResponse(w, ClaudeConnectionStatus{IsConnected: false})
+		return
 	}
+
+	sendJSONResponse(w, ClaudeConnectionStatus{
+		IsConnected:         true,
+		ConnectedAt:         user.Claude.ConnectedAt,
+		LastUsedAt:          user.Claude.LastUsedAt,
+		KeyHealth:           user.Claude.KeyHealth,
+		LastValidatedAt:     user.Claude.LastValidatedAt,
+		LastValidationError: user.Claude.LastValidationError,
+	})
 }
 
 // HandleGetRecommendations generates AI trade recommendations
@@ -146,7 +207,7 @@ func (h *AIHandlers) HandleGetRecommendations(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
+	userID := auth.UserIDFromContext(r.Context())
 	user, err := h.userStore.GetUser(userID)
 	if err != nil {
 		sendJSONError(w, "User not found", http.StatusNotFound)
@@ -154,42 +215,52 @@ func (h *AIHandlers) HandleGetRecommendations(w http.ResponseWriter, r *http.Req
 	}
 
 	// Get and decrypt API key
-	encryptedKey, ok := user.Metadata["claude_api_key"].(string)
-	if !ok || encryptedKey == "" {
+	if !user.Claude.IsConnected() {
 		sendJSONError(w, "Claude not connected", http.StatusBadRequest)
 		return
 	}
 
-	apiKey, err := h.encryptor.Decrypt(encryptedKey)
+	if !h.checkHalt(w, userID) {
+		return
+	}
+
+	apiKey, err := h.openAPIKey(userID, user.Claude.EncryptedAPIKey, "recommendations")
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to decrypt API key")
 		sendJSONError(w, "Failed to access Claude connection", http.StatusInternalServerError)
 		return
 	}
+	defer apiKey.Destroy()
 
-	// Initialize AI assistant if not already done
-	if h.aiAssistant == nil {
-		h.aiAssistant = ai_assistant.NewTradingAssistant(apiKey)
-	}
+	assistant := h.assistants.Get(userID)
 
 	// Get user's portfolio data
-	portfolio, err := h.getUserPortfolio(userID)
+	portfolio, err := h.portfolios.Load(r.Context(), user)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get portfolio")
-		portfolio = make(map[string]interface{}) // Use empty portfolio if error
 	}
 
-	// Aggregate market data for top symbols
-	symbols := []string{"SPY", "QQQ", "AAPL", "MSFT", "NVDA", "TSLA", "AMD", "META"}
-	marketData, err := h.dataAggregator.AggregateDataForSymbols(context.Background(), symbols)
+	// Loading positions may have tripped the daily loss breaker
+	if !h.checkHalt(w, userID) {
+		return
+	}
+
+	// Aggregate market data for the user's watchlist, or the default top symbols
+	marketData, err := h.dataAggregator.AggregateDataForSymbols(r.Context(), userWatchlist(user))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to aggregate market data")
 		sendJSONError(w, "Failed to fetch market data", http.StatusInternalServerError)
 		return
 	}
 
+	// Archive the market data so the recommendations can be replayed later
+	snapshotID, err := h.snapshots.Save(marketData, snapshots.SourceRecommendation)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to save market snapshot")
+	}
+
 	// Get AI recommendations
-	recommendations, err := h.aiAssistant.AnalyzeTrades(context.Background(), marketData, portfolio)
+	recommendations, err := assistant.AnalyzeTrades(ai_assistant.WithAPIKey(r.Context(), apiKey.String()), marketData, portfolio)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get AI recommendations")
 		sendJSONError(w, "Failed to generate recommendations", http.StatusInternalServerError)
@@ -197,15 +268,43 @@ func (h *AIHandlers) HandleGetRecommendations(w http.ResponseWriter, r *http.Req
 	}
 
 	// Update last used timestamp
-	user.Metadata["claude_last_used_at"] = time.Now()
-	h.userStore.UpdateUser(user)
+	if _, err := userstore.Modify(h.userStore, userID, func(user *userstore.User) error {
+		now := time.Now()
+		user.Claude.LastUsedAt = &now
+		return nil
+	}); err != nil {
+		h.logger.WithError(err).Warn("Failed to record Claude last used time")
+	}
+
+	// Apply the server risk policy with the user's own limits layered on top
+	riskManager := h.riskManagerFor(user).WithMarketData(marketData)
+	approved, rejected := riskManager.Screen(recommendations, portfolio)
+
+	// Approved trades are recorded so their orders and outcomes can be
+	// tracked, then become proposals awaiting the user's decision
+	for i := range approved {
+		record, err := h.performance.RecordRecommendation(userID, approved[i], snapshotID)
+		if err != nil {
+			h.logger.WithError(err).Warn("Failed to record recommendation")
+			continue
+		}
+		approved[i].RecordID = record.ID
+	}
+	if _, err := h.proposals.Propose(userID, approved, portfolio, marketData, h.riskPolicy.Approval, riskManager.Policy().RequireManualApproval); err != nil {
+		h.logger.WithError(err).Error("Failed to record trade proposals")
+		sendJSONError(w, "Failed to record trade proposals", http.StatusInternalServerError)
+		return
+	}
 
 	response := TradeRecommendationsResponse{
-		Trades:    recommendations,
-		Timestamp: time.Now(),
+		Trades:     approved,
+		Rejected:   rejected,
+		Greeks:     riskManager.BasketGreeks(approved, portfolio),
+		SnapshotID: snapshotID,
+		Timestamp:  time.Now(),
 	}
 
-	if len(recommendations) < 5 {
+	if len(approved) < 5 {
 		response.Message = "Fewer than 5 trades meet the strict criteria. Market conditions may be unfavorable."
 	}
 
@@ -219,7 +318,7 @@ func (h *AIHandlers) HandleAnalyzeRisk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
+	userID := auth.UserIDFromContext(r.Context())
 	user, err := h.userStore.GetUser(userID)
 	if err != nil {
 		sendJSONError(w, "User not found", http.StatusNotFound)
@@ -227,34 +326,35 @@ func (h *AIHandlers) HandleAnalyzeRisk(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check Claude connection
-	encryptedKey, ok := user.Metadata["claude_api_key"].(string)
-	if !ok || encryptedKey == "" {
+	if !user.Claude.IsConnected() {
 		sendJSONError(w, "Claude not connected", http.StatusBadRequest)
 		return
 	}
 
-	apiKey, err := h.encryptor.Decrypt(encryptedKey)
+	apiKey, err := h.openAPIKey(userID, user.Claude.EncryptedAPIKey, "analyze_risk")
 	if err != nil {
+		h.logger.WithError(err).Error("Failed to decrypt API key")
 		sendJSONError(w, "Failed to access Claude connection", http.StatusInternalServerError)
 		return
 	}
+	defer apiKey.Destroy()
 
 	// Parse positions from request
-	var req struct {
-		Positions []map[string]interface{} `json:"positions"`
-	}
+	var req StressTestRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	// Initialize AI assistant if needed
-	if h.aiAssistant == nil {
-		h.aiAssistant = ai_assistant.NewTradingAssistant(apiKey)
+	if err := req.validate(); err != nil {
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	// Compute metrics and stress results for the model to explain
+	computed := h.computeRisk(r, user, &req)
+
 	// Get risk analysis from AI
-	analysis, err := h.aiAssistant.AnalyzeRisk(context.Background(), req.Positions)
+	analysis, err := h.assistants.Get(userID).AnalyzeRisk(ai_assistant.WithAPIKey(r.Context(), apiKey.String()), req.Positions, computed)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to analyze risk")
 		sendJSONError(w, "Failed to analyze risk", http.StatusInternalServerError)
@@ -263,6 +363,175 @@ func (h *AIHandlers) HandleAnalyzeRisk(w http.ResponseWriter, r *http.Request) {
 
 	sendJSONResponse(w, map[string]interface{}{
 		"analysis":  analysis,
+		"metrics":   computed.Metrics,
+		"stress":    computed.Stress,
+		"timestamp": time.Now(),
+	})
+}
+
+// StressTestRequest lists held positions, optional proposed trades as
+// positions, and optional grid and repricing overrides
+type StressTestRequest struct {
+	Positions []map[string]interface{}     `json:"positions"`
+	Proposed  []map[string]interface{}     `json:"proposed,omitempty"`
+	Grid      *ai_assistant.StressGrid     `json:"grid,omitempty"`
+	Repricing ai_assistant.RepricingMethod `json:"repricing,omitempty"`
+}
+
+func (req *StressTestRequest) validate() error {
+	if len(req.Positions)+len(req.Proposed) == 0 {
+		return errors.New("At least one position is required")
+	}
+	if req.Repricing != "" && req.Repricing != ai_assistant.RepriceFull && req.Repricing != ai_assistant.RepriceGreeks {
+		return errors.New(`repricing must be "full" or "greeks"`)
+	}
+	if grid := req.Grid; grid != nil {
+		if len(grid.SpotShocks)*max(len(grid.VolShocks), 1)*max(len(grid.DecayDays), 1) > 500 {
+			return errors.New("grid may have at most 500 scenarios")
+		}
+		for _, spot := range grid.SpotShocks {
+			if spot <= -100 {
+				return errors.New("spotShocks must be greater than -100%")
+			}
+		}
+		for _, days := range grid.DecayDays {
+			if days < 0 {
+				return errors.New("decayDays must not be negative")
+			}
+		}
+	}
+	return nil
+}
+
+// HandleStressTest revalues positions and proposed trades under the stress
+// grid and historical replays
+func (h *AIHandlers) HandleStressTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req StressTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userStore.GetUser(auth.UserIDFromContext(r.Context()))
+	if err != nil {
+		user = &userstore.User{}
+	}
+
+	computed := h.computeRisk(r, user, &req)
+	sendJSONResponse(w, map[string]interface{}{
+		"stress":    computed.Stress,
+		"timestamp": time.Now(),
+	})
+}
+
+// computeRisk fetches history for the positions' underlyings and runs the
+// VaR and stress engines over them
+func (h *AIHandlers) computeRisk(r *http.Request, user *userstore.User, req *StressTestRequest) *ai_assistant.RiskAnalysisInput {
+	current, problems := ai_assistant.PositionsFromMaps(req.Positions)
+	proposed, proposedProblems := ai_assistant.PositionsFromMaps(req.Proposed)
+
+	symbols := []string{"SPY"} // Benchmark for beta-scaled historical replays
+	seen := map[string]bool{"SPY": true}
+	for _, position := range append(append([]*ai_assistant.Position(nil), current...), proposed...) {
+		if !seen[position.Underlying] {
+			seen[position.Underlying] = true
+			symbols = append(symbols, position.Underlying)
+		}
+	}
+	history := h.dataAggregator.FetchHistory(r.Context(), symbols)
+
+	engine := ai_assistant.NewStressEngine()
+	if req.Grid != nil {
+		engine.Grid = *req.Grid
+		if len(engine.Grid.VolShocks) == 0 {
+			engine.Grid.VolShocks = []float64{0}
+		}
+		if len(engine.Grid.DecayDays) == 0 {
+			engine.Grid.DecayDays = []float64{0}
+		}
+	}
+	if req.Repricing != "" {
+		engine.Repricing = req.Repricing
+	}
+	stress := engine.Run(current, proposed, history)
+	stress.Warnings = append(append(problems, proposedProblems...), stress.Warnings...)
+
+	var metrics *ai_assistant.PortfolioRiskMetrics
+	if len(req.Positions) > 0 {
+		metrics = h.riskManagerFor(user).CalculatePortfolioMetrics(req.Positions, history, ai_assistant.VaRConfig{
+			Repricing: req.Repricing,
+		})
+	}
+
+	return &ai_assistant.RiskAnalysisInput{Metrics: metrics, Stress: stress}
+}
+
+// PortfolioMetricsRequest lists positions and, optionally, VaR settings
+type PortfolioMetricsRequest struct {
+	Positions        []map[string]interface{}     `json:"positions"`
+	ConfidenceLevels []float64                    `json:"confidenceLevels,omitempty"`
+	HorizonDays      []int                        `json:"horizonDays,omitempty"`
+	Simulations      int                          `json:"simulations,omitempty"`
+	Repricing        ai_assistant.RepricingMethod `json:"repricing,omitempty"` // "full" or "greeks"
+}
+
+// HandlePortfolioMetrics computes Greeks, concentrations and historical and
+// Monte Carlo VaR for the given positions
+func (h *AIHandlers) HandlePortfolioMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req PortfolioMetricsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Positions) == 0 {
+		sendJSONError(w, "At least one position is required", http.StatusBadRequest)
+		return
+	}
+	config := ai_assistant.VaRConfig{
+		ConfidenceLevels: req.ConfidenceLevels,
+		HorizonDays:      req.HorizonDays,
+		Simulations:      req.Simulations,
+		Repricing:        req.Repricing,
+	}
+	if err := config.Validate(); err != nil {
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	positions, _ := ai_assistant.PositionsFromMaps(req.Positions)
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, position := range positions {
+		if !seen[position.Underlying] {
+			seen[position.Underlying] = true
+			symbols = append(symbols, position.Underlying)
+		}
+	}
+	history := h.dataAggregator.FetchHistory(r.Context(), symbols)
+
+	user, err := h.userStore.GetUser(auth.UserIDFromContext(r.Context()))
+	if err != nil {
+		user = &userstore.User{}
+	}
+
+	metrics := h.riskManagerFor(user).CalculatePortfolioMetrics(req.Positions, history, config)
+
+	sendJSONResponse(w, map[string]interface{}{
+		"metrics":   metrics,
 		"timestamp": time.Now(),
 	})
 }
@@ -274,26 +543,25 @@ func (h *AIHandlers) HandleClaudeDisconnect(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
-	user, err := h.userStore.GetUser(userID)
-	if err != nil {
+	userID := auth.UserIDFromContext(r.Context())
+
+	// Remove Claude connection data
+	_, err := userstore.Modify(h.userStore, userID, func(user *userstore.User) error {
+		user.Claude = userstore.ClaudeConnection{}
+		return nil
+	})
+	if errors.Is(err, userstore.ErrUserNotFound) {
 		sendJSONError(w, "User not found", http.StatusNotFound)
 		return
 	}
-
-	// Remove Claude connection data
-	delete(user.Metadata, "claude_api_key")
-	delete(user.Metadata, "claude_connected_at")
-	delete(user.Metadata, "claude_last_used_at")
-
-	if err := h.userStore.UpdateUser(user); err != nil {
+	if err != nil {
 		h.logger.WithError(err).Error("Failed to update user")
 		sendJSONError(w, "Failed to disconnect", http.StatusInternalServerError)
 		return
 	}
 
-	// Clear AI assistant
-	h.aiAssistant = nil
+	// Clear this user's AI assistant
+	h.assistants.Invalidate(userID)
 
 	sendJSONResponse(w, map[string]interface{}{
 		"success": true,
@@ -301,20 +569,147 @@ func (h *AIHandlers) HandleClaudeDisconnect(w http.ResponseWriter, r *http.Reque
 	})
 }
 
-func (h *AIHandlers) getUserPortfolio(userID string) (map[string]interface{}, error) {
-	// This would fetch real portfolio data from your database or broker connection
-	// For now, return mock data
-	return map[string]interface{}{
-		"cash_balance": 100000,
-		"positions": []map[string]interface{}{
-			{
-				"symbol":   "SPY",
-				"quantity": 100,
-				"cost_basis": 450.00,
-			},
-		},
-		"options": []map[string]interface{}{},
-	}, nil
+// UserSettings is the editable part of a user's profile
+type UserSettings struct {
+	RiskLimits  *userstore.RiskLimits `json:"riskLimits,omitempty"`
+	Preferences userstore.Preferences `json:"preferences"`
+}
+
+// HandleSettings returns (GET) or replaces (PUT) the user's risk limits and preferences
+func (h *AIHandlers) HandleSettings(w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserIDFromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		user, err := h.userStore.GetUser(userID)
+		if errors.Is(err, userstore.ErrUserNotFound) {
+			sendJSONResponse(w, UserSettings{})
+			return
+		}
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to load user")
+			sendJSONError(w, "Failed to load settings", http.StatusInternalServerError)
+			return
+		}
+		sendJSONResponse(w, UserSettings{RiskLimits: user.RiskLimits, Preferences: user.Preferences})
+
+	case http.MethodPut:
+		var req UserSettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendJSONError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := validateSettings(&req); err != nil {
+			sendJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := userstore.GetOrCreate(h.userStore, userID); err != nil {
+			h.logger.WithError(err).Error("Failed to load user")
+			sendJSONError(w, "Failed to save settings", http.StatusInternalServerError)
+			return
+		}
+		user, err := userstore.Modify(h.userStore, userID, func(user *userstore.User) error {
+			user.RiskLimits = req.RiskLimits
+			user.Preferences = req.Preferences
+			return nil
+		})
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to update user")
+			sendJSONError(w, "Failed to save settings", http.StatusInternalServerError)
+			return
+		}
+		sendJSONResponse(w, UserSettings{RiskLimits: user.RiskLimits, Preferences: user.Preferences})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func validateSettings(settings *UserSettings) error {
+	if limits := settings.RiskLimits; limits != nil {
+		for name, v := range map[string]float64{
+			"max_portfolio_risk": limits.MaxPortfolioRisk,
+			"max_position_size":  limits.MaxPositionSize,
+			"max_daily_loss":     limits.MaxDailyLoss,
+			"max_concentration":  limits.MaxConcentration,
+		} {
+			if v < 0 || v > 100 {
+				return fmt.Errorf("%s must be a percentage between 0 and 100", name)
+			}
+		}
+		if limits.MinPOP < 0 || limits.MinPOP > 1 {
+			return fmt.Errorf("min_pop must be between 0 and 1")
+		}
+	}
+
+	if len(settings.Preferences.Watchlist) > 50 {
+		return fmt.Errorf("watchlist may contain at most 50 symbols")
+	}
+	for i, symbol := range settings.Preferences.Watchlist {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" || len(symbol) > 10 {
+			return fmt.Errorf("invalid watchlist symbol %q", settings.Preferences.Watchlist[i])
+		}
+		settings.Preferences.Watchlist[i] = symbol
+	}
+
+	if tz := settings.Preferences.Timezone; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("invalid timezone %q", tz)
+		}
+	}
+
+	return nil
+}
+
+// HandleCircuitBreakerStatus reports the kill switch and the user's daily loss breaker
+func (h *AIHandlers) HandleCircuitBreakerStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sendJSONResponse(w, h.breaker.Status(auth.UserIDFromContext(r.Context())))
+}
+
+// riskManagerFor builds a risk manager from the server policy and the user's limits
+func (h *AIHandlers) riskManagerFor(user *userstore.User) *ai_assistant.RiskManager {
+	return ai_assistant.NewRiskManagerWithPolicy(userRiskPolicy(h.riskPolicy, user))
+}
+
+// checkHalt writes a 423 response and returns false if the circuit breaker
+// or kill switch blocks AI trading for userID
+func (h *AIHandlers) checkHalt(w http.ResponseWriter, userID string) bool {
+	var herr *ai_assistant.HaltError
+	if err := h.breaker.Check(userID); errors.As(err, &herr) {
+		sendJSONErrorCode(w, herr.Message, herr.Reason, http.StatusLocked)
+		return false
+	}
+	return true
+}
+
+// claudeKeyRecordID identifies a user's Claude key in the vault; it is bound
+// into the ciphertext so a sealed key cannot be replayed onto another user
+func claudeKeyRecordID(userID string) string {
+	return "user/" + userID + "/claude_api_key"
+}
+
+// openAPIKey decrypts the user's stored Claude key, auditing the access.
+// Callers must Destroy the returned secret when done.
+func (h *AIHandlers) openAPIKey(userID, encryptedKey, purpose string) (*vault.Secret, error) {
+	return h.vault.Open(claudeKeyRecordID(userID), encryptedKey, vault.AccessInfo{
+		Actor:   userID,
+		Purpose: purpose,
+	})
+}
+
+// recordKeyValidation stores the outcome of a key probe on the user
+func recordKeyValidation(user *userstore.User, validation *ai_assistant.KeyValidation) {
+	checkedAt := validation.CheckedAt
+	user.Claude.KeyHealth = string(validation.Health)
+	user.Claude.LastValidatedAt = &checkedAt
+	user.Claude.LastValidationError = validation.Error
 }
 
 func sendJSONResponse(w http.ResponseWriter, data interface{}) {
@@ -326,4 +721,11 @@ func sendJSONError(w http.ResponseWriter, message string, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
-}
\ No newline at end of file
+}
+
+// sendJSONErrorCode is sendJSONError with a machine-readable code for the frontend
+func sendJSONErrorCode(w http.ResponseWriter, message, code string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "code": code})
+}
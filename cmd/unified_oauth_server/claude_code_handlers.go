@@ -2,13 +2,13 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/auth"
 )
 
 // ClaudeCodeHandlers handles Claude Code related endpoints
@@ -33,32 +33,16 @@ func NewClaudeCodeHandlers(logger *logrus.Logger, serviceURL string) *ClaudeCode
 	}
 }
 
-// RegisterRoutes registers all Claude Code routes
-func (h *ClaudeCodeHandlers) RegisterRoutes(mux *http.ServeMux) {
+// RegisterRoutes registers all Claude Code routes behind the server's auth middleware
+func (h *ClaudeCodeHandlers) RegisterRoutes(mux *http.ServeMux, authenticate func(http.HandlerFunc) http.HandlerFunc) {
 	// Chat endpoint for simple HTTP-based chat
-	mux.HandleFunc("/api/claude-code/chat", h.authMiddleware(h.handleChat))
+	mux.HandleFunc("/api/claude-code/chat", authenticate(h.handleChat))
 	
-	// Status endpoint
-	mux.HandleFunc("/api/claude-code/status", h.authMiddleware(h.handleStatus))
+	// Service status endpoint (/api/claude-code/status reports the user's API key connection)
+	mux.HandleFunc("/api/claude-code/service-status", authenticate(h.handleStatus))
 	
 	// WebSocket proxy (future implementation)
-	mux.HandleFunc("/api/claude-code/ws", h.authMiddleware(h.handleWebSocket))
-}
-
-// authMiddleware validates authentication
-func (h *ClaudeCodeHandlers) authMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// For now, just check if user is authenticated via cookie
-		// In production, implement proper auth check
-		userID := r.Header.Get("X-User-ID")
-		if userID == "" {
-			// Try to get from cookie or session
-			userID = "default-user" // Placeholder
-		}
-		
-		ctx := context.WithValue(r.Context(), "userID", userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	}
+	mux.HandleFunc("/api/claude-code/ws", authenticate(h.handleWebSocket))
 }
 
 // ChatRequest represents a chat message request
@@ -86,7 +70,7 @@ func (h *ClaudeCodeHandlers) handleChat(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
+	userID := auth.UserIDFromContext(r.Context())
 	
 	// Forward request to Claude Code service
 	serviceReq := map[string]interface{}{
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/execution"
+	"vibetrade-claude/internal/execution/paper"
+	"vibetrade-claude/internal/turnstile"
+)
+
+// Config holds the server settings read from the environment
+type Config struct {
+	Port                  string
+	DataDir               string
+	UserStoreBackend      string
+	UserStorePath         string
+	EncryptionKey         []byte // pre-vault key, only used to read legacy values
+	EncryptionKeyFile     string
+	VaultMasterKeys       string
+	VaultMasterKeyFile    string
+	VaultAuditLog         string
+	VaultRotationInterval time.Duration
+	ClaudeCodeServiceURL  string
+	VibeTradeAPIURL       string
+	AssistantCacheSize    int
+	JWTIssuer             string
+	JWTAudience           string
+	JWTSecret             []byte
+	JWKSSource            string
+	JWKSRefreshInterval   time.Duration
+	TurnstileSecret       string
+	TurnstileDisabled     bool
+	TurnstileVerifyURL    string
+	TurnstileHostnames    []string
+	TurnstileAction       string
+	TrustedProxies        []*net.IPNet // Whose CF-Connecting-IP header is believed
+	KeyRevalidateInterval time.Duration
+	RiskPolicyFile        string
+	SectorFile            string
+	EventCalendarFiles    []string
+	CircuitBreakerFile    string
+	ProposalsFile         string
+	ProposalAuditLog      string
+	ExecutionBroker       string
+	AlpacaTradingURL      string
+	OrdersFile            string
+	OrderPollInterval     time.Duration
+	OrderRepriceAfter     time.Duration
+	PaperStateFile        string
+	PaperStartingCash     float64
+	PaperSlippage         float64
+	PaperCommission       float64
+	SnapshotDir           string
+	SnapshotRetention     time.Duration // 0 keeps snapshots forever
+	SnapshotWatchlist     []string      // Captured at each close, with every user's watchlist
+	PnLPollInterval       time.Duration
+	ReconcileInterval     time.Duration
+	PerformanceDBPath     string
+	MarksDir              string
+	MarkInterval          time.Duration
+	ShutdownTimeout       time.Duration
+	LogLevel              string
+}
+
+// LoadConfig reads configuration from environment variables, applying defaults
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		Port:                  getEnv("PORT", "8090"),
+		DataDir:               getEnv("DATA_DIR", "./data"),
+		ClaudeCodeServiceURL:  getEnv("CLAUDE_CODE_SERVICE_URL", "http://localhost:3001"),
+		VibeTradeAPIURL:       os.Getenv("VIBETRADE_API_URL"),
+		ShutdownTimeout:       10 * time.Second,
+		LogLevel:              getEnv("LOG_LEVEL", "info"),
+		AssistantCacheSize:    256,
+		JWTIssuer:             os.Getenv("JWT_ISSUER"),
+		JWTAudience:           os.Getenv("JWT_AUDIENCE"),
+		JWTSecret:             []byte(os.Getenv("JWT_HS256_SECRET")),
+		JWKSSource:            getEnv("JWT_JWKS_URL", os.Getenv("JWT_JWKS_FILE")),
+		JWKSRefreshInterval:   time.Hour,
+		TurnstileSecret:       os.Getenv("TURNSTILE_SECRET_KEY"),
+		TurnstileVerifyURL:    os.Getenv("TURNSTILE_VERIFY_URL"),
+		TurnstileAction:       os.Getenv("TURNSTILE_ACTION"),
+		KeyRevalidateInterval: 24 * time.Hour,
+		RiskPolicyFile:        os.Getenv("RISK_POLICY_FILE"),
+		SectorFile:            os.Getenv("SECTOR_FILE"),
+	}
+
+	if disabled := os.Getenv("TURNSTILE_DISABLED"); disabled != "" {
+		v, err := strconv.ParseBool(disabled)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TURNSTILE_DISABLED: %q", disabled)
+		}
+		cfg.TurnstileDisabled = v
+	}
+
+	if cfg.TurnstileSecret == "" && !cfg.TurnstileDisabled {
+		return nil, fmt.Errorf("TURNSTILE_SECRET_KEY must be set (use %s for local development, or TURNSTILE_DISABLED=true)", turnstile.TestSecretPass)
+	}
+
+	for _, file := range strings.Split(os.Getenv("EVENT_CALENDAR"), ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			cfg.EventCalendarFiles = append(cfg.EventCalendarFiles, file)
+		}
+	}
+
+	for _, host := range strings.Split(os.Getenv("TURNSTILE_HOSTNAMES"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			cfg.TurnstileHostnames = append(cfg.TurnstileHostnames, host)
+		}
+	}
+
+	proxies, err := turnstile.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, err
+	}
+	cfg.TrustedProxies = proxies
+
+	if len(cfg.JWTSecret) == 0 && cfg.JWKSSource == "" {
+		return nil, fmt.Errorf("JWT_HS256_SECRET, JWT_JWKS_URL or JWT_JWKS_FILE must be set")
+	}
+
+	if err := durationEnv("JWT_JWKS_REFRESH", &cfg.JWKSRefreshInterval); err != nil {
+		return nil, err
+	}
+
+	cfg.UserStoreBackend = getEnv("USER_STORE", "bolt")
+	cfg.UserStorePath = getEnv("USER_STORE_PATH", filepath.Join(cfg.DataDir, "users.db"))
+	cfg.EncryptionKeyFile = getEnv("ENCRYPTION_KEY_FILE", filepath.Join(cfg.DataDir, "encryption.key"))
+	cfg.VaultMasterKeys = os.Getenv("VAULT_MASTER_KEYS")
+	cfg.VaultMasterKeyFile = getEnv("VAULT_MASTER_KEY_FILE", filepath.Join(cfg.DataDir, "master.keys"))
+	cfg.VaultAuditLog = getEnv("VAULT_AUDIT_LOG", filepath.Join(cfg.DataDir, "vault_audit.log"))
+	cfg.VaultRotationInterval = time.Hour
+	cfg.CircuitBreakerFile = getEnv("CIRCUIT_BREAKER_STATE", filepath.Join(cfg.DataDir, "circuit_breaker.json"))
+	cfg.ProposalsFile = getEnv("PROPOSALS_FILE", filepath.Join(cfg.DataDir, "proposals.json"))
+	cfg.ProposalAuditLog = getEnv("PROPOSAL_AUDIT_LOG", filepath.Join(cfg.DataDir, "proposal_audit.log"))
+	cfg.ExecutionBroker = strings.ToLower(os.Getenv("EXECUTION_BROKER"))
+	cfg.AlpacaTradingURL = getEnv("ALPACA_TRADING_URL", execution.AlpacaPaperURL)
+	cfg.OrdersFile = getEnv("ORDERS_FILE", filepath.Join(cfg.DataDir, "orders.json"))
+	cfg.OrderPollInterval = 5 * time.Second
+	cfg.OrderRepriceAfter = execution.DefaultConfig().RepriceAfter
+	cfg.PaperStateFile = getEnv("PAPER_STATE_FILE", filepath.Join(cfg.DataDir, "paper_account.json"))
+	paperDefaults := paper.DefaultConfig()
+	cfg.PaperStartingCash = paperDefaults.StartingCash
+	cfg.PaperSlippage = paperDefaults.Slippage
+	cfg.PaperCommission = paperDefaults.Commission
+	cfg.SnapshotDir = getEnv("SNAPSHOT_DIR", filepath.Join(cfg.DataDir, "snapshots"))
+	cfg.SnapshotRetention = 365 * 24 * time.Hour
+	cfg.PnLPollInterval = time.Minute
+	cfg.ReconcileInterval = 5 * time.Minute
+	cfg.PerformanceDBPath = getEnv("PERFORMANCE_DB_PATH", filepath.Join(cfg.DataDir, "performance.db"))
+	cfg.MarksDir = getEnv("MARKS_DIR", filepath.Join(cfg.DataDir, "marks"))
+	cfg.MarkInterval = 15 * time.Minute
+
+	switch cfg.ExecutionBroker {
+	case "", "alpaca", "paper":
+	default:
+		return nil, fmt.Errorf("unknown EXECUTION_BROKER %q", cfg.ExecutionBroker)
+	}
+
+	if err := durationEnv("ORDER_POLL_INTERVAL", &cfg.OrderPollInterval); err != nil {
+		return nil, err
+	}
+
+	if err := durationEnv("ORDER_REPRICE_AFTER", &cfg.OrderRepriceAfter); err != nil {
+		return nil, err
+	}
+
+	for key, value := range map[string]*float64{
+		"PAPER_STARTING_CASH": &cfg.PaperStartingCash,
+		"PAPER_SLIPPAGE":      &cfg.PaperSlippage,
+		"PAPER_COMMISSION":    &cfg.PaperCommission,
+	} {
+		if raw := os.Getenv(key); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("invalid %s: %q", key, raw)
+			}
+			*value = v
+		}
+	}
+
+	if days := os.Getenv("SNAPSHOT_RETENTION_DAYS"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid SNAPSHOT_RETENTION_DAYS: %q", days)
+		}
+		cfg.SnapshotRetention = time.Duration(n) * 24 * time.Hour
+	}
+
+	for _, symbol := range strings.Split(os.Getenv("SNAPSHOT_WATCHLIST"), ",") {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			cfg.SnapshotWatchlist = append(cfg.SnapshotWatchlist, symbol)
+		}
+	}
+
+	if err := durationEnv("PNL_POLL_INTERVAL", &cfg.PnLPollInterval); err != nil {
+		return nil, err
+	}
+
+	if err := durationEnv("RECONCILE_INTERVAL", &cfg.ReconcileInterval); err != nil {
+		return nil, err
+	}
+
+	if err := durationEnv("MARK_INTERVAL", &cfg.MarkInterval); err != nil {
+		return nil, err
+	}
+
+	if err := durationEnv("VAULT_ROTATION_INTERVAL", &cfg.VaultRotationInterval); err != nil {
+		return nil, err
+	}
+
+	if keyHex := strings.TrimSpace(os.Getenv("ENCRYPTION_KEY")); keyHex != "" {
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil, fmt.Errorf("ENCRYPTION_KEY must be hex encoded: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("ENCRYPTION_KEY must be 32 bytes, got %d", len(key))
+		}
+		cfg.EncryptionKey = key
+	}
+
+	if size := os.Getenv("AI_ASSISTANT_CACHE_SIZE"); size != "" {
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid AI_ASSISTANT_CACHE_SIZE: %q", size)
+		}
+		cfg.AssistantCacheSize = n
+	}
+
+	if err := durationEnv("CLAUDE_KEY_REVALIDATE_INTERVAL", &cfg.KeyRevalidateInterval); err != nil {
+		return nil, err
+	}
+
+	if err := durationEnv("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// durationEnv overwrites dst with the duration in key, leaving the default in
+// place when the variable is unset
+func durationEnv(key string, dst *time.Duration) error {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
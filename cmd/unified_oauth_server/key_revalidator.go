@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/userstore"
+	"vibetrade-claude/internal/vault"
+)
+
+// KeyRevalidator periodically re-probes every stored Claude API key so that
+// keys revoked in the Anthropic console are flagged before users hit errors
+type KeyRevalidator struct {
+	userStore  userstore.UserStore
+	vault      *vault.Vault
+	assistants *ai_assistant.AssistantRegistry
+	interval   time.Duration
+	logger     *logrus.Logger
+	probe      func(ctx context.Context, apiKey string) (*ai_assistant.KeyValidation, error)
+}
+
+func NewKeyRevalidator(userStore userstore.UserStore, credentials *vault.Vault, assistants *ai_assistant.AssistantRegistry, interval time.Duration, logger *logrus.Logger) *KeyRevalidator {
+	return &KeyRevalidator{
+		userStore:  userStore,
+		vault:      credentials,
+		assistants: assistants,
+		interval:   interval,
+		logger:     logger,
+		probe: func(ctx context.Context, apiKey string) (*ai_assistant.KeyValidation, error) {
+			return ai_assistant.NewClaudeClient(apiKey).ValidateKey(ctx)
+		},
+	}
+}
+
+// Start runs RevalidateAll every interval until ctx is cancelled
+func (kr *KeyRevalidator) Start(ctx context.Context) {
+	if kr.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(kr.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				kr.RevalidateAll(ctx)
+			}
+		}
+	}()
+}
+
+// RevalidateAll probes each connected user's key and records the result
+func (kr *KeyRevalidator) RevalidateAll(ctx context.Context) {
+	users, err := kr.userStore.ListUsers()
+	if err != nil {
+		kr.logger.WithError(err).Error("Failed to list users for key revalidation")
+		return
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+
+		encryptedKey := user.Claude.EncryptedAPIKey
+		if encryptedKey == "" {
+			continue
+		}
+
+		log := kr.logger.WithField("user_id", user.ID)
+
+		apiKey, err := kr.vault.Open(claudeKeyRecordID(user.ID), encryptedKey, vault.AccessInfo{
+			Actor:   "system",
+			Purpose: "key_revalidation",
+		})
+		if err != nil {
+			log.WithError(err).Error("Failed to decrypt API key for revalidation")
+			continue
+		}
+
+		validation, err := kr.probe(ctx, apiKey.String())
+		apiKey.Destroy()
+		if err != nil {
+			// Inconclusive probe; keep the previous verdict
+			log.WithError(err).Warn("Failed to revalidate Claude API key")
+			continue
+		}
+
+		if validation.Health == ai_assistant.KeyHealthInvalid && user.Claude.KeyHealth != string(ai_assistant.KeyHealthInvalid) {
+			validation.Health = ai_assistant.KeyHealthRevoked
+		}
+		if validation.Health == ai_assistant.KeyHealthInvalid || validation.Health == ai_assistant.KeyHealthRevoked {
+			kr.assistants.Invalidate(user.ID)
+			log.WithField("health", validation.Health).Warn("Stored Claude API key is no longer valid")
+		}
+
+		_, err = userstore.Modify(kr.userStore, user.ID, func(current *userstore.User) error {
+			// Skip if the user replaced or removed the key while we were probing
+			if current.Claude.EncryptedAPIKey != encryptedKey {
+				return errKeyChanged
+			}
+			recordKeyValidation(current, validation)
+			return nil
+		})
+		if err != nil && !errors.Is(err, errKeyChanged) {
+			log.WithError(err).Error("Failed to save key validation result")
+		}
+	}
+}
+
+// errKeyChanged aborts a background update whose key was replaced concurrently
+var errKeyChanged = errors.New("stored key changed")
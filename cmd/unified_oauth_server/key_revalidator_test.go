@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/userstore"
+	"vibetrade-claude/internal/vault"
+)
+
+type discardAudit struct{}
+
+func (discardAudit) Record(vault.AuditEvent) error { return nil }
+
+func TestRevalidateAllRecordsEachKeysHealth(t *testing.T) {
+	keyring, err := vault.ParseKeyring("k1:" + "1111111111111111111111111111111111111111111111111111111111111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := vault.New(keyring, discardAudit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := userstore.NewMemoryUserStore()
+	addUser := func(id, apiKey, health string) {
+		user := &userstore.User{ID: id}
+		if apiKey != "" {
+			sealed, err := credentials.Seal(claudeKeyRecordID(id), []byte(apiKey))
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.Claude.EncryptedAPIKey = sealed
+			user.Claude.KeyHealth = health
+		}
+		if err := store.CreateUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addUser("revoked", "sk-revoked", string(ai_assistant.KeyHealthValid))
+	addUser("still-invalid", "sk-invalid", string(ai_assistant.KeyHealthInvalid))
+	addUser("healthy", "sk-healthy", string(ai_assistant.KeyHealthValid))
+	addUser("unreachable", "sk-unreachable", string(ai_assistant.KeyHealthNoQuota))
+	addUser("disconnected", "", "")
+
+	assistants := ai_assistant.NewAssistantRegistry(8)
+	for _, id := range []string{"revoked", "still-invalid", "healthy", "unreachable"} {
+		assistants.Get(id)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	kr := NewKeyRevalidator(store, credentials, assistants, 0, logger)
+
+	probed := map[string]bool{}
+	kr.probe = func(ctx context.Context, apiKey string) (*ai_assistant.KeyValidation, error) {
+		probed[apiKey] = true
+		switch apiKey {
+		case "sk-revoked", "sk-invalid":
+			return &ai_assistant.KeyValidation{Health: ai_assistant.KeyHealthInvalid, Error: "invalid x-api-key"}, nil
+		case "sk-healthy":
+			return &ai_assistant.KeyValidation{Health: ai_assistant.KeyHealthValid}, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	kr.RevalidateAll(context.Background())
+
+	if len(probed) != 4 {
+		t.Errorf("probed %v, want every stored key and nothing else", probed)
+	}
+	want := map[string]ai_assistant.KeyHealth{
+		"revoked":       ai_assistant.KeyHealthRevoked, // valid before, rejected now
+		"still-invalid": ai_assistant.KeyHealthInvalid,
+		"healthy":       ai_assistant.KeyHealthValid,
+		"unreachable":   ai_assistant.KeyHealthNoQuota, // inconclusive probes keep the last verdict
+	}
+	for id, health := range want {
+		user, err := store.GetUser(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Claude.KeyHealth != string(health) {
+			t.Errorf("%s: key health = %q, want %q", id, user.Claude.KeyHealth, health)
+		}
+		if checked := user.Claude.LastValidatedAt != nil; checked == (id == "unreachable") {
+			t.Errorf("%s: last validated at %v", id, user.Claude.LastValidatedAt)
+		}
+	}
+
+	// Rejected keys drop their cached assistants
+	if assistants.Len() != 2 {
+		t.Errorf("%d cached assistants, want the healthy and unreachable users'", assistants.Len())
+	}
+}
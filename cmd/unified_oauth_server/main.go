@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/auth"
+	"vibetrade-claude/internal/events"
+	"vibetrade-claude/internal/execution"
+	"vibetrade-claude/internal/execution/paper"
+	"vibetrade-claude/internal/snapshots"
+	"vibetrade-claude/internal/turnstile"
+	"vibetrade-claude/internal/userstore"
+	"vibetrade-claude/internal/vault"
+)
+
+// Server wires configuration, storage and HTTP routes together
+type Server struct {
+	config         *Config
+	logger         *logrus.Logger
+	userStore      userstore.UserStore
+	vault          *vault.Vault
+	dataAggregator *ai_assistant.MarketDataAggregator
+	assistants     *ai_assistant.AssistantRegistry
+	riskPolicy     *ai_assistant.RiskPolicy
+	breaker        *ai_assistant.CircuitBreaker
+	proposals      *ai_assistant.ProposalBook
+	records        ai_assistant.RecordStore
+	performance    *ai_assistant.PerformanceTracker
+	marks          *ai_assistant.MarkStore
+	snapshots      *snapshots.Store
+	collector      *snapshots.Collector
+	orders         *execution.Manager // Nil unless EXECUTION_BROKER is set
+	paper          *paper.Broker      // Nil unless EXECUTION_BROKER is paper
+	portfolios     *PortfolioService
+	pnlMonitor     *PnLMonitor
+	reconciler     *TradeReconciler // Nil without a paper broker or VibeTrade backend
+	bookMonitor    *BookMonitor
+	keySet         *auth.KeySet
+	authenticator  *auth.Authenticator
+	turnstile      *turnstile.Verifier
+	keyRevalidator *KeyRevalidator
+	vaultRotator   *VaultRotator
+	httpServer     *http.Server
+}
+
+// NewServer builds a server and its dependencies from config
+func NewServer(cfg *Config, logger *logrus.Logger) (*Server, error) {
+	userStore, err := openUserStore(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := openVault(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var keySet *auth.KeySet
+	if cfg.JWKSSource != "" {
+		keySet, err = auth.NewKeySet(cfg.JWKSSource, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	validator, err := auth.NewValidator(auth.ValidatorConfig{
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		HMACSecret: cfg.JWTSecret,
+		KeySet:     keySet,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := turnstile.NewVerifier(turnstile.Config{
+		Secret:         cfg.TurnstileSecret,
+		Disabled:       cfg.TurnstileDisabled,
+		VerifyURL:      cfg.TurnstileVerifyURL,
+		Hostnames:      cfg.TurnstileHostnames,
+		Action:         cfg.TurnstileAction,
+		TrustedProxies: cfg.TrustedProxies,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TurnstileDisabled {
+		logger.Warn("Turnstile verification is disabled; do not run this configuration in production")
+	}
+
+	riskPolicy := ai_assistant.DefaultRiskPolicy()
+	if cfg.RiskPolicyFile != "" {
+		riskPolicy, err = ai_assistant.LoadRiskPolicy(cfg.RiskPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("Loaded risk policy from %s", cfg.RiskPolicyFile)
+	}
+	if cfg.SectorFile != "" {
+		riskPolicy.Classification, err = ai_assistant.LoadSectorFile(cfg.SectorFile)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("Loaded %d sector classifications from %s", riskPolicy.Classification.Len(), cfg.SectorFile)
+	}
+
+	dataAggregator := ai_assistant.NewMarketDataAggregator(alpaca.NewClient(alpaca.ClientOpts{}))
+	if len(cfg.EventCalendarFiles) > 0 {
+		calendar, err := events.NewFileProvider(cfg.EventCalendarFiles...)
+		if err != nil {
+			return nil, err
+		}
+		dataAggregator.SetEventProvider(calendar)
+		logger.Infof("Loaded event calendar from %s", strings.Join(cfg.EventCalendarFiles, ", "))
+	}
+
+	breaker, err := ai_assistant.NewCircuitBreaker(cfg.CircuitBreakerFile)
+	if err != nil {
+		return nil, err
+	}
+	// A realized loss reported before the user's portfolio is loaded is
+	// measured against the user's loss limit and the assumed account value
+	breaker.SetSessionDefaults(func(userID string) (float64, float64) {
+		limits := riskPolicy.Limits
+		if user, err := userStore.GetUser(userID); err == nil {
+			limits = userRiskPolicy(riskPolicy, user).Limits
+		}
+		return defaultAccountValue, limits.MaxDailyLoss
+	})
+	if ks := breaker.KillSwitch(); ks.Engaged {
+		logger.Warnf("AI trading kill switch is engaged (by %s: %s)", ks.ChangedBy, ks.Reason)
+	}
+
+	proposalAudit, err := ai_assistant.NewFileProposalAuditLog(cfg.ProposalAuditLog)
+	if err != nil {
+		return nil, err
+	}
+	proposals, err := ai_assistant.NewProposalBook(cfg.ProposalsFile, proposalAudit, dataAggregator)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := openRecordStore(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	performance := ai_assistant.NewPerformanceTracker(records)
+
+	marks, err := ai_assistant.NewMarkStore(cfg.MarksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotStore, err := snapshots.NewStore(cfg.SnapshotDir, cfg.SnapshotRetention, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var router execution.OrderRouter
+	var paperBroker *paper.Broker
+	switch cfg.ExecutionBroker {
+	case "alpaca":
+		router = execution.NewAlpacaRouter(execution.AlpacaConfig{BaseURL: cfg.AlpacaTradingURL})
+		logger.Infof("Routing approved trades to Alpaca at %s", cfg.AlpacaTradingURL)
+	case "paper":
+		paperBroker, err = paper.NewBroker(paper.NewChainMarket(dataAggregator, 5*time.Second), cfg.PaperStateFile, paper.Config{
+			StartingCash: cfg.PaperStartingCash,
+			Slippage:     cfg.PaperSlippage,
+			Commission:   cfg.PaperCommission,
+		}, breaker, logger)
+		if err != nil {
+			return nil, err
+		}
+		router = paperBroker
+		logger.Infof("Routing approved trades to the paper account in %s", cfg.PaperStateFile)
+	}
+
+	var orders *execution.Manager
+	if router != nil {
+		orderConfig := execution.DefaultConfig()
+		orderConfig.RepriceAfter = cfg.OrderRepriceAfter
+		// The paper broker reports what its own fills realize
+		var fillPnL execution.PnLRecorder
+		if paperBroker == nil {
+			fillPnL = breaker
+		}
+		orders, err = execution.NewManager(router, cfg.OrdersFile, performance, fillPnL, orderConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Server{
+		config:         cfg,
+		logger:         logger,
+		userStore:      userStore,
+		vault:          credentials,
+		dataAggregator: dataAggregator,
+		assistants:     ai_assistant.NewAssistantRegistry(cfg.AssistantCacheSize),
+		riskPolicy:     riskPolicy,
+		breaker:        breaker,
+		proposals:      proposals,
+		records:        records,
+		performance:    performance,
+		marks:          marks,
+		snapshots:      snapshotStore,
+		orders:         orders,
+		paper:          paperBroker,
+		portfolios:     NewPortfolioService(cfg.VibeTradeAPIURL, paperBroker, riskPolicy, breaker, logger),
+		keySet:         keySet,
+		authenticator:  auth.NewAuthenticator(validator, logger),
+		turnstile:      verifier,
+	}
+
+	s.keyRevalidator = NewKeyRevalidator(userStore, credentials, s.assistants, cfg.KeyRevalidateInterval, logger)
+	s.vaultRotator = NewVaultRotator(userStore, credentials, cfg.VaultRotationInterval, logger)
+	s.pnlMonitor = NewPnLMonitor(userStore, s.portfolios, cfg.PnLPollInterval, logger)
+	s.collector = snapshots.NewCollector(snapshotStore, dataAggregator, s.snapshotWatchlist, logger)
+	s.bookMonitor = NewBookMonitor(ai_assistant.NewBookMarker(performance, dataAggregator, marks), cfg.MarkInterval, logger)
+	switch {
+	case paperBroker != nil:
+		// Every paper close is a fill or settlement the broker reports
+		s.reconciler = NewTradeReconciler(performance, paperPositions(paperBroker), dataAggregator, nil, cfg.ReconcileInterval, logger)
+	case cfg.VibeTradeAPIURL != "":
+		s.reconciler = NewTradeReconciler(performance, vibetradePositions(userStore, cfg.VibeTradeAPIURL, orders, logger), dataAggregator, breaker, cfg.ReconcileInterval, logger)
+	}
+
+	mux := http.NewServeMux()
+	s.RegisterAIRoutes(mux)
+	mux.HandleFunc("/api/health", s.handleHealth)
+
+	s.httpServer = &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	return s, nil
+}
+
+// Run serves HTTP until ctx is cancelled, then shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	if s.keySet != nil {
+		s.keySet.StartRefresh(ctx, s.config.JWKSRefreshInterval)
+	}
+	s.keyRevalidator.Start(ctx)
+	s.vaultRotator.Start(ctx)
+	s.pnlMonitor.Start(ctx)
+	s.collector.Start(ctx, snapshotCheckInterval)
+	if s.reconciler != nil {
+		s.reconciler.Start(ctx)
+	}
+	s.bookMonitor.Start(ctx)
+	if s.orders != nil {
+		s.orders.Start(ctx, s.config.OrderPollInterval)
+	}
+	if s.paper != nil {
+		s.paper.Start(ctx, s.config.OrderPollInterval)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Infof("Server listening on %s", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	s.logger.Info("Shutting down server")
+	err := s.httpServer.Shutdown(shutdownCtx)
+	if closeErr := s.userStore.Close(); closeErr != nil {
+		s.logger.WithError(closeErr).Error("Failed to close user store")
+	}
+	if closeErr := s.records.Close(); closeErr != nil {
+		s.logger.WithError(closeErr).Error("Failed to close recommendation record store")
+	}
+	return err
+}
+
+// openUserStore opens the configured user store backend
+func openUserStore(cfg *Config, logger *logrus.Logger) (userstore.UserStore, error) {
+	switch cfg.UserStoreBackend {
+	case "bolt":
+		legacyFile := filepath.Join(cfg.DataDir, "users.json")
+		if _, err := os.Stat(legacyFile); err == nil {
+			if _, err := os.Stat(cfg.UserStorePath); os.IsNotExist(err) {
+				logger.Warnf("Found legacy user file %s; import it with: go run ./cmd/migrate_users -from %s -to %s",
+					legacyFile, legacyFile, cfg.UserStorePath)
+			}
+		}
+		return userstore.NewBoltUserStore(cfg.UserStorePath)
+	case "memory":
+		logger.Warn("Using in-memory user store; users will be lost on restart")
+		return userstore.NewMemoryUserStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown USER_STORE backend %q", cfg.UserStoreBackend)
+	}
+}
+
+// openRecordStore opens the recommendation record database, importing the
+// monthly JSON files earlier versions kept in DATA_DIR
+func openRecordStore(cfg *Config, logger *logrus.Logger) (ai_assistant.RecordStore, error) {
+	store, err := ai_assistant.NewBoltRecordStore(cfg.PerformanceDBPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := ai_assistant.ImportMonthlyRecordFiles(cfg.DataDir, store)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to import recommendation records: %w", err)
+	}
+	if len(report.Files) > 0 {
+		logger.WithFields(logrus.Fields{
+			"files":    report.Files,
+			"imported": report.Imported,
+			"skipped":  report.Skipped,
+		}).Info("Imported monthly recommendation record files")
+	}
+	for _, message := range report.Errors {
+		logger.Warnf("Recommendation record import: %s", message)
+	}
+	return store, nil
+}
+
+// openVault loads the master keyring, registers the pre-vault encryption key
+// (if one exists) for reading legacy values, and opens the audit log
+func openVault(cfg *Config) (*vault.Vault, error) {
+	var keyring *vault.Keyring
+	var err error
+	if cfg.VaultMasterKeys != "" {
+		keyring, err = vault.ParseKeyring(cfg.VaultMasterKeys)
+	} else {
+		keyring, err = vault.LoadKeyringFile(cfg.VaultMasterKeyFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	legacyKey := cfg.EncryptionKey
+	if legacyKey == nil {
+		if data, err := os.ReadFile(cfg.EncryptionKeyFile); err == nil {
+			if legacyKey, err = hex.DecodeString(strings.TrimSpace(string(data))); err != nil {
+				return nil, fmt.Errorf("invalid legacy key file %s: %w", cfg.EncryptionKeyFile, err)
+			}
+		}
+	}
+	if legacyKey != nil {
+		if err := keyring.SetLegacyKey(legacyKey); err != nil {
+			return nil, err
+		}
+	}
+
+	auditLog, err := vault.NewFileAuditLog(cfg.VaultAuditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return vault.New(keyring, auditLog)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	sendJSONResponse(w, map[string]interface{}{
+		"status":    "ok",
+		"timestamp": time.Now(),
+	})
+}
+
+func main() {
+	logger := logrus.New()
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load configuration")
+	}
+
+	if level, err := logrus.ParseLevel(cfg.LogLevel); err == nil {
+		logger.SetLevel(level)
+	}
+
+	server, err := NewServer(cfg, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize server")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.Run(ctx); err != nil {
+		logger.WithError(err).Fatal("Server error")
+	}
+}
@@ -1,41 +1,68 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
-	"os"
-	"strings"
 	"time"
-	
+
 	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/auth"
 )
 
 // RegisterAIRoutes adds AI-related routes to the server
 func (s *Server) RegisterAIRoutes(mux *http.ServeMux) {
 	// Initialize AI handlers
-	aiHandlers := NewAIHandlers(s.snapTradeUsers, s.consentManager.encryptor)
+	aiHandlers := NewAIHandlers(s.userStore, s.vault, s.dataAggregator, s.assistants, s.riskPolicy, s.breaker, s.proposals, s.performance, s.snapshots, s.portfolios, s.turnstile, s.logger)
 	
 	// Claude Code connection endpoints
 	mux.HandleFunc("/api/claude-code/connect", s.authenticateMiddleware(aiHandlers.HandleClaudeConnect))
 	mux.HandleFunc("/api/claude-code/status", s.authenticateMiddleware(aiHandlers.HandleClaudeStatus))
 	mux.HandleFunc("/api/claude-code/disconnect", s.authenticateMiddleware(aiHandlers.HandleClaudeDisconnect))
+	mux.HandleFunc("/api/claude-code/settings", s.authenticateMiddleware(aiHandlers.HandleSettings))
 	
 	// AI trading features
 	mux.HandleFunc("/api/claude-code/recommendations", s.authenticateMiddleware(aiHandlers.HandleGetRecommendations))
 	mux.HandleFunc("/api/claude-code/analyze-risk", s.authenticateMiddleware(aiHandlers.HandleAnalyzeRisk))
+	mux.HandleFunc("/api/claude-code/stress-test", s.authenticateMiddleware(aiHandlers.HandleStressTest))
+	mux.HandleFunc("/api/claude-code/portfolio-metrics", s.authenticateMiddleware(aiHandlers.HandlePortfolioMetrics))
+	mux.HandleFunc("/api/claude-code/circuit-breaker", s.authenticateMiddleware(aiHandlers.HandleCircuitBreakerStatus))
+	
+	// Approval workflow for proposed trades
+	proposalHandlers := NewProposalHandlers(s.proposals, s.breaker, s.logger)
+	mux.HandleFunc("/api/claude-code/proposals", s.authenticateMiddleware(proposalHandlers.HandleListProposals))
+	mux.HandleFunc("/api/claude-code/proposals/approve", s.authenticateMiddleware(proposalHandlers.HandleApproveProposal))
+	mux.HandleFunc("/api/claude-code/proposals/reject", s.authenticateMiddleware(proposalHandlers.HandleRejectProposal))
+
+	// Order execution for approved proposals
+	orderHandlers := NewOrderHandlers(s.orders, s.paper, s.proposals, s.dataAggregator, s.riskPolicy, s.breaker, s.logger)
+	mux.HandleFunc("/api/claude-code/proposals/execute", s.authenticateMiddleware(orderHandlers.HandleExecuteProposal))
+	mux.HandleFunc("/api/claude-code/orders", s.authenticateMiddleware(orderHandlers.HandleListOrders))
+	mux.HandleFunc("/api/claude-code/orders/cancel", s.authenticateMiddleware(orderHandlers.HandleCancelOrder))
+	mux.HandleFunc("/api/claude-code/paper/account", s.authenticateMiddleware(orderHandlers.HandlePaperAccount))
+	
+	// Mark-to-market history of executed recommendations
+	performanceHandlers := NewPerformanceHandlers(s.performance, s.marks, s.logger)
+	mux.HandleFunc("/api/claude-code/performance/equity", s.authenticateMiddleware(performanceHandlers.HandleEquityCurve))
+	mux.HandleFunc("/api/claude-code/performance/trade", s.authenticateMiddleware(performanceHandlers.HandleTradePath))
+
+	// Archived market data
+	snapshotHandlers := NewSnapshotHandlers(s.snapshots, s.logger)
+	mux.HandleFunc("/api/claude-code/snapshots", s.authenticateMiddleware(snapshotHandlers.HandleListSnapshots))
+	mux.HandleFunc("/api/claude-code/snapshots/load", s.authenticateMiddleware(snapshotHandlers.HandleGetSnapshot))
 	
 	// Educational endpoints
 	mux.HandleFunc("/api/claude-code/explain-strategy", s.authenticateMiddleware(aiHandlers.HandleExplainStrategy))
 	
 	// Claude Code SDK endpoints
-	claudeCodeServiceURL := os.Getenv("CLAUDE_CODE_SERVICE_URL")
-	if claudeCodeServiceURL == "" {
-		claudeCodeServiceURL = "http://localhost:3001"
-	}
-	claudeCodeHandlers := NewClaudeCodeHandlers(s.logger, claudeCodeServiceURL)
-	claudeCodeHandlers.RegisterRoutes(mux)
+	claudeCodeHandlers := NewClaudeCodeHandlers(s.logger, s.config.ClaudeCodeServiceURL)
+	claudeCodeHandlers.RegisterRoutes(mux, s.authenticateMiddleware)
+	
+	// Operator controls
+	adminHandlers := NewAdminHandlers(s.breaker, s.reconciler, s.performance, s.riskPolicy, s.logger)
+	mux.HandleFunc("/api/admin/kill-switch", s.authenticateMiddleware(auth.RequireRole("admin", adminHandlers.HandleKillSwitch)))
+	mux.HandleFunc("/api/admin/circuit-breaker/reset", s.authenticateMiddleware(auth.RequireRole("admin", adminHandlers.HandleResetCircuitBreaker)))
+	mux.HandleFunc("/api/admin/reconciliation", s.authenticateMiddleware(auth.RequireRole("admin", adminHandlers.HandleReconciliation)))
+	mux.HandleFunc("/api/admin/scoring/calibration", s.authenticateMiddleware(auth.RequireRole("admin", adminHandlers.HandleScoringCalibration)))
 	
 	s.logger.Info("AI routes registered successfully")
 }
@@ -47,7 +74,7 @@ func (h *AIHandlers) HandleExplainStrategy(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	userID := r.Context().Value("userID").(string)
+	userID := auth.UserIDFromContext(r.Context())
 	user, err := h.userStore.GetUser(userID)
 	if err != nil {
 		sendJSONError(w, "User not found", http.StatusNotFound)
@@ -55,17 +82,18 @@ func (h *AIHandlers) HandleExplainStrategy(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Check Claude connection
-	encryptedKey, ok := user.Metadata["claude_api_key"].(string)
-	if !ok || encryptedKey == "" {
+	if !user.Claude.IsConnected() {
 		sendJSONError(w, "Claude not connected", http.StatusBadRequest)
 		return
 	}
 
-	apiKey, err := h.encryptor.Decrypt(encryptedKey)
+	apiKey, err := h.openAPIKey(userID, user.Claude.EncryptedAPIKey, "explain_strategy")
 	if err != nil {
+		h.logger.WithError(err).Error("Failed to decrypt API key")
 		sendJSONError(w, "Failed to access Claude connection", http.StatusInternalServerError)
 		return
 	}
+	defer apiKey.Destroy()
 
 	// Parse strategy from request
 	var req struct {
@@ -76,13 +104,8 @@ func (h *AIHandlers) HandleExplainStrategy(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Initialize AI assistant if needed
-	if h.aiAssistant == nil {
-		h.aiAssistant = ai_assistant.NewTradingAssistant(apiKey)
-	}
-
 	// Get explanation from AI
-	explanation, err := h.aiAssistant.ExplainStrategy(r.Context(), req.Strategy)
+	explanation, err := h.assistants.Get(userID).ExplainStrategy(ai_assistant.WithAPIKey(r.Context(), apiKey.String()), req.Strategy)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to explain strategy")
 		sendJSONError(w, "Failed to generate explanation", http.StatusInternalServerError)
@@ -96,45 +119,7 @@ func (h *AIHandlers) HandleExplainStrategy(w http.ResponseWriter, r *http.Reques
 	})
 }
 
-// authenticateMiddleware checks for valid session/JWT token
+// authenticateMiddleware validates the caller's JWT and stores their identity in the request context
 func (s *Server) authenticateMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Get token from Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			sendJSONError(w, "Missing authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		// Extract bearer token
-		const bearerPrefix = "Bearer "
-		if !strings.HasPrefix(authHeader, bearerPrefix) {
-			sendJSONError(w, "Invalid authorization format", http.StatusUnauthorized)
-			return
-		}
-
-		token := authHeader[len(bearerPrefix):]
-		
-		// Validate token and get user ID
-		userID, err := s.validateToken(token)
-		if err != nil {
-			sendJSONError(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Add user ID to request context
-		ctx := context.WithValue(r.Context(), "userID", userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	}
+	return s.authenticator.Middleware(next)
 }
-
-func (s *Server) validateToken(token string) (string, error) {
-	// This would validate the JWT token and return the user ID
-	// For now, return a mock user ID
-	if token == "" {
-		return "", fmt.Errorf("empty token")
-	}
-	
-	// In production, decode JWT and extract user ID
-	return "anonymous-user-123", nil
-}
\ No newline at end of file
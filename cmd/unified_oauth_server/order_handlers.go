@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/auth"
+	"vibetrade-claude/internal/execution"
+	"vibetrade-claude/internal/execution/paper"
+)
+
+// OrderHandlers sends approved proposals to the broker and serves their
+// orders
+type OrderHandlers struct {
+	orders         *execution.Manager
+	paper          *paper.Broker
+	proposals      *ai_assistant.ProposalBook
+	dataAggregator *ai_assistant.MarketDataAggregator
+	riskPolicy     *ai_assistant.RiskPolicy
+	breaker        *ai_assistant.CircuitBreaker
+	logger         *logrus.Logger
+}
+
+func NewOrderHandlers(orders *execution.Manager, paperBroker *paper.Broker, proposals *ai_assistant.ProposalBook, dataAggregator *ai_assistant.MarketDataAggregator, riskPolicy *ai_assistant.RiskPolicy, breaker *ai_assistant.CircuitBreaker, logger *logrus.Logger) *OrderHandlers {
+	return &OrderHandlers{
+		orders:         orders,
+		paper:          paperBroker,
+		proposals:      proposals,
+		dataAggregator: dataAggregator,
+		riskPolicy:     riskPolicy,
+		breaker:        breaker,
+		logger:         logger,
+	}
+}
+
+// ExecuteProposalRequest sends one approved proposal to the broker
+type ExecuteProposalRequest struct {
+	ID     string `json:"id"`
+	Legged bool   `json:"legged"` // One order per leg instead of a multi-leg order
+}
+
+// CancelOrderRequest identifies the order to cancel
+type CancelOrderRequest struct {
+	ID string `json:"id"`
+}
+
+// HandleExecuteProposal sends the caller's approved proposal to the broker
+// as limit orders at the current mid of each leg
+func (h *OrderHandlers) HandleExecuteProposal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.enabled(w) {
+		return
+	}
+
+	var req ExecuteProposalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		sendJSONError(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	approver := approverFromRequest(r)
+	proposal, err := h.proposals.Get(r.Context(), req.ID)
+	if errors.Is(err, ai_assistant.ErrProposalNotFound) || (err == nil && proposal.UserID != approver.UserID) {
+		sendJSONError(w, "Proposal not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to load proposal")
+		sendJSONError(w, "Failed to load proposal", http.StatusInternalServerError)
+		return
+	}
+
+	var herr *ai_assistant.HaltError
+	if errors.As(h.breaker.Check(proposal.UserID), &herr) {
+		sendJSONErrorCode(w, herr.Message, herr.Reason, http.StatusLocked)
+		return
+	}
+	if proposal.State != ai_assistant.ProposalApproved {
+		sendJSONErrorCode(w, fmt.Sprintf("Proposal is %s, not approved", proposal.State), "proposal_not_approved", http.StatusConflict)
+		return
+	}
+	if proposal.Expired(time.Now()) {
+		sendJSONErrorCode(w, fmt.Sprintf("Proposal expired at %s", proposal.ExpiresAt.Format(time.RFC3339)), "proposal_expired", http.StatusConflict)
+		return
+	}
+
+	// Limits start at the mid of each leg on the current chain
+	trade := proposal.Trade
+	marketData, err := h.dataAggregator.AggregateDataForSymbols(r.Context(), []string{trade.Ticker})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to aggregate market data")
+		sendJSONError(w, "Failed to fetch market data", http.StatusInternalServerError)
+		return
+	}
+	riskManager := ai_assistant.NewRiskManagerWithPolicy(h.riskPolicy).WithMarketData(marketData)
+	prices := make([]float64, len(trade.LegDetails))
+	for i, leg := range trade.LegDetails {
+		if prices[i], err = riskManager.LegPrice(trade.Ticker, leg); err != nil {
+			sendJSONError(w, fmt.Sprintf("Cannot price leg %d: %v", i+1, err), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	order, err := h.orders.Execute(r.Context(), execution.ExecuteRequest{
+		Proposal:  proposal,
+		LegPrices: prices,
+		Legged:    req.Legged,
+	})
+	switch {
+	case errors.Is(err, execution.ErrAlreadyExecuted):
+		sendJSONErrorCode(w, err.Error(), "order_exists", http.StatusConflict)
+		return
+	case errors.Is(err, execution.ErrUnstructuredLegs):
+		sendJSONError(w, "Trade has no structured legs to execute", http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, execution.ErrInvalidOrder):
+		sendJSONError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil && order != nil:
+		h.logger.WithError(err).WithField("order_id", order.ID).Error("Broker did not accept order")
+		sendJSONError(w, order.Message, http.StatusBadGateway)
+		return
+	case err != nil:
+		h.logger.WithError(err).Error("Failed to execute proposal")
+		sendJSONError(w, "Failed to execute proposal", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"order_id":    order.ID,
+		"proposal_id": proposal.ID,
+		"record_id":   order.RecordID,
+		"broker":      order.Broker,
+		"legged":      order.Legged,
+		"limit":       order.LimitPrice,
+	}).Info("Order submitted")
+
+	sendJSONResponse(w, order)
+}
+
+// HandleListOrders lists the caller's orders; reviewers can pass
+// ?all=true to list every user's
+func (h *OrderHandlers) HandleListOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.enabled(w) {
+		return
+	}
+
+	approver := approverFromRequest(r)
+	userID := approver.UserID
+	if r.URL.Query().Get("all") == "true" {
+		if !approver.Reviewer {
+			sendJSONError(w, "Only reviewers can list all orders", http.StatusForbidden)
+			return
+		}
+		userID = ""
+	}
+
+	orders := h.orders.List(userID)
+	if orders == nil {
+		orders = []*execution.Order{}
+	}
+	sendJSONResponse(w, map[string]interface{}{"orders": orders})
+}
+
+// HandleCancelOrder cancels the working legs of an order. Reviewers may
+// cancel any user's.
+func (h *OrderHandlers) HandleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.enabled(w) {
+		return
+	}
+
+	var req CancelOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		sendJSONError(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	approver := approverFromRequest(r)
+	owner := approver.UserID
+	if approver.Reviewer {
+		owner = ""
+	}
+
+	order, err := h.orders.Cancel(r.Context(), req.ID, owner)
+	switch {
+	case errors.Is(err, execution.ErrOrderNotFound):
+		sendJSONError(w, "Order not found", http.StatusNotFound)
+		return
+	case errors.Is(err, execution.ErrNotCancelable):
+		sendJSONErrorCode(w, err.Error(), "order_not_working", http.StatusConflict)
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("order_id", req.ID).Error("Failed to cancel order")
+		sendJSONError(w, "Failed to cancel order", http.StatusBadGateway)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"order_id": order.ID,
+		"actor":    approver.UserID,
+		"status":   order.Status,
+	}).Info("Order cancel requested")
+
+	sendJSONResponse(w, order)
+}
+
+// HandlePaperAccount returns the caller's paper account, with its most
+// recent ?activity=N entries (default 50)
+func (h *OrderHandlers) HandlePaperAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.paper == nil {
+		sendJSONErrorCode(w, "Paper trading is not configured", "paper_trading_disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	activity := 50
+	if raw := r.URL.Query().Get("activity"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			sendJSONError(w, "activity must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		activity = n
+	}
+	sendJSONResponse(w, h.paper.Account(auth.UserIDFromContext(r.Context()), activity))
+}
+
+// enabled reports whether a broker is configured, responding if not
+func (h *OrderHandlers) enabled(w http.ResponseWriter) bool {
+	if h.orders == nil {
+		sendJSONErrorCode(w, "Order execution is not configured", "execution_disabled", http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// marksAfterClose is how long after the 16:00 close the book is still
+// marked, so each day ends with a mark at closing prices
+const marksAfterClose = 15 * time.Minute
+
+// BookMonitor marks the open AI trades to market every interval while the
+// market is open
+type BookMonitor struct {
+	marker   *ai_assistant.BookMarker
+	interval time.Duration
+	location *time.Location
+	logger   *logrus.Logger
+}
+
+func NewBookMonitor(marker *ai_assistant.BookMarker, interval time.Duration, logger *logrus.Logger) *BookMonitor {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		location = time.UTC
+	}
+	return &BookMonitor{
+		marker:   marker,
+		interval: interval,
+		location: location,
+		logger:   logger,
+	}
+}
+
+// Start runs Mark every interval until ctx is cancelled, skipping the
+// hours the market is closed
+func (m *BookMonitor) Start(ctx context.Context) {
+	if m.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if m.marketHours(now) {
+					m.Mark(ctx, now)
+				}
+			}
+		}
+	}()
+}
+
+// Mark marks the book as of now and logs the trades that could not be
+// priced
+func (m *BookMonitor) Mark(ctx context.Context, now time.Time) {
+	book, warnings, err := m.marker.Mark(ctx, now)
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to mark AI trades to market")
+		return
+	}
+	for _, warning := range warnings {
+		m.logger.Warnf("Mark to market: %s", warning)
+	}
+	m.logger.WithFields(logrus.Fields{
+		"open_trades":    book.Total.OpenTrades,
+		"unrealized_pnl": book.Total.UnrealizedPnL,
+		"equity":         book.Total.Equity,
+	}).Debug("Marked AI trades to market")
+}
+
+// marketHours reports whether now is within regular trading hours on a
+// weekday, or just after the close
+func (m *BookMonitor) marketHours(now time.Time) bool {
+	local := now.In(m.location)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	open := time.Date(local.Year(), local.Month(), local.Day(), 9, 30, 0, 0, m.location)
+	closeAt := time.Date(local.Year(), local.Month(), local.Day(), 16, 0, 0, 0, m.location).Add(marksAfterClose)
+	return !local.Before(open) && !local.After(closeAt)
+}
+
+// PerformanceHandlers serves the mark-to-market history of AI trades
+type PerformanceHandlers struct {
+	performance *ai_assistant.PerformanceTracker
+	marks       *ai_assistant.MarkStore
+	logger      *logrus.Logger
+}
+
+func NewPerformanceHandlers(performance *ai_assistant.PerformanceTracker, marks *ai_assistant.MarkStore, logger *logrus.Logger) *PerformanceHandlers {
+	return &PerformanceHandlers{
+		performance: performance,
+		marks:       marks,
+		logger:      logger,
+	}
+}
+
+// EquityPoint is the book at one mark
+type EquityPoint struct {
+	At time.Time `json:"at"`
+	ai_assistant.BookTotals
+}
+
+// HandleEquityCurve returns the caller's AI book marked to market between
+// from and to (RFC 3339 or YYYY-MM-DD; the last 30 days by default).
+// Reviewers can pass ?all=true for the whole book.
+func (h *PerformanceHandlers) HandleEquityCurve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	to, err := parseTimeParam(query.Get("to"), time.Now(), true)
+	if err != nil {
+		sendJSONError(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), to.AddDate(0, 0, -30), false)
+	if err != nil {
+		sendJSONError(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caller := approverFromRequest(r)
+	all := query.Get("all") == "true"
+	if all && !caller.Reviewer {
+		sendJSONError(w, "Only reviewers can see the whole book", http.StatusForbidden)
+		return
+	}
+
+	marks, err := h.marks.EquityCurve(from, to)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to read the equity curve")
+		sendJSONError(w, "Failed to read the equity curve", http.StatusInternalServerError)
+		return
+	}
+
+	curve := make([]EquityPoint, 0, len(marks))
+	for _, mark := range marks {
+		point := EquityPoint{At: mark.At, BookTotals: mark.Total}
+		if !all {
+			point.BookTotals = ai_assistant.BookTotals{}
+			if totals := mark.ByUser[caller.UserID]; totals != nil {
+				point.BookTotals = *totals
+			}
+		}
+		curve = append(curve, point)
+	}
+
+	sendJSONResponse(w, map[string]interface{}{
+		"curve": curve,
+		"from":  from,
+		"to":    to,
+	})
+}
+
+// HandleTradePath returns a recommendation record with its path of marks
+// (?id=). Only the user it was made for and reviewers can see it.
+func (h *PerformanceHandlers) HandleTradePath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		sendJSONError(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	record, err := h.performance.GetRecord(id)
+	if errors.Is(err, ai_assistant.ErrRecordNotFound) {
+		sendJSONError(w, "Recommendation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to load recommendation record")
+		sendJSONError(w, "Failed to load recommendation", http.StatusInternalServerError)
+		return
+	}
+	if caller := approverFromRequest(r); record.UserID != caller.UserID && !caller.Reviewer {
+		sendJSONError(w, "Recommendation not found", http.StatusNotFound)
+		return
+	}
+
+	path, err := h.marks.TradePath(id)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to read trade path")
+		sendJSONError(w, "Failed to read trade path", http.StatusInternalServerError)
+		return
+	}
+	if path == nil {
+		path = []*ai_assistant.TradeMark{}
+	}
+
+	sendJSONResponse(w, map[string]interface{}{
+		"record": record,
+		"path":   path,
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/execution/paper"
+	"vibetrade-claude/internal/userstore"
+	"vibetrade-claude/internal/vibetrade"
+)
+
+// defaultAccountValue is the account value the risk checks assume when a
+// portfolio has no total_value
+const defaultAccountValue = 100000.0
+
+// PortfolioService loads user portfolios and feeds their P&L to the circuit breaker
+type PortfolioService struct {
+	vibetradeURL string
+	paper        *paper.Broker // Nil unless EXECUTION_BROKER is paper
+	riskPolicy   *ai_assistant.RiskPolicy
+	breaker      *ai_assistant.CircuitBreaker
+	logger       *logrus.Logger
+}
+
+func NewPortfolioService(vibetradeURL string, paperBroker *paper.Broker, riskPolicy *ai_assistant.RiskPolicy, breaker *ai_assistant.CircuitBreaker, logger *logrus.Logger) *PortfolioService {
+	return &PortfolioService{
+		vibetradeURL: vibetradeURL,
+		paper:        paperBroker,
+		riskPolicy:   riskPolicy,
+		breaker:      breaker,
+		logger:       logger,
+	}
+}
+
+// Load returns the user's portfolio: their paper account when the paper
+// broker is active, otherwise their VibeTrade positions when the backend
+// is configured. The account value and unrealized P&L are recorded with
+// the circuit breaker, and the portfolio's daily_pnl is today's P&L as
+// tracked by the breaker.
+func (ps *PortfolioService) Load(ctx context.Context, user *userstore.User) (map[string]interface{}, error) {
+	if ps.paper != nil {
+		return ps.loadPaper(user), nil
+	}
+
+	// Balances the broker does not report are left out, so the risk
+	// checks report them as unknown rather than checking a guess
+	portfolio := map[string]interface{}{
+		"positions": []map[string]interface{}{},
+		"options":   []vibetrade.OptionPosition{},
+	}
+
+	if ps.vibetradeURL == "" {
+		return portfolio, nil
+	}
+
+	client := vibetrade.NewClient(&vibetrade.Config{
+		BaseURL: ps.vibetradeURL,
+		UserID:  user.ID,
+		Timeout: 10 * time.Second,
+	}, ps.logger)
+
+	positions, err := client.GetOptionsPositions(ctx, user.ID)
+	if err != nil {
+		return portfolio, err
+	}
+	portfolio["options"] = positions
+
+	// The breaker measures losses against the value the risk checks assume
+	// when the account's is unknown
+	totalValue := defaultAccountValue
+	if account, err := client.GetAccount(ctx, user.ID); err != nil {
+		ps.logger.WithError(err).WithField("user_id", user.ID).Debug("Account balances unavailable")
+	} else {
+		totalValue = account.Equity.InexactFloat64()
+		portfolio["cash_balance"] = account.Cash.InexactFloat64()
+		portfolio["buying_power"] = account.BuyingPower.InexactFloat64()
+		portfolio["total_value"] = totalValue
+	}
+
+	limits := userRiskPolicy(ps.riskPolicy, user).Limits
+	daily, err := ps.breaker.UpdatePositions(user.ID, totalValue, limits.MaxDailyLoss, positions)
+	ps.track(user, portfolio, daily, err)
+
+	return portfolio, nil
+}
+
+// loadPaper returns the user's paper account as a portfolio, with its
+// cash, buying power and positions at their latest marks
+func (ps *PortfolioService) loadPaper(user *userstore.User) map[string]interface{} {
+	account := ps.paper.Account(user.ID, 0)
+	stocks := []map[string]interface{}{}
+	options := []vibetrade.OptionPosition{}
+	for _, p := range account.Positions {
+		if !p.IsOption() {
+			stocks = append(stocks, map[string]interface{}{
+				"symbol":       p.Symbol,
+				"quantity":     p.Quantity,
+				"cost_basis":   p.AvgPrice,
+				"market_value": p.MarketValue(),
+			})
+			continue
+		}
+		side := "long"
+		if p.Quantity < 0 {
+			side = "short"
+		}
+		options = append(options, vibetrade.OptionPosition{
+			Symbol:        p.Symbol,
+			Quantity:      decimal.NewFromInt(int64(p.Quantity)).Abs(),
+			AveragePrice:  decimal.NewFromFloat(p.AvgPrice),
+			MarketValue:   decimal.NewFromFloat(math.Abs(p.MarketValue())),
+			UnrealizedPnL: decimal.NewFromFloat(p.UnrealizedPnL()),
+			Side:          side,
+			AssetType:     "option",
+			LastUpdated:   p.MarkedAt,
+		})
+	}
+	portfolio := map[string]interface{}{
+		"cash_balance": account.Cash,
+		"buying_power": account.BuyingPower,
+		"positions":    stocks,
+		"options":      options,
+		"total_value":  account.Equity,
+	}
+
+	limits := userRiskPolicy(ps.riskPolicy, user).Limits
+	daily, err := ps.breaker.UpdateUnrealized(user.ID, account.Equity, limits.MaxDailyLoss, account.UnrealizedPnL)
+	ps.track(user, portfolio, daily, err)
+	return portfolio
+}
+
+// track sets the portfolio's daily_pnl from the breaker's update and logs
+// a tripped breaker
+func (ps *PortfolioService) track(user *userstore.User, portfolio map[string]interface{}, daily *ai_assistant.DailyPnL, err error) {
+	if err != nil {
+		ps.logger.WithError(err).Error("Failed to persist circuit breaker state")
+	}
+	portfolio["daily_pnl"] = daily.Total()
+
+	if daily.Tripped {
+		ps.logger.WithFields(logrus.Fields{
+			"user_id":   user.ID,
+			"daily_pnl": daily.Total(),
+		}).Warn(daily.TripReason)
+	}
+}
+
+// userRiskPolicy layers the user's own limits over the server policy
+func userRiskPolicy(policy *ai_assistant.RiskPolicy, user *userstore.User) *ai_assistant.RiskPolicy {
+	if user.RiskLimits == nil {
+		return policy
+	}
+	return policy.WithLimits(ai_assistant.RiskLimits(*user.RiskLimits))
+}
+
+// PnLMonitor periodically refreshes every connected user's portfolio so the
+// circuit breaker trips on losses even when no requests are being made
+type PnLMonitor struct {
+	userStore  userstore.UserStore
+	portfolios *PortfolioService
+	interval   time.Duration
+	logger     *logrus.Logger
+}
+
+func NewPnLMonitor(userStore userstore.UserStore, portfolios *PortfolioService, interval time.Duration, logger *logrus.Logger) *PnLMonitor {
+	return &PnLMonitor{
+		userStore:  userStore,
+		portfolios: portfolios,
+		interval:   interval,
+		logger:     logger,
+	}
+}
+
+// Start runs RefreshAll every interval until ctx is cancelled, as long as
+// there are positions to refresh
+func (m *PnLMonitor) Start(ctx context.Context) {
+	if m.interval <= 0 || (m.portfolios.vibetradeURL == "" && m.portfolios.paper == nil) {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.RefreshAll(ctx)
+			}
+		}
+	}()
+}
+
+// RefreshAll loads the portfolio of each user with a Claude connection
+func (m *PnLMonitor) RefreshAll(ctx context.Context) {
+	users, err := m.userStore.ListUsers()
+	if err != nil {
+		m.logger.WithError(err).Error("Failed to list users for P&L refresh")
+		return
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		if !user.Claude.IsConnected() {
+			continue
+		}
+		if _, err := m.portfolios.Load(ctx, user); err != nil {
+			m.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to refresh positions")
+		}
+	}
+}
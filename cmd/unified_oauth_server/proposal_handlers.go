@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/auth"
+)
+
+// reviewerRoles may approve or reject other users' proposals
+var reviewerRoles = []string{"admin", "approver"}
+
+// ProposalHandlers serves the approval workflow for AI trade proposals
+type ProposalHandlers struct {
+	proposals *ai_assistant.ProposalBook
+	breaker   *ai_assistant.CircuitBreaker
+	logger    *logrus.Logger
+}
+
+func NewProposalHandlers(proposals *ai_assistant.ProposalBook, breaker *ai_assistant.CircuitBreaker, logger *logrus.Logger) *ProposalHandlers {
+	return &ProposalHandlers{
+		proposals: proposals,
+		breaker:   breaker,
+		logger:    logger,
+	}
+}
+
+// ProposalDecisionRequest approves or rejects one proposal
+type ProposalDecisionRequest struct {
+	ID      string `json:"id"`
+	Comment string `json:"comment"` // Approval note or rejection reason
+}
+
+// HandleListProposals lists the caller's proposals, filtered by ?state=.
+// Reviewers can pass ?all=true to list every user's.
+func (h *ProposalHandlers) HandleListProposals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state, err := ai_assistant.ParseProposalState(r.URL.Query().Get("state"))
+	if err != nil {
+		sendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	approver := approverFromRequest(r)
+	userID := approver.UserID
+	if r.URL.Query().Get("all") == "true" {
+		if !approver.Reviewer {
+			sendJSONError(w, "Only reviewers can list all proposals", http.StatusForbidden)
+			return
+		}
+		userID = ""
+	}
+
+	proposals, err := h.proposals.List(r.Context(), userID, state)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list proposals")
+		sendJSONError(w, "Failed to list proposals", http.StatusInternalServerError)
+		return
+	}
+	if proposals == nil {
+		proposals = []*ai_assistant.Proposal{}
+	}
+
+	sendJSONResponse(w, map[string]interface{}{"proposals": proposals})
+}
+
+// HandleApproveProposal records the caller's approval of a proposal
+func (h *ProposalHandlers) HandleApproveProposal(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, ai_assistant.ProposalActionApprove)
+}
+
+// HandleRejectProposal rejects a proposal
+func (h *ProposalHandlers) HandleRejectProposal(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, ai_assistant.ProposalActionReject)
+}
+
+func (h *ProposalHandlers) decide(w http.ResponseWriter, r *http.Request, action string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ProposalDecisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		sendJSONError(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	approver := approverFromRequest(r)
+	comment := strings.TrimSpace(req.Comment)
+
+	var proposal *ai_assistant.Proposal
+	var err error
+	if action == ai_assistant.ProposalActionApprove {
+		// Trades cannot be approved while AI trading is halted for their
+		// owner, which is only revealed to those who may approve them
+		if proposal, err = h.proposals.Decidable(r.Context(), req.ID, approver); err == nil {
+			var herr *ai_assistant.HaltError
+			if errors.As(h.breaker.Check(proposal.UserID), &herr) {
+				sendJSONErrorCode(w, herr.Message, herr.Reason, http.StatusLocked)
+				return
+			}
+			proposal, err = h.proposals.Approve(r.Context(), req.ID, approver, comment)
+		}
+	} else {
+		proposal, err = h.proposals.Reject(r.Context(), req.ID, approver, comment)
+	}
+
+	switch {
+	case errors.Is(err, ai_assistant.ErrProposalNotFound):
+		sendJSONError(w, "Proposal not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ai_assistant.ErrProposalNotPending):
+		sendJSONErrorCode(w, err.Error(), "proposal_not_pending", http.StatusConflict)
+		return
+	case errors.Is(err, ai_assistant.ErrAlreadyApproved):
+		sendJSONErrorCode(w, err.Error(), "proposal_already_approved", http.StatusConflict)
+		return
+	case errors.Is(err, ai_assistant.ErrNotAuthorized):
+		sendJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	case err != nil:
+		h.logger.WithError(err).Error("Failed to record proposal decision")
+		sendJSONError(w, "Failed to record decision", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"proposal_id": proposal.ID,
+		"owner":       proposal.UserID,
+		"actor":       approver.UserID,
+		"action":      action,
+		"state":       proposal.State,
+	}).Info("Proposal decision recorded")
+
+	sendJSONResponse(w, proposal)
+}
+
+// approverFromRequest returns the caller as an approver; admins and
+// approvers are reviewers
+func approverFromRequest(r *http.Request) ai_assistant.Approver {
+	approver := ai_assistant.Approver{UserID: auth.UserIDFromContext(r.Context())}
+	if id, ok := auth.IdentityFromContext(r.Context()); ok {
+		for _, role := range reviewerRoles {
+			if id.HasRole(role) {
+				approver.Reviewer = true
+			}
+		}
+	}
+	return approver
+}
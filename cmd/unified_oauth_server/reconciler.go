@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/execution"
+	"vibetrade-claude/internal/execution/paper"
+	"vibetrade-claude/internal/userstore"
+	"vibetrade-claude/internal/vibetrade"
+)
+
+// PositionSource lists the option positions recommendation records are
+// reconciled against
+type PositionSource func(ctx context.Context) ([]ai_assistant.AccountPositions, error)
+
+// TradeReconciler periodically reconciles the recommendation records with
+// the positions actually held, so records move through executed, closed
+// and expired without anyone updating them by hand. The P&L of trades
+// closed outside the system is reported to pnl, if set.
+type TradeReconciler struct {
+	performance *ai_assistant.PerformanceTracker
+	positions   PositionSource
+	prices      ai_assistant.PriceSource
+	pnl         execution.PnLRecorder
+	interval    time.Duration
+	logger      *logrus.Logger
+
+	mu   sync.Mutex
+	last *ai_assistant.ReconciliationReport
+}
+
+func NewTradeReconciler(performance *ai_assistant.PerformanceTracker, positions PositionSource, prices ai_assistant.PriceSource, pnl execution.PnLRecorder, interval time.Duration, logger *logrus.Logger) *TradeReconciler {
+	return &TradeReconciler{
+		performance: performance,
+		positions:   positions,
+		prices:      prices,
+		pnl:         pnl,
+		interval:    interval,
+		logger:      logger,
+	}
+}
+
+// Start runs Reconcile every interval until ctx is cancelled
+func (tr *TradeReconciler) Start(ctx context.Context) {
+	if tr.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(tr.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := tr.Reconcile(ctx); err != nil {
+					tr.logger.WithError(err).Error("Failed to reconcile recommendation records")
+				}
+			}
+		}
+	}()
+}
+
+// Reconcile matches the open records to the current positions and logs
+// what changed and what could not be matched
+func (tr *TradeReconciler) Reconcile(ctx context.Context) (*ai_assistant.ReconciliationReport, error) {
+	accounts, err := tr.positions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load positions: %w", err)
+	}
+	report, err := tr.performance.Reconcile(ctx, accounts, tr.prices, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	tr.mu.Lock()
+	tr.last = report
+	tr.mu.Unlock()
+
+	if tr.pnl != nil {
+		for _, external := range report.External {
+			if _, err := tr.pnl.RecordRealizedPnL(external.UserID, 0, external.Profit); err != nil {
+				tr.logger.WithError(err).WithField("record_id", external.RecordID).Error("Failed to record realized P&L")
+			}
+		}
+	}
+
+	if n := len(report.Executed) + len(report.Closed) + len(report.Expired); n > 0 {
+		tr.logger.WithFields(logrus.Fields{
+			"executed": len(report.Executed),
+			"closed":   len(report.Closed),
+			"expired":  len(report.Expired),
+		}).Info("Reconciled recommendation records")
+	}
+	for _, position := range report.Unmatched {
+		tr.logger.WithFields(logrus.Fields{
+			"user_id":  position.UserID,
+			"symbol":   position.Symbol,
+			"quantity": position.Quantity,
+		}).Warn("Position matches no recommendation")
+	}
+	for _, message := range report.Errors {
+		tr.logger.Warnf("Reconciliation: %s", message)
+	}
+	return report, nil
+}
+
+// LastReport returns the most recent reconciliation, or nil before the first
+func (tr *TradeReconciler) LastReport() *ai_assistant.ReconciliationReport {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.last
+}
+
+// paperPositions lists each paper account's options, with the fills and
+// settlements that closed them
+func paperPositions(broker *paper.Broker) PositionSource {
+	return func(ctx context.Context) ([]ai_assistant.AccountPositions, error) {
+		var accounts []ai_assistant.AccountPositions
+		for _, userID := range broker.Users() {
+			snapshot := broker.Account(userID, math.MaxInt)
+			account := ai_assistant.AccountPositions{UserID: userID}
+			for _, p := range snapshot.Positions {
+				if !p.IsOption() {
+					continue
+				}
+				account.Positions = append(account.Positions, ai_assistant.HeldPosition{
+					Symbol:   p.Symbol,
+					Quantity: float64(p.Quantity),
+					AvgPrice: p.AvgPrice,
+					Mark:     p.Mark,
+				})
+			}
+			for _, a := range snapshot.Activity {
+				if a.Closed == 0 {
+					continue
+				}
+				quantity := float64(a.Closed)
+				if a.Side == execution.SideSell {
+					quantity = -quantity
+				}
+				account.Closes = append(account.Closes, ai_assistant.ClosingFill{Symbol: a.Symbol, Quantity: quantity, Price: a.Price, At: a.At})
+			}
+			accounts = append(accounts, account)
+		}
+		return accounts, nil
+	}
+}
+
+// vibetradePositions lists each user's VibeTrade option positions, with the
+// closing fills of the orders routed for them when orders is not nil.
+// Users whose positions cannot be fetched are left out, so their records
+// are not taken for closed.
+func vibetradePositions(userStore userstore.UserStore, baseURL string, orders *execution.Manager, logger *logrus.Logger) PositionSource {
+	return func(ctx context.Context) ([]ai_assistant.AccountPositions, error) {
+		users, err := userStore.ListUsers()
+		if err != nil {
+			return nil, err
+		}
+
+		var accounts []ai_assistant.AccountPositions
+		for _, user := range users {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			client := vibetrade.NewClient(&vibetrade.Config{
+				BaseURL: baseURL,
+				UserID:  user.ID,
+				Timeout: 10 * time.Second,
+			}, logger)
+			held, err := client.GetOptionsPositions(ctx, user.ID)
+			if err != nil {
+				logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to load positions for reconciliation")
+				continue
+			}
+
+			account := ai_assistant.AccountPositions{UserID: user.ID}
+			for _, p := range held {
+				quantity := p.Quantity.InexactFloat64()
+				if side := strings.ToLower(p.Side); (side == "short" || side == "sell") && quantity > 0 {
+					quantity = -quantity
+				}
+				mark := 0.0
+				if quantity != 0 {
+					mark = math.Abs(p.MarketValue.InexactFloat64()) / (math.Abs(quantity) * 100)
+				}
+				account.Positions = append(account.Positions, ai_assistant.HeldPosition{
+					Symbol:   p.Symbol,
+					Quantity: quantity,
+					AvgPrice: p.AveragePrice.InexactFloat64(),
+					Mark:     mark,
+				})
+			}
+			if orders != nil {
+				for _, fill := range orders.Fills(user.ID, execution.IntentClose) {
+					quantity := float64(fill.Quantity)
+					if fill.Side == execution.SideSell {
+						quantity = -quantity
+					}
+					account.Closes = append(account.Closes, ai_assistant.ClosingFill{Symbol: fill.Symbol, Quantity: quantity, Price: fill.Price, At: fill.At})
+				}
+			}
+			accounts = append(accounts, account)
+		}
+		return accounts, nil
+	}
+}
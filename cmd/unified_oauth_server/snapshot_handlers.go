@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/snapshots"
+	"vibetrade-claude/internal/userstore"
+)
+
+// snapshotCheckInterval is how often the collector checks whether the
+// end-of-day snapshot is due
+const snapshotCheckInterval = 5 * time.Minute
+
+// defaultWatchlist is used for users without a watchlist of their own
+var defaultWatchlist = []string{"SPY", "QQQ", "AAPL", "MSFT", "NVDA", "TSLA", "AMD", "META"}
+
+// SnapshotHandlers serves the stored market snapshots
+type SnapshotHandlers struct {
+	store  *snapshots.Store
+	logger *logrus.Logger
+}
+
+func NewSnapshotHandlers(store *snapshots.Store, logger *logrus.Logger) *SnapshotHandlers {
+	return &SnapshotHandlers{store: store, logger: logger}
+}
+
+// SnapshotResponse is a stored snapshot with its entry
+type SnapshotResponse struct {
+	Entry    *snapshots.Entry    `json:"entry"`
+	Snapshot *snapshots.Snapshot `json:"snapshot"`
+}
+
+// HandleListSnapshots lists the snapshots taken between from and to
+// (RFC 3339 or YYYY-MM-DD; the last week by default) that cover every one
+// of the comma-separated symbols
+func (h *SnapshotHandlers) HandleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	to, err := parseTimeParam(query.Get("to"), time.Now(), true)
+	if err != nil {
+		sendJSONError(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query.Get("from"), to.AddDate(0, 0, -7), false)
+	if err != nil {
+		sendJSONError(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries := h.store.List(from, to, splitSymbols(query.Get("symbols")))
+	if entries == nil {
+		entries = []snapshots.Entry{}
+	}
+	sendJSONResponse(w, map[string]interface{}{
+		"snapshots": entries,
+		"from":      from,
+		"to":        to,
+	})
+}
+
+// HandleGetSnapshot loads a snapshot by id, or the latest taken at or
+// before at (now by default) covering every one of symbols
+func (h *SnapshotHandlers) HandleGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	id := query.Get("id")
+	if id == "" {
+		at, err := parseTimeParam(query.Get("at"), time.Now(), true)
+		if err != nil {
+			sendJSONError(w, "Invalid at: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		entry, err := h.store.Find(at, splitSymbols(query.Get("symbols")))
+		if errors.Is(err, snapshots.ErrNotFound) {
+			sendJSONError(w, "No snapshot found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to find snapshot")
+			sendJSONError(w, "Failed to find snapshot", http.StatusInternalServerError)
+			return
+		}
+		id = entry.ID
+	}
+
+	snapshot, entry, err := h.store.Load(id)
+	if errors.Is(err, snapshots.ErrNotFound) {
+		sendJSONError(w, "Snapshot not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("snapshot_id", id).Error("Failed to load snapshot")
+		sendJSONError(w, "Failed to load snapshot", http.StatusInternalServerError)
+		return
+	}
+	sendJSONResponse(w, SnapshotResponse{Entry: entry, Snapshot: snapshot})
+}
+
+func splitSymbols(value string) []string {
+	var symbols []string
+	for _, symbol := range strings.Split(value, ",") {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// snapshotWatchlist is what the end-of-day collector captures: every
+// user's watchlist, the default watchlist for users without one, and
+// SNAPSHOT_WATCHLIST
+func (s *Server) snapshotWatchlist() []string {
+	seen := make(map[string]bool)
+	add := func(symbols []string) {
+		for _, symbol := range symbols {
+			seen[strings.ToUpper(symbol)] = true
+		}
+	}
+	add(s.config.SnapshotWatchlist)
+
+	users, err := s.userStore.ListUsers()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to list users for the snapshot watchlist")
+	}
+	usesDefault := len(users) == 0
+	for _, user := range users {
+		add(user.Preferences.Watchlist)
+		usesDefault = usesDefault || len(user.Preferences.Watchlist) == 0
+	}
+	if usesDefault {
+		add(defaultWatchlist)
+	}
+
+	symbols := make([]string, 0, len(seen))
+	for symbol := range seen {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// userWatchlist returns user's watchlist, or the default one
+func userWatchlist(user *userstore.User) []string {
+	if len(user.Preferences.Watchlist) > 0 {
+		return user.Preferences.Watchlist
+	}
+	return defaultWatchlist
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/userstore"
+	"vibetrade-claude/internal/vault"
+)
+
+// VaultRotator re-wraps stored credentials that were sealed with a retired
+// master key, so old keys can be removed from the keyring once it finishes
+type VaultRotator struct {
+	userStore userstore.UserStore
+	vault     *vault.Vault
+	interval  time.Duration
+	logger    *logrus.Logger
+}
+
+func NewVaultRotator(userStore userstore.UserStore, credentials *vault.Vault, interval time.Duration, logger *logrus.Logger) *VaultRotator {
+	return &VaultRotator{
+		userStore: userStore,
+		vault:     credentials,
+		interval:  interval,
+		logger:    logger,
+	}
+}
+
+// Start runs one pass immediately and then every interval until ctx is cancelled
+func (vr *VaultRotator) Start(ctx context.Context) {
+	if vr.interval <= 0 {
+		return
+	}
+
+	go func() {
+		vr.RotateAll(ctx)
+
+		ticker := time.NewTicker(vr.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				vr.RotateAll(ctx)
+			}
+		}
+	}()
+}
+
+// RotateAll re-wraps every stored Claude key not sealed under the active master key
+func (vr *VaultRotator) RotateAll(ctx context.Context) {
+	users, err := vr.userStore.ListUsers()
+	if err != nil {
+		vr.logger.WithError(err).Error("Failed to list users for key rotation")
+		return
+	}
+
+	rotated := 0
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+
+		sealed := user.Claude.EncryptedAPIKey
+		if sealed == "" || !vr.vault.NeedsRewrap(sealed) {
+			continue
+		}
+
+		resealed, err := vr.vault.Rewrap(claudeKeyRecordID(user.ID), sealed)
+		if err != nil {
+			vr.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to re-wrap Claude API key")
+			continue
+		}
+
+		_, err = userstore.Modify(vr.userStore, user.ID, func(current *userstore.User) error {
+			if current.Claude.EncryptedAPIKey != sealed {
+				return errKeyChanged
+			}
+			current.Claude.EncryptedAPIKey = resealed
+			return nil
+		})
+		if err != nil {
+			if !errors.Is(err, errKeyChanged) {
+				vr.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to save re-wrapped Claude API key")
+			}
+			continue
+		}
+		rotated++
+	}
+
+	if rotated > 0 {
+		vr.logger.Infof("Re-wrapped %d stored credentials with the active master key", rotated)
+	}
+}
@@ -44,6 +44,11 @@ export function ClaudeTradeAssistant() {
     queryKey: ['trade-recommendations'],
     queryFn: async () => {
       const response = await authApi.get('/api/claude-code/recommendations');
+      if (response.status === 423) {
+        // Daily loss breaker or kill switch; show why instead of failing
+        const halt = await response.json();
+        return { trades: [], message: halt.error };
+      }
       if (!response.ok) throw new Error('Failed to fetch recommendations');
       return response.json();
     },
@@ -60,10 +65,17 @@ export function ClaudeTradeAssistant() {
       });
       if (!response.ok) {
         const error = await response.json();
-        throw new Error(error.message || 'Failed to connect Claude');
+        throw Object.assign(new Error(error.error || 'Failed to connect Claude'), { code: error.code });
       }
       return response.json();
     },
+    onError: (error: Error & { code?: string }) => {
+      // Turnstile tokens are single use; show a fresh challenge unless the verifier is down
+      if (error.code?.startsWith('turnstile_') && error.code !== 'turnstile_unavailable') {
+        setTurnstileToken('');
+        setShowTurnstile(true);
+      }
+    },
     onSuccess: () => {
       queryClient.invalidateQueries({ queryKey: ['claude-connection'] });
       queryClient.invalidateQueries({ queryKey: ['trade-recommendations'] });
@@ -6,6 +6,8 @@ require (
 	github.com/alpacahq/alpaca-trade-api-go/v3 v3.5.0
 	github.com/shopspring/decimal v1.4.0
 	github.com/sirupsen/logrus v1.9.3
+	go.etcd.io/bbolt v1.3.10
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,85 @@
+package ai_assistant
+
+import (
+	"container/list"
+	"sync"
+)
+
+// AssistantRegistry holds one TradingAssistant per user. The cached
+// assistants hold no API key: callers open the user's key from the vault for
+// each request and attach it with WithAPIKey, so no plaintext key outlives
+// the request that used it.
+type AssistantRegistry struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	lru      *list.List // front is most recently used
+	factory  func() *TradingAssistant
+}
+
+type assistantEntry struct {
+	userID    string
+	assistant *TradingAssistant
+}
+
+// NewAssistantRegistry creates a registry that keeps at most capacity assistants
+func NewAssistantRegistry(capacity int) *AssistantRegistry {
+	if capacity <= 0 {
+		capacity = 256
+	}
+
+	return &AssistantRegistry{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		factory:  newKeylessTradingAssistant,
+	}
+}
+
+// Get returns the assistant for userID, creating a new one if none is
+// cached. Its requests must carry the user's key via WithAPIKey.
+func (r *AssistantRegistry) Get(userID string) *TradingAssistant {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elem, ok := r.entries[userID]; ok {
+		r.lru.MoveToFront(elem)
+		return elem.Value.(*assistantEntry).assistant
+	}
+
+	entry := &assistantEntry{
+		userID:    userID,
+		assistant: r.factory(),
+	}
+	r.entries[userID] = r.lru.PushFront(entry)
+
+	for r.lru.Len() > r.capacity {
+		r.removeElement(r.lru.Back())
+	}
+
+	return entry.assistant
+}
+
+// Invalidate drops the cached assistant for userID, e.g. after the user
+// replaces or disconnects their API key
+func (r *AssistantRegistry) Invalidate(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elem, ok := r.entries[userID]; ok {
+		r.removeElement(elem)
+	}
+}
+
+// Len returns the number of cached assistants
+func (r *AssistantRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lru.Len()
+}
+
+func (r *AssistantRegistry) removeElement(elem *list.Element) {
+	entry := r.lru.Remove(elem).(*assistantEntry)
+	delete(r.entries, entry.userID)
+}
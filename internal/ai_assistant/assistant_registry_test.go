@@ -0,0 +1,122 @@
+package ai_assistant
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// echoKeyServer answers every message with the x-api-key it was sent
+func echoKeyServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"content":[{"type":"text","text":%q}]}`, r.Header.Get("x-api-key"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestRegistry(capacity int, baseURL string) *AssistantRegistry {
+	registry := NewAssistantRegistry(capacity)
+	registry.factory = func() *TradingAssistant {
+		client := newClaudeClient("")
+		client.baseURL = baseURL
+		return NewTradingAssistantWithProvider(client, nil)
+	}
+	return registry
+}
+
+func TestAssistantRegistryKeysNeverLeakAcrossUsers(t *testing.T) {
+	srv := echoKeyServer(t)
+	registry := newTestRegistry(4, srv.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64*50)
+
+	for u := 0; u < 64; u++ {
+		wg.Add(1)
+		go func(u int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("user-%d", u)
+			apiKey := fmt.Sprintf("sk-ant-%d", u)
+			for i := 0; i < 50; i++ {
+				ctx := WithAPIKey(context.Background(), apiKey)
+				got, err := registry.Get(userID).llm.SendMessage(ctx, "", "ping")
+				if err != nil {
+					errs <- err
+				} else if got != apiKey {
+					errs <- fmt.Errorf("%s sent key %q, want %q", userID, got, apiKey)
+				}
+				if i%10 == 0 {
+					registry.Invalidate(userID)
+				}
+			}
+		}(u)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if n := registry.Len(); n > 4 {
+		t.Errorf("registry holds %d assistants, capacity is 4", n)
+	}
+}
+
+func TestAssistantRegistryHoldsNoKey(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-server")
+	srv := echoKeyServer(t)
+	registry := newTestRegistry(10, srv.URL)
+
+	assistant := registry.Get("alice")
+	if key := assistant.llm.(*ClaudeClient).apiKey; key != "" {
+		t.Fatalf("cached assistant holds key %q", key)
+	}
+	if got := NewAssistantRegistry(1).Get("alice").llm.(*ClaudeClient).apiKey; got != "" {
+		t.Fatalf("default factory fell back to the server key %q", got)
+	}
+
+	if _, err := assistant.llm.SendMessage(context.Background(), "", "ping"); err == nil {
+		t.Fatal("expected a request without a key to fail")
+	}
+}
+
+func TestAssistantRegistryInvalidate(t *testing.T) {
+	registry := NewAssistantRegistry(10)
+
+	alice := registry.Get("alice")
+	if registry.Get("alice") != alice {
+		t.Fatal("expected cached assistant")
+	}
+
+	bob := registry.Get("bob")
+	registry.Invalidate("alice")
+
+	if registry.Get("bob") != bob {
+		t.Fatal("invalidating alice must not affect bob")
+	}
+	if registry.Get("alice") == alice {
+		t.Fatal("expected a fresh assistant after invalidation")
+	}
+}
+
+func TestAssistantRegistryEvictsLeastRecentlyUsed(t *testing.T) {
+	registry := NewAssistantRegistry(2)
+
+	a := registry.Get("a")
+	registry.Get("b")
+	registry.Get("a") // a is now most recently used
+	registry.Get("c") // evicts b
+
+	if registry.Len() != 2 {
+		t.Fatalf("expected 2 cached assistants, got %d", registry.Len())
+	}
+	if registry.Get("a") != a {
+		t.Fatal("expected a to survive eviction")
+	}
+}
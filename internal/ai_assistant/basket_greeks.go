@@ -0,0 +1,473 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/vibetrade"
+)
+
+// NetGreeks are aggregate Greeks in contract units; see GreeksLimits
+type NetGreeks struct {
+	Delta float64 `json:"delta"`
+	Gamma float64 `json:"gamma"`
+	Theta float64 `json:"theta"`
+	Vega  float64 `json:"vega"`
+}
+
+func (g NetGreeks) add(o NetGreeks) NetGreeks {
+	return NetGreeks{g.Delta + o.Delta, g.Gamma + o.Gamma, g.Theta + o.Theta, g.Vega + o.Vega}
+}
+
+func (g NetGreeks) scale(s float64) NetGreeks {
+	return NetGreeks{g.Delta * s, g.Gamma * s, g.Theta * s, g.Vega * s}
+}
+
+// TradeGreeks is one proposed trade's contribution to the basket
+type TradeGreeks struct {
+	Index    int       `json:"index"` // Position in the proposed basket
+	Ticker   string    `json:"ticker"`
+	Strategy string    `json:"strategy"`
+	Greeks   NetGreeks `json:"greeks"`
+}
+
+// GreekBreach is a net Greek outside its band, already scaled to NAV
+type GreekBreach struct {
+	RuleID string   `json:"rule_id"`
+	Greek  string   `json:"greek"`
+	Value  float64  `json:"value"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	Excess float64  `json:"excess"` // Distance outside the band
+}
+
+// GreeksAdjustment is a resize or drop of one proposed trade
+type GreeksAdjustment struct {
+	Index     int       `json:"index"`
+	Ticker    string    `json:"ticker"`
+	Strategy  string    `json:"strategy"`
+	Action    string    `json:"action"`              // "resize" or "drop"
+	Contracts float64   `json:"contracts,omitempty"` // New size when resizing
+	Scale     float64   `json:"scale"`               // Share of the trade kept
+	Net       NetGreeks `json:"net"`                 // Net Greeks after the change and, in a plan, those before it
+	Message   string    `json:"message"`
+}
+
+// BasketGreeks is the Greeks exposure of the existing portfolio plus a
+// proposed basket, checked against the policy's bands
+type BasketGreeks struct {
+	Existing    NetGreeks          `json:"existing"`
+	Proposed    NetGreeks          `json:"proposed"`
+	Net         NetGreeks          `json:"net"`
+	Trades      []TradeGreeks      `json:"trades"`
+	NAVScale    float64            `json:"nav_scale"` // NAV / 100k, applied to every band
+	Breaches    []GreekBreach      `json:"breaches,omitempty"`
+	Adjustments []GreeksAdjustment `json:"adjustments,omitempty"`
+	// AdjustmentsCombined is set when no single change fixes every breach
+	// and Adjustments is instead a plan whose changes apply together
+	AdjustmentsCombined bool     `json:"adjustments_combined,omitempty"`
+	Warnings            []string `json:"warnings,omitempty"`
+}
+
+// greekBands pairs each band with its rule and accessor
+var greekBands = []struct {
+	ruleID string
+	name   string
+	band   func(*GreeksLimits) GreekBand
+	value  func(NetGreeks) float64
+}{
+	{RuleNetDelta, "delta", func(l *GreeksLimits) GreekBand { return l.Delta }, func(g NetGreeks) float64 { return g.Delta }},
+	{RuleNetGamma, "gamma", func(l *GreeksLimits) GreekBand { return l.Gamma }, func(g NetGreeks) float64 { return g.Gamma }},
+	{RuleNetTheta, "theta", func(l *GreeksLimits) GreekBand { return l.Theta }, func(g NetGreeks) float64 { return g.Theta }},
+	{RuleNetVega, "vega", func(l *GreeksLimits) GreekBand { return l.Vega }, func(g NetGreeks) float64 { return g.Vega }},
+}
+
+// BasketGreeks aggregates the Greeks of the portfolio's holdings and of
+// the trades' structured legs, priced from the option chains passed to
+// WithMarketData. Legs missing from the chain are priced with
+// Black-Scholes. When a band is breached, Adjustments lists the single
+// trade resizes or drops that fix every breach, smallest change first, or
+// failing that a combined plan.
+func (rm *RiskManager) BasketGreeks(trades []TradeRecommendation, portfolio map[string]interface{}) *BasketGreeks {
+	now := rm.clock()
+	basket := &BasketGreeks{
+		Trades:   make([]TradeGreeks, 0, len(trades)),
+		NAVScale: portfolioValue(portfolio) / 100000,
+	}
+
+	basket.Existing, basket.Warnings = rm.existingGreeks(portfolio, now)
+	for i := range trades {
+		greeks, warnings := rm.tradeGreeks(&trades[i], now)
+		basket.Warnings = append(basket.Warnings, warnings...)
+		basket.Trades = append(basket.Trades, TradeGreeks{
+			Index:    i,
+			Ticker:   trades[i].Ticker,
+			Strategy: trades[i].Strategy,
+			Greeks:   greeks,
+		})
+		basket.Proposed = basket.Proposed.add(greeks)
+	}
+	basket.Net = basket.Existing.add(basket.Proposed)
+
+	basket.Breaches = rm.greekBreaches(basket.Net, basket.NAVScale)
+	if len(basket.Breaches) > 0 {
+		basket.Adjustments = rm.greeksAdjustments(basket, trades)
+		if len(basket.Adjustments) == 0 {
+			basket.Adjustments = rm.greeksPlan(basket, trades)
+			basket.AdjustmentsCombined = len(basket.Adjustments) > 0
+		}
+	}
+	return basket
+}
+
+// greekBreaches returns each enabled band that net falls outside of
+func (rm *RiskManager) greekBreaches(net NetGreeks, navScale float64) []GreekBreach {
+	var breaches []GreekBreach
+	for _, b := range greekBands {
+		if !rm.policy.RuleEnabled(b.ruleID) {
+			continue
+		}
+		lo, hi := scaledBand(b.band(&rm.policy.Greeks), navScale)
+		value := b.value(net)
+		excess := 0.0
+		if lo != nil && value < *lo {
+			excess = *lo - value
+		}
+		if hi != nil && value > *hi {
+			excess = value - *hi
+		}
+		if excess > 1e-9 {
+			breaches = append(breaches, GreekBreach{RuleID: b.ruleID, Greek: b.name, Value: value, Min: lo, Max: hi, Excess: excess})
+		}
+	}
+	return breaches
+}
+
+func scaledBand(band GreekBand, navScale float64) (*float64, *float64) {
+	var lo, hi *float64
+	if band.Min != nil {
+		lo = floatPtr(*band.Min * navScale)
+	}
+	if band.Max != nil {
+		hi = floatPtr(*band.Max * navScale)
+	}
+	return lo, hi
+}
+
+// greeksAdjustments finds, for each trade, the largest share of it that can
+// be kept with every band satisfied. Keeping some of it is a resize to whole
+// contracts; keeping none is a drop.
+func (rm *RiskManager) greeksAdjustments(basket *BasketGreeks, trades []TradeRecommendation) []GreeksAdjustment {
+	var adjustments []GreeksAdjustment
+	for _, tg := range basket.Trades {
+		others := basket.Net.add(tg.Greeks.scale(-1))
+
+		// Intersect the range of s in [0, 1] keeping others + s × trade in band
+		lo, hi := 0.0, 1.0
+		for _, b := range greekBands {
+			if !rm.policy.RuleEnabled(b.ruleID) {
+				continue
+			}
+			bandLo, bandHi := scaledBand(b.band(&rm.policy.Greeks), basket.NAVScale)
+			lo, hi = keepRange(b.value(others), b.value(tg.Greeks), bandLo, bandHi, lo, hi)
+		}
+		if lo > hi {
+			continue
+		}
+
+		trade := trades[tg.Index]
+		contracts := tradeContracts(trade)
+		label := fmt.Sprintf("%s %s (trade %d)", trade.Ticker, trade.Strategy, tg.Index+1)
+		adjustment := GreeksAdjustment{Index: tg.Index, Ticker: trade.Ticker, Strategy: trade.Strategy}
+
+		keep := math.Floor(hi*contracts+1e-9) / contracts
+		if hi < 1 && keep > 0 && keep >= lo-1e-9 {
+			adjustment.Action = "resize"
+			adjustment.Scale = keep
+			adjustment.Contracts = keep * contracts
+			adjustment.Message = fmt.Sprintf("Resize %s from %g to %g contracts", label, contracts, adjustment.Contracts)
+		} else if lo <= 0 {
+			adjustment.Action = "drop"
+			adjustment.Message = fmt.Sprintf("Drop %s", label)
+		} else {
+			continue
+		}
+		adjustment.Net = others.add(tg.Greeks.scale(adjustment.Scale))
+		adjustments = append(adjustments, adjustment)
+	}
+
+	sort.SliceStable(adjustments, func(i, j int) bool { return adjustments[i].Scale > adjustments[j].Scale })
+	return adjustments
+}
+
+// greeksPlan greedily changes one trade at a time, each time choosing the
+// resize or drop that leaves the least total excess outside the bands,
+// until every band is satisfied or no change helps
+func (rm *RiskManager) greeksPlan(basket *BasketGreeks, trades []TradeRecommendation) []GreeksAdjustment {
+	var plan []GreeksAdjustment
+	net := basket.Net
+	excess := totalExcess(rm.greekBreaches(net, basket.NAVScale))
+	changed := make(map[int]bool)
+
+	for excess > 1e-9 {
+		var best *GreeksAdjustment
+		bestExcess := excess
+		for _, tg := range basket.Trades {
+			if changed[tg.Index] {
+				continue
+			}
+			contracts := tradeContracts(trades[tg.Index])
+			for keep := contracts - 1; keep >= 0; keep-- {
+				scale := keep / contracts
+				candidate := net.add(tg.Greeks.scale(scale - 1))
+				if e := totalExcess(rm.greekBreaches(candidate, basket.NAVScale)); e < bestExcess-1e-9 {
+					best = &GreeksAdjustment{Index: tg.Index, Scale: scale, Contracts: keep, Net: candidate}
+					bestExcess = e
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+
+		trade := trades[best.Index]
+		label := fmt.Sprintf("%s %s (trade %d)", trade.Ticker, trade.Strategy, best.Index+1)
+		best.Ticker, best.Strategy = trade.Ticker, trade.Strategy
+		if best.Scale > 0 {
+			best.Action = "resize"
+			best.Message = fmt.Sprintf("Resize %s from %g to %g contracts", label, tradeContracts(trade), best.Contracts)
+		} else {
+			best.Action = "drop"
+			best.Contracts = 0
+			best.Message = fmt.Sprintf("Drop %s", label)
+		}
+		plan = append(plan, *best)
+		changed[best.Index] = true
+		net, excess = best.Net, bestExcess
+	}
+	return plan
+}
+
+func totalExcess(breaches []GreekBreach) float64 {
+	total := 0.0
+	for _, breach := range breaches {
+		total += breach.Excess
+	}
+	return total
+}
+
+// keepRange narrows [lo, hi] to the s for which base + s × delta lies
+// within [bandLo, bandHi]
+func keepRange(base, delta float64, bandLo, bandHi *float64, lo, hi float64) (float64, float64) {
+	limit := func(bound float64, atLeast bool) {
+		if delta == 0 {
+			if (atLeast && base < bound) || (!atLeast && base > bound) {
+				lo, hi = 1, 0 // Unreachable by resizing this trade
+			}
+			return
+		}
+		s := (bound - base) / delta
+		// base + s × delta >= bound holds above s when delta > 0, below it otherwise
+		if atLeast == (delta > 0) {
+			lo = math.Max(lo, s)
+		} else {
+			hi = math.Min(hi, s)
+		}
+	}
+	if bandLo != nil {
+		limit(*bandLo, true)
+	}
+	if bandHi != nil {
+		limit(*bandHi, false)
+	}
+	return lo, hi
+}
+
+// tradeContracts returns the trade's size as its smallest option leg
+// quantity times its Quantity, or its Quantity when there are no legs
+func tradeContracts(trade TradeRecommendation) float64 {
+	contracts := 0.0
+	for _, leg := range trade.LegDetails {
+		if leg.Type == PositionStock || leg.Quantity <= 0 {
+			continue
+		}
+		if contracts == 0 || leg.Quantity < contracts {
+			contracts = leg.Quantity
+		}
+	}
+	if contracts == 0 {
+		contracts = 1
+	}
+	return contracts * trade.units()
+}
+
+// tradeGreeks sums the Greeks of a trade's legs
+func (rm *RiskManager) tradeGreeks(trade *TradeRecommendation, now time.Time) (NetGreeks, []string) {
+	var total NetGreeks
+	var warnings []string
+	if len(trade.LegDetails) == 0 {
+		return total, []string{fmt.Sprintf("%s %s: no structured legs; Greeks not counted", trade.Ticker, trade.Strategy)}
+	}
+	for _, leg := range trade.LegDetails {
+		value, err := rm.valueLeg(trade.Ticker, leg, now)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s %s: %v", trade.Ticker, trade.Strategy, err))
+			continue
+		}
+		total = total.add(value.greeks.scale(leg.sign() * leg.size() / 100))
+	}
+	return total.scale(trade.units()), warnings
+}
+
+// legValue is the per-share price and Greeks of a leg
+type legValue struct {
+	price  float64
+	greeks NetGreeks
+}
+
+// valueLeg prices leg from the option chain when the contract is listed
+// there and with Black-Scholes otherwise
+func (rm *RiskManager) valueLeg(ticker string, leg OptionLeg, now time.Time) (legValue, error) {
+	if leg.Type == PositionStock {
+		return legValue{price: rm.spotPrice(ticker), greeks: NetGreeks{Delta: 1}}, nil
+	}
+	if leg.Type != PositionCall && leg.Type != PositionPut {
+		return legValue{}, fmt.Errorf("leg type %q is not call, put or stock", leg.Type)
+	}
+
+	var chains []*OptionChain
+	if rm.market != nil {
+		chains = rm.market.Options[strings.ToUpper(ticker)]
+	}
+
+	// The listed contract, or failing that the nearest strike of the same
+	// type, whose volatility and expiration stand in for the leg's
+	var listed, nearest *OptionChain
+	for _, chain := range chains {
+		if chain.Type != leg.Type {
+			continue
+		}
+		if math.Abs(chain.Strike-leg.Strike) < 0.005 && (leg.Expiration == "" || chain.Expiration == leg.Expiration) {
+			listed = chain
+		}
+		if nearest == nil || math.Abs(chain.Strike-leg.Strike) < math.Abs(nearest.Strike-leg.Strike) {
+			nearest = chain
+		}
+	}
+	var value legValue
+	if listed != nil {
+		value.price = listed.Last
+		if listed.Bid > 0 && listed.Ask > 0 {
+			value.price = (listed.Bid + listed.Ask) / 2
+		}
+		if listed.Greeks != nil {
+			value.greeks = NetGreeks{listed.Greeks.Delta, listed.Greeks.Gamma, listed.Greeks.Theta, listed.Greeks.Vega}
+			if value.price > 0 {
+				return value, nil
+			}
+		}
+	}
+
+	spot := rm.spotPrice(ticker)
+	if spot <= 0 {
+		if listed != nil && listed.Greeks != nil {
+			return value, nil
+		}
+		return legValue{}, fmt.Errorf("no price for %s to value the %g %s", ticker, leg.Strike, leg.Type)
+	}
+	vol := fallbackVolatility
+	expiration := leg.Expiration
+	if nearest != nil {
+		if nearest.IV > 0 {
+			vol = nearest.IV
+		}
+		if expiration == "" {
+			expiration = nearest.Expiration
+		}
+	}
+	expiry := now.AddDate(0, 0, 30)
+	if t, err := time.Parse("2006-01-02", expiration); err == nil {
+		expiry = t.Add(16 * time.Hour) // Options expire at the close
+	}
+	years := math.Max(expiry.Sub(now).Hours()/(24*365), 0)
+
+	if value.price <= 0 {
+		value.price = BlackScholesPrice(leg.Type, spot, leg.Strike, years, DefaultRiskFreeRate, vol)
+	}
+	if listed == nil || listed.Greeks == nil {
+		g := BlackScholesGreeks(leg.Type, spot, leg.Strike, years, DefaultRiskFreeRate, vol)
+		value.greeks = NetGreeks{g.Delta, g.Gamma, g.Theta, g.Vega}
+	}
+	return value, nil
+}
+
+// LegPrice returns the per-share price of leg: the mid of its listed
+// contract when there is one, a Black-Scholes value otherwise
+func (rm *RiskManager) LegPrice(ticker string, leg OptionLeg) (float64, error) {
+	value, err := rm.valueLeg(ticker, leg, rm.clock())
+	if err != nil {
+		return 0, err
+	}
+	if value.price <= 0 {
+		return 0, fmt.Errorf("no price for the %s %g %s", ticker, leg.Strike, leg.Type)
+	}
+	return value.price, nil
+}
+
+// spotPrice returns the latest quote for symbol, falling back to its last
+// daily close
+func (rm *RiskManager) spotPrice(symbol string) float64 {
+	symbol = strings.ToUpper(symbol)
+	if rm.market != nil {
+		if quote := rm.market.Quotes[symbol]; quote != nil && quote.Price > 0 {
+			return quote.Price
+		}
+	}
+	if bars := rm.history[symbol]; len(bars) > 0 {
+		return bars[len(bars)-1].Close
+	}
+	return 0
+}
+
+// existingGreeks sums the Greeks of the portfolio's stock positions and
+// option positions
+func (rm *RiskManager) existingGreeks(portfolio map[string]interface{}, now time.Time) (NetGreeks, []string) {
+	var maps []map[string]interface{}
+	if positions, ok := portfolio["positions"].([]map[string]interface{}); ok {
+		maps = append(maps, positions...)
+	}
+	if options, ok := portfolio["options"].([]vibetrade.OptionPosition); ok {
+		for _, option := range options {
+			maps = append(maps, map[string]interface{}{
+				"symbol":       option.Symbol,
+				"quantity":     option.Quantity.InexactFloat64(),
+				"market_value": option.MarketValue.InexactFloat64(),
+				"side":         option.Side,
+			})
+		}
+	}
+
+	positions, warnings := PositionsFromMaps(maps)
+	var options []*Position
+	var total NetGreeks
+	for _, p := range positions {
+		if !p.IsOption() {
+			total.Delta += p.Quantity * p.Multiplier / 100
+			continue
+		}
+		if p.UnderlyingPrice <= 0 {
+			p.UnderlyingPrice = rm.spotPrice(p.Underlying)
+		}
+		options = append(options, p)
+	}
+
+	warnings = append(warnings, PreparePositions(options, rm.history, now, DefaultRiskFreeRate)...)
+	for _, p := range options {
+		size := p.Quantity * p.Multiplier / 100
+		total = total.add(NetGreeks{p.Delta, p.Gamma, p.Theta, p.Vega}.scale(size))
+	}
+	return total, warnings
+}
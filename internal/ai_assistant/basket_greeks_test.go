@@ -0,0 +1,132 @@
+package ai_assistant
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const greeksExpiration = "2026-11-20"
+
+// newGreeksRiskManager enforces a net delta of at most maxDelta per $100k
+// against a SPY chain with a 500 call at 0.5 delta and a 490 put at -0.4
+func newGreeksRiskManager(maxDelta float64) *RiskManager {
+	policy := DefaultRiskPolicy()
+	policy.Greeks = GreeksLimits{Delta: GreekBand{Max: floatPtr(maxDelta)}}
+	market := &AggregatedMarketData{
+		Quotes: map[string]*Quote{"SPY": {Symbol: "SPY", Price: 500}},
+		Options: map[string][]*OptionChain{"SPY": {
+			{Symbol: "SPY", Strike: 500, Expiration: greeksExpiration, Type: PositionCall, Bid: 10, Ask: 10.5,
+				Greeks: &Greeks{Delta: 0.5, Gamma: 0.01, Theta: -0.2, Vega: 0.6}},
+			{Symbol: "SPY", Strike: 490, Expiration: greeksExpiration, Type: PositionPut, Bid: 7, Ask: 7.5,
+				Greeks: &Greeks{Delta: -0.4, Gamma: 0.01, Theta: -0.15, Vega: 0.5}},
+		}},
+	}
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	return NewRiskManagerWithPolicy(policy).WithMarketData(market).WithClock(func() time.Time { return now })
+}
+
+func longCalls(contracts float64) TradeRecommendation {
+	return TradeRecommendation{Ticker: "SPY", Strategy: "Long Call", LegDetails: []OptionLeg{
+		{Action: LegBuy, Type: PositionCall, Strike: 500, Expiration: greeksExpiration, Quantity: contracts},
+	}}
+}
+
+func shortPuts(contracts float64) TradeRecommendation {
+	return TradeRecommendation{Ticker: "SPY", Strategy: "Cash-Secured Put", LegDetails: []OptionLeg{
+		{Action: LegSell, Type: PositionPut, Strike: 490, Expiration: greeksExpiration, Quantity: contracts},
+	}}
+}
+
+func stockPortfolio(nav, shares float64) map[string]interface{} {
+	return map[string]interface{}{
+		"total_value": nav,
+		"positions":   []map[string]interface{}{{"symbol": "SPY", "quantity": shares, "type": "stock"}},
+	}
+}
+
+func TestBasketGreeksNetsHoldingsAndTrades(t *testing.T) {
+	rm := newGreeksRiskManager(2.5)
+	basket := rm.BasketGreeks([]TradeRecommendation{longCalls(2), shortPuts(1)}, stockPortfolio(200000, 200))
+
+	if basket.Existing.Delta != 2 {
+		t.Errorf("existing delta = %v, want 2 for 200 shares", basket.Existing.Delta)
+	}
+	want := NetGreeks{Delta: 1.4, Gamma: 0.01, Theta: -0.25, Vega: 0.7}
+	got := basket.Proposed
+	if math.Abs(got.Delta-want.Delta) > 1e-9 || math.Abs(got.Gamma-want.Gamma) > 1e-9 ||
+		math.Abs(got.Theta-want.Theta) > 1e-9 || math.Abs(got.Vega-want.Vega) > 1e-9 {
+		t.Errorf("proposed = %+v, want %+v", got, want)
+	}
+	if math.Abs(basket.Net.Delta-3.4) > 1e-9 || basket.NAVScale != 2 {
+		t.Errorf("net delta %v at scale %v, want 3.4 at 2", basket.Net.Delta, basket.NAVScale)
+	}
+
+	// The band scales with NAV: 2.5 per $100k allows 5 on $200k
+	if len(basket.Breaches) != 0 || len(basket.Adjustments) != 0 {
+		t.Errorf("breaches %+v, adjustments %+v, want none", basket.Breaches, basket.Adjustments)
+	}
+}
+
+func TestBasketGreeksSuggestsResizeOrDrop(t *testing.T) {
+	rm := newGreeksRiskManager(2.5)
+
+	// 200 shares and two calls is 3 deltas against a limit of 2.5; one
+	// call fits
+	basket := rm.BasketGreeks([]TradeRecommendation{longCalls(2)}, stockPortfolio(100000, 200))
+	if len(basket.Breaches) != 1 || basket.Breaches[0].RuleID != RuleNetDelta || math.Abs(basket.Breaches[0].Excess-0.5) > 1e-9 {
+		t.Fatalf("breaches = %+v, want delta 0.5 over", basket.Breaches)
+	}
+	if len(basket.Adjustments) != 1 {
+		t.Fatalf("adjustments = %+v, want one", basket.Adjustments)
+	}
+	resize := basket.Adjustments[0]
+	if resize.Action != "resize" || resize.Contracts != 1 || resize.Scale != 0.5 || math.Abs(resize.Net.Delta-2.5) > 1e-9 {
+		t.Errorf("adjustment = %+v, want a resize to 1 contract", resize)
+	}
+
+	// With the stock alone at the limit the calls have to go
+	basket = rm.BasketGreeks([]TradeRecommendation{longCalls(2)}, stockPortfolio(100000, 250))
+	if len(basket.Adjustments) != 1 || basket.Adjustments[0].Action != "drop" || basket.AdjustmentsCombined {
+		t.Errorf("adjustments = %+v, want the calls dropped", basket.Adjustments)
+	}
+}
+
+func TestBasketGreeksPlansCombinedChanges(t *testing.T) {
+	rm := newGreeksRiskManager(0.5)
+
+	// Calls add 1.0 and puts 0.8; neither alone brings 1.8 under 0.5
+	basket := rm.BasketGreeks([]TradeRecommendation{longCalls(2), shortPuts(2)}, map[string]interface{}{"total_value": 100000.0})
+	if !basket.AdjustmentsCombined || len(basket.Adjustments) != 2 {
+		t.Fatalf("adjustments = %+v, want a combined plan of two", basket.Adjustments)
+	}
+	drop, resize := basket.Adjustments[0], basket.Adjustments[1]
+	if drop.Index != 0 || drop.Action != "drop" {
+		t.Errorf("first change = %+v, want the calls dropped", drop)
+	}
+	if resize.Index != 1 || resize.Action != "resize" || resize.Contracts != 1 || math.Abs(resize.Net.Delta-0.4) > 1e-9 {
+		t.Errorf("second change = %+v, want the puts resized to 1", resize)
+	}
+}
+
+func TestBasketGreeksWarnsAboutUnpricedTrades(t *testing.T) {
+	rm := newGreeksRiskManager(2.5)
+	unstructured := TradeRecommendation{Ticker: "SPY", Strategy: "Iron Condor"}
+	unpriced := TradeRecommendation{Ticker: "QQQ", Strategy: "Long Call", LegDetails: []OptionLeg{
+		{Action: LegBuy, Type: PositionCall, Strike: 400, Expiration: greeksExpiration, Quantity: 1},
+	}}
+	// Off-chain strikes are valued with Black-Scholes at the nearest
+	// strike's expiration
+	offChain := TradeRecommendation{Ticker: "SPY", Strategy: "Long Call", LegDetails: []OptionLeg{
+		{Action: LegBuy, Type: PositionCall, Strike: 520, Quantity: 1},
+	}}
+
+	basket := rm.BasketGreeks([]TradeRecommendation{unstructured, unpriced, offChain}, map[string]interface{}{"total_value": 100000.0})
+	if len(basket.Warnings) != 2 || !strings.Contains(basket.Warnings[0], "no structured legs") || !strings.Contains(basket.Warnings[1], "no price for QQQ") {
+		t.Errorf("warnings = %v, want the unstructured and unpriced trades", basket.Warnings)
+	}
+	if delta := basket.Trades[2].Greeks.Delta; delta <= 0 || delta >= 0.5 {
+		t.Errorf("out-of-the-money call delta = %v, want between 0 and 0.5", delta)
+	}
+}
@@ -0,0 +1,103 @@
+package ai_assistant
+
+import "math"
+
+// DefaultRiskFreeRate is the annual rate used to price options when none is configured
+const DefaultRiskFreeRate = 0.04
+
+// BlackScholesPrice returns the Black-Scholes value of a European call or
+// put. At or past expiry, or with no volatility, it returns intrinsic value.
+func BlackScholesPrice(optionType string, spot, strike, years, rate, vol float64) float64 {
+	if years <= 0 || vol <= 0 || spot <= 0 || strike <= 0 {
+		return intrinsicValue(optionType, spot, strike)
+	}
+
+	d1, d2 := blackScholesD(spot, strike, years, rate, vol)
+	discount := strike * math.Exp(-rate*years)
+	if optionType == PositionPut {
+		return discount*normCDF(-d2) - spot*normCDF(-d1)
+	}
+	return spot*normCDF(d1) - discount*normCDF(d2)
+}
+
+// BlackScholesGreeks returns per-share delta, gamma, theta (per calendar
+// day) and vega (per vol point)
+func BlackScholesGreeks(optionType string, spot, strike, years, rate, vol float64) Greeks {
+	if years <= 0 || vol <= 0 || spot <= 0 || strike <= 0 {
+		delta := 0.0
+		if intrinsicValue(optionType, spot, strike) > 0 {
+			delta = 1
+			if optionType == PositionPut {
+				delta = -1
+			}
+		}
+		return Greeks{Delta: delta}
+	}
+
+	d1, d2 := blackScholesD(spot, strike, years, rate, vol)
+	sqrtT := math.Sqrt(years)
+	pdf := normPDF(d1)
+	discount := math.Exp(-rate * years)
+
+	greeks := Greeks{
+		Gamma: pdf / (spot * vol * sqrtT),
+		Vega:  spot * pdf * sqrtT / 100,
+	}
+	decay := -spot * pdf * vol / (2 * sqrtT)
+	if optionType == PositionPut {
+		greeks.Delta = normCDF(d1) - 1
+		greeks.Theta = (decay + rate*strike*discount*normCDF(-d2)) / 365
+		greeks.Rho = -strike * years * discount * normCDF(-d2) / 100
+	} else {
+		greeks.Delta = normCDF(d1)
+		greeks.Theta = (decay - rate*strike*discount*normCDF(d2)) / 365
+		greeks.Rho = strike * years * discount * normCDF(d2) / 100
+	}
+	return greeks
+}
+
+// ImpliedVolatility solves for the volatility that prices the option at
+// price, returning 0 if no volatility in (0.1%, 500%) does
+func ImpliedVolatility(optionType string, price, spot, strike, years, rate float64) float64 {
+	if years <= 0 || price <= intrinsicValue(optionType, spot, strike) {
+		return 0
+	}
+
+	low, high := 0.001, 5.0
+	if BlackScholesPrice(optionType, spot, strike, years, rate, high) < price {
+		return 0
+	}
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if BlackScholesPrice(optionType, spot, strike, years, rate, mid) < price {
+			low = mid
+		} else {
+			high = mid
+		}
+		if high-low < 1e-6 {
+			break
+		}
+	}
+	return (low + high) / 2
+}
+
+func blackScholesD(spot, strike, years, rate, vol float64) (float64, float64) {
+	sqrtT := math.Sqrt(years)
+	d1 := (math.Log(spot/strike) + (rate+vol*vol/2)*years) / (vol * sqrtT)
+	return d1, d1 - vol*sqrtT
+}
+
+func intrinsicValue(optionType string, spot, strike float64) float64 {
+	if optionType == PositionPut {
+		return math.Max(strike-spot, 0)
+	}
+	return math.Max(spot-strike, 0)
+}
+
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
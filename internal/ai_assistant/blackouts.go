@@ -0,0 +1,184 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/events"
+)
+
+// Blackout scopes select which trades a BlackoutRule blocks
+const (
+	BlackoutAll          = "all"
+	BlackoutShortPremium = "short_premium"
+	BlackoutShortCalls   = "short_calls"
+)
+
+// BlackoutRule blocks new trades near one kind of event. A trade is blocked
+// from DaysBefore the event, if it would still be open on the event date,
+// until DaysAfter it. With ThroughExpiration it is also blocked whenever it
+// would be held through the event, however far away.
+type BlackoutRule struct {
+	Event             string `json:"event" yaml:"event"` // events.KindEarnings, KindExDividend, KindFOMC or KindCPI
+	DaysBefore        int    `json:"days_before" yaml:"days_before"`
+	DaysAfter         int    `json:"days_after" yaml:"days_after"`
+	ThroughExpiration bool   `json:"through_expiration" yaml:"through_expiration"`
+	// Scope is BlackoutAll, BlackoutShortPremium or BlackoutShortCalls;
+	// Strategies, if set, narrows it to strategies containing one of them
+	Scope      string   `json:"scope" yaml:"scope"`
+	Strategies []string `json:"strategies,omitempty" yaml:"strategies,omitempty"`
+}
+
+// defaultHoldDays is how long a trade without an expiration is assumed to
+// be held, matching the 30 days used to value legs without one
+const defaultHoldDays = 30
+
+// shortPremiumNames mark a strategy as collecting premium when its legs
+// cannot be priced
+var shortPremiumNames = []string{"credit", "iron", "short", "naked", "covered", "cash", "wheel", "jade lizard"}
+
+// WithEvents sets the event calendar the blackout rules check against,
+// e.g. AggregatedMarketData.Calendar
+func (rm *RiskManager) WithEvents(calendar []events.Event) *RiskManager {
+	rm.events = calendar
+	return rm
+}
+
+// checkBlackouts adds a violation to validation for each blackout rule
+// that an event on trade's underlying, or a macro event, puts it inside
+func (rm *RiskManager) checkBlackouts(trade *TradeRecommendation, portfolio map[string]interface{}, validation *TradeValidation) {
+	if len(rm.events) == 0 || len(rm.policy.Blackouts) == 0 || !rm.policy.RuleEnabled(RuleEventBlackout) {
+		return
+	}
+
+	today := events.Today(rm.clock())
+	expiration, known := tradeExpiration(trade)
+	if !known {
+		expiration = today.AddDate(0, 0, defaultHoldDays)
+	}
+
+	for _, rule := range rm.policy.Blackouts {
+		if !rm.blackoutApplies(rule, trade, portfolio) {
+			continue
+		}
+		for _, event := range rm.events {
+			if event.Kind != rule.Event || (!event.IsMacro() && !strings.EqualFold(event.Symbol, trade.Ticker)) {
+				continue
+			}
+			daysAway := events.DaysBetween(today, event.Date)
+			heldThrough := daysAway >= 0 && !expiration.Before(event.Date)
+
+			var reason string
+			switch {
+			case daysAway < 0 && -daysAway <= rule.DaysAfter:
+				reason = fmt.Sprintf("was %s ago", dayCount(-daysAway))
+			case daysAway >= 0 && daysAway <= rule.DaysBefore && heldThrough:
+				reason = "is " + daysUntil(daysAway)
+			case rule.ThroughExpiration && heldThrough:
+				reason = fmt.Sprintf("is %s, before the %s expiration", daysUntil(daysAway), expiration.Format("2006-01-02"))
+			default:
+				continue
+			}
+			validation.addViolation(RuleEventBlackout,
+				fmt.Sprintf("%s blackout for %s: %s on %s %s", eventName(event), trade.Ticker, eventName(event), event.Date.Format("2006-01-02"), reason))
+			break
+		}
+	}
+}
+
+// blackoutApplies reports whether rule's scope and strategies cover trade
+func (rm *RiskManager) blackoutApplies(rule BlackoutRule, trade *TradeRecommendation, portfolio map[string]interface{}) bool {
+	if len(rule.Strategies) > 0 {
+		name := normalizeStrategyName(trade.Strategy)
+		matched := false
+		for _, strategy := range rule.Strategies {
+			if strings.Contains(name, normalizeStrategyName(strategy)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	switch rule.Scope {
+	case BlackoutShortPremium:
+		return rm.sellsPremium(trade, portfolio)
+	case BlackoutShortCalls:
+		return sellsCalls(trade)
+	}
+	return true
+}
+
+// sellsPremium reports whether trade is opened for a net credit, judged
+// from its priced legs or, failing that, its strategy name
+func (rm *RiskManager) sellsPremium(trade *TradeRecommendation, portfolio map[string]interface{}) bool {
+	if len(trade.LegDetails) > 0 {
+		if premium := rm.MarginRequirement(trade, portfolio).Premium; premium != 0 {
+			return premium > 0
+		}
+	}
+	name := normalizeStrategyName(trade.Strategy)
+	for _, marker := range shortPremiumNames {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// sellsCalls reports whether trade has a short call leg
+func sellsCalls(trade *TradeRecommendation) bool {
+	if len(trade.LegDetails) == 0 {
+		name := normalizeStrategyName(trade.Strategy)
+		return strings.Contains(name, "call") && (strings.Contains(name, "covered") || strings.Contains(name, "short") ||
+			strings.Contains(name, "naked") || strings.Contains(name, "credit") || strings.Contains(name, "bear"))
+	}
+	for _, leg := range trade.LegDetails {
+		if leg.Type == PositionCall && leg.Action == LegSell {
+			return true
+		}
+	}
+	return false
+}
+
+// tradeExpiration returns the latest expiration among trade's legs
+func tradeExpiration(trade *TradeRecommendation) (time.Time, bool) {
+	var latest time.Time
+	for _, leg := range trade.LegDetails {
+		if t, err := time.Parse("2006-01-02", leg.Expiration); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+func eventName(e events.Event) string {
+	switch e.Kind {
+	case events.KindEarnings:
+		return "earnings"
+	case events.KindExDividend:
+		return "ex-dividend"
+	case events.KindFOMC:
+		return "FOMC"
+	case events.KindCPI:
+		return "CPI"
+	}
+	return e.Kind
+}
+
+func dayCount(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", n)
+}
+
+func daysUntil(n int) string {
+	if n == 0 {
+		return "today"
+	}
+	return "in " + dayCount(n)
+}
@@ -0,0 +1,117 @@
+package ai_assistant
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"vibetrade-claude/internal/events"
+)
+
+// blackoutToday is the date the blackout tests are checked on
+var blackoutToday = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// blackoutViolations returns the blackout violations the default policy
+// finds for trade against calendar
+func blackoutViolations(calendar []events.Event, trade *TradeRecommendation) []string {
+	rm := NewRiskManager().
+		WithClock(func() time.Time { return blackoutToday.Add(15 * time.Hour) }).
+		WithEvents(calendar)
+	validation := &TradeValidation{IsValid: true}
+	rm.checkBlackouts(trade, map[string]interface{}{"total_value": 100000.0}, validation)
+	return validation.Violations
+}
+
+func event(symbol, kind string, daysAway int) events.Event {
+	return events.Event{Symbol: symbol, Kind: kind, Date: blackoutToday.AddDate(0, 0, daysAway)}
+}
+
+// putSpread is a bull put spread on ticker expiring days from today
+func putSpread(ticker string, days int) *TradeRecommendation {
+	expiration := blackoutToday.AddDate(0, 0, days).Format("2006-01-02")
+	return &TradeRecommendation{Ticker: ticker, Strategy: "Bull Put Spread", LegDetails: []OptionLeg{
+		{Action: LegSell, Type: PositionPut, Strike: 95, Expiration: expiration, Quantity: 1},
+		{Action: LegBuy, Type: PositionPut, Strike: 90, Expiration: expiration, Quantity: 1},
+	}}
+}
+
+func TestEarningsBlackoutWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		daysAway  int
+		expiresIn int
+		reason    string // empty when the trade is allowed
+	}{
+		{"inside the week before", 5, 30, "is in 5 days"},
+		{"today", 0, 30, "is today"},
+		{"expires before earnings", 5, 3, ""},
+		{"held through distant earnings", 25, 30, "is in 25 days, before the"},
+		{"expires before distant earnings", 25, 20, ""},
+		{"day after", -1, 30, "was 1 day ago"},
+		{"two days after", -2, 30, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := blackoutViolations([]events.Event{event("XYZ", events.KindEarnings, tt.daysAway)}, putSpread("XYZ", tt.expiresIn))
+			if tt.reason == "" {
+				if len(violations) != 0 {
+					t.Fatalf("violations = %v, want none", violations)
+				}
+				return
+			}
+			if len(violations) != 1 || !strings.Contains(violations[0], "earnings blackout for XYZ") || !strings.Contains(violations[0], tt.reason) {
+				t.Fatalf("violations = %v, want one earnings blackout that %s", violations, tt.reason)
+			}
+		})
+	}
+}
+
+func TestBlackoutOnlyMatchesTheTradesUnderlying(t *testing.T) {
+	if violations := blackoutViolations([]events.Event{event("ABC", events.KindEarnings, 2)}, putSpread("XYZ", 30)); len(violations) != 0 {
+		t.Errorf("another symbol's earnings: violations = %v, want none", violations)
+	}
+
+	// Macro events have no symbol and apply to everything
+	violations := blackoutViolations([]events.Event{event("", events.KindFOMC, 1)}, putSpread("XYZ", 30))
+	if len(violations) != 1 || !strings.Contains(violations[0], "FOMC blackout") {
+		t.Errorf("FOMC tomorrow: violations = %v, want an FOMC blackout", violations)
+	}
+	if violations := blackoutViolations([]events.Event{event("", events.KindCPI, 2)}, putSpread("XYZ", 30)); len(violations) != 0 {
+		t.Errorf("CPI in 2 days: violations = %v, want none", violations)
+	}
+}
+
+func TestBlackoutScopes(t *testing.T) {
+	expiration := blackoutToday.AddDate(0, 0, 30).Format("2006-01-02")
+	longCall := &TradeRecommendation{Ticker: "XYZ", Strategy: "Long Call", LegDetails: []OptionLeg{
+		{Action: LegBuy, Type: PositionCall, Strike: 100, Expiration: expiration, Quantity: 1},
+	}}
+	coveredCall := &TradeRecommendation{Ticker: "XYZ", Strategy: "Covered Call", LegDetails: []OptionLeg{
+		{Action: LegSell, Type: PositionCall, Strike: 110, Expiration: expiration, Quantity: 1},
+	}}
+
+	earnings := []events.Event{event("XYZ", events.KindEarnings, 3)}
+	if violations := blackoutViolations(earnings, longCall); len(violations) != 0 {
+		t.Errorf("long call into earnings: violations = %v, want none", violations)
+	}
+
+	// Ex-dividend blackouts only cover short calls
+	exDividend := []events.Event{event("XYZ", events.KindExDividend, 3)}
+	if violations := blackoutViolations(exDividend, coveredCall); len(violations) != 1 || !strings.Contains(violations[0], "ex-dividend") {
+		t.Errorf("covered call before ex-dividend: violations = %v, want an ex-dividend blackout", violations)
+	}
+	if violations := blackoutViolations(exDividend, putSpread("XYZ", 30)); len(violations) != 0 {
+		t.Errorf("put spread before ex-dividend: violations = %v, want none", violations)
+	}
+}
+
+func TestBlackoutWithoutExpirationAssumesThirtyDayHold(t *testing.T) {
+	trade := &TradeRecommendation{Ticker: "XYZ", Strategy: "Iron Condor"}
+	if violations := blackoutViolations([]events.Event{event("XYZ", events.KindEarnings, 20)}, trade); len(violations) != 1 {
+		t.Errorf("earnings in 20 days: violations = %v, want the trade held through them", violations)
+	}
+	if violations := blackoutViolations([]events.Event{event("XYZ", events.KindEarnings, 40)}, trade); len(violations) != 0 {
+		t.Errorf("earnings in 40 days: violations = %v, want none", violations)
+	}
+}
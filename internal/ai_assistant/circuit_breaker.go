@@ -0,0 +1,366 @@
+package ai_assistant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"vibetrade-claude/internal/vibetrade"
+)
+
+// Halt reasons reported by CircuitBreaker.Check
+const (
+	HaltKillSwitch = "kill_switch"
+	HaltDailyLoss  = "daily_loss_limit"
+)
+
+// HaltError is returned by CircuitBreaker.Check when AI-driven trading is
+// blocked for a user
+type HaltError struct {
+	Reason  string // HaltKillSwitch or HaltDailyLoss
+	Message string
+	Since   time.Time
+}
+
+func (e *HaltError) Error() string {
+	return e.Message
+}
+
+// IsHalted reports whether err is a HaltError
+func IsHalted(err error) bool {
+	var herr *HaltError
+	return errors.As(err, &herr)
+}
+
+// KillSwitch is the global halt engaged by an administrator
+type KillSwitch struct {
+	Engaged   bool      `json:"engaged"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// DailyPnL is one user's profit and loss for the current session. Unrealized
+// P&L is measured against the first reading of the session, so positions
+// carried overnight only count for today's move.
+type DailyPnL struct {
+	Session           string     `json:"session"` // Trading date, America/New_York
+	AccountValue      float64    `json:"account_value"`
+	RealizedPnL       float64    `json:"realized_pnl"`
+	OpeningUnrealized float64    `json:"opening_unrealized"`
+	BaselineAt        *time.Time `json:"baseline_at,omitempty"` // When OpeningUnrealized was taken
+	UnrealizedPnL     float64    `json:"unrealized_pnl"`
+	LossLimitPercent  float64    `json:"loss_limit_percent"`
+	Tripped           bool       `json:"tripped"`
+	TrippedAt         *time.Time `json:"tripped_at,omitempty"`
+	TripReason        string     `json:"trip_reason,omitempty"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// Total returns today's combined realized and unrealized P&L
+func (d *DailyPnL) Total() float64 {
+	return d.RealizedPnL + d.UnrealizedPnL - d.OpeningUnrealized
+}
+
+// LossPercent returns today's loss as a percentage of account value, or 0
+// if the account is flat or up
+func (d *DailyPnL) LossPercent() float64 {
+	total := d.Total()
+	if total >= 0 || d.AccountValue <= 0 {
+		return 0
+	}
+	return -total / d.AccountValue * 100
+}
+
+// BreakerStatus is the halt state that applies to one user
+type BreakerStatus struct {
+	KillSwitch KillSwitch `json:"kill_switch"`
+	Daily      *DailyPnL  `json:"daily,omitempty"`
+	Halted     bool       `json:"halted"`
+	Reason     string     `json:"reason,omitempty"`
+}
+
+type breakerState struct {
+	KillSwitch KillSwitch           `json:"kill_switch"`
+	Users      map[string]*DailyPnL `json:"users"`
+}
+
+// SessionDefaults returns the account value and daily loss limit, as a
+// percent, to assume for userID's session until a portfolio refresh
+// records them
+type SessionDefaults func(userID string) (accountValue, limitPercent float64)
+
+// CircuitBreaker tracks each user's daily P&L and halts AI-driven trading
+// when the daily loss limit is breached or the global kill switch is engaged.
+// A tripped breaker stays tripped until the next session or a manual reset.
+// State is persisted to a JSON file so halts survive restarts.
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	path     string
+	location *time.Location
+	now      func() time.Time
+	defaults SessionDefaults
+	state    breakerState
+}
+
+// NewCircuitBreaker loads breaker state from path, starting clean if the
+// file does not exist. An empty path keeps state in memory only.
+func NewCircuitBreaker(path string) (*CircuitBreaker, error) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		location = time.UTC
+	}
+
+	cb := &CircuitBreaker{
+		path:     path,
+		location: location,
+		now:      time.Now,
+		state:    breakerState{Users: make(map[string]*DailyPnL)},
+	}
+
+	if path == "" {
+		return cb, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cb, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read circuit breaker state: %w", err)
+	}
+	if err := json.Unmarshal(data, &cb.state); err != nil {
+		return nil, fmt.Errorf("failed to parse circuit breaker state: %w", err)
+	}
+	if cb.state.Users == nil {
+		cb.state.Users = make(map[string]*DailyPnL)
+	}
+
+	return cb, nil
+}
+
+// Check returns a HaltError if the kill switch is engaged or the user's
+// breaker has tripped this session
+func (cb *CircuitBreaker) Check(userID string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if ks := cb.state.KillSwitch; ks.Engaged {
+		message := "AI trading is halted by an administrator"
+		if ks.Reason != "" {
+			message += ": " + ks.Reason
+		}
+		return &HaltError{Reason: HaltKillSwitch, Message: message, Since: ks.ChangedAt}
+	}
+
+	daily := cb.session(userID)
+	if daily != nil && daily.Tripped {
+		return &HaltError{Reason: HaltDailyLoss, Message: daily.TripReason, Since: *daily.TrippedAt}
+	}
+
+	return nil
+}
+
+// UpdatePositions records the user's current account value and option
+// positions, then trips the breaker if today's loss reaches limitPercent
+func (cb *CircuitBreaker) UpdatePositions(userID string, accountValue, limitPercent float64, positions []vibetrade.OptionPosition) (*DailyPnL, error) {
+	unrealized := 0.0
+	for _, position := range positions {
+		unrealized += position.UnrealizedPnL.InexactFloat64()
+	}
+	return cb.UpdateUnrealized(userID, accountValue, limitPercent, unrealized)
+}
+
+// UpdateUnrealized records the user's account value and total unrealized
+// P&L, then trips the breaker if today's loss reaches limitPercent
+func (cb *CircuitBreaker) UpdateUnrealized(userID string, accountValue, limitPercent, unrealized float64) (*DailyPnL, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	daily := cb.sessionOrNew(userID)
+	if daily.BaselineAt == nil {
+		now := cb.now()
+		daily.OpeningUnrealized = unrealized
+		daily.BaselineAt = &now
+	}
+	daily.AccountValue = accountValue
+	daily.UnrealizedPnL = unrealized
+	daily.LossLimitPercent = limitPercent
+	cb.evaluate(daily)
+
+	return cb.snapshot(daily), cb.save()
+}
+
+// RecordRealizedPnL adds a realized gain or loss, e.g. from a closing fill,
+// to the user's session and re-evaluates the breaker. accountValue is the
+// account's value if the caller knows it, or 0; a session without one
+// takes the account value and loss limit from the session defaults.
+func (cb *CircuitBreaker) RecordRealizedPnL(userID string, accountValue, amount float64) (*DailyPnL, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	daily := cb.sessionOrNew(userID)
+	if accountValue > 0 {
+		daily.AccountValue = accountValue
+	}
+	if (daily.AccountValue <= 0 || daily.LossLimitPercent <= 0) && cb.defaults != nil {
+		value, limit := cb.defaults(userID)
+		if daily.AccountValue <= 0 {
+			daily.AccountValue = value
+		}
+		if daily.LossLimitPercent <= 0 {
+			daily.LossLimitPercent = limit
+		}
+	}
+	daily.RealizedPnL += amount
+	cb.evaluate(daily)
+
+	return cb.snapshot(daily), cb.save()
+}
+
+// SetSessionDefaults sets where a session that starts with a realized P&L
+// report, before any portfolio refresh, gets its account value and loss
+// limit. defaults is called with the breaker locked.
+func (cb *CircuitBreaker) SetSessionDefaults(defaults SessionDefaults) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.defaults = defaults
+}
+
+// Reset clears a tripped breaker for the rest of the session. Today's P&L is
+// re-baselined at the current level, so the breaker only trips again on a
+// further loss of the full limit.
+func (cb *CircuitBreaker) Reset(userID string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	daily := cb.session(userID)
+	if daily == nil {
+		return nil
+	}
+	now := cb.now()
+	daily.RealizedPnL = 0
+	daily.OpeningUnrealized = daily.UnrealizedPnL
+	daily.BaselineAt = &now
+	daily.Tripped = false
+	daily.TrippedAt = nil
+	daily.TripReason = ""
+	daily.UpdatedAt = now
+
+	return cb.save()
+}
+
+// SetKillSwitch engages or releases the global kill switch
+func (cb *CircuitBreaker) SetKillSwitch(engaged bool, actor, reason string) (KillSwitch, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.state.KillSwitch = KillSwitch{
+		Engaged:   engaged,
+		Reason:    reason,
+		ChangedBy: actor,
+		ChangedAt: cb.now(),
+	}
+
+	return cb.state.KillSwitch, cb.save()
+}
+
+// KillSwitch returns the current kill switch state
+func (cb *CircuitBreaker) KillSwitch() KillSwitch {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state.KillSwitch
+}
+
+// Status returns the halt state that applies to userID
+func (cb *CircuitBreaker) Status(userID string) BreakerStatus {
+	cb.mu.Lock()
+	status := BreakerStatus{KillSwitch: cb.state.KillSwitch}
+	if daily := cb.session(userID); daily != nil {
+		status.Daily = cb.snapshot(daily)
+	}
+	cb.mu.Unlock()
+
+	var herr *HaltError
+	if errors.As(cb.Check(userID), &herr) {
+		status.Halted = true
+		status.Reason = herr.Reason
+	}
+	return status
+}
+
+// session returns the user's P&L for the current session, or nil if there
+// is none yet. A record from an earlier session is discarded, which is what
+// re-arms a tripped breaker at the next session.
+func (cb *CircuitBreaker) session(userID string) *DailyPnL {
+	daily := cb.state.Users[userID]
+	if daily == nil {
+		return nil
+	}
+	if daily.Session != cb.sessionDate() {
+		delete(cb.state.Users, userID)
+		return nil
+	}
+	return daily
+}
+
+func (cb *CircuitBreaker) sessionOrNew(userID string) *DailyPnL {
+	daily := cb.session(userID)
+	if daily == nil {
+		daily = &DailyPnL{Session: cb.sessionDate()}
+		cb.state.Users[userID] = daily
+	}
+	return daily
+}
+
+func (cb *CircuitBreaker) sessionDate() string {
+	return cb.now().In(cb.location).Format("2006-01-02")
+}
+
+func (cb *CircuitBreaker) evaluate(daily *DailyPnL) {
+	now := cb.now()
+	daily.UpdatedAt = now
+
+	if daily.Tripped || daily.LossLimitPercent <= 0 {
+		return
+	}
+	if loss := daily.LossPercent(); loss >= daily.LossLimitPercent {
+		daily.Tripped = true
+		daily.TrippedAt = &now
+		daily.TripReason = fmt.Sprintf("Daily loss %.2f%% reached limit %.2f%%; trading resumes next session",
+			loss, daily.LossLimitPercent)
+	}
+}
+
+func (cb *CircuitBreaker) snapshot(daily *DailyPnL) *DailyPnL {
+	cp := *daily
+	return &cp
+}
+
+// save writes state atomically; callers hold cb.mu
+func (cb *CircuitBreaker) save() error {
+	if cb.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(cb.state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cb.path), 0700); err != nil {
+		return fmt.Errorf("failed to create circuit breaker directory: %w", err)
+	}
+	tmp := cb.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write circuit breaker state: %w", err)
+	}
+	if err := os.Rename(tmp, cb.path); err != nil {
+		return fmt.Errorf("failed to replace circuit breaker state: %w", err)
+	}
+	return nil
+}
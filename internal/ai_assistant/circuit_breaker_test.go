@@ -0,0 +1,138 @@
+package ai_assistant
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(t *testing.T, path string) (*CircuitBreaker, *time.Time) {
+	t.Helper()
+	cb, err := NewCircuitBreaker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	cb.now = func() time.Time { return now }
+	return cb, &now
+}
+
+func haltReason(err error) string {
+	if herr, ok := err.(*HaltError); ok {
+		return herr.Reason
+	}
+	return ""
+}
+
+func TestRealizedLossAloneTripsBreaker(t *testing.T) {
+	cb, _ := newTestBreaker(t, "")
+	cb.SetSessionDefaults(func(userID string) (float64, float64) { return 100000, 2 })
+
+	daily, err := cb.RecordRealizedPnL("alice", 0, -1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daily.AccountValue != 100000 || daily.Tripped {
+		t.Fatalf("after a 1.5%% loss: account value %v, tripped %v; want 100000 and not tripped", daily.AccountValue, daily.Tripped)
+	}
+
+	if daily, _ = cb.RecordRealizedPnL("alice", 0, -600); !daily.Tripped {
+		t.Fatalf("after a 2.1%% loss: loss %.2f%%, want tripped", daily.LossPercent())
+	}
+	if reason := haltReason(cb.Check("alice")); reason != HaltDailyLoss {
+		t.Errorf("alice halted for %q, want %q", reason, HaltDailyLoss)
+	}
+	if err := cb.Check("bob"); err != nil {
+		t.Errorf("bob halted: %v", err)
+	}
+}
+
+func TestRealizedLossUsesReportedAccountValue(t *testing.T) {
+	cb, _ := newTestBreaker(t, "")
+	cb.SetSessionDefaults(func(userID string) (float64, float64) { return 100000, 2 })
+
+	// 300 is 3% of a $10,000 paper account, but 0.3% of the default
+	daily, err := cb.RecordRealizedPnL("alice", 10000, -300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !daily.Tripped {
+		t.Errorf("loss %.2f%% of $%.0f, want tripped", daily.LossPercent(), daily.AccountValue)
+	}
+}
+
+func TestBreakerMeasuresUnrealizedFromSessionBaseline(t *testing.T) {
+	cb, now := newTestBreaker(t, "")
+
+	// Positions carried in down $5,000 only count for today's move
+	if daily, _ := cb.UpdateUnrealized("alice", 100000, 2, -5000); daily.Tripped || daily.Total() != 0 {
+		t.Fatalf("opening reading: total %v, tripped %v; want 0 and not tripped", daily.Total(), daily.Tripped)
+	}
+	if daily, _ := cb.UpdateUnrealized("alice", 98500, 2, -6500); daily.Tripped {
+		t.Fatalf("1.5%% loss tripped the breaker")
+	}
+	daily, _ := cb.UpdateUnrealized("alice", 97000, 2, -8000)
+	if !daily.Tripped {
+		t.Fatalf("loss %.2f%%, want tripped at 2%%", daily.LossPercent())
+	}
+
+	// A tripped breaker stays tripped through a recovery, until the next
+	// session
+	if daily, _ = cb.UpdateUnrealized("alice", 100000, 2, -5000); !daily.Tripped {
+		t.Error("breaker re-armed by a recovery within the session")
+	}
+	*now = now.Add(24 * time.Hour)
+	if err := cb.Check("alice"); err != nil {
+		t.Errorf("next session: %v, want re-armed", err)
+	}
+}
+
+func TestBreakerResetRebaselines(t *testing.T) {
+	cb, _ := newTestBreaker(t, "")
+	if daily, _ := cb.RecordRealizedPnL("alice", 100000, -2500); daily.Tripped {
+		t.Fatal("tripped without a loss limit")
+	}
+	cb.UpdateUnrealized("alice", 97500, 2, 0)
+	if err := cb.Check("alice"); haltReason(err) != HaltDailyLoss {
+		t.Fatalf("check = %v, want a daily loss halt", err)
+	}
+
+	if err := cb.Reset("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cb.Check("alice"); err != nil {
+		t.Fatalf("after reset: %v", err)
+	}
+	// Only a further loss of the full limit trips it again
+	if daily, _ := cb.RecordRealizedPnL("alice", 0, -1000); daily.Tripped {
+		t.Errorf("tripped again on a 1%% loss after reset")
+	}
+}
+
+func TestKillSwitchHaltsEveryoneAndPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breaker.json")
+	cb, _ := newTestBreaker(t, path)
+	if _, err := cb.SetKillSwitch(true, "admin", "market turmoil"); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []string{"alice", "bob"} {
+		if reason := haltReason(cb.Check(user)); reason != HaltKillSwitch {
+			t.Errorf("%s halted for %q, want %q", user, reason, HaltKillSwitch)
+		}
+	}
+
+	reloaded, _ := newTestBreaker(t, path)
+	if ks := reloaded.KillSwitch(); !ks.Engaged || ks.ChangedBy != "admin" || ks.Reason != "market turmoil" {
+		t.Fatalf("reloaded kill switch = %+v, want engaged by admin", ks)
+	}
+	if status := reloaded.Status("alice"); !status.Halted || status.Reason != HaltKillSwitch {
+		t.Errorf("status = %+v, want halted by the kill switch", status)
+	}
+
+	if _, err := reloaded.SetKillSwitch(false, "admin", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Check("alice"); err != nil {
+		t.Errorf("after release: %v", err)
+	}
+}
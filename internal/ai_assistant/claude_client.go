@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -41,11 +42,25 @@ type ClaudeResponse struct {
 	StopReason string `json:"stop_reason"`
 }
 
+type apiKeyContextKey struct{}
+
+// WithAPIKey attaches apiKey to ctx for a single request. It takes precedence
+// over the client's own key, and is the only key a client from an
+// AssistantRegistry will use.
+func WithAPIKey(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+}
+
 func NewClaudeClient(apiKey string) *ClaudeClient {
 	if apiKey == "" {
 		apiKey = os.Getenv("ANTHROPIC_API_KEY")
 	}
-	
+
+	return newClaudeClient(apiKey)
+}
+
+// newClaudeClient creates a client without the ANTHROPIC_API_KEY fallback
+func newClaudeClient(apiKey string) *ClaudeClient {
 	return &ClaudeClient{
 		apiKey: apiKey,
 		httpClient: &http.Client{
@@ -55,59 +70,150 @@ func NewClaudeClient(apiKey string) *ClaudeClient {
 	}
 }
 
-func (c *ClaudeClient) SendMessage(ctx context.Context, systemPrompt string, userMessage string) (string, error) {
-	messages := []ClaudeMessage{
-		{
-			Role:    "user",
-			Content: userMessage,
-		},
+// key returns the API key for a request made with ctx
+func (c *ClaudeClient) key(ctx context.Context) (string, error) {
+	if apiKey, _ := ctx.Value(apiKeyContextKey{}).(string); apiKey != "" {
+		return apiKey, nil
+	}
+	if c.apiKey != "" {
+		return c.apiKey, nil
 	}
+	return "", fmt.Errorf("no API key for request")
+}
 
+func (c *ClaudeClient) SendMessage(ctx context.Context, systemPrompt string, userMessage string) (string, error) {
 	request := ClaudeRequest{
-		Model:       "claude-3-opus-20240229",
-		Messages:    messages,
+		Model: "claude-3-opus-20240229",
+		Messages: []ClaudeMessage{
+			{
+				Role:    "user",
+				Content: userMessage,
+			},
+		},
 		MaxTokens:   4096,
 		Temperature: 0.7,
 		System:      systemPrompt,
 	}
 
+	status, body, err := c.post(ctx, request)
+	if err != nil {
+		return "", err
+	}
+
+	if status != http.StatusOK {
+		return "", fmt.Errorf("API error (status %d): %s", status, string(body))
+	}
+
+	var claudeResp ClaudeResponse
+	if err := json.Unmarshal(body, &claudeResp); err != nil {
+		return "", fmt.Errorf("error parsing response: %w", err)
+	}
+
+	if len(claudeResp.Content) > 0 {
+		return claudeResp.Content[0].Text, nil
+	}
+
+	return "", fmt.Errorf("no content in response")
+}
+
+// post sends request to the messages API with the key for ctx and returns
+// the response status and body
+func (c *ClaudeClient) post(ctx context.Context, request ClaudeRequest) (int, []byte, error) {
+	apiKey, err := c.key(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
 	jsonData, err := json.Marshal(request)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %w", err)
+		return 0, nil, fmt.Errorf("error marshaling request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return 0, nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("x-api-key", apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error sending request: %w", err)
+		return 0, nil, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading response: %w", err)
+		return 0, nil, fmt.Errorf("error reading response: %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	return resp.StatusCode, body, nil
+}
+
+// KeyHealth classifies the result of probing an API key
+type KeyHealth string
+
+const (
+	KeyHealthValid   KeyHealth = "valid"
+	KeyHealthInvalid KeyHealth = "invalid"
+	KeyHealthNoQuota KeyHealth = "no_quota"
+	KeyHealthRevoked KeyHealth = "revoked" // previously valid, now rejected
+)
+
+// KeyValidation is the outcome of ValidateKey
+type KeyValidation struct {
+	Health    KeyHealth `json:"health"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type claudeErrorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ValidateKey makes the cheapest possible authenticated request (a one-token
+// completion on the smallest model) and classifies the key. An error is
+// returned only when the probe itself could not reach a verdict, e.g. on
+// network failures or API outages.
+func (c *ClaudeClient) ValidateKey(ctx context.Context) (*KeyValidation, error) {
+	request := ClaudeRequest{
+		Model:     "claude-3-haiku-20240307",
+		Messages:  []ClaudeMessage{{Role: "user", Content: "ping"}},
+		MaxTokens: 1,
 	}
 
-	var claudeResp ClaudeResponse
-	if err := json.Unmarshal(body, &claudeResp); err != nil {
-		return "", fmt.Errorf("error parsing response: %w", err)
+	status, body, err := c.post(ctx, request)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(claudeResp.Content) > 0 {
-		return claudeResp.Content[0].Text, nil
+	validation := &KeyValidation{CheckedAt: time.Now()}
+
+	var apiErr claudeErrorResponse
+	json.Unmarshal(body, &apiErr)
+
+	switch {
+	case status == http.StatusOK:
+		validation.Health = KeyHealthValid
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		validation.Health = KeyHealthInvalid
+		validation.Error = apiErr.Error.Message
+	case status == http.StatusTooManyRequests:
+		// Rate limited requests are still authenticated
+		validation.Health = KeyHealthValid
+	case status == http.StatusPaymentRequired,
+		status == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Error.Message), "credit balance"):
+		validation.Health = KeyHealthNoQuota
+		validation.Error = apiErr.Error.Message
+	default:
+		return nil, fmt.Errorf("API error (status %d): %s", status, string(body))
 	}
 
-	return "", fmt.Errorf("no content in response")
-}
\ No newline at end of file
+	return validation, nil
+}
@@ -0,0 +1,71 @@
+package ai_assistant
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateKeyClassifiesResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		health KeyHealth // empty when the probe is inconclusive
+	}{
+		{"ok", http.StatusOK, `{"content":[{"type":"text","text":"p"}]}`, KeyHealthValid},
+		{"unauthorized", http.StatusUnauthorized, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`, KeyHealthInvalid},
+		{"forbidden", http.StatusForbidden, `{"type":"error","error":{"type":"permission_error","message":"disabled"}}`, KeyHealthInvalid},
+		{"rate limited", http.StatusTooManyRequests, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`, KeyHealthValid},
+		{"payment required", http.StatusPaymentRequired, `{}`, KeyHealthNoQuota},
+		{"credit balance", http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"Your credit balance is too low"}}`, KeyHealthNoQuota},
+		{"other bad request", http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens"}}`, ""},
+		{"overloaded", 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent ClaudeRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("x-api-key"); got != "sk-ant-test" {
+					t.Errorf("x-api-key = %q, want the key being validated", got)
+				}
+				json.NewDecoder(r.Body).Decode(&sent)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			client := newClaudeClient("sk-ant-test")
+			client.baseURL = srv.URL
+			validation, err := client.ValidateKey(context.Background())
+
+			if sent.MaxTokens != 1 {
+				t.Errorf("probe asked for %d tokens, want 1", sent.MaxTokens)
+			}
+			if tt.health == "" {
+				if err == nil {
+					t.Fatalf("validation = %+v, want an inconclusive error", validation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if validation.Health != tt.health || validation.CheckedAt.IsZero() {
+				t.Errorf("validation = %+v, want %s", validation, tt.health)
+			}
+			if tt.health == KeyHealthInvalid && validation.Error == "" {
+				t.Error("invalid key without the API's error message")
+			}
+		})
+	}
+}
+
+func TestValidateKeyNeedsAKey(t *testing.T) {
+	if _, err := newClaudeClient("").ValidateKey(context.Background()); err == nil {
+		t.Error("expected an error without a key")
+	}
+}
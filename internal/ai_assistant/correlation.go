@@ -0,0 +1,229 @@
+package ai_assistant
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultCorrelationWindow is the number of daily returns in a correlation matrix
+const DefaultCorrelationWindow = 60
+
+// CorrelationMatrix holds pairwise correlations of daily log returns over a
+// window ending at AsOf
+type CorrelationMatrix struct {
+	Symbols []string    `json:"symbols"`
+	Values  [][]float64 `json:"values"`
+	Window  int         `json:"window"`
+	AsOf    time.Time   `json:"as_of"`
+
+	index map[string]int
+}
+
+// Get returns the correlation between a and b
+func (m *CorrelationMatrix) Get(a, b string) (float64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	i, ok := m.index[strings.ToUpper(a)]
+	if !ok {
+		return 0, false
+	}
+	j, ok := m.index[strings.ToUpper(b)]
+	if !ok {
+		return 0, false
+	}
+	return m.Values[i][j], true
+}
+
+// RollingCorrelations returns correlation matrices over successive windows
+// of the symbols' common history, every step days, oldest first
+func RollingCorrelations(symbols []string, history map[string][]DailyBar, window, step int) []*CorrelationMatrix {
+	symbols = withHistory(symbols, history)
+	if len(symbols) == 0 {
+		return nil
+	}
+	dates, returns := dailyReturns(symbols, history)
+	if window < 2 || len(dates) < window {
+		return nil
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	var matrices []*CorrelationMatrix
+	for end := len(dates); end >= window; end -= step {
+		matrices = append(matrices, correlationOver(symbols, returns, dates, end-window, end))
+	}
+	// Collected newest first; reverse to oldest first
+	for i, j := 0, len(matrices)-1; i < j; i, j = i+1, j-1 {
+		matrices[i], matrices[j] = matrices[j], matrices[i]
+	}
+	return matrices
+}
+
+// LatestCorrelation returns the correlation matrix over the most recent
+// window, shortened to the available history if needed, or nil if there
+// are fewer than 20 common returns. Symbols without history are left out.
+func LatestCorrelation(symbols []string, history map[string][]DailyBar, window int) *CorrelationMatrix {
+	symbols = withHistory(symbols, history)
+	if len(symbols) == 0 {
+		return nil
+	}
+	dates, returns := dailyReturns(symbols, history)
+	if len(dates) < 20 {
+		return nil
+	}
+	if window <= 0 || window > len(dates) {
+		window = len(dates)
+	}
+	return correlationOver(symbols, returns, dates, len(dates)-window, len(dates))
+}
+
+func correlationOver(symbols []string, returns map[string][]float64, dates []time.Time, start, end int) *CorrelationMatrix {
+	window := make(map[string][]float64, len(symbols))
+	for _, symbol := range symbols {
+		window[symbol] = returns[symbol][start:end]
+	}
+	cov := covariance(symbols, window)
+
+	m := &CorrelationMatrix{
+		Symbols: symbols,
+		Values:  make([][]float64, len(symbols)),
+		Window:  end - start,
+		AsOf:    dates[end-1],
+		index:   make(map[string]int, len(symbols)),
+	}
+	for i, symbol := range symbols {
+		m.index[symbol] = i
+		m.Values[i] = make([]float64, len(symbols))
+		for j := range symbols {
+			if i == j {
+				m.Values[i][j] = 1
+				continue
+			}
+			denom := math.Sqrt(cov[i][i] * cov[j][j])
+			if denom > 0 {
+				m.Values[i][j] = cov[i][j] / denom
+			}
+		}
+	}
+	return m
+}
+
+// withHistory returns the distinct upper-cased symbols that have bars, sorted
+func withHistory(symbols []string, history map[string][]DailyBar) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if seen[symbol] || len(history[symbol]) < 2 {
+			continue
+		}
+		seen[symbol] = true
+		out = append(out, symbol)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// BetCluster is a group of holdings that move together and count as one bet
+type BetCluster struct {
+	Symbols []string `json:"symbols"`
+	Weight  float64  `json:"weight"` // Share of gross exposure, 0-1
+}
+
+// CorrelationClusters groups symbols by average-linkage clustering, merging
+// clusters while their average pairwise correlation is at least threshold.
+// Symbols missing from the matrix form their own clusters.
+func CorrelationClusters(symbols []string, corr *CorrelationMatrix, threshold float64) [][]string {
+	clusters := make([][]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		clusters = append(clusters, []string{symbol})
+	}
+
+	for len(clusters) > 1 {
+		bestI, bestJ, best := -1, -1, threshold
+		for i := 0; i < len(clusters); i++ {
+			for j := i + 1; j < len(clusters); j++ {
+				if avg, ok := averageCorrelation(clusters[i], clusters[j], corr); ok && avg >= best {
+					bestI, bestJ, best = i, j, avg
+				}
+			}
+		}
+		if bestI < 0 {
+			break
+		}
+		clusters[bestI] = append(clusters[bestI], clusters[bestJ]...)
+		clusters = append(clusters[:bestJ], clusters[bestJ+1:]...)
+	}
+
+	for _, cluster := range clusters {
+		sort.Strings(cluster)
+	}
+	return clusters
+}
+
+func averageCorrelation(a, b []string, corr *CorrelationMatrix) (float64, bool) {
+	sum, n := 0.0, 0
+	for _, x := range a {
+		for _, y := range b {
+			if c, ok := corr.Get(x, y); ok {
+				sum += c
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
+}
+
+// EffectiveBets returns the effective number of independent bets: the
+// inverse Herfindahl index of the clusters' shares of gross exposure. Equal
+// weights in k uncorrelated clusters give k; everything in one cluster gives 1.
+func EffectiveBets(exposures map[string]float64, clusters [][]string) (float64, []BetCluster) {
+	gross := 0.0
+	for _, exposure := range exposures {
+		gross += math.Abs(exposure)
+	}
+	if gross == 0 {
+		return 0, nil
+	}
+
+	bets := make([]BetCluster, 0, len(clusters))
+	herfindahl := 0.0
+	for _, cluster := range clusters {
+		weight := 0.0
+		for _, symbol := range cluster {
+			weight += math.Abs(exposures[symbol])
+		}
+		weight /= gross
+		herfindahl += weight * weight
+		bets = append(bets, BetCluster{Symbols: cluster, Weight: weight})
+	}
+	sort.Slice(bets, func(i, j int) bool { return bets[i].Weight > bets[j].Weight })
+
+	return 1 / herfindahl, bets
+}
+
+// sectorClusters groups symbols by sector; unclassified symbols form their
+// own clusters
+func sectorClusters(symbols []string, sectorOf func(string) string) [][]string {
+	bySector := make(map[string][]string)
+	var clusters [][]string
+	for _, symbol := range symbols {
+		sector := sectorOf(symbol)
+		if sector == "" {
+			clusters = append(clusters, []string{symbol})
+			continue
+		}
+		bySector[sector] = append(bySector[sector], symbol)
+	}
+	for _, sector := range sortedKeys(bySector) {
+		clusters = append(clusters, bySector[sector])
+	}
+	return clusters
+}
@@ -0,0 +1,161 @@
+package ai_assistant
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// barsFromReturns turns daily log returns into closes starting at $100
+func barsFromReturns(returns []float64) []DailyBar {
+	bars := make([]DailyBar, len(returns)+1)
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	price := 100.0
+	bars[0] = DailyBar{Date: day, Close: price}
+	for i, r := range returns {
+		price *= math.Exp(r)
+		bars[i+1] = DailyBar{Date: day.AddDate(0, 0, i+1), Close: price}
+	}
+	return bars
+}
+
+// wave is n returns following a sine wave of the given period
+func wave(n int, period, scale float64) []float64 {
+	returns := make([]float64, n)
+	for i := range returns {
+		returns[i] = scale * math.Sin(2*math.Pi*float64(i)/period)
+	}
+	return returns
+}
+
+// correlatedHistory has AAA and BBB moving together, CCC against them and
+// DDD on its own cycle
+func correlatedHistory(n int) map[string][]DailyBar {
+	base := wave(n, 7, 0.01)
+	inverse := make([]float64, n)
+	for i, r := range base {
+		inverse[i] = -r
+	}
+	return map[string][]DailyBar{
+		"AAA": barsFromReturns(base),
+		"BBB": barsFromReturns(wave(n, 7, 0.02)),
+		"CCC": barsFromReturns(inverse),
+		"DDD": barsFromReturns(wave(n, 5, 0.01)),
+	}
+}
+
+func TestLatestCorrelation(t *testing.T) {
+	history := correlatedHistory(70)
+	m := LatestCorrelation([]string{"ccc", "AAA", "BBB", "DDD", "NOPE", "AAA"}, history, DefaultCorrelationWindow)
+	if m == nil {
+		t.Fatal("no correlation matrix")
+	}
+	if !reflect.DeepEqual(m.Symbols, []string{"AAA", "BBB", "CCC", "DDD"}) || m.Window != 60 {
+		t.Fatalf("symbols %v over %d days, want the four with history over 60", m.Symbols, m.Window)
+	}
+	if !m.AsOf.Equal(history["AAA"][70].Date) {
+		t.Errorf("as of %v, want the last bar", m.AsOf)
+	}
+
+	for _, tt := range []struct {
+		a, b string
+		want float64
+	}{
+		{"AAA", "AAA", 1},
+		{"aaa", "BBB", 1},
+		{"AAA", "CCC", -1},
+	} {
+		if got, ok := m.Get(tt.a, tt.b); !ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("corr(%s, %s) = %v, %v, want %v", tt.a, tt.b, got, ok, tt.want)
+		}
+	}
+	if got, _ := m.Get("AAA", "DDD"); math.Abs(got) > 0.2 {
+		t.Errorf("corr(AAA, DDD) = %v, want about 0", got)
+	}
+	if _, ok := m.Get("AAA", "NOPE"); ok {
+		t.Error("correlation for a symbol without history")
+	}
+
+	if LatestCorrelation([]string{"AAA", "BBB"}, correlatedHistory(19), 0) != nil {
+		t.Error("matrix from 19 returns, want nil")
+	}
+}
+
+func TestRollingCorrelationsStepBackFromTheEnd(t *testing.T) {
+	matrices := RollingCorrelations([]string{"AAA", "DDD"}, correlatedHistory(45), 20, 10)
+	if len(matrices) != 3 {
+		t.Fatalf("%d matrices, want windows ending at returns 25, 35 and 45", len(matrices))
+	}
+	history := correlatedHistory(45)
+	for i, end := range []int{25, 35, 45} {
+		if !matrices[i].AsOf.Equal(history["AAA"][end].Date) || matrices[i].Window != 20 {
+			t.Errorf("matrix %d as of %v over %d, want bar %d over 20", i, matrices[i].AsOf, matrices[i].Window, end)
+		}
+	}
+	if RollingCorrelations([]string{"AAA", "DDD"}, correlatedHistory(10), 20, 10) != nil {
+		t.Error("matrices from too little history")
+	}
+}
+
+func TestCorrelationClustersAndEffectiveBets(t *testing.T) {
+	symbols := []string{"AAA", "BBB", "CCC", "DDD", "EEE"}
+	m := LatestCorrelation(symbols, correlatedHistory(70), DefaultCorrelationWindow)
+	clusters := CorrelationClusters(symbols, m, 0.7)
+	want := [][]string{{"AAA", "BBB"}, {"CCC"}, {"DDD"}, {"EEE"}}
+	if !reflect.DeepEqual(clusters, want) {
+		t.Fatalf("clusters = %v, want %v", clusters, want)
+	}
+
+	// Half the book in the AAA/BBB pair and the rest split over two
+	// independent names: 1 / (0.5² + 0.25² + 0.25²) = 2.67 bets
+	exposures := map[string]float64{"AAA": 30000, "BBB": -20000, "CCC": 25000, "DDD": 25000}
+	bets, weights := EffectiveBets(exposures, clusters)
+	if math.Abs(bets-8.0/3) > 1e-9 {
+		t.Errorf("effective bets = %v, want 2.67", bets)
+	}
+	if weights[0].Weight != 0.5 || !reflect.DeepEqual(weights[0].Symbols, []string{"AAA", "BBB"}) {
+		t.Errorf("largest cluster = %+v, want AAA/BBB at half", weights[0])
+	}
+
+	if bets, _ := EffectiveBets(map[string]float64{"AAA": 1, "BBB": 1}, [][]string{{"AAA", "BBB"}}); bets != 1 {
+		t.Errorf("one cluster = %v bets, want 1", bets)
+	}
+	if bets, clusters := EffectiveBets(nil, want); bets != 0 || clusters != nil {
+		t.Errorf("no exposure = %v, %v, want none", bets, clusters)
+	}
+}
+
+func TestSectorClassification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sectors.csv")
+	csv := "# symbol,sector,industry\nSymbol,Sector,Industry\naapl, Information Technology, Hardware\nMSFT,Information Technology\nXOM,Energy,Oil & Gas\nBAD,\n"
+	if err := os.WriteFile(path, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := LoadSectorFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Len() != 3 {
+		t.Errorf("%d classified symbols, want 3 without the blank sector", sc.Len())
+	}
+	if c, ok := sc.Lookup("AAPL"); !ok || c.Sector != "Information Technology" || c.Industry != "Hardware" {
+		t.Errorf("AAPL = %+v, %v", c, ok)
+	}
+
+	clusters := sectorClusters([]string{"AAPL", "XOM", "TSLA", "MSFT"}, sc.Sector)
+	want := [][]string{{"TSLA"}, {"XOM"}, {"AAPL", "MSFT"}}
+	if !reflect.DeepEqual(clusters, want) {
+		t.Errorf("sector clusters = %v, want %v", clusters, want)
+	}
+
+	txt := filepath.Join(t.TempDir(), "sectors.txt")
+	if err := os.WriteFile(txt, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSectorFile(txt); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
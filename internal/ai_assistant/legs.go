@@ -0,0 +1,108 @@
+package ai_assistant
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Leg actions
+const (
+	LegBuy  = "buy"
+	LegSell = "sell"
+)
+
+// OptionLeg is one leg of a recommended trade. Type is PositionCall,
+// PositionPut or PositionStock; Quantity is in contracts for options and
+// shares for stock.
+type OptionLeg struct {
+	Action     string  `json:"action"`
+	Type       string  `json:"type"`
+	Strike     float64 `json:"strike,omitempty"`
+	Expiration string  `json:"expiration,omitempty"` // YYYY-MM-DD
+	Quantity   float64 `json:"quantity"`
+}
+
+// sign is +1 for bought legs and -1 for sold legs
+func (l OptionLeg) sign() float64 {
+	if l.Action == LegSell {
+		return -1
+	}
+	return 1
+}
+
+// size returns the number of shares the leg controls
+func (l OptionLeg) size() float64 {
+	quantity := l.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	if l.Type == PositionStock {
+		return quantity
+	}
+	return quantity * 100
+}
+
+var (
+	legSeparator  = regexp.MustCompile(`(?i)\s*(?:/|,|;|\+|\band\b)\s*`)
+	legAction     = regexp.MustCompile(`(?i)\b(buy|sell|long|short|bto|sto)\b(?:\s+(\d+)x?\b)?`)
+	legStrikeType = regexp.MustCompile(`(?i)\$?(\d+(?:\.\d+)?)\s*(calls?|puts?|c|p)\b`)
+	legShares     = regexp.MustCompile(`(?i)\b(\d+)\s+shares\b`)
+	legExpiration = regexp.MustCompile(`\d{4}-\d{2}-\d{2}`)
+)
+
+// ParseLegs extracts structured legs from a free-text description such as
+// "Sell 1 AAPL 2024-02-16 180P / Buy 1 AAPL 2024-02-16 175P". Segments
+// without both an action and a strike and type are skipped. An expiration
+// given once applies to every leg that lacks one.
+func ParseLegs(description string) []OptionLeg {
+	var legs []OptionLeg
+	expiration := legExpiration.FindString(description)
+
+	for _, segment := range legSeparator.Split(description, -1) {
+		loc := legAction.FindStringSubmatchIndex(segment)
+		if loc == nil {
+			continue
+		}
+		action := legAction.FindStringSubmatch(segment)
+		leg := OptionLeg{Action: LegBuy, Quantity: 1}
+		switch strings.ToLower(action[1]) {
+		case "sell", "short", "sto":
+			leg.Action = LegSell
+		}
+		if action[2] != "" {
+			leg.Quantity, _ = strconv.ParseFloat(action[2], 64)
+		}
+
+		if shares := legShares.FindStringSubmatch(segment); shares != nil {
+			leg.Type = PositionStock
+			leg.Quantity, _ = strconv.ParseFloat(shares[1], 64)
+			legs = append(legs, leg)
+			continue
+		}
+
+		// Look after the action and quantity, with dates removed, so neither
+		// is read as the strike
+		rest := legExpiration.ReplaceAllString(segment[loc[1]:], "")
+		match := legStrikeType.FindStringSubmatch(rest)
+		if match == nil && action[2] != "" {
+			// "Short 180 put": the number was the strike, not a quantity
+			leg.Quantity = 1
+			match = legStrikeType.FindStringSubmatch(legExpiration.ReplaceAllString(segment[loc[3]:], ""))
+		}
+		if match == nil {
+			continue
+		}
+		leg.Strike, _ = strconv.ParseFloat(match[1], 64)
+		leg.Type = PositionCall
+		if strings.HasPrefix(strings.ToLower(match[2]), "p") {
+			leg.Type = PositionPut
+		}
+		leg.Expiration = legExpiration.FindString(segment)
+		if leg.Expiration == "" {
+			leg.Expiration = expiration
+		}
+		legs = append(legs, leg)
+	}
+	return legs
+}
@@ -0,0 +1,339 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Option structures recognized by the margin calculator
+const (
+	StructureLongOption     = "long option"
+	StructureNakedCall      = "naked call"
+	StructureNakedPut       = "naked put"
+	StructureCashSecuredPut = "cash-secured put"
+	StructureCoveredCall    = "covered call"
+	StructureVertical       = "vertical spread"
+	StructureIronCondor     = "iron condor"
+	StructureIronButterfly  = "iron butterfly"
+	StructureButterfly      = "butterfly"
+	StructureStock          = "stock"
+	StructureCustom         = "custom"
+)
+
+// MarginComponent is the requirement of one part of a structure
+type MarginComponent struct {
+	Kind        string  `json:"kind"`
+	Description string  `json:"description"`
+	Requirement float64 `json:"requirement"`
+}
+
+// MarginRequirement is the Reg-T buying power a trade uses, net of the
+// premium it collects. PerContract is for one of the trade's structure;
+// Total is for its Quantity.
+type MarginRequirement struct {
+	Structure   string            `json:"structure"`
+	PerContract float64           `json:"per_contract"`
+	Total       float64           `json:"total"`
+	Premium     float64           `json:"premium"` // Net credit per contract; negative for a debit
+	Components  []MarginComponent `json:"components"`
+	Estimated   bool              `json:"estimated,omitempty"` // No structured legs; max loss used instead
+}
+
+// marginLeg is a leg being allocated to margin components, with its
+// quantity in contracts (shares for stock) and premium per share
+type marginLeg struct {
+	OptionLeg
+	premium float64
+}
+
+// MarginRequirement estimates the Reg-T requirement of trade from its
+// structured legs: the stock leg at 50%, short calls covered by stock in
+// the trade or already held, unhedged short calls and puts at the naked
+// rates (or the strike for cash-secured puts), and the remaining spreads,
+// condors and butterflies at their maximum loss at expiration. Without legs
+// the requirement is the trade's max loss.
+func (rm *RiskManager) MarginRequirement(trade *TradeRecommendation, portfolio map[string]interface{}) *MarginRequirement {
+	req := &MarginRequirement{Structure: classifyStructure(trade)}
+	if len(trade.LegDetails) == 0 {
+		req.Estimated = true
+		req.PerContract = math.Abs(trade.MaxLoss)
+		req.Components = []MarginComponent{{Kind: StructureCustom, Description: "No structured legs; max loss", Requirement: req.PerContract}}
+		req.Total = req.PerContract * trade.units()
+		return req
+	}
+
+	now := rm.clock()
+	spot := rm.spotPrice(trade.Ticker)
+	var calls, puts []*marginLeg
+	var stockShares float64
+	for _, leg := range trade.LegDetails {
+		quantity := leg.Quantity
+		if quantity <= 0 {
+			quantity = 1
+		}
+		if leg.Type == PositionStock {
+			stockShares += leg.sign() * quantity
+			continue
+		}
+		value, err := rm.valueLeg(trade.Ticker, leg, now)
+		if err != nil {
+			// Unpriced legs are margined on intrinsic value alone
+			value.price = intrinsicValue(leg.Type, spot, leg.Strike)
+		}
+		ml := &marginLeg{OptionLeg: leg, premium: value.price}
+		ml.Quantity = quantity
+		req.Premium -= leg.sign() * quantity * 100 * value.price
+		if leg.Type == PositionCall {
+			calls = append(calls, ml)
+		} else {
+			puts = append(puts, ml)
+		}
+	}
+
+	add := func(kind, description string, requirement float64) {
+		req.Components = append(req.Components, MarginComponent{Kind: kind, Description: description, Requirement: requirement})
+		req.PerContract += requirement
+	}
+
+	if stockShares != 0 {
+		add(StructureStock, fmt.Sprintf("%g shares at 50%%", stockShares), 0.5*spot*math.Abs(stockShares))
+	}
+
+	// Short calls not offset by long calls are covered by shares, then naked
+	coverShares := math.Max(stockShares, 0) + sharesHeld(portfolio, trade.Ticker)
+	for _, short := range takeUnhedged(calls) {
+		covered := math.Min(short.Quantity, math.Floor(coverShares/100))
+		if covered > 0 {
+			coverShares -= covered * 100
+			add(StructureCoveredCall, fmt.Sprintf("%g short %g call covered by stock", covered, short.Strike), -covered*100*short.premium)
+		}
+		if naked := short.Quantity - covered; naked > 0 {
+			otm := math.Max(short.Strike-spot, 0)
+			perShare := math.Max(0.20*spot-otm, 0.10*spot)
+			add(StructureNakedCall, fmt.Sprintf("%g short %g call at 20%% of underlying less OTM, min 10%%", naked, short.Strike), naked*100*perShare)
+		}
+	}
+
+	if req.Structure == StructureNakedCall && len(req.Components) > 0 && req.Components[len(req.Components)-1].Kind == StructureCoveredCall {
+		req.Structure = StructureCoveredCall // Covered by shares already held
+	}
+
+	// Short puts not offset by long puts are cash-secured or naked
+	cashSecured := strings.Contains(normalizeStrategyName(trade.Strategy), "cash")
+	for _, short := range takeUnhedged(puts) {
+		if cashSecured {
+			add(StructureCashSecuredPut, fmt.Sprintf("%g short %g put secured by the strike", short.Quantity, short.Strike), short.Quantity*100*(short.Strike-short.premium))
+			continue
+		}
+		otm := math.Max(spot-short.Strike, 0)
+		perShare := math.Max(0.20*spot-otm, 0.10*short.Strike)
+		add(StructureNakedPut, fmt.Sprintf("%g short %g put at 20%% of underlying less OTM, min 10%% of strike", short.Quantity, short.Strike), short.Quantity*100*perShare)
+	}
+
+	// What remains is hedged; it needs its maximum loss at expiration
+	var hedged []*marginLeg
+	for _, leg := range append(calls, puts...) {
+		if leg.Quantity > 0 {
+			hedged = append(hedged, leg)
+		}
+	}
+	if len(hedged) > 0 {
+		kind := StructureLongOption
+		for _, leg := range hedged {
+			if leg.Action == LegSell {
+				kind = StructureCustom
+				switch req.Structure {
+				case StructureVertical, StructureIronCondor, StructureIronButterfly, StructureButterfly:
+					kind = req.Structure
+				}
+				break
+			}
+		}
+		add(kind, "Maximum loss at expiration", maxLossAtExpiry(hedged))
+	}
+
+	req.PerContract = math.Max(req.PerContract, 0)
+	req.Total = req.PerContract * trade.units()
+	return req
+}
+
+// takeUnhedged removes from legs the short contracts that long contracts of
+// the same type cannot offset and returns them. Calls are taken from the
+// highest strike down and puts from the lowest up, leaving the shorts
+// nearest the money in spreads.
+func takeUnhedged(legs []*marginLeg) []*marginLeg {
+	var short, long float64
+	for _, leg := range legs {
+		if leg.Action == LegSell {
+			short += leg.Quantity
+		} else {
+			long += leg.Quantity
+		}
+	}
+	excess := short - long
+	if excess <= 0 {
+		return nil
+	}
+
+	shorts := make([]*marginLeg, 0, len(legs))
+	for _, leg := range legs {
+		if leg.Action == LegSell {
+			shorts = append(shorts, leg)
+		}
+	}
+	sort.SliceStable(shorts, func(i, j int) bool {
+		if shorts[i].Type == PositionCall {
+			return shorts[i].Strike > shorts[j].Strike
+		}
+		return shorts[i].Strike < shorts[j].Strike
+	})
+
+	var taken []*marginLeg
+	for _, leg := range shorts {
+		if excess <= 0 {
+			break
+		}
+		n := math.Min(leg.Quantity, excess)
+		leg.Quantity -= n
+		excess -= n
+		part := *leg
+		part.Quantity = n
+		taken = append(taken, &part)
+	}
+	return taken
+}
+
+// maxLossAtExpiry returns the largest loss of legs at expiration, including
+// the premium paid or collected, over every underlying price. The payoff is
+// piecewise linear, so checking zero, each strike and beyond the highest
+// strike is enough.
+func maxLossAtExpiry(legs []*marginLeg) float64 {
+	prices := []float64{0}
+	top := 0.0
+	for _, leg := range legs {
+		prices = append(prices, leg.Strike)
+		top = math.Max(top, leg.Strike)
+	}
+	prices = append(prices, top*2+1)
+
+	worst := 0.0
+	for _, price := range prices {
+		pnl := 0.0
+		for _, leg := range legs {
+			pnl += leg.sign() * leg.Quantity * 100 * (intrinsicValue(leg.Type, price, leg.Strike) - leg.premium)
+		}
+		worst = math.Min(worst, pnl)
+	}
+	return -worst
+}
+
+// classifyStructure names the structure of trade's legs
+func classifyStructure(trade *TradeRecommendation) string {
+	var calls, puts, stock []OptionLeg
+	for _, leg := range trade.LegDetails {
+		switch leg.Type {
+		case PositionCall:
+			calls = append(calls, leg)
+		case PositionPut:
+			puts = append(puts, leg)
+		case PositionStock:
+			stock = append(stock, leg)
+		}
+	}
+	strategy := normalizeStrategyName(trade.Strategy)
+
+	switch {
+	case len(trade.LegDetails) == 0:
+		return StructureCustom
+	case len(stock) > 0 && len(calls) == 1 && len(puts) == 0 && calls[0].Action == LegSell:
+		return StructureCoveredCall
+	case len(stock) > 0:
+		if len(calls)+len(puts) == 0 {
+			return StructureStock
+		}
+		return StructureCustom
+	case len(calls)+len(puts) == 1:
+		leg := append(calls, puts...)[0]
+		switch {
+		case leg.Action == LegBuy:
+			return StructureLongOption
+		case leg.Type == PositionCall:
+			return StructureNakedCall
+		case strings.Contains(strategy, "cash"):
+			return StructureCashSecuredPut
+		default:
+			return StructureNakedPut
+		}
+	case isVertical(calls) && len(puts) == 0, isVertical(puts) && len(calls) == 0:
+		return StructureVertical
+	case isVertical(calls) && isVertical(puts) && isCreditVertical(calls) && isCreditVertical(puts):
+		if shortStrike(calls) == shortStrike(puts) {
+			return StructureIronButterfly
+		}
+		return StructureIronCondor
+	case isButterfly(calls) && len(puts) == 0, isButterfly(puts) && len(calls) == 0:
+		return StructureButterfly
+	}
+	return StructureCustom
+}
+
+// isVertical reports whether legs are one bought and one sold option of
+// equal quantity
+func isVertical(legs []OptionLeg) bool {
+	return len(legs) == 2 && legs[0].Action != legs[1].Action && legs[0].Quantity == legs[1].Quantity
+}
+
+// isCreditVertical reports whether a vertical sells the option nearer the
+// money: the lower call strike or the higher put strike
+func isCreditVertical(legs []OptionLeg) bool {
+	short, long := legs[0], legs[1]
+	if short.Action != LegSell {
+		short, long = long, short
+	}
+	if short.Type == PositionCall {
+		return short.Strike < long.Strike
+	}
+	return short.Strike > long.Strike
+}
+
+func shortStrike(legs []OptionLeg) float64 {
+	for _, leg := range legs {
+		if leg.Action == LegSell {
+			return leg.Strike
+		}
+	}
+	return 0
+}
+
+// isButterfly reports whether legs are equidistant wings around a body of
+// twice their quantity, bought on one side and sold on the other
+func isButterfly(legs []OptionLeg) bool {
+	byStrike := make(map[float64]float64)
+	for _, leg := range legs {
+		byStrike[leg.Strike] += leg.sign() * math.Max(leg.Quantity, 1)
+	}
+	if len(byStrike) != 3 {
+		return false
+	}
+	strikes := make([]float64, 0, 3)
+	for strike := range byStrike {
+		strikes = append(strikes, strike)
+	}
+	sort.Float64s(strikes)
+	low, body, high := byStrike[strikes[0]], byStrike[strikes[1]], byStrike[strikes[2]]
+	return low == high && body == -2*low && math.Abs((strikes[1]-strikes[0])-(strikes[2]-strikes[1])) < 0.005
+}
+
+// sharesHeld returns the shares of symbol among the portfolio's positions
+func sharesHeld(portfolio map[string]interface{}, symbol string) float64 {
+	positions, _ := portfolio["positions"].([]map[string]interface{})
+	shares := 0.0
+	for _, position := range positions {
+		if strings.EqualFold(stringField(position, "symbol"), symbol) {
+			shares += floatField(position, "quantity", "qty")
+		}
+	}
+	return math.Max(shares, 0)
+}
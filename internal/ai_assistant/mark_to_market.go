@@ -0,0 +1,322 @@
+package ai_assistant
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TradeMark is one executed recommendation marked to market. Value and
+// Greeks cover the option legs for the whole trade; Greeks are in contract
+// units, as NetGreeks are elsewhere.
+type TradeMark struct {
+	RecordID        string    `json:"record_id"`
+	At              time.Time `json:"at"`
+	UnderlyingPrice float64   `json:"underlying_price,omitempty"`
+	Value           float64   `json:"value"`          // At the legs' mids, negative when short
+	UnrealizedPnL   float64   `json:"unrealized_pnl"` // Value less the entry cost
+	Greeks          NetGreeks `json:"greeks"`
+	DTE             int       `json:"dte"` // Calendar days to the nearest expiration
+}
+
+// BookTotals sums the open trades of a book at one mark
+type BookTotals struct {
+	OpenTrades    int       `json:"open_trades"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	RealizedPnL   float64   `json:"realized_pnl"` // Of every closed and expired trade so far
+	Equity        float64   `json:"equity"`       // Realized plus unrealized P&L
+	Greeks        NetGreeks `json:"greeks"`
+}
+
+func (t *BookTotals) add(mark *TradeMark) {
+	t.OpenTrades++
+	t.UnrealizedPnL = round2(t.UnrealizedPnL + mark.UnrealizedPnL)
+	t.Equity = round2(t.RealizedPnL + t.UnrealizedPnL)
+	t.Greeks = t.Greeks.add(mark.Greeks)
+}
+
+// BookMark is the AI book as of one marking pass, in total and by the user
+// each trade was recommended to
+type BookMark struct {
+	At     time.Time              `json:"at"`
+	Total  BookTotals             `json:"total"`
+	ByUser map[string]*BookTotals `json:"by_user"`
+}
+
+// MarkSource quotes the underlyings and option chains open trades are
+// marked from; *MarketDataAggregator is one
+type MarkSource interface {
+	LatestQuote(ctx context.Context, symbol string) (*Quote, error)
+	OptionChains(ctx context.Context, symbol string) ([]*OptionChain, error)
+}
+
+// BookMarker marks every executed recommendation to market and keeps the
+// series in a MarkStore
+type BookMarker struct {
+	tracker *PerformanceTracker
+	source  MarkSource
+	store   *MarkStore
+}
+
+// NewBookMarker creates a BookMarker pricing tracker's open trades from
+// source
+func NewBookMarker(tracker *PerformanceTracker, source MarkSource, store *MarkStore) *BookMarker {
+	return &BookMarker{tracker: tracker, source: source, store: store}
+}
+
+// Mark values each executed record's option legs as of now, from the
+// listed contracts' mids or Black-Scholes when a contract is not listed,
+// and appends the marks to the store. Unrealized P&L is measured from the
+// record's entry cost, or from its first mark when no fill was seen.
+// Trades that cannot be priced are skipped and returned as warnings.
+func (bm *BookMarker) Mark(ctx context.Context, now time.Time) (*BookMark, []string, error) {
+	records, err := bm.tracker.OpenRecords()
+	if err != nil {
+		return nil, nil, err
+	}
+	realized, err := bm.tracker.RealizedByUser()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var open []*RecommendationRecord
+	for _, rec := range records {
+		if rec.Status == RecordExecuted && len(rec.Recommendation.LegDetails) > 0 {
+			open = append(open, rec)
+		}
+	}
+
+	var warnings []string
+	data := &AggregatedMarketData{
+		Timestamp: now,
+		Quotes:    make(map[string]*Quote),
+		Options:   make(map[string][]*OptionChain),
+	}
+	for _, rec := range open {
+		ticker := strings.ToUpper(rec.Recommendation.Ticker)
+		if _, ok := data.Options[ticker]; ok {
+			continue
+		}
+		if quote, err := bm.source.LatestQuote(ctx, ticker); err == nil {
+			data.Quotes[ticker] = quote
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s: no quote: %v", ticker, err))
+		}
+		chains, err := bm.source.OptionChains(ctx, ticker)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s: no option chain: %v", ticker, err))
+		}
+		data.Options[ticker] = chains
+	}
+	rm := NewRiskManager().WithClock(func() time.Time { return now }).WithMarketData(data)
+
+	book := &BookMark{At: now, ByUser: make(map[string]*BookTotals)}
+	for userID, profit := range realized {
+		book.ByUser[userID] = &BookTotals{RealizedPnL: round2(profit), Equity: round2(profit)}
+		book.Total.RealizedPnL += profit
+	}
+	book.Total.RealizedPnL = round2(book.Total.RealizedPnL)
+	book.Total.Equity = book.Total.RealizedPnL
+
+	var marks []*TradeMark
+	for _, rec := range open {
+		mark, err := rm.markTrade(&rec.Recommendation, now)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("record %s: %v", rec.ID, err))
+			continue
+		}
+		mark.RecordID = rec.ID
+
+		basis := rec.EntryCost
+		if basis == nil {
+			if first, err := bm.store.first(rec.ID); err != nil {
+				return nil, warnings, err
+			} else if first != nil {
+				basis = &first.Value
+			}
+		}
+		if basis != nil {
+			mark.UnrealizedPnL = round2(mark.Value - *basis)
+		}
+		marks = append(marks, mark)
+
+		user := book.ByUser[rec.UserID]
+		if user == nil {
+			user = &BookTotals{}
+			book.ByUser[rec.UserID] = user
+		}
+		user.add(mark)
+		book.Total.add(mark)
+	}
+
+	if err := bm.store.append(book, marks); err != nil {
+		return nil, warnings, err
+	}
+	return book, warnings, nil
+}
+
+// markTrade values trade's option legs for its whole quantity
+func (rm *RiskManager) markTrade(trade *TradeRecommendation, now time.Time) (*TradeMark, error) {
+	mark := &TradeMark{At: now, UnderlyingPrice: rm.spotPrice(trade.Ticker), DTE: -1}
+	for _, leg := range trade.LegDetails {
+		if leg.Type == PositionStock {
+			continue
+		}
+		value, err := rm.valueLeg(trade.Ticker, leg, now)
+		if err != nil {
+			return nil, err
+		}
+		mark.Value += leg.sign() * leg.size() * value.price
+		mark.Greeks = mark.Greeks.add(value.greeks.scale(leg.sign() * leg.size() / 100))
+
+		if expiration, err := time.ParseInLocation("2006-01-02", leg.Expiration, marketLocation); err == nil {
+			days := int(math.Ceil(expiration.Add(16*time.Hour).Sub(now).Hours() / 24))
+			if days < 0 {
+				days = 0
+			}
+			if mark.DTE < 0 || days < mark.DTE {
+				mark.DTE = days
+			}
+		}
+	}
+	mark.Value = round2(mark.Value * trade.units())
+	mark.Greeks = mark.Greeks.scale(trade.units())
+	mark.DTE = max(mark.DTE, 0)
+	return mark, nil
+}
+
+// MarkStore keeps marks as JSON lines: book.jsonl has one BookMark per
+// pass and trades/<record ID>.jsonl each trade's path
+type MarkStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewMarkStore opens the mark store in dir, creating it if needed
+func NewMarkStore(dir string) (*MarkStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "trades"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mark directory: %w", err)
+	}
+	return &MarkStore{dir: dir}, nil
+}
+
+// EquityCurve returns the book's marks taken from from to to inclusive,
+// oldest first
+func (s *MarkStore) EquityCurve(from, to time.Time) ([]*BookMark, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var curve []*BookMark
+	err := readLines(filepath.Join(s.dir, "book.jsonl"), func(line []byte) error {
+		var mark BookMark
+		if err := json.Unmarshal(line, &mark); err != nil {
+			return err
+		}
+		if !mark.At.Before(from) && !mark.At.After(to) {
+			curve = append(curve, &mark)
+		}
+		return nil
+	})
+	return curve, err
+}
+
+// TradePath returns the marks of the trade with recordID, oldest first
+func (s *MarkStore) TradePath(recordID string) ([]*TradeMark, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.path(recordID, 0)
+}
+
+// first returns the trade's first mark, or nil if it has none
+func (s *MarkStore) first(recordID string) (*TradeMark, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.path(recordID, 1)
+	if err != nil || len(path) == 0 {
+		return nil, err
+	}
+	return path[0], nil
+}
+
+// path reads up to limit of the trade's marks, or all of them when limit
+// is 0; the caller holds the lock
+func (s *MarkStore) path(recordID string, limit int) ([]*TradeMark, error) {
+	var marks []*TradeMark
+	err := readLines(s.tradeFile(recordID), func(line []byte) error {
+		if limit > 0 && len(marks) >= limit {
+			return nil
+		}
+		var mark TradeMark
+		if err := json.Unmarshal(line, &mark); err != nil {
+			return err
+		}
+		marks = append(marks, &mark)
+		return nil
+	})
+	return marks, err
+}
+
+func (s *MarkStore) append(book *BookMark, trades []*TradeMark) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, mark := range trades {
+		if err := appendLine(s.tradeFile(mark.RecordID), mark); err != nil {
+			return err
+		}
+	}
+	return appendLine(filepath.Join(s.dir, "book.jsonl"), book)
+}
+
+// tradeFile is where a trade's path is kept. Record IDs are generated
+// here, but are cleaned so a stray one cannot name another file.
+func (s *MarkStore) tradeFile(recordID string) string {
+	return filepath.Join(s.dir, "trades", filepath.Base(filepath.Clean("/"+recordID))+".jsonl")
+}
+
+func appendLine(path string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return f.Close()
+}
+
+// readLines calls fn with each line of path, which may not exist yet
+func readLines(path string, fn func([]byte) error) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,164 @@
+package ai_assistant
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMarkSource quotes from maps
+type fakeMarkSource struct {
+	quotes map[string]float64
+	chains map[string][]*OptionChain
+}
+
+func (s *fakeMarkSource) LatestQuote(ctx context.Context, symbol string) (*Quote, error) {
+	price, ok := s.quotes[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no quote for %s", symbol)
+	}
+	return &Quote{Symbol: symbol, Price: price}, nil
+}
+
+func (s *fakeMarkSource) OptionChains(ctx context.Context, symbol string) ([]*OptionChain, error) {
+	return s.chains[symbol], nil
+}
+
+const markExpiration = "2026-11-20"
+
+// setPuts lists symbol's 470 and 467 puts at the given mids
+func (s *fakeMarkSource) setPuts(symbol string, short, long float64) {
+	s.chains[symbol] = []*OptionChain{
+		{Symbol: symbol, Strike: 470, Expiration: markExpiration, Type: PositionPut, Bid: short - 0.05, Ask: short + 0.05,
+			Greeks: &Greeks{Delta: -0.3, Gamma: 0.02, Theta: -0.1, Vega: 0.4}},
+		{Symbol: symbol, Strike: 467, Expiration: markExpiration, Type: PositionPut, Bid: long - 0.05, Ask: long + 0.05,
+			Greeks: &Greeks{Delta: -0.25, Gamma: 0.015, Theta: -0.08, Vega: 0.35}},
+	}
+}
+
+// executedSpread records an executed 470/467 put spread on ticker for
+// userID, with entryCost if it is not nil
+func executedSpread(t *testing.T, tracker *PerformanceTracker, userID, ticker string, quantity int, entryCost *float64) *RecommendationRecord {
+	t.Helper()
+	rec, err := tracker.RecordRecommendation(userID, TradeRecommendation{
+		Ticker:   ticker,
+		Strategy: "Put Credit Spread",
+		Quantity: quantity,
+		LegDetails: []OptionLeg{
+			{Action: LegSell, Type: PositionPut, Strike: 470, Expiration: markExpiration, Quantity: 1},
+			{Action: LegBuy, Type: PositionPut, Strike: 467, Expiration: markExpiration, Quantity: 1},
+		},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tracker.UpdateRecord(rec.ID, func(r *RecommendationRecord) {
+		r.Status = RecordExecuted
+		r.Executed = true
+		r.EntryCost = entryCost
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestBookMarkerMarksOpenTrades(t *testing.T) {
+	tracker := newTestTracker(t)
+	source := &fakeMarkSource{quotes: map[string]float64{"SPY": 500, "QQQ": 480}, chains: make(map[string][]*OptionChain)}
+	source.setPuts("SPY", 2, 1)
+	source.setPuts("QQQ", 3, 2)
+
+	// Alice sold two SPY spreads for $240 and closed another for $50;
+	// Bob's QQQ spread has no recorded fill; nothing prices ZZZ
+	entry := -240.0
+	alice := executedSpread(t, tracker, "alice", "SPY", 2, &entry)
+	bob := executedSpread(t, tracker, "bob", "QQQ", 1, nil)
+	unpriced := executedSpread(t, tracker, "bob", "ZZZ", 1, nil)
+	closed := executedSpread(t, tracker, "alice", "SPY", 1, nil)
+	if err := tracker.UpdateTradeResult(closed.ID, 50); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.RecordRecommendation("alice", TradeRecommendation{Ticker: "SPY"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewMarkStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	marker := NewBookMarker(tracker, source, store)
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+	book, warnings, err := marker.Mark(context.Background(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 || !strings.Contains(warnings[len(warnings)-1], unpriced.ID) {
+		t.Errorf("warnings = %v, want ZZZ's quote and record", warnings)
+	}
+	if book.Total.OpenTrades != 2 || book.Total.RealizedPnL != 50 {
+		t.Fatalf("total = %+v, want two open trades and $50 realized", book.Total)
+	}
+
+	// Two spreads at $100 each, bought back for $200 against $240 taken in
+	a := book.ByUser["alice"]
+	if a.OpenTrades != 1 || a.UnrealizedPnL != 40 || a.Equity != 90 {
+		t.Errorf("alice = %+v, want $40 unrealized on $50 realized", a)
+	}
+	if math.Abs(a.Greeks.Delta-0.1) > 1e-9 {
+		t.Errorf("alice delta = %v, want 2 × (0.3 - 0.25)", a.Greeks.Delta)
+	}
+	// Without a fill Bob's first mark is his basis
+	if b := book.ByUser["bob"]; b.OpenTrades != 1 || b.UnrealizedPnL != 0 {
+		t.Errorf("bob = %+v, want one trade at its first mark", b)
+	}
+
+	source.setPuts("SPY", 1, 0.5)
+	source.setPuts("QQQ", 2.5, 1.8)
+	book, _, err = marker.Mark(context.Background(), now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := book.ByUser["alice"]; a.UnrealizedPnL != 140 {
+		t.Errorf("alice unrealized = %v, want 240 - 100", a.UnrealizedPnL)
+	}
+	if b := book.ByUser["bob"]; b.UnrealizedPnL != 30 {
+		t.Errorf("bob unrealized = %v, want 100 - 70", b.UnrealizedPnL)
+	}
+	if book.Total.Equity != 50+140+30 {
+		t.Errorf("total equity = %v, want 220", book.Total.Equity)
+	}
+
+	curve, err := store.EquityCurve(now, now.Add(24*time.Hour))
+	if err != nil || len(curve) != 2 || curve[0].Total.Equity != 90 {
+		t.Fatalf("equity curve = %v, %v, want both marks", curve, err)
+	}
+	path, err := store.TradePath(alice.ID)
+	if err != nil || len(path) != 2 || path[0].Value != -200 || path[1].Value != -100 {
+		t.Fatalf("alice's path = %v, %v, want -200 then -100", path, err)
+	}
+	if path[0].DTE != 33 || path[0].UnderlyingPrice != 500 {
+		t.Errorf("first mark DTE %d at %v, want 33 days at $500", path[0].DTE, path[0].UnderlyingPrice)
+	}
+	if path, _ := store.TradePath(bob.ID); len(path) != 2 {
+		t.Errorf("bob's path has %d marks, want 2", len(path))
+	}
+}
+
+func TestMarkStoreKeepsTradeFilesInItsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewMarkStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"../../book", "/etc/passwd", "rec_1"} {
+		if path := store.tradeFile(id); filepath.Dir(path) != filepath.Join(dir, "trades") {
+			t.Errorf("trade file for %q = %s, outside %s/trades", id, path, dir)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
 	"github.com/alpacahq/alpaca-trade-api-go/v3/marketdata"
 	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/events"
 	"vibetrade-claude/internal/vibetrade"
 )
 
@@ -17,6 +18,19 @@ type MarketDataAggregator struct {
 	alpacaClient    *alpaca.Client
 	marketData      *marketdata.Client
 	vibetradeClient *vibetrade.Client
+	eventProvider   events.Provider
+	source          MarketSource // Replaces the live feeds when set
+}
+
+// MarketSource supplies quotes, option chains and daily bars as of some
+// point in time, e.g. a replay of stored snapshots for backtesting
+type MarketSource interface {
+	// Now is the time the source's data is as of
+	Now() time.Time
+	Quote(ctx context.Context, symbol string) (*Quote, error)
+	OptionChains(ctx context.Context, symbol string) ([]*OptionChain, error)
+	// DailyBars returns daily closes up to Now
+	DailyBars(ctx context.Context, symbol string) ([]DailyBar, error)
 }
 
 type AggregatedMarketData struct {
@@ -26,6 +40,21 @@ type AggregatedMarketData struct {
 	Technicals  map[string]*TechnicalIndicators `json:"technicals"`
 	Fundamentals map[string]*Fundamentals       `json:"fundamentals"`
 	MarketStats *MarketStatistics              `json:"market_stats"`
+
+	// History holds the daily closes behind Technicals; it feeds the risk
+	// engines and is left out of the prompt
+	History map[string][]DailyBar `json:"-"`
+
+	// Events gives each symbol's nearby earnings, dividends and macro
+	// events; Calendar holds the raw events for the blackout rules
+	Events   map[string]*events.Proximity `json:"events,omitempty"`
+	Calendar []events.Event               `json:"-"`
+}
+
+// DailyBar is one daily closing price
+type DailyBar struct {
+	Date  time.Time `json:"date"`
+	Close float64   `json:"close"`
 }
 
 type Quote struct {
@@ -109,13 +138,33 @@ func NewMarketDataAggregator(alpacaClient *alpaca.Client) *MarketDataAggregator
 	}
 }
 
+// NewMarketDataAggregatorFromSource creates an aggregator that reads from
+// source instead of Alpaca and VibeTrade
+func NewMarketDataAggregatorFromSource(source MarketSource) *MarketDataAggregator {
+	return &MarketDataAggregator{source: source}
+}
+
+// now is the time the aggregated data is as of
+func (mda *MarketDataAggregator) now() time.Time {
+	if mda.source != nil {
+		return mda.source.Now()
+	}
+	return time.Now()
+}
+
+// SetEventProvider sets where earnings, dividend and macro events come from
+func (mda *MarketDataAggregator) SetEventProvider(provider events.Provider) {
+	mda.eventProvider = provider
+}
+
 func (mda *MarketDataAggregator) AggregateDataForSymbols(ctx context.Context, symbols []string) (*AggregatedMarketData, error) {
 	aggregated := &AggregatedMarketData{
-		Timestamp:    time.Now(),
+		Timestamp:    mda.now(),
 		Quotes:       make(map[string]*Quote),
 		Options:      make(map[string][]*OptionChain),
 		Technicals:   make(map[string]*TechnicalIndicators),
 		Fundamentals: make(map[string]*Fundamentals),
+		History:      make(map[string][]DailyBar),
 	}
 
 	// Fetch quotes for all symbols
@@ -140,12 +189,13 @@ func (mda *MarketDataAggregator) AggregateDataForSymbols(ctx context.Context, sy
 
 	// Calculate technical indicators
 	for _, symbol := range symbols {
-		technicals, err := mda.calculateTechnicals(ctx, symbol)
+		bars, err := mda.FetchDailyBars(ctx, symbol)
 		if err != nil {
 			fmt.Printf("Error calculating technicals for %s: %v\n", symbol, err)
 			continue
 		}
-		aggregated.Technicals[symbol] = technicals
+		aggregated.History[symbol] = bars
+		aggregated.Technicals[symbol] = calculateTechnicals(bars)
 	}
 
 	// Fetch market statistics
@@ -156,10 +206,49 @@ func (mda *MarketDataAggregator) AggregateDataForSymbols(ctx context.Context, sy
 		aggregated.MarketStats = marketStats
 	}
 
+	// Fetch the event calendar from a week back to two months out
+	if mda.eventProvider != nil {
+		now := mda.now()
+		calendar, err := mda.eventProvider.Events(ctx, symbols, now.AddDate(0, 0, -7), now.AddDate(0, 0, 60))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to fetch event calendar")
+		} else {
+			aggregated.Calendar = calendar
+			aggregated.Events = make(map[string]*events.Proximity)
+			for _, symbol := range symbols {
+				aggregated.Events[symbol] = events.ProximityFor(symbol, calendar, now)
+			}
+		}
+	}
+
 	return aggregated, nil
 }
 
+// LatestPrice returns symbol's latest mid price
+func (mda *MarketDataAggregator) LatestPrice(ctx context.Context, symbol string) (float64, error) {
+	quote, err := mda.fetchQuote(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	return quote.Price, nil
+}
+
+// LatestQuote returns symbol's latest bid, ask and mid
+func (mda *MarketDataAggregator) LatestQuote(ctx context.Context, symbol string) (*Quote, error) {
+	return mda.fetchQuote(ctx, symbol)
+}
+
+// OptionChains returns symbol's option chain, mock contracts if no
+// VibeTrade API is configured
+func (mda *MarketDataAggregator) OptionChains(ctx context.Context, symbol string) ([]*OptionChain, error) {
+	return mda.fetchOptionChains(ctx, symbol)
+}
+
 func (mda *MarketDataAggregator) fetchQuote(ctx context.Context, symbol string) (*Quote, error) {
+	if mda.source != nil {
+		return mda.source.Quote(ctx, symbol)
+	}
+
 	// Fetch latest quote from Alpaca
 	latestQuote, err := mda.marketData.GetLatestQuote(symbol, marketdata.GetLatestQuoteRequest{})
 	if err != nil {
@@ -177,6 +266,10 @@ func (mda *MarketDataAggregator) fetchQuote(ctx context.Context, symbol string)
 }
 
 func (mda *MarketDataAggregator) fetchOptionChains(ctx context.Context, symbol string) ([]*OptionChain, error) {
+	if mda.source != nil {
+		return mda.source.OptionChains(ctx, symbol)
+	}
+
 	// Use vibetrade API if available, otherwise fall back to mock data
 	if mda.vibetradeClient != nil {
 		// Fetch real options data from vibetrade API
@@ -285,8 +378,12 @@ func (mda *MarketDataAggregator) getMockOptionChains(symbol string) []*OptionCha
 	}
 }
 
-func (mda *MarketDataAggregator) calculateTechnicals(ctx context.Context, symbol string) (*TechnicalIndicators, error) {
-	// Fetch historical bars for technical calculations
+// FetchDailyBars returns the last six months of daily closes for symbol
+func (mda *MarketDataAggregator) FetchDailyBars(ctx context.Context, symbol string) ([]DailyBar, error) {
+	if mda.source != nil {
+		return mda.source.DailyBars(ctx, symbol)
+	}
+
 	bars, err := mda.marketData.GetBars(symbol, marketdata.GetBarsRequest{
 		TimeFrame: marketdata.OneDay,
 		Start:     time.Now().AddDate(0, -6, 0),
@@ -296,6 +393,29 @@ func (mda *MarketDataAggregator) calculateTechnicals(ctx context.Context, symbol
 		return nil, err
 	}
 
+	history := make([]DailyBar, 0, len(bars))
+	for _, bar := range bars {
+		history = append(history, DailyBar{Date: bar.Timestamp, Close: bar.Close})
+	}
+	return history, nil
+}
+
+// FetchHistory returns daily closes for each symbol, skipping symbols whose
+// bars cannot be fetched
+func (mda *MarketDataAggregator) FetchHistory(ctx context.Context, symbols []string) map[string][]DailyBar {
+	history := make(map[string][]DailyBar)
+	for _, symbol := range symbols {
+		bars, err := mda.FetchDailyBars(ctx, symbol)
+		if err != nil {
+			logrus.WithError(err).Errorf("Failed to fetch daily bars for %s", symbol)
+			continue
+		}
+		history[symbol] = bars
+	}
+	return history
+}
+
+func calculateTechnicals(bars []DailyBar) *TechnicalIndicators {
 	// Calculate simple moving averages and other indicators
 	// This is simplified - in production you'd use a proper technical analysis library
 	tech := &TechnicalIndicators{}
@@ -312,7 +432,7 @@ func (mda *MarketDataAggregator) calculateTechnicals(ctx context.Context, symbol
 	// RSI calculation placeholder
 	tech.RSI = 50.0 // Neutral RSI
 	
-	return tech, nil
+	return tech
 }
 
 func (mda *MarketDataAggregator) fetchMarketStats(ctx context.Context) (*MarketStatistics, error) {
@@ -326,14 +446,12 @@ func (mda *MarketDataAggregator) fetchMarketStats(ctx context.Context) (*MarketS
 	}
 	
 	// Fetch SPY and QQQ changes
-	spyQuote, err := mda.fetchQuote(ctx, "SPY")
-	if err == nil {
+	if _, err := mda.fetchQuote(ctx, "SPY"); err == nil {
 		// Calculate daily change percentage
 		stats.SPYChange = 0.0 // Placeholder
 	}
 	
-	qqqQuote, err := mda.fetchQuote(ctx, "QQQ")
-	if err == nil {
+	if _, err := mda.fetchQuote(ctx, "QQQ"); err == nil {
 		// Calculate daily change percentage
 		stats.QQQChange = 0.0 // Placeholder
 	}
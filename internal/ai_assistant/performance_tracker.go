@@ -1,31 +1,43 @@
 package ai_assistant
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
-	"sync"
+	"math"
 	"time"
 )
 
 // PerformanceTracker tracks AI recommendation performance
 type PerformanceTracker struct {
-	mu          sync.RWMutex
-	dataDir     string
-	currentFile string
+	store RecordStore
 }
 
+// Recommendation record statuses
+const (
+	RecordPending  = "pending"
+	RecordExecuted = "executed"
+	RecordClosed   = "closed"
+	RecordExpired  = "expired"
+)
+
+// ErrRecordNotFound is returned for an unknown recommendation record
+var ErrRecordNotFound = errors.New("recommendation record not found")
+
 // RecommendationRecord stores a single AI recommendation
 type RecommendationRecord struct {
 	ID            string                `json:"id"`
+	UserID        string                `json:"user_id,omitempty"`
 	Timestamp     time.Time             `json:"timestamp"`
 	Recommendation TradeRecommendation   `json:"recommendation"`
 	Executed      bool                  `json:"executed"`
 	ExecutionTime *time.Time            `json:"execution_time,omitempty"`
 	ExitTime      *time.Time            `json:"exit_time,omitempty"`
 	ActualProfit  *float64              `json:"actual_profit,omitempty"`
-	Status        string                `json:"status"` // "pending", "executed", "closed", "expired"
+	Status        string                `json:"status"` // RecordPending, RecordExecuted, RecordClosed or RecordExpired
+	SnapshotID    string                `json:"snapshot_id,omitempty"` // Market data the recommendation was made from
+	EntryCost     *float64              `json:"entry_cost,omitempty"`  // Paid for the option legs, negative for a credit; set by Reconcile
+	MarkValue     *float64              `json:"mark_value,omitempty"`  // Option legs' latest market value
+	MarkedAt      *time.Time            `json:"marked_at,omitempty"`
 }
 
 // PerformanceMetrics contains aggregated performance statistics
@@ -61,109 +73,65 @@ type TimeframeMetrics struct {
 	Return       float64   `json:"return"`
 }
 
-func NewPerformanceTracker(dataDir string) (*PerformanceTracker, error) {
-	// Create data directory if it doesn't exist
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create data directory: %w", err)
-	}
-
-	// Create current month's file
-	currentFile := filepath.Join(dataDir, fmt.Sprintf("ai_performance_%s.json", time.Now().Format("2006_01")))
-
-	return &PerformanceTracker{
-		dataDir:     dataDir,
-		currentFile: currentFile,
-	}, nil
+// NewPerformanceTracker creates a tracker keeping its records in store
+func NewPerformanceTracker(store RecordStore) *PerformanceTracker {
+	return &PerformanceTracker{store: store}
 }
 
-// RecordRecommendation saves a new AI recommendation
-func (pt *PerformanceTracker) RecordRecommendation(rec TradeRecommendation) (*RecommendationRecord, error) {
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
-
+// RecordRecommendation saves a new AI recommendation made for userID from
+// the market snapshot with snapshotID, if it was stored
+func (pt *PerformanceTracker) RecordRecommendation(userID string, rec TradeRecommendation, snapshotID string) (*RecommendationRecord, error) {
 	record := &RecommendationRecord{
 		ID:             generateRecordID(),
+		UserID:         userID,
 		Timestamp:      time.Now(),
 		Recommendation: rec,
 		Executed:       false,
-		Status:         "pending",
+		Status:         RecordPending,
+		SnapshotID:     snapshotID,
 	}
 
-	// Load existing records
-	records, err := pt.loadRecords()
-	if err != nil {
+	if err := pt.store.Create(record); err != nil {
 		return nil, err
 	}
-
-	// Add new record
-	records = append(records, record)
-
-	// Save updated records
-	if err := pt.saveRecords(records); err != nil {
-		return nil, err
-	}
-
 	return record, nil
 }
 
 // UpdateExecution marks a recommendation as executed
 func (pt *PerformanceTracker) UpdateExecution(recordID string, executed bool) error {
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
-
-	records, err := pt.loadRecords()
-	if err != nil {
-		return err
-	}
-
-	for i, rec := range records {
-		if rec.ID == recordID {
-			records[i].Executed = executed
-			if executed {
-				now := time.Now()
-				records[i].ExecutionTime = &now
-				records[i].Status = "executed"
-			}
-			break
+	return pt.UpdateRecord(recordID, func(rec *RecommendationRecord) {
+		rec.Executed = executed
+		if executed {
+			now := time.Now()
+			rec.ExecutionTime = &now
+			rec.Status = RecordExecuted
 		}
-	}
-
-	return pt.saveRecords(records)
+	})
 }
 
 // UpdateTradeResult updates the outcome of a closed trade
 func (pt *PerformanceTracker) UpdateTradeResult(recordID string, profit float64) error {
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
-
-	records, err := pt.loadRecords()
-	if err != nil {
-		return err
-	}
-
-	for i, rec := range records {
-		if rec.ID == recordID {
-			now := time.Now()
-			records[i].ExitTime = &now
-			records[i].ActualProfit = &profit
-			records[i].Status = "closed"
-			break
-		}
-	}
-
-	return pt.saveRecords(records)
+	return pt.UpdateRecord(recordID, func(rec *RecommendationRecord) {
+		now := time.Now()
+		rec.ExitTime = &now
+		rec.ActualProfit = &profit
+		rec.Status = RecordClosed
+	})
 }
 
 // GetMetrics calculates performance metrics for a time period
 func (pt *PerformanceTracker) GetMetrics(startDate, endDate time.Time) (*PerformanceMetrics, error) {
-	pt.mu.RLock()
-	defer pt.mu.RUnlock()
-
-	records, err := pt.loadRecordsForPeriod(startDate, endDate)
+	records, err := pt.store.Find(RecordQuery{From: startDate, To: endDate})
 	if err != nil {
 		return nil, err
 	}
 
+	return CalculateMetrics(records, time.Now()), nil
+}
+
+// CalculateMetrics summarizes the results of records, with the daily,
+// weekly and monthly timeframes ending at asOf
+func CalculateMetrics(records []*RecommendationRecord, asOf time.Time) *PerformanceMetrics {
 	metrics := &PerformanceMetrics{
 		TotalRecommendations: len(records),
 		ByStrategy:          make(map[string]*StrategyMetrics),
@@ -235,100 +203,86 @@ func (pt *PerformanceTracker) GetMetrics(startDate, endDate time.Time) (*Perform
 	}
 
 	// Add timeframe metrics
-	pt.addTimeframeMetrics(metrics, records)
+	addTimeframeMetrics(metrics, records, asOf)
 
-	return metrics, nil
+	return metrics
 }
 
-// GetRecommendationHistory retrieves recent recommendations
+// GetRecommendationHistory retrieves the most recent recommendations,
+// oldest first
 func (pt *PerformanceTracker) GetRecommendationHistory(limit int) ([]*RecommendationRecord, error) {
-	pt.mu.RLock()
-	defer pt.mu.RUnlock()
+	return pt.store.Find(RecordQuery{Limit: limit, Newest: true})
+}
 
-	records, err := pt.loadRecords()
+// ClosedRecords returns the recommendations made between startDate and
+// endDate that were closed or expired with a result, e.g. to calibrate the
+// risk scoring weights
+func (pt *PerformanceTracker) ClosedRecords(startDate, endDate time.Time) ([]*RecommendationRecord, error) {
+	records, err := pt.store.Find(RecordQuery{From: startDate, To: endDate, Statuses: []string{RecordClosed, RecordExpired}})
 	if err != nil {
 		return nil, err
 	}
 
-	// Sort by timestamp (newest first)
-	// Return limited number of records
-	if limit > 0 && limit < len(records) {
-		return records[len(records)-limit:], nil
+	var closed []*RecommendationRecord
+	for _, rec := range records {
+		if rec.ActualProfit != nil {
+			closed = append(closed, rec)
+		}
 	}
+	return closed, nil
+}
 
-	return records, nil
+// OpenRecords returns the pending and executed records, oldest first
+func (pt *PerformanceTracker) OpenRecords() ([]*RecommendationRecord, error) {
+	return pt.store.Find(RecordQuery{Statuses: []string{RecordPending, RecordExecuted}})
 }
 
-func (pt *PerformanceTracker) loadRecords() ([]*RecommendationRecord, error) {
-	var records []*RecommendationRecord
-	
-	data, err := os.ReadFile(pt.currentFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return records, nil // Empty records if file doesn't exist
-		}
-		return nil, err
-	}
+// UpdateRecord applies update to the record with recordID
+func (pt *PerformanceTracker) UpdateRecord(recordID string, update func(*RecommendationRecord)) error {
+	return pt.store.Update(recordID, func(rec *RecommendationRecord) error {
+		update(rec)
+		return nil
+	})
+}
 
-	if err := json.Unmarshal(data, &records); err != nil {
+// RealizedByUser sums the profit of every closed and expired record by the
+// user it was made for
+func (pt *PerformanceTracker) RealizedByUser() (map[string]float64, error) {
+	records, err := pt.store.Find(RecordQuery{Statuses: []string{RecordClosed, RecordExpired}})
+	if err != nil {
 		return nil, err
 	}
 
-	return records, nil
-}
-
-func (pt *PerformanceTracker) loadRecordsForPeriod(startDate, endDate time.Time) ([]*RecommendationRecord, error) {
-	var allRecords []*RecommendationRecord
-
-	// Load records from multiple monthly files if needed
-	current := startDate
-	for current.Before(endDate) || current.Equal(endDate) {
-		monthFile := filepath.Join(pt.dataDir, fmt.Sprintf("ai_performance_%s.json", current.Format("2006_01")))
-		
-		if data, err := os.ReadFile(monthFile); err == nil {
-			var records []*RecommendationRecord
-			if err := json.Unmarshal(data, &records); err == nil {
-				// Filter records within date range
-				for _, rec := range records {
-					if rec.Timestamp.After(startDate) && rec.Timestamp.Before(endDate) {
-						allRecords = append(allRecords, rec)
-					}
-				}
-			}
+	realized := make(map[string]float64)
+	for _, rec := range records {
+		if rec.ActualProfit != nil {
+			realized[rec.UserID] += *rec.ActualProfit
 		}
-		
-		// Move to next month
-		current = current.AddDate(0, 1, 0)
 	}
-
-	return allRecords, nil
+	return realized, nil
 }
 
-func (pt *PerformanceTracker) saveRecords(records []*RecommendationRecord) error {
-	data, err := json.MarshalIndent(records, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(pt.currentFile, data, 0644)
+// GetRecord returns the record with recordID
+func (pt *PerformanceTracker) GetRecord(recordID string) (*RecommendationRecord, error) {
+	return pt.store.Get(recordID)
 }
 
-func (pt *PerformanceTracker) addTimeframeMetrics(metrics *PerformanceMetrics, records []*RecommendationRecord) {
+func addTimeframeMetrics(metrics *PerformanceMetrics, records []*RecommendationRecord, asOf time.Time) {
 	// Daily metrics
-	dailyMetrics := pt.calculateTimeframeMetrics(records, "daily", 1)
+	dailyMetrics := calculateTimeframeMetrics(records, "daily", 1, asOf)
 	metrics.ByTimeframe["daily"] = dailyMetrics
 
 	// Weekly metrics
-	weeklyMetrics := pt.calculateTimeframeMetrics(records, "weekly", 7)
+	weeklyMetrics := calculateTimeframeMetrics(records, "weekly", 7, asOf)
 	metrics.ByTimeframe["weekly"] = weeklyMetrics
 
 	// Monthly metrics
-	monthlyMetrics := pt.calculateTimeframeMetrics(records, "monthly", 30)
+	monthlyMetrics := calculateTimeframeMetrics(records, "monthly", 30, asOf)
 	metrics.ByTimeframe["monthly"] = monthlyMetrics
 }
 
-func (pt *PerformanceTracker) calculateTimeframeMetrics(records []*RecommendationRecord, period string, days int) *TimeframeMetrics {
-	endDate := time.Now()
+func calculateTimeframeMetrics(records []*RecommendationRecord, period string, days int, asOf time.Time) *TimeframeMetrics {
+	endDate := asOf
 	startDate := endDate.AddDate(0, 0, -days)
 
 	metrics := &TimeframeMetrics{
@@ -401,6 +355,9 @@ func calculateMaxDrawdown(returns []float64) float64 {
 		if cumulative > peak {
 			peak = cumulative
 		}
+		if peak <= 0 {
+			continue // No gains yet to draw down from
+		}
 		drawdown := (peak - cumulative) / peak
 		if drawdown > maxDrawdown {
 			maxDrawdown = drawdown
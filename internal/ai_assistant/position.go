@@ -0,0 +1,229 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Position types
+const (
+	PositionStock = "stock"
+	PositionCall  = "call"
+	PositionPut   = "put"
+)
+
+// Position is a typed holding used by the risk engines. Greeks are per
+// share, as quoted on the option chain; multiply by Quantity and Multiplier
+// for position-level exposure. Quantity is negative for short positions.
+type Position struct {
+	Symbol          string    `json:"symbol"`     // Contract or stock symbol as held
+	Underlying      string    `json:"underlying"` // Stock symbol the position is priced off
+	Type            string    `json:"type"`       // PositionStock, PositionCall or PositionPut
+	Quantity        float64   `json:"quantity"`
+	Multiplier      float64   `json:"multiplier"`
+	Strike          float64   `json:"strike,omitempty"`
+	Expiration      time.Time `json:"expiration,omitempty"`
+	UnderlyingPrice float64   `json:"underlying_price"`
+	MarketValue     float64   `json:"market_value"`
+	IV              float64   `json:"implied_volatility,omitempty"`
+	Delta           float64   `json:"delta,omitempty"`
+	Gamma           float64   `json:"gamma,omitempty"`
+	Theta           float64   `json:"theta,omitempty"` // Per calendar day
+	Vega            float64   `json:"vega,omitempty"`  // Per vol point
+	MaxLoss         float64   `json:"max_loss,omitempty"`
+}
+
+// IsOption reports whether the position is a call or put
+func (p *Position) IsOption() bool {
+	return p.Type == PositionCall || p.Type == PositionPut
+}
+
+// YearsToExpiry returns the time to expiration in years as of now, or 0 if
+// the position is not an option or has expired
+func (p *Position) YearsToExpiry(now time.Time) float64 {
+	if !p.IsOption() || p.Expiration.IsZero() {
+		return 0
+	}
+	years := p.Expiration.Sub(now).Hours() / (24 * 365)
+	if years < 0 {
+		return 0
+	}
+	return years
+}
+
+// occSymbol matches OCC option symbols such as AAPL240119C00150000
+var occSymbol = regexp.MustCompile(`^([A-Z.]{1,6})\s*(\d{6})([CP])(\d{8})$`)
+
+// OCCSymbol formats an option leg as an OCC symbol, e.g. the 150 call on
+// AAPL expiring 2024-01-19 as AAPL240119C00150000
+func OCCSymbol(underlying string, leg OptionLeg) (string, error) {
+	if leg.Type != PositionCall && leg.Type != PositionPut {
+		return "", fmt.Errorf("%s leg is not an option", leg.Type)
+	}
+	expiration, err := time.Parse("2006-01-02", leg.Expiration)
+	if err != nil {
+		return "", fmt.Errorf("%s %g %s has no valid expiration", underlying, leg.Strike, leg.Type)
+	}
+	if leg.Strike <= 0 {
+		return "", fmt.Errorf("%s %s has no strike", underlying, leg.Type)
+	}
+	right := "C"
+	if leg.Type == PositionPut {
+		right = "P"
+	}
+	return fmt.Sprintf("%s%s%s%08d", strings.ToUpper(underlying), expiration.Format("060102"), right, int64(math.Round(leg.Strike*1000))), nil
+}
+
+// ParseOCCSymbol splits an OCC option symbol into its underlying and an
+// option leg with the contract's type, strike and expiration
+func ParseOCCSymbol(symbol string) (string, OptionLeg, bool) {
+	match := occSymbol.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(symbol)))
+	if match == nil {
+		return "", OptionLeg{}, false
+	}
+	expiration, err := time.Parse("060102", match[2])
+	if err != nil {
+		return "", OptionLeg{}, false
+	}
+	strike, _ := strconv.ParseFloat(match[4], 64)
+	leg := OptionLeg{Type: PositionCall, Strike: strike / 1000, Expiration: expiration.Format("2006-01-02")}
+	if match[3] == "P" {
+		leg.Type = PositionPut
+	}
+	return match[1], leg, true
+}
+
+// PositionFromMap builds a Position from the loosely typed maps used by the
+// HTTP API and the portfolio. Option details are taken from explicit fields
+// when present, otherwise parsed from an OCC symbol.
+func PositionFromMap(m map[string]interface{}) (*Position, error) {
+	symbol := strings.ToUpper(strings.TrimSpace(stringField(m, "symbol")))
+	if symbol == "" {
+		return nil, fmt.Errorf("position is missing a symbol")
+	}
+
+	p := &Position{
+		Symbol:          symbol,
+		Underlying:      strings.ToUpper(stringField(m, "underlying")),
+		Type:            strings.ToLower(stringField(m, "type", "option_type", "asset_type")),
+		Quantity:        floatField(m, "quantity", "qty"),
+		Multiplier:      floatField(m, "multiplier"),
+		Strike:          floatField(m, "strike"),
+		UnderlyingPrice: floatField(m, "underlying_price", "price"),
+		MarketValue:     floatField(m, "market_value"),
+		IV:              floatField(m, "implied_volatility", "iv"),
+		Delta:           floatField(m, "delta"),
+		Gamma:           floatField(m, "gamma"),
+		Theta:           floatField(m, "theta"),
+		Vega:            floatField(m, "vega"),
+		MaxLoss:         floatField(m, "max_loss"),
+	}
+
+	if side := strings.ToLower(stringField(m, "side")); (side == "short" || side == "sell") && p.Quantity > 0 {
+		p.Quantity = -p.Quantity
+	}
+
+	if expiration := stringField(m, "expiration", "expiry"); expiration != "" {
+		t, err := time.Parse("2006-01-02", expiration)
+		if err != nil {
+			return nil, fmt.Errorf("position %s has invalid expiration %q: %w", symbol, expiration, err)
+		}
+		p.Expiration = t.Add(16 * time.Hour) // Options expire at the close
+	}
+
+	if match := occSymbol.FindStringSubmatch(symbol); match != nil {
+		if p.Underlying == "" {
+			p.Underlying = match[1]
+		}
+		if p.Expiration.IsZero() {
+			if t, err := time.Parse("060102", match[2]); err == nil {
+				p.Expiration = t.Add(16 * time.Hour)
+			}
+		}
+		if p.Type == "" || p.Type == "option" {
+			p.Type = PositionCall
+			if match[3] == "P" {
+				p.Type = PositionPut
+			}
+		}
+		if p.Strike == 0 {
+			strike, _ := strconv.ParseFloat(match[4], 64)
+			p.Strike = strike / 1000
+		}
+	}
+
+	switch p.Type {
+	case "c":
+		p.Type = PositionCall
+	case "p":
+		p.Type = PositionPut
+	case PositionCall, PositionPut:
+	default:
+		p.Type = PositionStock
+	}
+
+	if p.Underlying == "" {
+		p.Underlying = symbol
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = 1
+		if p.IsOption() {
+			p.Multiplier = 100
+		}
+	}
+	if p.IV > 3 {
+		p.IV /= 100 // Quoted in percent
+	}
+	if p.Type == PositionStock && p.UnderlyingPrice == 0 && p.Quantity != 0 {
+		p.UnderlyingPrice = p.MarketValue / p.Quantity
+	}
+
+	return p, nil
+}
+
+// PositionsFromMaps converts each map with PositionFromMap, returning the
+// positions that parsed and a message for each that did not
+func PositionsFromMaps(maps []map[string]interface{}) ([]*Position, []string) {
+	var positions []*Position
+	var problems []string
+	for _, m := range maps {
+		p, err := PositionFromMap(m)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		positions = append(positions, p)
+	}
+	return positions, problems
+}
+
+func stringField(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if s, ok := m[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func floatField(m map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		switch v := m[key].(type) {
+		case float64:
+			return v
+		case int:
+			return float64(v)
+		case int64:
+			return float64(v)
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	}
+	return 0
+}
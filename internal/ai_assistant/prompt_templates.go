@@ -65,14 +65,19 @@ Selection Rules:
 2. Ensure diversification: maximum of 2 trades per GICS sector
 3. Net basket Delta must remain between [-0.30, +0.30] × (NAV / 100k)
 4. Net basket Vega must remain ≥ -0.05 × (NAV / 100k)
-5. In case of ties, prefer higher momentum_z and flow_z scores
+5. Check each symbol's events (days_away from today): no short premium within 7 days of or expiring after earnings, no short calls within 5 days of an ex-dividend date, and no new short premium the day before or of an FOMC decision or CPI release
+6. In case of ties, prefer higher momentum_z and flow_z scores
 
 Output Format:
 Provide output as a JSON array with exactly 5 trades, each containing:
 {
   "ticker": "SYMBOL",
   "strategy": "strategy name",
-  "legs": "option legs description",
+  "legs": "Sell 1 SYMBOL 2024-02-16 180P / Buy 1 SYMBOL 2024-02-16 175P",
+  "leg_details": [
+    {"action": "sell", "type": "put", "strike": 180, "expiration": "2024-02-16", "quantity": 1},
+    {"action": "buy", "type": "put", "strike": 175, "expiration": "2024-02-16", "quantity": 1}
+  ],
   "thesis": "30 words or less explanation",
   "pop": 0.75,
   "max_loss": 500,
@@ -81,6 +86,8 @@ Provide output as a JSON array with exactly 5 trades, each containing:
 }
 
 Additional Guidelines:
+- Give every leg in leg_details: action "buy" or "sell", type "call", "put" or "stock", numeric strike, expiration as YYYY-MM-DD, and quantity in contracts (shares for stock)
+- Quote max_loss and max_profit for one contract of the structure; the number of contracts is set by the risk engine
 - Limit each trade thesis to ≤ 30 words
 - Use straightforward language, free from exaggerated claims
 - If fewer than 5 trades satisfy all criteria, clearly indicate: "Fewer than 5 trades meet criteria, do not execute."
@@ -97,7 +104,7 @@ const riskAnalysisPrompt = `You are a professional risk manager specializing in
 2. Risk Metrics
    - Maximum portfolio loss
    - Value at Risk (VaR) at 95% confidence
-   - Stress test scenarios
+   - Stress test scenarios: summarize the worst scenarios from the computed stress results and the positions that drive them
    - Correlation risks
 
 3. Concentration Analysis
@@ -110,6 +117,8 @@ const riskAnalysisPrompt = `You are a professional risk manager specializing in
    - Position sizing adjustments
    - Risk reduction strategies
 
+When computed Greeks, VaR or stress test results are provided, report those figures exactly. Do not invent or re-estimate numbers that were computed for you.
+
 Provide clear, actionable insights focused on protecting capital while maintaining income generation.`
 
 const educationalPrompt = `You are an expert options trading educator. Explain complex concepts in simple, accessible language. When explaining strategies:
@@ -0,0 +1,548 @@
+package ai_assistant
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Proposal states. A proposal starts pending and ends approved, rejected or
+// expired.
+const (
+	ProposalPending  = "pending"
+	ProposalApproved = "approved"
+	ProposalRejected = "rejected"
+	ProposalExpired  = "expired"
+)
+
+// Proposal audit actions
+const (
+	ProposalActionPropose = "propose"
+	ProposalActionApprove = "approve"
+	ProposalActionReject  = "reject"
+	ProposalActionExpire  = "expire"
+)
+
+// SystemActor is the actor recorded for transitions nobody made by hand
+const SystemActor = "system"
+
+var (
+	ErrProposalNotFound   = errors.New("proposal not found")
+	ErrProposalNotPending = errors.New("proposal is no longer pending")
+	ErrNotAuthorized      = errors.New("not authorized to decide this proposal")
+	ErrAlreadyApproved    = errors.New("already approved by this user")
+)
+
+// ApprovalConfig controls how long AI trades wait for approval
+type ApprovalConfig struct {
+	// ExpireAfterMinutes expires a pending proposal after this long; 0 never
+	ExpireAfterMinutes int `json:"expire_after_minutes" yaml:"expire_after_minutes"`
+	// MaxUnderlyingMove expires a pending proposal when the underlying moves
+	// more than this % from its price when proposed; 0 disables
+	MaxUnderlyingMove float64 `json:"max_underlying_move" yaml:"max_underlying_move"`
+	// FourEyesThreshold requires a second approver for accounts worth at
+	// least this much; 0 disables
+	FourEyesThreshold float64 `json:"four_eyes_threshold" yaml:"four_eyes_threshold"`
+}
+
+// Proposal is an AI trade awaiting, or having received, a decision
+type Proposal struct {
+	ID                string               `json:"id"`
+	UserID            string               `json:"user_id"`
+	State             string               `json:"state"`
+	Trade             TradeRecommendation  `json:"trade"`
+	UnderlyingPrice   float64              `json:"underlying_price,omitempty"` // When proposed
+	MaxUnderlyingMove float64              `json:"max_underlying_move,omitempty"`
+	AccountValue      float64              `json:"account_value"`
+	RequiredApprovals int                  `json:"required_approvals"`
+	Approvals         []ProposalDecision   `json:"approvals"`
+	Reason            string               `json:"reason,omitempty"` // Why it was rejected or expired
+	CreatedAt         time.Time            `json:"created_at"`
+	ExpiresAt         *time.Time           `json:"expires_at,omitempty"`
+	DecidedAt         *time.Time           `json:"decided_at,omitempty"`
+	History           []ProposalTransition `json:"history"`
+}
+
+// ProposalDecision is one approval of a proposal
+type ProposalDecision struct {
+	Actor   string    `json:"actor"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// ProposalTransition records one action on a proposal for the audit trail
+type ProposalTransition struct {
+	ProposalID string    `json:"proposal_id"`
+	UserID     string    `json:"user_id"`
+	Action     string    `json:"action"`
+	From       string    `json:"from,omitempty"`
+	To         string    `json:"to"`
+	Actor      string    `json:"actor"`
+	Reason     string    `json:"reason,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// Approver is the user deciding a proposal. Reviewers may decide other
+// users' proposals; anyone may decide their own.
+type Approver struct {
+	UserID   string
+	Reviewer bool
+}
+
+// ProposalAuditLog receives every proposal transition
+type ProposalAuditLog interface {
+	Record(transition ProposalTransition) error
+}
+
+// PriceSource returns the latest price of an underlying
+type PriceSource interface {
+	LatestPrice(ctx context.Context, symbol string) (float64, error)
+}
+
+// proposalRetention is how long decided proposals are kept in the book; the
+// audit log keeps their history for good
+const proposalRetention = 30 * 24 * time.Hour
+
+// ProposalBook holds AI trade proposals through their approval lifecycle.
+// Pending proposals expire when they are read after their deadline or once
+// the underlying has moved too far. State is persisted to a JSON file and
+// every transition is appended to the audit log.
+type ProposalBook struct {
+	mu        sync.Mutex
+	path      string
+	audit     ProposalAuditLog
+	prices    PriceSource
+	now       func() time.Time
+	proposals map[string]*Proposal
+}
+
+// NewProposalBook loads proposals from path, starting empty if the file
+// does not exist. An empty path keeps them in memory only; a nil prices
+// disables the underlying move check.
+func NewProposalBook(path string, audit ProposalAuditLog, prices PriceSource) (*ProposalBook, error) {
+	if audit == nil {
+		return nil, fmt.Errorf("proposal audit log is required")
+	}
+	b := &ProposalBook{
+		path:      path,
+		audit:     audit,
+		prices:    prices,
+		now:       time.Now,
+		proposals: make(map[string]*Proposal),
+	}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proposals: %w", err)
+	}
+	var proposals []*Proposal
+	if err := json.Unmarshal(data, &proposals); err != nil {
+		return nil, fmt.Errorf("failed to parse proposals: %w", err)
+	}
+	for _, p := range proposals {
+		b.proposals[p.ID] = p
+	}
+	return b, nil
+}
+
+// Propose turns screened trades into proposals for userID. When approval
+// is not required they are approved at once by the system. Each trade's
+// ProposalID is set.
+func (b *ProposalBook) Propose(userID string, trades []TradeRecommendation, portfolio map[string]interface{}, market *AggregatedMarketData, config ApprovalConfig, requireApproval bool) ([]*Proposal, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	accountValue := portfolioValue(portfolio)
+	required := 1
+	if config.FourEyesThreshold > 0 && accountValue >= config.FourEyesThreshold {
+		required = 2
+	}
+
+	proposals := make([]*Proposal, 0, len(trades))
+	for i := range trades {
+		p := &Proposal{
+			ID:                newProposalID(),
+			UserID:            userID,
+			State:             ProposalPending,
+			AccountValue:      accountValue,
+			RequiredApprovals: required,
+			Approvals:         []ProposalDecision{},
+			MaxUnderlyingMove: config.MaxUnderlyingMove,
+			CreatedAt:         now,
+		}
+		if market != nil {
+			if quote := market.Quotes[trades[i].Ticker]; quote != nil {
+				p.UnderlyingPrice = quote.Price
+			}
+		}
+		if config.ExpireAfterMinutes > 0 {
+			expires := now.Add(time.Duration(config.ExpireAfterMinutes) * time.Minute)
+			p.ExpiresAt = &expires
+		}
+		trades[i].ProposalID = p.ID
+		p.Trade = trades[i]
+
+		reason := fmt.Sprintf("Awaiting %s", approvalCount(required))
+		if !requireApproval {
+			reason = "Manual approval not required by policy"
+		}
+		if err := b.transition(p, ProposalActionPropose, ProposalPending, SystemActor, reason, now); err != nil {
+			return nil, err
+		}
+		if !requireApproval {
+			if err := b.transition(p, ProposalActionApprove, ProposalApproved, SystemActor, reason, now); err != nil {
+				return nil, err
+			}
+		}
+		b.proposals[p.ID] = p
+		proposals = append(proposals, p.snapshot())
+	}
+	return proposals, b.save()
+}
+
+// List returns userID's proposals, or everyone's if userID is empty, in
+// state if given, newest first
+func (b *ProposalBook) List(ctx context.Context, userID, state string) ([]*Proposal, error) {
+	if err := b.ExpireStale(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var proposals []*Proposal
+	for _, p := range b.proposals {
+		if (userID == "" || p.UserID == userID) && (state == "" || p.State == state) {
+			proposals = append(proposals, p.snapshot())
+		}
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].CreatedAt.After(proposals[j].CreatedAt) })
+	return proposals, nil
+}
+
+// Get returns one proposal
+func (b *ProposalBook) Get(ctx context.Context, id string) (*Proposal, error) {
+	if err := b.ExpireStale(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.proposals[id]
+	if !ok {
+		return nil, ErrProposalNotFound
+	}
+	return p.snapshot(), nil
+}
+
+// Decidable returns proposal id if it is pending and approver may decide
+// it, so callers can authorize the approver before checking anything else
+func (b *ProposalBook) Decidable(ctx context.Context, id string, approver Approver) (*Proposal, error) {
+	if err := b.ExpireStale(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, err := b.decidable(id, approver)
+	if err != nil {
+		return nil, err
+	}
+	return p.snapshot(), nil
+}
+
+// Approve records approver's approval of proposal id. The proposal is
+// approved once it has its required number of distinct approvers.
+func (b *ProposalBook) Approve(ctx context.Context, id string, approver Approver, comment string) (*Proposal, error) {
+	if err := b.ExpireStale(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, err := b.decidable(id, approver)
+	if err != nil {
+		return nil, err
+	}
+	for _, approval := range p.Approvals {
+		if approval.Actor == approver.UserID {
+			return nil, ErrAlreadyApproved
+		}
+	}
+
+	now := b.now()
+	p.Approvals = append(p.Approvals, ProposalDecision{Actor: approver.UserID, Comment: comment, At: now})
+	to := ProposalPending
+	reason := fmt.Sprintf("Approval %d of %d", len(p.Approvals), p.RequiredApprovals)
+	if len(p.Approvals) >= p.RequiredApprovals {
+		to = ProposalApproved
+	}
+	if comment != "" {
+		reason += ": " + comment
+	}
+	if err := b.transition(p, ProposalActionApprove, to, approver.UserID, reason, now); err != nil {
+		return nil, err
+	}
+	return p.snapshot(), b.save()
+}
+
+// Reject rejects proposal id
+func (b *ProposalBook) Reject(ctx context.Context, id string, approver Approver, reason string) (*Proposal, error) {
+	if err := b.ExpireStale(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, err := b.decidable(id, approver)
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		reason = "Rejected"
+	}
+	if err := b.transition(p, ProposalActionReject, ProposalRejected, approver.UserID, reason, b.now()); err != nil {
+		return nil, err
+	}
+	return p.snapshot(), b.save()
+}
+
+// ExpireStale expires pending proposals past their deadline or whose
+// underlying has moved more than their limit. Prices are fetched once per
+// symbol; a symbol whose price cannot be fetched is left alone.
+func (b *ProposalBook) ExpireStale(ctx context.Context) error {
+	b.mu.Lock()
+	symbols := make(map[string]bool)
+	for _, p := range b.proposals {
+		if p.State == ProposalPending && p.MaxUnderlyingMove > 0 && p.UnderlyingPrice > 0 {
+			symbols[p.Trade.Ticker] = true
+		}
+	}
+	b.mu.Unlock()
+
+	prices := make(map[string]float64, len(symbols))
+	if b.prices != nil {
+		for symbol := range symbols {
+			if price, err := b.prices.LatestPrice(ctx, symbol); err == nil && price > 0 {
+				prices[symbol] = price
+			}
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	changed := false
+	for _, p := range b.proposals {
+		if p.State != ProposalPending {
+			continue
+		}
+		var reason string
+		at := now
+		if p.Expired(now) {
+			reason = fmt.Sprintf("Not approved within %s", p.ExpiresAt.Sub(p.CreatedAt).Round(time.Minute))
+			at = *p.ExpiresAt
+		} else if price, ok := prices[p.Trade.Ticker]; ok && p.MaxUnderlyingMove > 0 && p.UnderlyingPrice > 0 {
+			if move := (price/p.UnderlyingPrice - 1) * 100; math.Abs(move) > p.MaxUnderlyingMove {
+				reason = fmt.Sprintf("%s moved %+.2f%% from $%.2f to $%.2f, beyond the %.2f%% limit",
+					p.Trade.Ticker, move, p.UnderlyingPrice, price, p.MaxUnderlyingMove)
+			}
+		}
+		if reason == "" {
+			continue
+		}
+		if err := b.transition(p, ProposalActionExpire, ProposalExpired, SystemActor, reason, at); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return b.save()
+}
+
+// decidable returns proposal id if it is pending and approver may decide
+// it; callers hold b.mu
+func (b *ProposalBook) decidable(id string, approver Approver) (*Proposal, error) {
+	if approver.UserID == "" {
+		return nil, ErrNotAuthorized
+	}
+	p, ok := b.proposals[id]
+	if !ok || (approver.UserID != p.UserID && !approver.Reviewer) {
+		// Other users' proposals are not revealed to non-reviewers
+		return nil, ErrProposalNotFound
+	}
+	if p.State != ProposalPending {
+		return nil, fmt.Errorf("%w: %s", ErrProposalNotPending, p.State)
+	}
+	return p, nil
+}
+
+// transition moves p to state to and records the action; callers hold b.mu
+func (b *ProposalBook) transition(p *Proposal, action, to, actor, reason string, at time.Time) error {
+	t := ProposalTransition{
+		ProposalID: p.ID,
+		UserID:     p.UserID,
+		Action:     action,
+		To:         to,
+		Actor:      actor,
+		Reason:     reason,
+		At:         at,
+	}
+	if action != ProposalActionPropose {
+		t.From = p.State
+	}
+	if err := b.audit.Record(t); err != nil {
+		return fmt.Errorf("failed to audit proposal %s: %w", p.ID, err)
+	}
+
+	p.History = append(p.History, t)
+	p.State = to
+	if to != ProposalPending {
+		p.DecidedAt = &at
+		if to != ProposalApproved {
+			p.Reason = reason
+		}
+	}
+	return nil
+}
+
+// Expired reports whether p's deadline has passed at now. An approved
+// proposal past its deadline is not executed.
+func (p *Proposal) Expired(now time.Time) bool {
+	return p.ExpiresAt != nil && !now.Before(*p.ExpiresAt)
+}
+
+func (p *Proposal) snapshot() *Proposal {
+	cp := *p
+	cp.Approvals = append([]ProposalDecision{}, p.Approvals...)
+	cp.History = append([]ProposalTransition{}, p.History...)
+	return &cp
+}
+
+// save writes the proposals atomically, dropping decided ones past the
+// retention period; callers hold b.mu
+func (b *ProposalBook) save() error {
+	cutoff := b.now().Add(-proposalRetention)
+	proposals := make([]*Proposal, 0, len(b.proposals))
+	for id, p := range b.proposals {
+		if p.DecidedAt != nil && p.DecidedAt.Before(cutoff) {
+			delete(b.proposals, id)
+			continue
+		}
+		proposals = append(proposals, p)
+	}
+	if b.path == "" {
+		return nil
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].CreatedAt.Before(proposals[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(proposals, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return fmt.Errorf("failed to create proposals directory: %w", err)
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write proposals: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("failed to replace proposals: %w", err)
+	}
+	return nil
+}
+
+func newProposalID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("prop_%d", time.Now().UnixNano())
+	}
+	return "prop_" + hex.EncodeToString(buf)
+}
+
+func approvalCount(n int) string {
+	if n == 1 {
+		return "1 approval"
+	}
+	return fmt.Sprintf("%d approvals", n)
+}
+
+// FileProposalAuditLog appends proposal transitions as JSON lines to a file
+type FileProposalAuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileProposalAuditLog opens path for appending, creating it if needed
+func NewFileProposalAuditLog(path string) (*FileProposalAuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create proposal audit log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proposal audit log: %w", err)
+	}
+
+	return &FileProposalAuditLog{file: file}, nil
+}
+
+// Record writes transition and syncs it to disk
+func (l *FileProposalAuditLog) Record(transition ProposalTransition) error {
+	line, err := json.Marshal(transition)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write proposal audit event: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// Close closes the underlying file
+func (l *FileProposalAuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// ParseProposalState lowercases a state filter, rejecting unknown states
+func ParseProposalState(state string) (string, error) {
+	state = strings.ToLower(strings.TrimSpace(state))
+	switch state {
+	case "", ProposalPending, ProposalApproved, ProposalRejected, ProposalExpired:
+		return state, nil
+	}
+	return "", fmt.Errorf("unknown proposal state %q", state)
+}
@@ -0,0 +1,166 @@
+package ai_assistant
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// auditTrail keeps every transition recorded
+type auditTrail []ProposalTransition
+
+func (a *auditTrail) Record(transition ProposalTransition) error {
+	*a = append(*a, transition)
+	return nil
+}
+
+// fixedPrices quotes from a map
+type fixedPrices map[string]float64
+
+func (p fixedPrices) LatestPrice(ctx context.Context, symbol string) (float64, error) {
+	return p[symbol], nil
+}
+
+func newTestProposalBook(t *testing.T, prices PriceSource) (*ProposalBook, *auditTrail, *time.Time) {
+	t.Helper()
+	audit := &auditTrail{}
+	b, err := NewProposalBook("", audit, prices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	return b, audit, &now
+}
+
+func propose(t *testing.T, b *ProposalBook, accountValue float64, config ApprovalConfig) *Proposal {
+	t.Helper()
+	market := &AggregatedMarketData{Quotes: map[string]*Quote{"SPY": {Symbol: "SPY", Price: 500}}}
+	trades := []TradeRecommendation{{Ticker: "SPY", Strategy: "Bull Put Spread"}}
+	proposals, err := b.Propose("alice", trades, map[string]interface{}{"total_value": accountValue}, market, config, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trades[0].ProposalID != proposals[0].ID {
+		t.Errorf("trade's proposal id = %q, want %q", trades[0].ProposalID, proposals[0].ID)
+	}
+	return proposals[0]
+}
+
+func TestFourEyesNeedsTwoDistinctApprovers(t *testing.T) {
+	b, audit, _ := newTestProposalBook(t, nil)
+	p := propose(t, b, 500000, ApprovalConfig{FourEyesThreshold: 250000})
+	if p.RequiredApprovals != 2 {
+		t.Fatalf("required approvals = %d, want 2", p.RequiredApprovals)
+	}
+	ctx := context.Background()
+	owner := Approver{UserID: "alice"}
+
+	// Another user who is not a reviewer cannot see the proposal
+	if _, err := b.Approve(ctx, p.ID, Approver{UserID: "mallory"}, ""); !errors.Is(err, ErrProposalNotFound) {
+		t.Fatalf("non-reviewer approval = %v, want %v", err, ErrProposalNotFound)
+	}
+
+	p, err := b.Approve(ctx, p.ID, owner, "looks fine")
+	if err != nil || p.State != ProposalPending {
+		t.Fatalf("first approval = %v, %v, want still pending", p, err)
+	}
+	if _, err := b.Approve(ctx, p.ID, owner, ""); !errors.Is(err, ErrAlreadyApproved) {
+		t.Fatalf("second approval by the owner = %v, want %v", err, ErrAlreadyApproved)
+	}
+
+	p, err = b.Approve(ctx, p.ID, Approver{UserID: "risk-desk", Reviewer: true}, "")
+	if err != nil || p.State != ProposalApproved || p.DecidedAt == nil {
+		t.Fatalf("reviewer approval = %v, %v, want approved", p, err)
+	}
+	if len(*audit) != 3 || (*audit)[2].From != ProposalPending || (*audit)[2].To != ProposalApproved {
+		t.Errorf("audit trail = %+v, want propose and two approvals", *audit)
+	}
+
+	if _, err := b.Reject(ctx, p.ID, owner, ""); !errors.Is(err, ErrProposalNotPending) {
+		t.Errorf("reject after approval = %v, want %v", err, ErrProposalNotPending)
+	}
+}
+
+func TestSmallAccountNeedsOneApproval(t *testing.T) {
+	b, _, _ := newTestProposalBook(t, nil)
+	p := propose(t, b, 100000, ApprovalConfig{FourEyesThreshold: 250000})
+	if p.RequiredApprovals != 1 {
+		t.Fatalf("required approvals = %d, want 1", p.RequiredApprovals)
+	}
+	if p, err := b.Approve(context.Background(), p.ID, Approver{UserID: "alice"}, ""); err != nil || p.State != ProposalApproved {
+		t.Errorf("approval = %v, %v, want approved", p, err)
+	}
+}
+
+func TestDecidableAuthorizesBeforeRevealing(t *testing.T) {
+	b, _, _ := newTestProposalBook(t, nil)
+	p := propose(t, b, 100000, ApprovalConfig{})
+	ctx := context.Background()
+
+	if _, err := b.Decidable(ctx, p.ID, Approver{UserID: "mallory"}); !errors.Is(err, ErrProposalNotFound) {
+		t.Errorf("other user = %v, want %v", err, ErrProposalNotFound)
+	}
+	if _, err := b.Decidable(ctx, p.ID, Approver{}); !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("anonymous = %v, want %v", err, ErrNotAuthorized)
+	}
+	for _, approver := range []Approver{{UserID: "alice"}, {UserID: "risk-desk", Reviewer: true}} {
+		if got, err := b.Decidable(ctx, p.ID, approver); err != nil || got.ID != p.ID {
+			t.Errorf("%s = %v, %v, want the proposal", approver.UserID, got, err)
+		}
+	}
+}
+
+func TestPendingProposalExpiresAtDeadline(t *testing.T) {
+	b, _, now := newTestProposalBook(t, nil)
+	p := propose(t, b, 100000, ApprovalConfig{ExpireAfterMinutes: 15})
+	if p.ExpiresAt == nil || !p.ExpiresAt.Equal(now.Add(15*time.Minute)) {
+		t.Fatalf("expires at %v, want 15 minutes from now", p.ExpiresAt)
+	}
+
+	*now = now.Add(15 * time.Minute)
+	if _, err := b.Approve(context.Background(), p.ID, Approver{UserID: "alice"}, ""); !errors.Is(err, ErrProposalNotPending) {
+		t.Fatalf("approval at the deadline = %v, want %v", err, ErrProposalNotPending)
+	}
+	p, err := b.Get(context.Background(), p.ID)
+	if err != nil || p.State != ProposalExpired || p.Reason != "Not approved within 15m0s" {
+		t.Errorf("proposal = %+v, %v, want expired for the deadline", p, err)
+	}
+}
+
+func TestApprovedProposalExpiresForExecution(t *testing.T) {
+	b, _, now := newTestProposalBook(t, nil)
+	p := propose(t, b, 100000, ApprovalConfig{ExpireAfterMinutes: 15})
+	p, err := b.Approve(context.Background(), p.ID, Approver{UserID: "alice"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Expired(now.Add(14 * time.Minute)) {
+		t.Error("expired before the deadline")
+	}
+
+	// Reading the book leaves approved proposals alone, but they can no
+	// longer be executed
+	*now = now.Add(time.Hour)
+	if p, err = b.Get(context.Background(), p.ID); err != nil || p.State != ProposalApproved {
+		t.Fatalf("proposal = %v, %v, want still approved", p, err)
+	}
+	if !p.Expired(*now) {
+		t.Error("approved proposal past its deadline not expired")
+	}
+}
+
+func TestPendingProposalExpiresOnUnderlyingMove(t *testing.T) {
+	prices := fixedPrices{"SPY": 504}
+	b, _, _ := newTestProposalBook(t, prices)
+	p := propose(t, b, 100000, ApprovalConfig{MaxUnderlyingMove: 1})
+
+	if p, _ := b.Get(context.Background(), p.ID); p.State != ProposalPending {
+		t.Fatalf("state after a 0.8%% move = %s, want pending", p.State)
+	}
+	prices["SPY"] = 494
+	if p, _ := b.Get(context.Background(), p.ID); p.State != ProposalExpired {
+		t.Errorf("state after a -1.2%% move = %s, want expired", p.State)
+	}
+}
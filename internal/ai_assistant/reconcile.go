@@ -0,0 +1,411 @@
+package ai_assistant
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HeldPosition is an option position as a broker reports it. Quantity is
+// in contracts and negative when short; prices are per share.
+type HeldPosition struct {
+	Symbol   string  `json:"symbol"` // OCC option symbol
+	Quantity float64 `json:"quantity"`
+	AvgPrice float64 `json:"avg_price"`
+	Mark     float64 `json:"mark"`
+}
+
+// ClosingFill is a trade that closed some of an option position, by an
+// order this system routed or by settlement at expiration. Quantity is in
+// contracts, positive when bought to close; Price is per share.
+type ClosingFill struct {
+	Symbol   string    `json:"symbol"`
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price"`
+	At       time.Time `json:"at"`
+}
+
+// AccountPositions are the option positions held in one user's account,
+// and the closing fills known for it. An empty UserID is an account shared
+// by every user.
+type AccountPositions struct {
+	UserID    string
+	Positions []HeldPosition
+	Closes    []ClosingFill
+}
+
+// UnmatchedPosition is a held position, or the part of one, that no open
+// recommendation accounts for
+type UnmatchedPosition struct {
+	UserID string `json:"user_id,omitempty"`
+	HeldPosition
+}
+
+// ExternalPnL is the profit of a record closed or expired without closing
+// fills, so realized where no broker this system routes to saw it
+type ExternalPnL struct {
+	RecordID string  `json:"record_id"`
+	UserID   string  `json:"user_id,omitempty"`
+	Profit   float64 `json:"profit"`
+}
+
+// ReconciliationReport lists the records a reconciliation changed and the
+// positions it could not match
+type ReconciliationReport struct {
+	At        time.Time           `json:"at"`
+	Executed  []string            `json:"executed"`
+	Closed    []string            `json:"closed"`
+	Expired   []string            `json:"expired"`
+	External  []ExternalPnL       `json:"external_pnl"`
+	Unmatched []UnmatchedPosition `json:"unmatched"`
+	Errors    []string            `json:"errors,omitempty"`
+}
+
+// heldLot is a position with the quantity not yet claimed by a record
+type heldLot struct {
+	position  HeldPosition
+	remaining float64
+}
+
+// closeLot is a closing fill with the contracts not yet claimed by a record
+type closeLot struct {
+	fill      ClosingFill
+	remaining float64
+}
+
+// recordLeg is one option leg of a record, in contracts held
+type recordLeg struct {
+	symbol     string
+	leg        OptionLeg
+	quantity   float64 // Negative when sold
+	expiration time.Time
+}
+
+// Reconcile matches the open records to the positions held in accounts, as
+// of now. Records are matched oldest first, each to the account of the
+// user it was made for or else a shared account, and claim the contracts
+// they match so two records never count the same position.
+//
+//   - A pending record is executed once every one of its option legs is
+//     held; its entry cost is taken from the positions' average prices.
+//   - An executed record is marked while its legs are held. Once they are
+//     gone it is closed at the prices of the account's closing fills since
+//     it executed, if they account for every leg, and expired if they came
+//     at its last expiration. Otherwise it was closed outside the system
+//     and closes at its last mark, or after its last expiration expires,
+//     settling each leg at intrinsic value.
+//   - A pending record whose legs have all expired unfilled is expired.
+//
+// Records without structured option legs, and those of users missing from
+// accounts, are left alone. Stock legs are not matched or valued.
+func (pt *PerformanceTracker) Reconcile(ctx context.Context, accounts []AccountPositions, prices PriceSource, now time.Time) (*ReconciliationReport, error) {
+	records, err := pt.OpenRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make(map[string]map[string]*heldLot, len(accounts))
+	closes := make(map[string]map[string][]*closeLot, len(accounts))
+	for _, account := range accounts {
+		pool := pools[account.UserID]
+		if pool == nil {
+			pool = make(map[string]*heldLot)
+			pools[account.UserID] = pool
+			closes[account.UserID] = make(map[string][]*closeLot)
+		}
+		for _, fill := range account.Closes {
+			fill.Symbol = strings.ToUpper(strings.TrimSpace(fill.Symbol))
+			closes[account.UserID][fill.Symbol] = append(closes[account.UserID][fill.Symbol], &closeLot{fill: fill, remaining: fill.Quantity})
+		}
+		for _, position := range account.Positions {
+			symbol := strings.ToUpper(strings.TrimSpace(position.Symbol))
+			if lot := pool[symbol]; lot != nil {
+				lot.position.Quantity += position.Quantity
+				lot.remaining += position.Quantity
+				continue
+			}
+			position.Symbol = symbol
+			pool[symbol] = &heldLot{position: position, remaining: position.Quantity}
+		}
+	}
+
+	report := &ReconciliationReport{
+		At:        now,
+		Executed:  []string{},
+		Closed:    []string{},
+		Expired:   []string{},
+		External:  []ExternalPnL{},
+		Unmatched: []UnmatchedPosition{},
+	}
+	for _, rec := range records {
+		legs := reconcileLegs(rec)
+		if len(legs) == 0 {
+			continue
+		}
+		owner := rec.UserID
+		if _, ok := pools[owner]; !ok {
+			if owner = ""; pools[owner] == nil {
+				continue
+			}
+		}
+		pool := pools[owner]
+
+		if lots := claim(pool, legs); lots != nil {
+			entry, mark := 0.0, 0.0
+			for i, lot := range lots {
+				entry += legs[i].quantity * 100 * lot.position.AvgPrice
+				mark += legs[i].quantity * 100 * lot.position.Mark
+			}
+			entry, mark = math.Round(entry*100)/100, math.Round(mark*100)/100
+			executed := rec.Status == RecordPending
+			err := pt.UpdateRecord(rec.ID, func(r *RecommendationRecord) {
+				if r.Status != RecordPending && r.Status != RecordExecuted {
+					return
+				}
+				if !r.Executed || r.Status == RecordPending {
+					r.Executed = true
+					r.Status = RecordExecuted
+					r.ExecutionTime = &now
+				}
+				if r.EntryCost == nil {
+					r.EntryCost = &entry
+				}
+				r.MarkValue = &mark
+				r.MarkedAt = &now
+			})
+			if err != nil {
+				report.Errors = append(report.Errors, err.Error())
+			} else if executed {
+				report.Executed = append(report.Executed, rec.ID)
+			}
+			continue
+		}
+
+		expiration := legs[len(legs)-1].expiration
+		expired := !now.Before(expiration)
+		if rec.Status == RecordExecuted && rec.EntryCost != nil {
+			since := rec.Timestamp
+			if rec.ExecutionTime != nil {
+				since = *rec.ExecutionTime
+			}
+			if value, exitTime, ok := claimCloses(closes[owner], legs, since); ok {
+				profit := math.Round((value-*rec.EntryCost)*100) / 100
+				status := RecordClosed
+				if !exitTime.Before(expiration) {
+					status = RecordExpired
+				}
+				err = pt.UpdateRecord(rec.ID, func(r *RecommendationRecord) {
+					if r.Status == RecordExecuted {
+						r.Status = status
+						r.ExitTime = &exitTime
+						r.ActualProfit = &profit
+					}
+				})
+				if status == RecordExpired {
+					report.Expired = appendResult(report, rec.ID, report.Expired, err)
+				} else {
+					report.Closed = appendResult(report, rec.ID, report.Closed, err)
+				}
+				continue
+			}
+		}
+		switch {
+		case rec.Status == RecordPending && expired:
+			err = pt.UpdateRecord(rec.ID, func(r *RecommendationRecord) {
+				if r.Status == RecordPending {
+					r.Status = RecordExpired
+					r.ExitTime = &expiration
+				}
+			})
+			report.Expired = appendResult(report, rec.ID, report.Expired, err)
+
+		case rec.Status != RecordExecuted || (rec.EntryCost == nil && !expired):
+			// Not yet seen in an account: still waiting for fills, or
+			// executed by an order the positions do not show yet
+
+		case expired:
+			// A trade never seen held has no cost to settle against
+			var profit *float64
+			if rec.EntryCost != nil {
+				value, err := settlementValue(ctx, prices, rec.Recommendation.Ticker, legs)
+				if err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("record %s: %v", rec.ID, err))
+					continue
+				}
+				settled := math.Round((value-*rec.EntryCost)*100) / 100
+				profit = &settled
+			}
+			err = pt.UpdateRecord(rec.ID, func(r *RecommendationRecord) {
+				if r.Status == RecordExecuted {
+					r.Status = RecordExpired
+					r.ExitTime = &expiration
+					r.ActualProfit = profit
+				}
+			})
+			report.Expired = appendResult(report, rec.ID, report.Expired, err)
+			if err == nil && profit != nil {
+				report.External = append(report.External, ExternalPnL{RecordID: rec.ID, UserID: rec.UserID, Profit: *profit})
+			}
+
+		default:
+			mark := *rec.EntryCost
+			if rec.MarkValue != nil {
+				mark = *rec.MarkValue
+			}
+			profit := math.Round((mark-*rec.EntryCost)*100) / 100
+			err = pt.UpdateRecord(rec.ID, func(r *RecommendationRecord) {
+				if r.Status == RecordExecuted {
+					r.Status = RecordClosed
+					r.ExitTime = &now
+					r.ActualProfit = &profit
+				}
+			})
+			report.Closed = appendResult(report, rec.ID, report.Closed, err)
+			if err == nil {
+				report.External = append(report.External, ExternalPnL{RecordID: rec.ID, UserID: rec.UserID, Profit: profit})
+			}
+		}
+	}
+
+	for userID, pool := range pools {
+		for _, lot := range pool {
+			if math.Abs(lot.remaining) < 1e-9 {
+				continue
+			}
+			position := lot.position
+			position.Quantity = lot.remaining
+			report.Unmatched = append(report.Unmatched, UnmatchedPosition{UserID: userID, HeldPosition: position})
+		}
+	}
+	sort.Slice(report.Unmatched, func(i, j int) bool {
+		a, b := report.Unmatched[i], report.Unmatched[j]
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		return a.Symbol < b.Symbol
+	})
+	return report, nil
+}
+
+// appendResult adds id to ids, or err to the report's errors
+func appendResult(report *ReconciliationReport, id string, ids []string, err error) []string {
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return ids
+	}
+	return append(ids, id)
+}
+
+// reconcileLegs returns rec's option legs in contracts for the whole
+// trade, latest expiring last, or nil if any cannot be named by an OCC
+// symbol
+func reconcileLegs(rec *RecommendationRecord) []recordLeg {
+	units := float64(rec.Recommendation.Quantity)
+	if units <= 0 {
+		units = 1
+	}
+
+	var legs []recordLeg
+	for _, leg := range rec.Recommendation.LegDetails {
+		if leg.Type == PositionStock {
+			continue
+		}
+		symbol, err := OCCSymbol(rec.Recommendation.Ticker, leg)
+		if err != nil {
+			return nil
+		}
+		expiration, _ := time.ParseInLocation("2006-01-02", leg.Expiration, marketLocation)
+		quantity := math.Max(math.Round(leg.Quantity), 1)
+		legs = append(legs, recordLeg{
+			symbol:     symbol,
+			leg:        leg,
+			quantity:   leg.sign() * quantity * units,
+			expiration: expiration.Add(16 * time.Hour), // Options expire at the close
+		})
+	}
+	sort.SliceStable(legs, func(i, j int) bool { return legs[i].expiration.Before(legs[j].expiration) })
+	return legs
+}
+
+// claim takes legs' contracts from pool if every leg is held on the same
+// side in at least its quantity, returning the lots in the order of legs
+func claim(pool map[string]*heldLot, legs []recordLeg) []*heldLot {
+	lots := make([]*heldLot, len(legs))
+	for i, leg := range legs {
+		lot := pool[leg.symbol]
+		if lot == nil || lot.remaining*leg.quantity <= 0 || math.Abs(lot.remaining) < math.Abs(leg.quantity) {
+			return nil
+		}
+		lots[i] = lot
+	}
+	for i, leg := range legs {
+		lots[i].remaining -= leg.quantity
+	}
+	return lots
+}
+
+// claimCloses takes from pool the closing fills since since that close
+// every leg in full, oldest first, returning what they brought in for the
+// legs and when the last came. Nothing is taken unless every leg is closed.
+func claimCloses(pool map[string][]*closeLot, legs []recordLeg, since time.Time) (float64, time.Time, bool) {
+	taken := make(map[*closeLot]float64)
+	value, last := 0.0, time.Time{}
+	for _, leg := range legs {
+		lots := pool[leg.symbol]
+		sort.SliceStable(lots, func(i, j int) bool { return lots[i].fill.At.Before(lots[j].fill.At) })
+		need := -leg.quantity // Closing is the other side
+		for _, lot := range lots {
+			if math.Abs(need) < 1e-9 {
+				break
+			}
+			available := lot.remaining - taken[lot]
+			if lot.fill.At.Before(since) || available*need <= 0 {
+				continue
+			}
+			quantity := math.Copysign(math.Min(math.Abs(available), math.Abs(need)), need)
+			taken[lot] += quantity
+			need -= quantity
+			value -= quantity * 100 * lot.fill.Price
+			if lot.fill.At.After(last) {
+				last = lot.fill.At
+			}
+		}
+		if math.Abs(need) >= 1e-9 {
+			return 0, time.Time{}, false
+		}
+	}
+	for lot, quantity := range taken {
+		lot.remaining -= quantity
+	}
+	return math.Round(value*100) / 100, last, true
+}
+
+// settlementValue is what legs are worth at expiration with the underlying
+// at its latest price
+func settlementValue(ctx context.Context, prices PriceSource, underlying string, legs []recordLeg) (float64, error) {
+	if prices == nil {
+		return 0, fmt.Errorf("no price source to settle %s", underlying)
+	}
+	spot, err := prices.LatestPrice(ctx, underlying)
+	if err != nil {
+		return 0, fmt.Errorf("failed to price %s for settlement: %w", underlying, err)
+	}
+	value := 0.0
+	for _, leg := range legs {
+		value += leg.quantity * 100 * intrinsicValue(leg.leg.Type, spot, leg.leg.Strike)
+	}
+	return value, nil
+}
+
+var marketLocation = loadMarketLocation()
+
+func loadMarketLocation() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
@@ -0,0 +1,179 @@
+package ai_assistant
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestTracker(t *testing.T) *PerformanceTracker {
+	t.Helper()
+	store, err := NewBoltRecordStore(filepath.Join(t.TempDir(), "records.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewPerformanceTracker(store)
+}
+
+// bullCallSpread records a 500/510 SPY call spread for userID and returns
+// the record with its two OCC symbols
+func bullCallSpread(t *testing.T, pt *PerformanceTracker, userID string) (*RecommendationRecord, string, string) {
+	t.Helper()
+	trade := TradeRecommendation{
+		Ticker:   "SPY",
+		Strategy: "Bull Call Spread",
+		Quantity: 1,
+		LegDetails: []OptionLeg{
+			{Action: LegBuy, Type: PositionCall, Strike: 500, Expiration: "2026-12-18", Quantity: 1},
+			{Action: LegSell, Type: PositionCall, Strike: 510, Expiration: "2026-12-18", Quantity: 1},
+		},
+	}
+	rec, err := pt.RecordRecommendation(userID, trade, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, _ := OCCSymbol("SPY", trade.LegDetails[0])
+	short, _ := OCCSymbol("SPY", trade.LegDetails[1])
+	return rec, long, short
+}
+
+// executeSpread reconciles rec against held positions costing $600 and
+// marked at $700
+func executeSpread(t *testing.T, pt *PerformanceTracker, rec *RecommendationRecord, long, short string, now time.Time) {
+	t.Helper()
+	accounts := []AccountPositions{{UserID: rec.UserID, Positions: []HeldPosition{
+		{Symbol: long, Quantity: 1, AvgPrice: 10, Mark: 12},
+		{Symbol: short, Quantity: -1, AvgPrice: 4, Mark: 5},
+	}}}
+	report, err := pt.Reconcile(context.Background(), accounts, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Executed) != 1 {
+		t.Fatalf("executed = %v, want %s", report.Executed, rec.ID)
+	}
+}
+
+func TestReconcileClosesAtClosingFills(t *testing.T) {
+	pt := newTestTracker(t)
+	rec, long, short := bullCallSpread(t, pt, "alice")
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	executeSpread(t, pt, rec, long, short, now)
+
+	closedAt := now.Add(time.Hour)
+	accounts := []AccountPositions{{UserID: "alice", Closes: []ClosingFill{
+		{Symbol: long, Quantity: -1, Price: 15, At: closedAt},
+		{Symbol: short, Quantity: 1, Price: 6, At: closedAt},
+	}}}
+	report, err := pt.Reconcile(context.Background(), accounts, nil, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Closed) != 1 {
+		t.Fatalf("closed = %v, want %s", report.Closed, rec.ID)
+	}
+
+	got, err := pt.GetRecord(rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != RecordClosed || got.ActualProfit == nil || *got.ActualProfit != 300 {
+		t.Fatalf("record = %s with profit %v, want closed with 300 from the fills", got.Status, got.ActualProfit)
+	}
+	if !got.ExitTime.Equal(closedAt) {
+		t.Errorf("exit time = %v, want %v", got.ExitTime, closedAt)
+	}
+}
+
+func TestReconcileClosesExternallyAtMark(t *testing.T) {
+	pt := newTestTracker(t)
+	rec, long, short := bullCallSpread(t, pt, "alice")
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	executeSpread(t, pt, rec, long, short, now)
+
+	// A fill from before the trade executed is not its close
+	accounts := []AccountPositions{{UserID: "alice", Closes: []ClosingFill{
+		{Symbol: long, Quantity: -1, Price: 15, At: now.Add(-time.Hour)},
+		{Symbol: short, Quantity: 1, Price: 6, At: now.Add(-time.Hour)},
+	}}}
+	if _, err := pt.Reconcile(context.Background(), accounts, nil, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := pt.GetRecord(rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != RecordClosed || got.ActualProfit == nil || *got.ActualProfit != 100 {
+		t.Fatalf("record = %s with profit %v, want closed with 100 at the mark", got.Status, got.ActualProfit)
+	}
+}
+
+func TestReconcileExpiresAtSettlementFills(t *testing.T) {
+	pt := newTestTracker(t)
+	rec, long, short := bullCallSpread(t, pt, "alice")
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	executeSpread(t, pt, rec, long, short, now)
+
+	settledAt := time.Date(2026, 12, 18, 16, 5, 0, 0, marketLocation)
+	accounts := []AccountPositions{{UserID: "alice", Closes: []ClosingFill{
+		{Symbol: long, Quantity: -1, Price: 8, At: settledAt},
+		{Symbol: short, Quantity: 1, Price: 0, At: settledAt},
+	}}}
+	report, err := pt.Reconcile(context.Background(), accounts, nil, settledAt.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Expired) != 1 {
+		t.Fatalf("expired = %v, want %s", report.Expired, rec.ID)
+	}
+	got, err := pt.GetRecord(rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != RecordExpired || got.ActualProfit == nil || *got.ActualProfit != 200 {
+		t.Fatalf("record = %s with profit %v, want expired with 200", got.Status, got.ActualProfit)
+	}
+
+	// Expired trades are outcomes for calibration too
+	closed, err := pt.ClosedRecords(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 1 || closed[0].ID != rec.ID {
+		t.Errorf("closed records = %v, want %s", closed, rec.ID)
+	}
+}
+
+func TestReconcileKeepsUsersApart(t *testing.T) {
+	pt := newTestTracker(t)
+	rec, long, short := bullCallSpread(t, pt, "alice")
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+	accounts := []AccountPositions{
+		{UserID: "alice"},
+		{UserID: "bob", Positions: []HeldPosition{
+			{Symbol: long, Quantity: 1, AvgPrice: 10, Mark: 12},
+			{Symbol: short, Quantity: -1, AvgPrice: 4, Mark: 5},
+		}},
+	}
+	report, err := pt.Reconcile(context.Background(), accounts, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Executed) != 0 {
+		t.Fatalf("executed = %v, want alice's record left pending", report.Executed)
+	}
+	if len(report.Unmatched) != 2 || report.Unmatched[0].UserID != "bob" {
+		t.Errorf("unmatched = %+v, want bob's two positions", report.Unmatched)
+	}
+	got, err := pt.GetRecord(rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != RecordPending {
+		t.Errorf("status = %s, want %s", got.Status, RecordPending)
+	}
+}
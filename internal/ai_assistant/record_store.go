@@ -0,0 +1,336 @@
+package ai_assistant
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RecordStore persists recommendation records. Implementations must be
+// safe for concurrent use, and each write must be atomic.
+type RecordStore interface {
+	// Create stores a new record, failing if its ID is taken
+	Create(rec *RecommendationRecord) error
+	// Get returns the record with id, or ErrRecordNotFound
+	Get(id string) (*RecommendationRecord, error)
+	// Update applies fn to the record with id and stores the result, or
+	// nothing if fn returns an error
+	Update(id string, fn func(*RecommendationRecord) error) error
+	// Find returns the records matching q, oldest first
+	Find(q RecordQuery) ([]*RecommendationRecord, error)
+	Close() error
+}
+
+// RecordQuery selects records. Zero fields match every record.
+type RecordQuery struct {
+	From     time.Time // Taken at or after
+	To       time.Time // Taken before
+	Statuses []string
+	Strategy string
+	Limit    int  // At most this many, the oldest unless Newest is set
+	Newest   bool // Keep the newest Limit records instead
+}
+
+func (q RecordQuery) matches(rec *RecommendationRecord) bool {
+	if !q.From.IsZero() && rec.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !rec.Timestamp.Before(q.To) {
+		return false
+	}
+	if q.Strategy != "" && rec.Recommendation.Strategy != q.Strategy {
+		return false
+	}
+	if len(q.Statuses) > 0 {
+		for _, status := range q.Statuses {
+			if rec.Status == status {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ErrRecordExists is returned when creating a record whose ID is taken
+var ErrRecordExists = errors.New("recommendation record already exists")
+
+var (
+	recordsBucket    = []byte("records")
+	byTimeBucket     = []byte("records_by_time")
+	byStatusBucket   = []byte("records_by_status")
+	byStrategyBucket = []byte("records_by_strategy")
+)
+
+// BoltRecordStore keeps records in an embedded bbolt database, one JSON
+// document per record keyed by ID. Index buckets map timestamp, status and
+// strategy to IDs, and are kept in step with the records in the same
+// transaction.
+type BoltRecordStore struct {
+	db *bolt.DB
+}
+
+// NewBoltRecordStore opens or creates the database at path
+func NewBoltRecordStore(path string) (*BoltRecordStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create record store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open record store: %w", err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, byTimeBucket, byStatusBucket, byStrategyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize record store: %w", err)
+	}
+
+	return &BoltRecordStore{db: db}, nil
+}
+
+func (s *BoltRecordStore) Create(rec *RecommendationRecord) error {
+	if rec == nil || rec.ID == "" {
+		return fmt.Errorf("record ID is required")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(recordsBucket).Get([]byte(rec.ID)) != nil {
+			return fmt.Errorf("%w: %s", ErrRecordExists, rec.ID)
+		}
+		return putRecord(tx, nil, rec)
+	})
+}
+
+func (s *BoltRecordStore) Get(id string) (*RecommendationRecord, error) {
+	var rec *RecommendationRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		rec, err = getRecord(tx, id)
+		return err
+	})
+	return rec, err
+}
+
+func (s *BoltRecordStore) Update(id string, fn func(*RecommendationRecord) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rec, err := getRecord(tx, id)
+		if err != nil {
+			return err
+		}
+		old := *rec
+		if err := fn(rec); err != nil {
+			return err
+		}
+		rec.ID = id
+		return putRecord(tx, &old, rec)
+	})
+}
+
+// Find walks the status index when q names statuses, else the strategy
+// index when it names a strategy, else the timestamp index
+func (s *BoltRecordStore) Find(q RecordQuery) ([]*RecommendationRecord, error) {
+	var records []*RecommendationRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var ids [][]byte
+		switch {
+		case len(q.Statuses) > 0:
+			for _, status := range q.Statuses {
+				ids = append(ids, scanIndex(tx.Bucket(byStatusBucket), []byte(status+"\x00"), q.From, q.To)...)
+			}
+		case q.Strategy != "":
+			ids = scanIndex(tx.Bucket(byStrategyBucket), []byte(q.Strategy+"\x00"), q.From, q.To)
+		default:
+			ids = scanIndex(tx.Bucket(byTimeBucket), nil, q.From, q.To)
+		}
+
+		for _, id := range ids {
+			rec, err := getRecord(tx, string(id))
+			if err != nil {
+				return err
+			}
+			if q.matches(rec) {
+				records = append(records, rec)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	if q.Limit > 0 && len(records) > q.Limit {
+		if q.Newest {
+			records = records[len(records)-q.Limit:]
+		} else {
+			records = records[:q.Limit]
+		}
+	}
+	return records, nil
+}
+
+func (s *BoltRecordStore) Close() error {
+	return s.db.Close()
+}
+
+func getRecord(tx *bolt.Tx, id string) (*RecommendationRecord, error) {
+	data := tx.Bucket(recordsBucket).Get([]byte(id))
+	if data == nil {
+		return nil, fmt.Errorf("%w: %s", ErrRecordNotFound, id)
+	}
+
+	var rec RecommendationRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode record %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+// putRecord stores rec and moves its index entries from those of old, the
+// version it replaces, if any
+func putRecord(tx *bolt.Tx, old, rec *RecommendationRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode record %s: %w", rec.ID, err)
+	}
+	if err := tx.Bucket(recordsBucket).Put([]byte(rec.ID), data); err != nil {
+		return err
+	}
+
+	if old != nil {
+		for bucket, key := range indexKeys(old) {
+			if err := tx.Bucket([]byte(bucket)).Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	for bucket, key := range indexKeys(rec) {
+		if err := tx.Bucket([]byte(bucket)).Put(key, []byte(rec.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexKeys returns rec's key in each index bucket. Keys end with the
+// timestamp and ID, so each prefix is walked in time order.
+func indexKeys(rec *RecommendationRecord) map[string][]byte {
+	suffix := append(timeKey(rec.Timestamp), rec.ID...)
+	return map[string][]byte{
+		string(byTimeBucket):     suffix,
+		string(byStatusBucket):   append([]byte(rec.Status+"\x00"), suffix...),
+		string(byStrategyBucket): append([]byte(rec.Recommendation.Strategy+"\x00"), suffix...),
+	}
+}
+
+// timeKey encodes t so keys sort in time order; times before 1970 sort
+// first
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	if t.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	}
+	return key
+}
+
+// scanIndex returns the IDs under prefix with timestamps from from up to
+// but not including to, either of which may be zero
+func scanIndex(bucket *bolt.Bucket, prefix []byte, from, to time.Time) [][]byte {
+	start := prefix
+	if !from.IsZero() {
+		start = append(append([]byte{}, prefix...), timeKey(from)...)
+	}
+	var end []byte
+	if !to.IsZero() {
+		end = append(append([]byte{}, prefix...), timeKey(to)...)
+	}
+
+	var ids [][]byte
+	c := bucket.Cursor()
+	for k, v := c.Seek(start); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if end != nil && bytes.Compare(k, end) >= 0 {
+			break
+		}
+		ids = append(ids, append([]byte{}, v...))
+	}
+	return ids
+}
+
+// RecordImportReport summarizes an import of monthly record files
+type RecordImportReport struct {
+	Files    []string `json:"files"`
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// ImportMonthlyRecordFiles copies the records of the ai_performance_YYYY_MM.json
+// files the tracker used to keep in dataDir into dst. Records already in
+// dst are skipped, so an interrupted import can be run again. Each file
+// imported without errors is renamed to end in .migrated.
+func ImportMonthlyRecordFiles(dataDir string, dst RecordStore) (*RecordImportReport, error) {
+	files, err := filepath.Glob(filepath.Join(dataDir, "ai_performance_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	report := &RecordImportReport{Files: []string{}}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return report, fmt.Errorf("failed to read %s: %w", filepath.Base(file), err)
+		}
+		var records []*RecommendationRecord
+		if err := json.Unmarshal(data, &records); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", filepath.Base(file), err))
+			continue
+		}
+
+		failed := false
+		for _, rec := range records {
+			if rec == nil || rec.ID == "" {
+				continue
+			}
+			if rec.Status == "" {
+				rec.Status = RecordPending
+			}
+			err := dst.Create(rec)
+			if errors.Is(err, ErrRecordExists) {
+				report.Skipped++
+				continue
+			}
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %s: %v", filepath.Base(file), rec.ID, err))
+				failed = true
+				continue
+			}
+			report.Imported++
+		}
+		if failed {
+			continue
+		}
+
+		if err := os.Rename(file, file+".migrated"); err != nil {
+			return report, fmt.Errorf("failed to rename %s: %w", filepath.Base(file), err)
+		}
+		report.Files = append(report.Files, filepath.Base(file))
+	}
+	return report, nil
+}
@@ -0,0 +1,177 @@
+package ai_assistant
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var recordsStart = time.Date(2026, 9, 1, 14, 0, 0, 0, time.UTC)
+
+func openRecordStore(t *testing.T, path string) *BoltRecordStore {
+	t.Helper()
+	store, err := NewBoltRecordStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// storedRecord is a record taken day days after recordsStart
+func storedRecord(id string, day int, status, strategy string) *RecommendationRecord {
+	return &RecommendationRecord{
+		ID:             id,
+		Timestamp:      recordsStart.AddDate(0, 0, day),
+		Status:         status,
+		Recommendation: TradeRecommendation{Ticker: "SPY", Strategy: strategy},
+	}
+}
+
+func recordIDs(records []*RecommendationRecord) []string {
+	ids := []string{}
+	for _, rec := range records {
+		ids = append(ids, rec.ID)
+	}
+	return ids
+}
+
+func TestBoltRecordStoreCreateGetUpdate(t *testing.T) {
+	store := openRecordStore(t, filepath.Join(t.TempDir(), "records.db"))
+	if err := store.Create(storedRecord("rec_1", 0, RecordPending, "Iron Condor")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(storedRecord("rec_1", 1, RecordPending, "Iron Condor")); !errors.Is(err, ErrRecordExists) {
+		t.Errorf("duplicate create = %v, want %v", err, ErrRecordExists)
+	}
+	if _, err := store.Get("rec_2"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("missing get = %v, want %v", err, ErrRecordNotFound)
+	}
+
+	// A failed update leaves the record as it was
+	failed := errors.New("no")
+	err := store.Update("rec_1", func(rec *RecommendationRecord) error {
+		rec.Status = RecordExecuted
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("update = %v, want fn's error", err)
+	}
+	if rec, _ := store.Get("rec_1"); rec.Status != RecordPending {
+		t.Errorf("status after a failed update = %s, want pending", rec.Status)
+	}
+
+	// A successful one moves the record between status indexes and keeps
+	// its ID
+	err = store.Update("rec_1", func(rec *RecommendationRecord) error {
+		rec.Status = RecordExecuted
+		rec.ID = "renamed"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := store.Find(RecordQuery{Statuses: []string{RecordPending}}); len(pending) != 0 {
+		t.Errorf("pending = %v, want none", recordIDs(pending))
+	}
+	if executed, _ := store.Find(RecordQuery{Statuses: []string{RecordExecuted}}); !reflect.DeepEqual(recordIDs(executed), []string{"rec_1"}) {
+		t.Errorf("executed = %v, want rec_1", recordIDs(executed))
+	}
+}
+
+func TestBoltRecordStoreFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.db")
+	store := openRecordStore(t, path)
+	for _, rec := range []*RecommendationRecord{
+		storedRecord("d", 3, RecordClosed, "Iron Condor"),
+		storedRecord("a", 0, RecordPending, "Iron Condor"),
+		storedRecord("c", 2, RecordExecuted, "Bull Put Spread"),
+		storedRecord("b", 1, RecordClosed, "Bull Put Spread"),
+		storedRecord("e", 4, RecordExpired, "Iron Condor"),
+	} {
+		if err := store.Create(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+	store = openRecordStore(t, path)
+
+	for _, tt := range []struct {
+		name  string
+		query RecordQuery
+		want  []string
+	}{
+		{"everything oldest first", RecordQuery{}, []string{"a", "b", "c", "d", "e"}},
+		{"from is inclusive, to is not", RecordQuery{From: recordsStart.AddDate(0, 0, 1), To: recordsStart.AddDate(0, 0, 3)}, []string{"b", "c"}},
+		{"statuses merged in time order", RecordQuery{Statuses: []string{RecordExpired, RecordClosed}}, []string{"b", "d", "e"}},
+		{"statuses within dates", RecordQuery{Statuses: []string{RecordClosed}, From: recordsStart.AddDate(0, 0, 2)}, []string{"d"}},
+		{"status and strategy", RecordQuery{Statuses: []string{RecordClosed}, Strategy: "Iron Condor"}, []string{"d"}},
+		{"strategy", RecordQuery{Strategy: "Bull Put Spread"}, []string{"b", "c"}},
+		{"oldest", RecordQuery{Limit: 2}, []string{"a", "b"}},
+		{"newest", RecordQuery{Limit: 2, Newest: true}, []string{"d", "e"}},
+		{"unknown status", RecordQuery{Statuses: []string{"nope"}}, []string{}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := store.Find(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := recordIDs(records); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("found %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportMonthlyRecordFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, v interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ai_performance_2026_08.json", []*RecommendationRecord{
+		storedRecord("old_1", -20, "", "Iron Condor"),
+		storedRecord("old_2", -10, RecordClosed, "Iron Condor"),
+	})
+	write("ai_performance_2026_09.json", []*RecommendationRecord{
+		storedRecord("old_2", -10, RecordClosed, "Iron Condor"),
+		storedRecord("new_1", 0, RecordExecuted, "Bull Put Spread"),
+		{},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "ai_performance_2026_10.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := openRecordStore(t, filepath.Join(t.TempDir(), "records.db"))
+	report, err := ImportMonthlyRecordFiles(dir, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 3 || report.Skipped != 1 || len(report.Errors) != 1 {
+		t.Errorf("report = %+v, want 3 imported, old_2 skipped and the broken file reported", report)
+	}
+	if !reflect.DeepEqual(report.Files, []string{"ai_performance_2026_08.json", "ai_performance_2026_09.json"}) {
+		t.Errorf("imported files = %v", report.Files)
+	}
+	if rec, err := store.Get("old_1"); err != nil || rec.Status != RecordPending {
+		t.Errorf("old_1 = %+v, %v, want pending without a status", rec, err)
+	}
+
+	// Imported files are renamed, so only the broken one is tried again
+	if _, err := os.Stat(filepath.Join(dir, "ai_performance_2026_08.json.migrated")); err != nil {
+		t.Error(err)
+	}
+	report, err = ImportMonthlyRecordFiles(dir, store)
+	if err != nil || report.Imported != 0 || len(report.Files) != 0 || len(report.Errors) != 1 {
+		t.Errorf("second import = %+v, %v, want only the broken file", report, err)
+	}
+}
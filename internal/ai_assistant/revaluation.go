@@ -0,0 +1,164 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// RepricingMethod selects how option positions are revalued under a shock
+type RepricingMethod string
+
+const (
+	// RepriceFull revalues options with Black-Scholes at the shocked inputs
+	RepriceFull RepricingMethod = "full"
+	// RepriceGreeks approximates the change with delta, gamma, vega and theta
+	RepriceGreeks RepricingMethod = "greeks"
+)
+
+// Shock is a change in market conditions applied to a position
+type Shock struct {
+	SpotReturn float64 `json:"spot_return"` // Simple return of the underlying, e.g. -0.10
+	VolPoints  float64 `json:"vol_points"`  // Change in implied volatility, e.g. +5 for 5 vol points
+	Days       float64 `json:"days"`        // Calendar days elapsed
+}
+
+// fallbackVolatility is used for options whose implied volatility is unknown
+const fallbackVolatility = 0.30
+
+// PreparePositions fills in what the revaluation needs but the position
+// lacks: the underlying price from the latest close, implied volatility
+// from the market value, and Black-Scholes Greeks. It returns a warning for
+// each assumption it had to make.
+func PreparePositions(positions []*Position, history map[string][]DailyBar, now time.Time, rate float64) []string {
+	var warnings []string
+	for _, p := range positions {
+		if p.UnderlyingPrice <= 0 {
+			if bars := history[p.Underlying]; len(bars) > 0 {
+				p.UnderlyingPrice = bars[len(bars)-1].Close
+			} else {
+				warnings = append(warnings, fmt.Sprintf("%s: no price for %s; position ignored", p.Symbol, p.Underlying))
+				continue
+			}
+		}
+
+		if !p.IsOption() {
+			p.Delta = 1
+			continue
+		}
+
+		years := p.YearsToExpiry(now)
+		if p.IV <= 0 && p.MarketValue != 0 && p.Quantity != 0 {
+			price := math.Abs(p.MarketValue / (p.Quantity * p.Multiplier))
+			p.IV = ImpliedVolatility(p.Type, price, p.UnderlyingPrice, p.Strike, years, rate)
+		}
+		if p.IV <= 0 {
+			p.IV = fallbackVolatility
+			warnings = append(warnings, fmt.Sprintf("%s: implied volatility unknown; assuming %.0f%%", p.Symbol, fallbackVolatility*100))
+		}
+
+		if p.Delta == 0 && p.Gamma == 0 {
+			greeks := BlackScholesGreeks(p.Type, p.UnderlyingPrice, p.Strike, years, rate, p.IV)
+			p.Delta, p.Gamma, p.Theta, p.Vega = greeks.Delta, greeks.Gamma, greeks.Theta, greeks.Vega
+		}
+	}
+	return warnings
+}
+
+// PnL returns the change in the position's value under shock. Positions
+// without an underlying price are worth nothing to the engines and return 0.
+func (p *Position) PnL(shock Shock, method RepricingMethod, now time.Time, rate float64) float64 {
+	if p.UnderlyingPrice <= 0 {
+		return 0
+	}
+	size := p.Quantity * p.Multiplier
+	dS := p.UnderlyingPrice * shock.SpotReturn
+
+	if !p.IsOption() {
+		return size * dS
+	}
+
+	if method == RepriceGreeks {
+		change := p.Delta*dS + 0.5*p.Gamma*dS*dS + p.Vega*shock.VolPoints + p.Theta*shock.Days
+		return size * change
+	}
+
+	years := p.YearsToExpiry(now)
+	shockedYears := math.Max(years-shock.Days/365, 0)
+	shockedVol := math.Max(p.IV+shock.VolPoints/100, 0.01)
+
+	before := BlackScholesPrice(p.Type, p.UnderlyingPrice, p.Strike, years, rate, p.IV)
+	after := BlackScholesPrice(p.Type, p.UnderlyingPrice+dS, p.Strike, shockedYears, rate, shockedVol)
+	return size * (after - before)
+}
+
+// dailyReturns aligns the closes of symbols on their common dates and
+// returns the dates and, per symbol, the daily log returns between them
+func dailyReturns(symbols []string, history map[string][]DailyBar) ([]time.Time, map[string][]float64) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+
+	closes := make(map[string]map[string]float64, len(symbols))
+	counts := make(map[string]int)
+	dates := make(map[string]time.Time)
+	for _, symbol := range symbols {
+		byDate := make(map[string]float64)
+		for _, bar := range history[symbol] {
+			key := bar.Date.Format("2006-01-02")
+			if _, seen := byDate[key]; !seen {
+				counts[key]++
+			}
+			byDate[key] = bar.Close
+			dates[key] = bar.Date
+		}
+		closes[symbol] = byDate
+	}
+
+	var common []string
+	for key, n := range counts {
+		if n == len(symbols) {
+			common = append(common, key)
+		}
+	}
+	sort.Strings(common)
+	if len(common) < 2 {
+		return nil, nil
+	}
+
+	returns := make(map[string][]float64, len(symbols))
+	for _, symbol := range symbols {
+		series := make([]float64, 0, len(common)-1)
+		for i := 1; i < len(common); i++ {
+			prev, cur := closes[symbol][common[i-1]], closes[symbol][common[i]]
+			if prev <= 0 || cur <= 0 {
+				series = append(series, 0)
+				continue
+			}
+			series = append(series, math.Log(cur/prev))
+		}
+		returns[symbol] = series
+	}
+
+	returnDates := make([]time.Time, 0, len(common)-1)
+	for _, key := range common[1:] {
+		returnDates = append(returnDates, dates[key])
+	}
+	return returnDates, returns
+}
+
+// underlyings returns the sorted, distinct underlyings of priced positions
+func underlyings(positions []*Position) []string {
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, p := range positions {
+		if p.UnderlyingPrice <= 0 || seen[p.Underlying] {
+			continue
+		}
+		seen[p.Underlying] = true
+		symbols = append(symbols, p.Underlying)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
@@ -3,33 +3,48 @@ package ai_assistant
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/events"
 )
 
 // RiskManager enforces safety rules for AI-generated trades
 type RiskManager struct {
-	maxPortfolioRiskPercent  float64
-	maxPositionSizePercent   float64
-	maxDailyLossPercent      float64
-	minProbabilityOfProfit   float64
-	maxConcentrationPercent  float64
-	requireManualApproval    bool
+	policy       *RiskPolicy
+	correlations *CorrelationMatrix
+	history      map[string][]DailyBar
+	market       *AggregatedMarketData
+	events       []events.Event
+	now          func() time.Time
 }
 
 // RiskLimits defines user-configurable risk parameters
 type RiskLimits struct {
-	MaxPortfolioRisk  float64 `json:"max_portfolio_risk"`  // Max % of portfolio at risk
-	MaxPositionSize   float64 `json:"max_position_size"`   // Max % per position
-	MaxDailyLoss      float64 `json:"max_daily_loss"`      // Max daily loss %
-	MinPOP            float64 `json:"min_pop"`             // Minimum probability of profit
-	MaxConcentration  float64 `json:"max_concentration"`   // Max % in single symbol
+	MaxPortfolioRisk  float64 `json:"max_portfolio_risk" yaml:"max_portfolio_risk"`  // Max % of portfolio at risk
+	MaxPositionSize   float64 `json:"max_position_size" yaml:"max_position_size"`    // Max % per position
+	MaxDailyLoss      float64 `json:"max_daily_loss" yaml:"max_daily_loss"`          // Max daily loss %
+	MinPOP            float64 `json:"min_pop" yaml:"min_pop"`                        // Minimum probability of profit
+	MaxConcentration  float64 `json:"max_concentration" yaml:"max_concentration"`    // Max % in single symbol
 }
 
 // TradeValidation contains the result of risk validation
 type TradeValidation struct {
-	IsValid      bool     `json:"is_valid"`
-	Violations   []string `json:"violations"`
-	RiskScore    float64  `json:"risk_score"`
-	RequiresApproval bool `json:"requires_approval"`
+	IsValid          bool                `json:"is_valid"`
+	Violations       []string            `json:"violations"`
+	RuleViolations   []RuleViolation     `json:"rule_violations"`
+	RiskScore        float64             `json:"risk_score"`
+	ScoreBreakdown   *RiskScoreBreakdown `json:"score_breakdown,omitempty"`
+	RequiresApproval bool                `json:"requires_approval"`
+	Greeks           *BasketGreeks       `json:"greeks,omitempty"` // Set by ValidatePortfolio
+	Warnings         []string            `json:"warnings,omitempty"`  // Checks that could not be made
+}
+
+// RuleViolation pairs a machine-readable rule ID with its explanation
+type RuleViolation struct {
+	RuleID  string `json:"rule_id"`
+	Message string `json:"message"`
 }
 
 // PortfolioRiskMetrics contains overall portfolio risk measurements
@@ -42,202 +57,506 @@ type PortfolioRiskMetrics struct {
 	MaxDrawdown     float64            `json:"max_drawdown"`
 	Concentrations  map[string]float64 `json:"concentrations"`
 	CorrelationRisk float64            `json:"correlation_risk"`
+	VaR             *VaRReport         `json:"var_report,omitempty"`
+
+	SectorConcentrations map[string]float64 `json:"sector_concentrations"`
+	EffectiveBets        float64            `json:"effective_bets"`
+	BetClusters          []BetCluster       `json:"bet_clusters,omitempty"`
+	Correlation          *CorrelationMatrix `json:"correlation,omitempty"`
 }
 
+// NewRiskManager creates a risk manager with the default policy
 func NewRiskManager() *RiskManager {
-	return &RiskManager{
-		maxPortfolioRiskPercent:  2.0,   // 2% max portfolio risk
-		maxPositionSizePercent:   0.5,   // 0.5% max per position
-		maxDailyLossPercent:      1.0,   // 1% max daily loss
-		minProbabilityOfProfit:   0.65,  // 65% minimum POP
-		maxConcentrationPercent:  10.0,  // 10% max in single symbol
-		requireManualApproval:    true,  // Always require manual approval
+	return NewRiskManagerWithPolicy(DefaultRiskPolicy())
+}
+
+// NewRiskManagerFromLimits creates a risk manager with the default policy
+// and the given limits; zero fields keep their defaults
+func NewRiskManagerFromLimits(limits RiskLimits) *RiskManager {
+	return NewRiskManagerWithPolicy(DefaultRiskPolicy().WithLimits(limits))
+}
+
+// NewRiskManagerWithPolicy creates a risk manager that enforces policy
+func NewRiskManagerWithPolicy(policy *RiskPolicy) *RiskManager {
+	if policy == nil {
+		policy = DefaultRiskPolicy()
+	}
+	return &RiskManager{policy: policy, now: time.Now}
+}
+
+// WithClock makes now the time trades are valued and checked at, e.g. the
+// date being replayed by a backtest
+func (rm *RiskManager) WithClock(now func() time.Time) *RiskManager {
+	rm.now = now
+	return rm
+}
+
+// clock returns the risk manager's current time
+func (rm *RiskManager) clock() time.Time {
+	if rm.now == nil {
+		return time.Now()
+	}
+	return rm.now()
+}
+
+// WithHistory computes the return correlations used by the correlated
+// exposure check from daily bars, e.g. AggregatedMarketData.History
+func (rm *RiskManager) WithHistory(history map[string][]DailyBar) *RiskManager {
+	symbols := make([]string, 0, len(history))
+	for symbol := range history {
+		symbols = append(symbols, symbol)
 	}
+	rm.history = history
+	rm.correlations = LatestCorrelation(symbols, history, rm.policy.CorrelationWindow)
+	return rm
+}
+
+// WithMarketData uses data's history as WithHistory does, its quotes
+// and option chains to price the legs of proposed trades, and its event
+// calendar as WithEvents does
+func (rm *RiskManager) WithMarketData(data *AggregatedMarketData) *RiskManager {
+	rm.market = data
+	rm.events = data.Calendar
+	return rm.WithHistory(data.History)
+}
+
+// Policy returns the policy this manager enforces
+func (rm *RiskManager) Policy() *RiskPolicy {
+	return rm.policy
+}
+
+func (v *TradeValidation) addViolation(ruleID, message string) {
+	v.IsValid = false
+	v.Violations = append(v.Violations, message)
+	v.RuleViolations = append(v.RuleViolations, RuleViolation{RuleID: ruleID, Message: message})
 }
 
 // ValidateTrade checks if a trade recommendation meets risk criteria
 func (rm *RiskManager) ValidateTrade(trade *TradeRecommendation, portfolio map[string]interface{}) *TradeValidation {
+	policy := rm.policy
 	validation := &TradeValidation{
 		IsValid:          true,
 		Violations:       []string{},
-		RequiresApproval: rm.requireManualApproval,
+		RuleViolations:   []RuleViolation{},
+		RequiresApproval: policy.RequireManualApproval,
 	}
 
 	// Get portfolio value
-	portfolioValue, ok := portfolio["total_value"].(float64)
-	if !ok || portfolioValue <= 0 {
-		portfolioValue = 100000 // Default to 100k if not provided
+	portfolioValue := portfolioValue(portfolio)
+
+	strategyKey, strategyRule := policy.strategyRule(trade)
+	symbolRule := policy.Symbols[strings.ToUpper(trade.Ticker)]
+
+	// Check whether the strategy and symbol may be traded at all
+	if strategyRule != nil && strategyRule.Allowed != nil && !*strategyRule.Allowed && policy.RuleEnabled(RuleStrategyAllowed) {
+		validation.addViolation(RuleStrategyAllowed,
+			fmt.Sprintf("Strategy %q is not allowed by policy (%s)", trade.Strategy, strategyKey))
+	}
+	if symbolRule != nil && symbolRule.Allowed != nil && !*symbolRule.Allowed && policy.RuleEnabled(RuleSymbolAllowed) {
+		validation.addViolation(RuleSymbolAllowed,
+			fmt.Sprintf("Trading %s is not allowed by policy", trade.Ticker))
 	}
 
-	// Check position size
-	positionRisk := math.Abs(trade.MaxLoss)
+	// Check position size against the global, strategy and symbol caps
+	positionRisk := trade.totalRisk()
 	positionRiskPercent := (positionRisk / portfolioValue) * 100
 	
-	if positionRiskPercent > rm.maxPositionSizePercent {
-		validation.IsValid = false
-		validation.Violations = append(validation.Violations, 
+	if positionRiskPercent > policy.Limits.MaxPositionSize && policy.RuleEnabled(RulePositionSize) {
+		validation.addViolation(RulePositionSize,
 			fmt.Sprintf("Position risk %.2f%% exceeds limit %.2f%%", 
-				positionRiskPercent, rm.maxPositionSizePercent))
+				positionRiskPercent, policy.Limits.MaxPositionSize))
+	}
+	if strategyRule != nil && strategyRule.MaxPositionSize != nil && positionRiskPercent > *strategyRule.MaxPositionSize && policy.RuleEnabled(RuleStrategySize) {
+		validation.addViolation(RuleStrategySize,
+			fmt.Sprintf("Position risk %.2f%% exceeds %s limit %.2f%%",
+				positionRiskPercent, strategyKey, *strategyRule.MaxPositionSize))
+	}
+	if symbolRule != nil && symbolRule.MaxPositionSize != nil && positionRiskPercent > *symbolRule.MaxPositionSize && policy.RuleEnabled(RuleSymbolSize) {
+		validation.addViolation(RuleSymbolSize,
+			fmt.Sprintf("Position risk %.2f%% exceeds %s limit %.2f%%",
+				positionRiskPercent, trade.Ticker, *symbolRule.MaxPositionSize))
 	}
 
 	// Check probability of profit
-	if trade.POP < rm.minProbabilityOfProfit {
-		validation.IsValid = false
-		validation.Violations = append(validation.Violations,
+	if trade.POP < policy.Limits.MinPOP && policy.RuleEnabled(RuleMinPOP) {
+		validation.addViolation(RuleMinPOP,
 			fmt.Sprintf("POP %.2f%% below minimum %.2f%%",
-				trade.POP*100, rm.minProbabilityOfProfit*100))
+				trade.POP*100, policy.Limits.MinPOP*100))
+	}
+	if strategyRule != nil && strategyRule.MinPOP != nil && trade.POP < *strategyRule.MinPOP && policy.RuleEnabled(RuleStrategyMinPOP) {
+		validation.addViolation(RuleStrategyMinPOP,
+			fmt.Sprintf("POP %.2f%% below %s minimum %.2f%%",
+				trade.POP*100, strategyKey, *strategyRule.MinPOP*100))
 	}
 
 	// Check risk/reward ratio
 	riskRewardRatio := trade.MaxProfit / math.Abs(trade.MaxLoss)
-	if riskRewardRatio < 0.33 { // Minimum 1:3 risk/reward
-		validation.IsValid = false
-		validation.Violations = append(validation.Violations,
-			fmt.Sprintf("Risk/reward ratio %.2f below minimum 0.33", riskRewardRatio))
+	if riskRewardRatio < policy.MinRiskReward && policy.RuleEnabled(RuleRiskReward) {
+		validation.addViolation(RuleRiskReward,
+			fmt.Sprintf("Risk/reward ratio %.2f below minimum %.2f", riskRewardRatio, policy.MinRiskReward))
+	}
+
+	// Check the margin requirement against available buying power
+	if policy.RuleEnabled(RuleBuyingPower) {
+		margin := rm.MarginRequirement(trade, portfolio)
+		switch available, known := buyingPower(portfolio); {
+		case !known:
+			validation.Warnings = append(validation.Warnings,
+				fmt.Sprintf("Buying power unknown; margin requirement $%.2f not checked", margin.Total))
+		case available <= 0 || margin.Total > available:
+			validation.addViolation(RuleBuyingPower,
+				fmt.Sprintf("Margin requirement $%.2f exceeds buying power $%.2f", margin.Total, available))
+		}
+	}
+
+	// Check for earnings, dividends and macro events near the trade
+	rm.checkBlackouts(trade, portfolio, validation)
+
+	// Check today's realized and unrealized P&L against the daily loss limit
+	if dailyPnL, ok := portfolio["daily_pnl"].(float64); ok && dailyPnL < 0 && policy.RuleEnabled(RuleDailyLoss) {
+		dailyLossPercent := (-dailyPnL / portfolioValue) * 100
+		if dailyLossPercent >= policy.Limits.MaxDailyLoss {
+			validation.addViolation(RuleDailyLoss,
+				fmt.Sprintf("Daily loss %.2f%% has reached limit %.2f%%",
+					dailyLossPercent, policy.Limits.MaxDailyLoss))
+		}
 	}
 
 	// Calculate risk score (0-100, lower is better)
-	validation.RiskScore = rm.calculateRiskScore(trade, positionRiskPercent)
+	validation.ScoreBreakdown = NewRiskScorer(policy.Scoring).Score(trade, positionRiskPercent, policy.Limits.MaxPositionSize)
+	validation.RiskScore = validation.ScoreBreakdown.Score
 
 	return validation
 }
 
 // ValidatePortfolio checks overall portfolio risk
 func (rm *RiskManager) ValidatePortfolio(trades []TradeRecommendation, portfolio map[string]interface{}) *TradeValidation {
+	policy := rm.policy
 	validation := &TradeValidation{
-		IsValid:    true,
-		Violations: []string{},
+		IsValid:          true,
+		Violations:       []string{},
+		RuleViolations:   []RuleViolation{},
+		RequiresApproval: policy.RequireManualApproval,
 	}
 
-	portfolioValue, ok := portfolio["total_value"].(float64)
-	if !ok || portfolioValue <= 0 {
-		portfolioValue = 100000
-	}
+	portfolioValue := portfolioValue(portfolio)
 
 	// Calculate total risk
 	totalRisk := 0.0
 	symbolRisk := make(map[string]float64)
+	sectorRisk := make(map[string]float64)
+	sectorTrades := make(map[string]int)
 	
 	for _, trade := range trades {
-		risk := math.Abs(trade.MaxLoss)
+		risk := trade.totalRisk()
 		totalRisk += risk
-		symbolRisk[trade.Ticker] += risk
+		symbolRisk[strings.ToUpper(trade.Ticker)] += risk
+		if sector := policy.sectorOf(trade.Ticker); sector != "" {
+			sectorRisk[sector] += risk
+			sectorTrades[sector]++
+		}
+	}
+
+	// Check the combined margin requirement against available buying power
+	if policy.RuleEnabled(RuleBuyingPower) {
+		required := 0.0
+		for i := range trades {
+			required += rm.MarginRequirement(&trades[i], portfolio).Total
+		}
+		switch available, known := buyingPower(portfolio); {
+		case !known:
+			validation.Warnings = append(validation.Warnings,
+				fmt.Sprintf("Buying power unknown; combined margin requirement $%.2f not checked", required))
+		case available <= 0 || required > available:
+			validation.addViolation(RuleBuyingPower,
+				fmt.Sprintf("Combined margin requirement $%.2f exceeds buying power $%.2f", required, available))
+		}
 	}
 
 	// Check total portfolio risk
 	totalRiskPercent := (totalRisk / portfolioValue) * 100
-	if totalRiskPercent > rm.maxPortfolioRiskPercent {
-		validation.IsValid = false
-		validation.Violations = append(validation.Violations,
+	if totalRiskPercent > policy.Limits.MaxPortfolioRisk && policy.RuleEnabled(RulePortfolioRisk) {
+		validation.addViolation(RulePortfolioRisk,
 			fmt.Sprintf("Total portfolio risk %.2f%% exceeds limit %.2f%%",
-				totalRiskPercent, rm.maxPortfolioRiskPercent))
+				totalRiskPercent, policy.Limits.MaxPortfolioRisk))
 	}
 
 	// Check concentration risk
-	for symbol, risk := range symbolRisk {
-		concentrationPercent := (risk / portfolioValue) * 100
-		if concentrationPercent > rm.maxConcentrationPercent {
-			validation.IsValid = false
-			validation.Violations = append(validation.Violations,
+	for _, symbol := range sortedKeys(symbolRisk) {
+		concentrationPercent := (symbolRisk[symbol] / portfolioValue) * 100
+		if concentrationPercent > policy.Limits.MaxConcentration && policy.RuleEnabled(RuleConcentration) {
+			validation.addViolation(RuleConcentration,
 				fmt.Sprintf("Concentration in %s (%.2f%%) exceeds limit %.2f%%",
-					symbol, concentrationPercent, rm.maxConcentrationPercent))
+					symbol, concentrationPercent, policy.Limits.MaxConcentration))
+		}
+		if rule := policy.Symbols[symbol]; rule != nil && rule.MaxConcentration != nil &&
+			concentrationPercent > *rule.MaxConcentration && policy.RuleEnabled(RuleSymbolConcentrate) {
+			validation.addViolation(RuleSymbolConcentrate,
+				fmt.Sprintf("Concentration in %s (%.2f%%) exceeds symbol limit %.2f%%",
+					symbol, concentrationPercent, *rule.MaxConcentration))
 		}
 	}
 
+	// Check sector limits
+	for _, sector := range sortedKeys(sectorRisk) {
+		rule := policy.Sectors[sector]
+		maxTrades := policy.MaxTradesPerSector
+		if rule != nil && rule.MaxTrades != nil {
+			maxTrades = *rule.MaxTrades
+		}
+		if maxTrades > 0 && sectorTrades[sector] > maxTrades && policy.RuleEnabled(RuleSectorTradeCount) {
+			validation.addViolation(RuleSectorTradeCount,
+				fmt.Sprintf("%d trades in sector %s exceed limit of %d",
+					sectorTrades[sector], sector, maxTrades))
+		}
+		if rule == nil {
+			continue
+		}
+		concentrationPercent := (sectorRisk[sector] / portfolioValue) * 100
+		if rule.MaxConcentration != nil && concentrationPercent > *rule.MaxConcentration && policy.RuleEnabled(RuleSectorConcentrate) {
+			validation.addViolation(RuleSectorConcentrate,
+				fmt.Sprintf("Concentration in sector %s (%.2f%%) exceeds limit %.2f%%",
+					sector, concentrationPercent, *rule.MaxConcentration))
+		}
+	}
+
+	// Check risk in underlyings that move together
+	if rm.correlations != nil && policy.MaxCorrelatedExposure > 0 && policy.RuleEnabled(RuleCorrelatedExposure) {
+		symbols := sortedKeys(symbolRisk)
+		for _, cluster := range CorrelationClusters(symbols, rm.correlations, policy.CorrelationThreshold) {
+			if len(cluster) < 2 {
+				continue
+			}
+			clusterRisk := 0.0
+			for _, symbol := range cluster {
+				clusterRisk += symbolRisk[symbol]
+			}
+			exposurePercent := (clusterRisk / portfolioValue) * 100
+			if exposurePercent > policy.MaxCorrelatedExposure {
+				validation.addViolation(RuleCorrelatedExposure,
+					fmt.Sprintf("Correlated exposure in %s (%.2f%%, correlation >= %.2f) exceeds limit %.2f%%",
+						strings.Join(cluster, ", "), exposurePercent, policy.CorrelationThreshold, policy.MaxCorrelatedExposure))
+			}
+		}
+	}
+
+	// Check the net Greeks of the portfolio plus the basket
+	validation.Greeks = rm.BasketGreeks(trades, portfolio)
+	for _, breach := range validation.Greeks.Breaches {
+		validation.addViolation(breach.RuleID, breach.message())
+	}
+
 	return validation
 }
 
-// CalculatePortfolioMetrics computes comprehensive risk metrics
-func (rm *RiskManager) CalculatePortfolioMetrics(positions []map[string]interface{}) *PortfolioRiskMetrics {
-	metrics := &PortfolioRiskMetrics{
-		Concentrations: make(map[string]float64),
+func (b GreekBreach) message() string {
+	switch {
+	case b.Min != nil && b.Max != nil:
+		return fmt.Sprintf("Net %s %.2f outside band [%.2f, %.2f]", b.Greek, b.Value, *b.Min, *b.Max)
+	case b.Min != nil:
+		return fmt.Sprintf("Net %s %.2f below minimum %.2f", b.Greek, b.Value, *b.Min)
+	default:
+		return fmt.Sprintf("Net %s %.2f above maximum %.2f", b.Greek, b.Value, *b.Max)
 	}
+}
 
-	totalValue := 0.0
-	symbolValues := make(map[string]float64)
+// Screen validates each trade and splits them into those that pass and those
+// that don't. Trades are admitted in order, so a trade that would push the
+// accepted set over a portfolio-level limit is rejected even if it passes on
+// its own. A Greeks band already breached by the accepted set only rejects
+// trades that move further outside it. Unsized trades that pass on their
+// own are sized with the policy's sizing method against what is left of the
+// risk budget and buying power, and sized down if that alone keeps the net
+// Greeks within bands. Every returned trade carries its Validation.
+func (rm *RiskManager) Screen(trades []TradeRecommendation, portfolio map[string]interface{}) (approved, rejected []TradeRecommendation) {
+	approved = []TradeRecommendation{}
+	nav := portfolioValue(portfolio)
+	buyingPowerLeft, buyingPowerKnown := buyingPower(portfolio)
+	riskBudget := rm.policy.Limits.MaxPortfolioRisk / 100 * nav
+	before := rm.greekBreaches(rm.BasketGreeks(approved, portfolio).Net, nav/100000)
 
-	// Aggregate position values and Greeks
-	for _, pos := range positions {
-		if symbol, ok := pos["symbol"].(string); ok {
-			if value, ok := pos["market_value"].(float64); ok {
-				totalValue += value
-				symbolValues[symbol] += value
+	for _, trade := range trades {
+		validation := rm.ValidateTrade(&trade, portfolio)
+		if validation.IsValid && trade.Quantity == 0 && rm.policy.Sizing.Method != "" {
+			margin := rm.MarginRequirement(&trade, portfolio).PerContract
+			trade.Sizing = rm.sizeTrade(&trade, nav, buyingPowerLeft, buyingPowerKnown, margin, riskBudget)
+			trade.Quantity = trade.Sizing.Contracts
+			if trade.Quantity == 0 && rm.policy.RuleEnabled(RuleSizing) {
+				validation.addViolation(RuleSizing, trade.Sizing.Rationale)
 			}
-
-			// Sum up Greeks if available
-			if delta, ok := pos["delta"].(float64); ok {
-				metrics.TotalDelta += delta
+		}
+		if validation.IsValid {
+			violations, greeks := rm.basketViolations(approved, trade, portfolio, before)
+			if units := greeksResize(&trade, greeks, len(approved)); units > 0 && onlyGreeksViolations(violations) {
+				trade.Quantity = units
+				if trade.Sizing != nil {
+					trade.Sizing.resize(units, nav, "net Greeks bands")
+				}
+				violations, greeks = rm.basketViolations(approved, trade, portfolio, before)
 			}
-			if gamma, ok := pos["gamma"].(float64); ok {
-				metrics.TotalGamma += gamma
+			for _, violation := range violations {
+				validation.addViolation(violation.RuleID, violation.Message)
 			}
-			if vega, ok := pos["vega"].(float64); ok {
-				metrics.TotalVega += vega
+			validation.Greeks = greeks
+			if validation.IsValid {
+				before = greeks.Breaches
 			}
-			if theta, ok := pos["theta"].(float64); ok {
-				metrics.TotalTheta += theta
+		}
+
+		trade.Validation = validation
+		trade.Margin = rm.MarginRequirement(&trade, portfolio)
+		if validation.IsValid {
+			approved = append(approved, trade)
+			riskBudget -= trade.totalRisk()
+			buyingPowerLeft -= trade.Margin.Total
+		} else {
+			rejected = append(rejected, trade)
+		}
+	}
+	return approved, rejected
+}
+
+// basketViolations validates approved plus trade, dropping Greeks breaches
+// that trade does not make worse than before
+func (rm *RiskManager) basketViolations(approved []TradeRecommendation, trade TradeRecommendation, portfolio map[string]interface{}, before []GreekBreach) ([]RuleViolation, *BasketGreeks) {
+	combined := rm.ValidatePortfolio(append(approved[:len(approved):len(approved)], trade), portfolio)
+	var violations []RuleViolation
+	for _, violation := range combined.RuleViolations {
+		if breach := findBreach(combined.Greeks.Breaches, violation.RuleID); breach != nil {
+			if prior := findBreach(before, violation.RuleID); prior != nil && breach.Excess <= prior.Excess+1e-9 {
+				continue
 			}
 		}
+		violations = append(violations, violation)
 	}
+	return violations, combined.Greeks
+}
 
-	// Calculate concentrations
-	if totalValue > 0 {
-		for symbol, value := range symbolValues {
-			metrics.Concentrations[symbol] = (value / totalValue) * 100
+// greeksResize returns the smaller whole Quantity of the trade at index
+// that brings the basket within its Greeks bands, or 0 if there is none
+func greeksResize(trade *TradeRecommendation, greeks *BasketGreeks, index int) int {
+	if greeks.AdjustmentsCombined {
+		return 0
+	}
+	for _, adjustment := range greeks.Adjustments {
+		if adjustment.Index != index || adjustment.Action != "resize" {
+			continue
+		}
+		units := trade.units() * adjustment.Scale
+		if whole := math.Round(units); math.Abs(units-whole) < 1e-6 && whole >= 1 && whole < trade.units() {
+			return int(whole)
 		}
 	}
+	return 0
+}
 
-	// Calculate Value at Risk (simplified)
-	// In production, use historical data and proper VaR calculation
-	metrics.ValueAtRisk = totalValue * 0.02 // 2% VaR estimate
+func onlyGreeksViolations(violations []RuleViolation) bool {
+	for _, violation := range violations {
+		if !strings.HasPrefix(violation.RuleID, "greeks.") {
+			return false
+		}
+	}
+	return len(violations) > 0
+}
 
-	// Estimate max drawdown based on position characteristics
-	metrics.MaxDrawdown = rm.estimateMaxDrawdown(positions)
+func findBreach(breaches []GreekBreach, ruleID string) *GreekBreach {
+	for i := range breaches {
+		if breaches[i].RuleID == ruleID {
+			return &breaches[i]
+		}
+	}
+	return nil
+}
 
-	// Calculate correlation risk (simplified)
-	metrics.CorrelationRisk = rm.calculateCorrelationRisk(symbolValues)
+// portfolioValue reads total_value from a portfolio, defaulting to 100k
+func portfolioValue(portfolio map[string]interface{}) float64 {
+	value, ok := portfolio["total_value"].(float64)
+	if !ok || value <= 0 {
+		return 100000 // Default to 100k if not provided
+	}
+	return value
+}
 
-	return metrics
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func (rm *RiskManager) calculateRiskScore(trade *TradeRecommendation, positionRiskPercent float64) float64 {
-	score := 0.0
+// CalculatePortfolioMetrics computes comprehensive risk metrics. Greeks are
+// summed at position level (per-share Greeks × quantity × multiplier).
+// ValueAtRisk and MaxDrawdown come from the VaR engine run over history;
+// without enough history they fall back to rough estimates.
+func (rm *RiskManager) CalculatePortfolioMetrics(positions []map[string]interface{}, history map[string][]DailyBar, config VaRConfig) *PortfolioRiskMetrics {
+	metrics := &PortfolioRiskMetrics{
+		Concentrations:       make(map[string]float64),
+		SectorConcentrations: make(map[string]float64),
+	}
+
+	typed, problems := PositionsFromMaps(positions)
+	engine := NewVaREngine(config)
+	report, err := engine.Run(typed, history)
+	report.Warnings = append(problems, report.Warnings...)
+	if err != nil {
+		report.Warnings = append(report.Warnings, err.Error())
+	}
+	metrics.VaR = report
 
-	// POP component (0-30 points, higher POP = lower risk)
-	popScore := (1 - trade.POP) * 30
-	score += popScore
+	totalValue := 0.0
+	symbolValues := make(map[string]float64)
 
-	// Position size component (0-30 points)
-	sizeScore := (positionRiskPercent / rm.maxPositionSizePercent) * 30
-	score += math.Min(sizeScore, 30)
+	// Aggregate position values and Greeks
+	for _, pos := range typed {
+		value := math.Abs(pos.MarketValue)
+		totalValue += value
+		symbolValues[pos.Underlying] += value
 
-	// Risk/reward component (0-20 points)
-	rrRatio := trade.MaxProfit / math.Abs(trade.MaxLoss)
-	rrScore := (1 / (rrRatio + 1)) * 20
-	score += rrScore
+		size := pos.Quantity * pos.Multiplier
+		metrics.TotalDelta += pos.Delta * size
+		metrics.TotalGamma += pos.Gamma * size
+		metrics.TotalVega += pos.Vega * size
+		metrics.TotalTheta += pos.Theta * size
+	}
 
-	// Strategy risk component (0-20 points)
-	strategyScore := rm.getStrategyRiskScore(trade.Strategy)
-	score += strategyScore
+	// Calculate concentrations
+	if totalValue > 0 {
+		for symbol, value := range symbolValues {
+			metrics.Concentrations[symbol] = (value / totalValue) * 100
+		}
+	}
 
-	return math.Min(score, 100)
-}
+	if len(report.Historical) > 0 {
+		metrics.ValueAtRisk = report.Historical[0].VaR
+		metrics.MaxDrawdown = report.MaxDrawdown
+	} else {
+		// Not enough history; use rough estimates
+		metrics.ValueAtRisk = totalValue * 0.02
+		metrics.MaxDrawdown = rm.estimateMaxDrawdown(positions)
+	}
 
-func (rm *RiskManager) getStrategyRiskScore(strategy string) float64 {
-	// Assign risk scores to different strategies
-	strategyRiskMap := map[string]float64{
-		"covered call":       5.0,
-		"cash secured put":   7.0,
-		"credit spread":      10.0,
-		"iron condor":        12.0,
-		"butterfly":          15.0,
-		"naked option":       20.0,
+	// Count independent bets by clustering correlated underlyings, or by
+	// sector when there is not enough history
+	symbols := sortedKeys(symbolValues)
+	var clusters [][]string
+	if corr := LatestCorrelation(symbols, history, rm.policy.CorrelationWindow); corr != nil {
+		metrics.Correlation = corr
+		clusters = CorrelationClusters(symbols, corr, rm.policy.CorrelationThreshold)
+	} else {
+		clusters = sectorClusters(symbols, rm.policy.sectorOf)
 	}
+	metrics.EffectiveBets, metrics.BetClusters = EffectiveBets(symbolValues, clusters)
+	metrics.CorrelationRisk = correlationRisk(metrics.EffectiveBets, len(symbols))
 
-	if score, ok := strategyRiskMap[strategy]; ok {
-		return score
+	for symbol, value := range symbolValues {
+		if sector := rm.policy.sectorOf(symbol); sector != "" && totalValue > 0 {
+			metrics.SectorConcentrations[sector] += (value / totalValue) * 100
+		}
 	}
-	return 15.0 // Default medium-high risk
+
+	return metrics
 }
 
 func (rm *RiskManager) estimateMaxDrawdown(positions []map[string]interface{}) float64 {
@@ -254,31 +573,14 @@ func (rm *RiskManager) estimateMaxDrawdown(positions []map[string]interface{}) f
 	return totalRisk * 1.5 // 1.5x multiplier for correlated moves
 }
 
-func (rm *RiskManager) calculateCorrelationRisk(symbolValues map[string]float64) float64 {
-	// Simplified correlation risk based on sector concentration
-	// In production, use actual correlation matrices
-	
-	techSymbols := []string{"AAPL", "MSFT", "NVDA", "AMD", "META", "GOOGL"}
-	techConcentration := 0.0
-	totalValue := 0.0
-	
-	for symbol, value := range symbolValues {
-		totalValue += value
-		for _, techSymbol := range techSymbols {
-			if symbol == techSymbol {
-				techConcentration += value
-				break
-			}
-		}
+// correlationRisk scores the shortfall of effective bets against the
+// number of underlyings: 0 when every underlying is an independent, equally
+// weighted bet, 100 when they all amount to one
+func correlationRisk(effectiveBets float64, symbols int) float64 {
+	if symbols < 2 || effectiveBets <= 0 {
+		return 0
 	}
-	
-	if totalValue > 0 {
-		techPercent := techConcentration / totalValue
-		// Higher concentration = higher correlation risk
-		return math.Min(techPercent * 100, 100)
-	}
-	
-	return 0
+	return math.Max(0, math.Min(100, (float64(symbols)-effectiveBets)/float64(symbols-1)*100))
 }
 
 // GetRiskDisclaimer returns appropriate risk disclaimers
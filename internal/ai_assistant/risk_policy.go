@@ -0,0 +1,358 @@
+package ai_assistant
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"vibetrade-claude/internal/events"
+)
+
+// Rule IDs identify each check RiskManager performs. They appear on every
+// violation and are the keys used to switch rules on or off in a policy.
+const (
+	RulePositionSize       = "position_size"
+	RuleMinPOP             = "min_pop"
+	RuleRiskReward         = "risk_reward"
+	RulePortfolioRisk      = "portfolio_risk"
+	RuleConcentration      = "concentration"
+	RuleDailyLoss          = "daily_loss"
+	RuleStrategyAllowed    = "strategy.allowed"
+	RuleStrategySize       = "strategy.position_size"
+	RuleStrategyMinPOP     = "strategy.min_pop"
+	RuleSymbolAllowed      = "symbol.allowed"
+	RuleSymbolSize         = "symbol.position_size"
+	RuleSymbolConcentrate  = "symbol.concentration"
+	RuleSectorConcentrate  = "sector.concentration"
+	RuleSectorTradeCount   = "sector.trade_count"
+	RuleCorrelatedExposure = "correlation.exposure"
+	RuleNetDelta           = "greeks.delta"
+	RuleNetGamma           = "greeks.gamma"
+	RuleNetTheta           = "greeks.theta"
+	RuleNetVega            = "greeks.vega"
+	RuleSizing             = "sizing"
+	RuleBuyingPower        = "buying_power"
+	RuleEventBlackout      = "event.blackout"
+)
+
+// RiskPolicy is the full configuration of a RiskManager. Limits hold the
+// global thresholds; Strategies, Symbols and Sectors add narrower rules on
+// top. Any rule can be disabled by setting its ID to false in Rules.
+type RiskPolicy struct {
+	Limits                RiskLimits               `json:"limits" yaml:"limits"`
+	MinRiskReward         float64                  `json:"min_risk_reward" yaml:"min_risk_reward"`
+	RequireManualApproval bool                     `json:"require_manual_approval" yaml:"require_manual_approval"`
+	Rules                 map[string]bool          `json:"rules,omitempty" yaml:"rules,omitempty"`
+	Strategies            map[string]*StrategyRule `json:"strategies,omitempty" yaml:"strategies,omitempty"`
+	Symbols               map[string]*SymbolRule   `json:"symbols,omitempty" yaml:"symbols,omitempty"`
+	Sectors               map[string]*SectorRule   `json:"sectors,omitempty" yaml:"sectors,omitempty"`
+	SymbolSectors         map[string]string        `json:"symbol_sectors,omitempty" yaml:"symbol_sectors,omitempty"`
+
+	// MaxTradesPerSector caps trades per sector unless a SectorRule sets
+	// its own MaxTrades; 0 disables the cap
+	MaxTradesPerSector int `json:"max_trades_per_sector" yaml:"max_trades_per_sector"`
+	// MaxCorrelatedExposure caps the combined risk, as % of NAV, of trades
+	// whose underlyings correlate at CorrelationThreshold or more
+	MaxCorrelatedExposure float64 `json:"max_correlated_exposure" yaml:"max_correlated_exposure"`
+	CorrelationThreshold  float64 `json:"correlation_threshold" yaml:"correlation_threshold"`
+	CorrelationWindow     int     `json:"correlation_window" yaml:"correlation_window"` // Daily returns
+	// SectorFile is a CSV or JSON sector classification; a relative path is
+	// resolved against the policy file's directory
+	SectorFile string `json:"sector_file,omitempty" yaml:"sector_file,omitempty"`
+
+	// Greeks bounds the net Greeks of the portfolio plus the proposed basket
+	Greeks GreeksLimits `json:"greeks" yaml:"greeks"`
+	// Sizing chooses the number of contracts of each accepted trade
+	Sizing SizingConfig `json:"sizing" yaml:"sizing"`
+	// Blackouts block trades around earnings, dividends and macro events
+	Blackouts []BlackoutRule `json:"blackouts" yaml:"blackouts"`
+	// Scoring weighs the factors of each trade's risk score
+	Scoring ScoringConfig `json:"scoring" yaml:"scoring"`
+	// Approval controls when proposed trades expire and who must approve them
+	Approval ApprovalConfig `json:"approval" yaml:"approval"`
+
+	// Classification supplies sectors for symbols not in SymbolSectors
+	Classification *SectorClassification `json:"-" yaml:"-"`
+}
+
+// GreeksLimits holds a band for each net Greek. Greeks are in contract
+// units (per-share Greek × shares / 100), so a long at-the-money call is
+// about +0.5 delta and 100 shares of stock +1.0. Bands are set for a
+// $100k account and scale with NAV.
+type GreeksLimits struct {
+	Delta GreekBand `json:"delta" yaml:"delta"`
+	Gamma GreekBand `json:"gamma" yaml:"gamma"`
+	Theta GreekBand `json:"theta" yaml:"theta"` // Per calendar day
+	Vega  GreekBand `json:"vega" yaml:"vega"`   // Per vol point
+}
+
+// GreekBand bounds one net Greek per $100k of NAV; a nil end is open
+type GreekBand struct {
+	Min *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// StrategyRule constrains one strategy class of the taxonomy, e.g.
+// "iron_condor", or a group of them from strategyGroups such as "naked".
+// Trades are matched by the class ClassifyStrategy assigns them, not by the
+// words in their name, so "Short Put" and "Short Strangle" fall under
+// "naked" as surely as "Naked Put" does.
+type StrategyRule struct {
+	Allowed         *bool    `json:"allowed,omitempty" yaml:"allowed,omitempty"`
+	MaxPositionSize *float64 `json:"max_position_size,omitempty" yaml:"max_position_size,omitempty"`
+	MinPOP          *float64 `json:"min_pop,omitempty" yaml:"min_pop,omitempty"`
+}
+
+// SymbolRule constrains trades on a single underlying
+type SymbolRule struct {
+	Allowed          *bool    `json:"allowed,omitempty" yaml:"allowed,omitempty"`
+	MaxPositionSize  *float64 `json:"max_position_size,omitempty" yaml:"max_position_size,omitempty"`
+	MaxConcentration *float64 `json:"max_concentration,omitempty" yaml:"max_concentration,omitempty"`
+}
+
+// SectorRule constrains the aggregate exposure of a basket to one sector
+type SectorRule struct {
+	MaxConcentration *float64 `json:"max_concentration,omitempty" yaml:"max_concentration,omitempty"`
+	MaxTrades        *int     `json:"max_trades,omitempty" yaml:"max_trades,omitempty"`
+}
+
+// DefaultRiskPolicy returns the built-in policy, matching the hard filters
+// in the trading system prompt
+func DefaultRiskPolicy() *RiskPolicy {
+	return &RiskPolicy{
+		Limits: RiskLimits{
+			MaxPortfolioRisk: 2.0,  // 2% max portfolio risk
+			MaxPositionSize:  0.5,  // 0.5% max per position
+			MaxDailyLoss:     1.0,  // 1% max daily loss
+			MinPOP:           0.65, // 65% minimum POP
+			MaxConcentration: 10.0, // 10% max in single symbol
+		},
+		MinRiskReward:         0.33, // Minimum 1:3 risk/reward
+		RequireManualApproval: true,
+		MaxTradesPerSector:    2,   // Max 2 trades per GICS sector
+		MaxCorrelatedExposure: 1.0, // 1% max in trades that move together
+		CorrelationThreshold:  0.7,
+		CorrelationWindow:     DefaultCorrelationWindow,
+		Greeks: GreeksLimits{
+			Delta: GreekBand{Min: floatPtr(-0.30), Max: floatPtr(0.30)}, // Net delta within ±0.30
+			Vega:  GreekBand{Min: floatPtr(-0.05)},                      // Net vega at least -0.05
+		},
+		Sizing: SizingConfig{
+			Method:           SizingFixedFractional, // Risk max_position_size per trade
+			KellyFraction:    0.25,
+			TargetVolatility: 1.0,
+			MaxContracts:     10,
+		},
+		Scoring: DefaultScoringConfig(),
+		Approval: ApprovalConfig{
+			ExpireAfterMinutes: 15,  // Proposals go stale after 15 minutes
+			MaxUnderlyingMove:  1.0, // Or when the underlying moves more than 1%
+		},
+		Blackouts: []BlackoutRule{
+			// No short premium from a week before earnings, or held through them
+			{Event: events.KindEarnings, DaysBefore: 7, DaysAfter: 1, ThroughExpiration: true, Scope: BlackoutShortPremium},
+			// No new short calls in the 5 days before an ex-dividend date
+			{Event: events.KindExDividend, DaysBefore: 5, Scope: BlackoutShortCalls},
+			// No short premium opened the day before or of a rate decision or CPI print
+			{Event: events.KindFOMC, DaysBefore: 1, Scope: BlackoutShortPremium},
+			{Event: events.KindCPI, DaysBefore: 1, Scope: BlackoutShortPremium},
+		},
+	}
+}
+
+// LoadRiskPolicy reads a policy from a .yaml/.yml or .json file. Fields
+// missing from the file keep their DefaultRiskPolicy values.
+func LoadRiskPolicy(path string) (*RiskPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read risk policy: %w", err)
+	}
+
+	policy := DefaultRiskPolicy()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, policy)
+	case ".json":
+		err = json.Unmarshal(data, policy)
+	default:
+		return nil, fmt.Errorf("unsupported risk policy format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse risk policy: %w", err)
+	}
+
+	if err := policy.normalize(); err != nil {
+		return nil, err
+	}
+
+	if policy.SectorFile != "" {
+		sectorFile := policy.SectorFile
+		if !filepath.IsAbs(sectorFile) {
+			sectorFile = filepath.Join(filepath.Dir(path), sectorFile)
+		}
+		if policy.Classification, err = LoadSectorFile(sectorFile); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// WithLimits returns a copy of the policy whose global limits are replaced
+// by the non-zero fields of limits, e.g. a user's own settings
+func (p *RiskPolicy) WithLimits(limits RiskLimits) *RiskPolicy {
+	cp := *p
+	if limits.MaxPortfolioRisk > 0 {
+		cp.Limits.MaxPortfolioRisk = limits.MaxPortfolioRisk
+	}
+	if limits.MaxPositionSize > 0 {
+		cp.Limits.MaxPositionSize = limits.MaxPositionSize
+	}
+	if limits.MaxDailyLoss > 0 {
+		cp.Limits.MaxDailyLoss = limits.MaxDailyLoss
+	}
+	if limits.MinPOP > 0 {
+		cp.Limits.MinPOP = limits.MinPOP
+	}
+	if limits.MaxConcentration > 0 {
+		cp.Limits.MaxConcentration = limits.MaxConcentration
+	}
+	return &cp
+}
+
+// RuleEnabled reports whether the rule with the given ID is switched on.
+// Rules are enabled unless the policy explicitly sets them to false.
+func (p *RiskPolicy) RuleEnabled(ruleID string) bool {
+	enabled, ok := p.Rules[ruleID]
+	return !ok || enabled
+}
+
+// strategyGroups name sets of strategy classes that one rule can cover
+var strategyGroups = map[string][]string{
+	"naked":    {ClassNakedOption, ClassShortStraddle}, // Any uncovered short option
+	"spread":   {ClassCreditSpread, ClassDebitSpread},
+	"straddle": {ClassShortStraddle, ClassLongStraddle},
+}
+
+// strategyRule returns the rule for trade's strategy class, preferring a
+// rule on the class itself over one on a group containing it
+func (p *RiskPolicy) strategyRule(trade *TradeRecommendation) (string, *StrategyRule) {
+	class := ClassifyStrategy(trade.Strategy, trade.LegDetails)
+	if rule, ok := p.Strategies[class]; ok {
+		return class, rule
+	}
+
+	for _, group := range sortedKeys(strategyGroups) {
+		rule, ok := p.Strategies[group]
+		if !ok {
+			continue
+		}
+		for _, member := range strategyGroups[group] {
+			if member == class {
+				return group, rule
+			}
+		}
+	}
+	return "", nil
+}
+
+// sectorOf returns the sector of symbol from SymbolSectors, falling back
+// to the classification file
+func (p *RiskPolicy) sectorOf(symbol string) string {
+	if sector, ok := p.SymbolSectors[strings.ToUpper(symbol)]; ok {
+		return sector
+	}
+	return p.Classification.Sector(symbol)
+}
+
+// normalize canonicalizes map keys and checks values are in range
+func (p *RiskPolicy) normalize() error {
+	strategies := make(map[string]*StrategyRule, len(p.Strategies))
+	for key, rule := range p.Strategies {
+		if rule == nil {
+			continue
+		}
+		class := strings.ReplaceAll(normalizeStrategyName(key), " ", "_")
+		_, isGroup := strategyGroups[class]
+		if !isStrategyClass(class) && !isGroup {
+			return fmt.Errorf("risk policy has unknown strategy %q; use a strategy class or one of %s",
+				key, strings.Join(sortedKeys(strategyGroups), ", "))
+		}
+		strategies[class] = rule
+	}
+	p.Strategies = strategies
+
+	symbols := make(map[string]*SymbolRule, len(p.Symbols))
+	for key, rule := range p.Symbols {
+		if rule == nil {
+			continue
+		}
+		symbols[strings.ToUpper(strings.TrimSpace(key))] = rule
+	}
+	p.Symbols = symbols
+
+	sectorMap := make(map[string]string, len(p.SymbolSectors))
+	for symbol, sector := range p.SymbolSectors {
+		sectorMap[strings.ToUpper(strings.TrimSpace(symbol))] = sector
+	}
+	p.SymbolSectors = sectorMap
+
+	if p.CorrelationThreshold < 0 || p.CorrelationThreshold > 1 {
+		return fmt.Errorf("risk policy correlation_threshold must be between 0 and 1")
+	}
+	for name, band := range map[string]GreekBand{"delta": p.Greeks.Delta, "gamma": p.Greeks.Gamma, "theta": p.Greeks.Theta, "vega": p.Greeks.Vega} {
+		if band.Min != nil && band.Max != nil && *band.Min > *band.Max {
+			return fmt.Errorf("risk policy greeks.%s min must not exceed max", name)
+		}
+	}
+	switch p.Sizing.Method {
+	case "", SizingFixedFractional, SizingKelly, SizingVolTarget:
+	default:
+		return fmt.Errorf("risk policy sizing method %q is not %s, %s or %s", p.Sizing.Method, SizingFixedFractional, SizingKelly, SizingVolTarget)
+	}
+	if p.Approval.ExpireAfterMinutes < 0 || p.Approval.MaxUnderlyingMove < 0 || p.Approval.FourEyesThreshold < 0 {
+		return fmt.Errorf("risk policy approval settings must not be negative")
+	}
+	if err := p.Scoring.normalize(); err != nil {
+		return err
+	}
+	for i := range p.Blackouts {
+		rule := &p.Blackouts[i]
+		rule.Event = strings.ToLower(strings.TrimSpace(rule.Event))
+		if rule.Scope == "" {
+			rule.Scope = BlackoutAll
+		}
+		switch rule.Scope {
+		case BlackoutAll, BlackoutShortPremium, BlackoutShortCalls:
+		default:
+			return fmt.Errorf("risk policy blackout scope %q is not %s, %s or %s", rule.Scope, BlackoutAll, BlackoutShortPremium, BlackoutShortCalls)
+		}
+		if rule.Event == "" || rule.DaysBefore < 0 || rule.DaysAfter < 0 {
+			return fmt.Errorf("risk policy blackouts need an event and non-negative days")
+		}
+	}
+	if p.Limits.MinPOP < 0 || p.Limits.MinPOP > 1 {
+		return fmt.Errorf("risk policy min_pop must be between 0 and 1")
+	}
+	for _, v := range []float64{p.Limits.MaxPortfolioRisk, p.Limits.MaxPositionSize, p.Limits.MaxDailyLoss, p.Limits.MaxConcentration} {
+		if v < 0 {
+			return fmt.Errorf("risk policy limits must not be negative")
+		}
+	}
+
+	return nil
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// normalizeStrategyName lowercases a strategy name and collapses
+// separators so "Iron-Condor" and "iron  condor" compare equal
+func normalizeStrategyName(strategy string) string {
+	strategy = strings.ToLower(strategy)
+	strategy = strings.NewReplacer("-", " ", "_", " ", "/", " ").Replace(strategy)
+	return strings.Join(strings.Fields(strategy), " ")
+}
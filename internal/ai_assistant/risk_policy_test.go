@@ -0,0 +1,152 @@
+package ai_assistant
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func loadExamplePolicy(t *testing.T) *RiskPolicy {
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	policy, err := LoadRiskPolicy(filepath.Join(filepath.Dir(file), "..", "..", "risk_policy.example.yaml"))
+	if err != nil {
+		t.Fatalf("LoadRiskPolicy: %v", err)
+	}
+	return policy
+}
+
+func hasViolation(validation *TradeValidation, ruleID string) bool {
+	for _, v := range validation.RuleViolations {
+		if v.RuleID == ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNakedStrategyRuleMatchesByClass(t *testing.T) {
+	rm := NewRiskManagerWithPolicy(loadExamplePolicy(t))
+	portfolio := map[string]interface{}{"total_value": 100000.0}
+
+	tests := []struct {
+		strategy string
+		blocked  bool
+	}{
+		{"Naked Put", true},
+		{"Short Put", true},
+		{"Short Call", true},
+		{"Short Strangle", true},
+		{"Short Straddle", true},
+		{"Uncovered Call", true},
+		{"Bull Put Spread", false},
+		{"Cash-Secured Put", false},
+		{"Covered Call", false},
+		{"Iron Condor", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			trade := &TradeRecommendation{Ticker: "SPY", Strategy: tt.strategy, POP: 0.8, MaxLoss: -100, MaxProfit: 100}
+			validation := rm.ValidateTrade(trade, portfolio)
+			if got := hasViolation(validation, RuleStrategyAllowed); got != tt.blocked {
+				t.Fatalf("%s: strategy.allowed violation = %v, want %v (%v)", tt.strategy, got, tt.blocked, validation.Violations)
+			}
+		})
+	}
+}
+
+func TestStrategyRuleUsesLegsOverName(t *testing.T) {
+	policy := loadExamplePolicy(t)
+
+	// A single sold put is naked whatever the model calls it
+	trade := &TradeRecommendation{
+		Ticker:     "SPY",
+		Strategy:   "Income Trade",
+		LegDetails: []OptionLeg{{Action: LegSell, Type: PositionPut, Strike: 400, Quantity: 1}},
+	}
+	if key, rule := policy.strategyRule(trade); key != "naked" || rule == nil {
+		t.Fatalf("strategyRule = %q, want naked", key)
+	}
+}
+
+func TestStrategyRulePrefersClassOverGroup(t *testing.T) {
+	policy := DefaultRiskPolicy()
+	allowed, blocked := true, false
+	policy.Strategies = map[string]*StrategyRule{
+		"naked":          {Allowed: &blocked},
+		"Short Straddle": {Allowed: &allowed},
+	}
+	if err := policy.normalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	if key, _ := policy.strategyRule(&TradeRecommendation{Strategy: "Short Strangle"}); key != ClassShortStraddle {
+		t.Fatalf("strategyRule = %q, want %q", key, ClassShortStraddle)
+	}
+	if key, _ := policy.strategyRule(&TradeRecommendation{Strategy: "Short Put"}); key != "naked" {
+		t.Fatalf("strategyRule = %q, want naked", key)
+	}
+}
+
+func TestPolicyRejectsUnknownStrategyKeys(t *testing.T) {
+	policy := DefaultRiskPolicy()
+	policy.Strategies = map[string]*StrategyRule{"nakde": {}}
+	if err := policy.normalize(); err == nil {
+		t.Fatal("expected an unknown strategy key to be rejected")
+	}
+}
+
+func TestBuyingPowerCheckReportsUnknown(t *testing.T) {
+	rm := NewRiskManagerWithPolicy(DefaultRiskPolicy()).WithMarketData(&AggregatedMarketData{
+		Quotes: map[string]*Quote{"SPY": {Symbol: "SPY", Price: 450}},
+	})
+	trade := &TradeRecommendation{
+		Ticker:     "SPY",
+		Strategy:   "Naked Put",
+		POP:        0.8,
+		MaxLoss:    -1000,
+		MaxProfit:  1000,
+		LegDetails: []OptionLeg{{Action: LegSell, Type: PositionPut, Strike: 400, Expiration: "2026-12-18", Quantity: 1}},
+	}
+
+	unknown := rm.ValidateTrade(trade, map[string]interface{}{"total_value": 100000.0})
+	if hasViolation(unknown, RuleBuyingPower) || len(unknown.Warnings) == 0 {
+		t.Fatalf("unknown buying power: violations %v, warnings %v; want a warning only", unknown.Violations, unknown.Warnings)
+	}
+
+	short := rm.ValidateTrade(trade, map[string]interface{}{"total_value": 100000.0, "buying_power": 100.0})
+	if !hasViolation(short, RuleBuyingPower) {
+		t.Fatalf("buying power $100: violations %v, want %s", short.Violations, RuleBuyingPower)
+	}
+}
+
+func TestBuyingPowerDeficitRejectsAndSizesToZero(t *testing.T) {
+	policy := DefaultRiskPolicy()
+	policy.Sizing = SizingConfig{Method: SizingFixedFractional, RiskPercent: 0.5}
+	rm := NewRiskManagerWithPolicy(policy).WithMarketData(&AggregatedMarketData{
+		Quotes: map[string]*Quote{"SPY": {Symbol: "SPY", Price: 450}},
+	})
+	trade := &TradeRecommendation{
+		Ticker:     "SPY",
+		Strategy:   "Bull Put Spread",
+		POP:        0.8,
+		MaxLoss:    -500,
+		MaxProfit:  500,
+		LegDetails: []OptionLeg{{Action: LegSell, Type: PositionPut, Strike: 400, Expiration: "2026-12-18", Quantity: 1}, {Action: LegBuy, Type: PositionPut, Strike: 395, Expiration: "2026-12-18", Quantity: 1}},
+	}
+
+	for _, available := range []float64{0, -2500} {
+		portfolio := map[string]interface{}{"total_value": 100000.0, "buying_power": available}
+		if validation := rm.ValidateTrade(trade, portfolio); !hasViolation(validation, RuleBuyingPower) {
+			t.Errorf("buying power $%.0f: violations %v, want %s", available, validation.Violations, RuleBuyingPower)
+		}
+		if sizing := rm.SizeTrade(trade, 100000, available, true); sizing.Contracts != 0 {
+			t.Errorf("buying power $%.0f: sized %d contracts, want 0", available, sizing.Contracts)
+		}
+	}
+
+	if sizing := rm.SizeTrade(trade, 100000, 0, false); sizing.Contracts != 1 {
+		t.Errorf("unknown buying power: sized %d contracts (%s), want 1", sizing.Contracts, sizing.Rationale)
+	}
+}
@@ -0,0 +1,434 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Risk score factors
+const (
+	FactorPOP          = "pop"
+	FactorPositionSize = "position_size"
+	FactorRiskReward   = "risk_reward"
+	FactorStrategy     = "strategy"
+)
+
+// ScoringConfig weighs the factors of a trade's 0-100 risk score
+type ScoringConfig struct {
+	Weights ScoringWeights `json:"weights" yaml:"weights"`
+	// StrategyRisk rates each strategy class from 0 (safest) to 1; entries
+	// are merged over the defaults
+	StrategyRisk map[string]float64 `json:"strategy_risk,omitempty" yaml:"strategy_risk,omitempty"`
+}
+
+// ScoringWeights are the points each factor contributes at its riskiest.
+// The score is rescaled to 0-100 whatever they sum to.
+type ScoringWeights struct {
+	POP          float64 `json:"pop" yaml:"pop"`
+	PositionSize float64 `json:"position_size" yaml:"position_size"`
+	RiskReward   float64 `json:"risk_reward" yaml:"risk_reward"`
+	Strategy     float64 `json:"strategy" yaml:"strategy"`
+}
+
+// RiskScoreBreakdown is a trade's risk score and how each factor adds to it
+type RiskScoreBreakdown struct {
+	Score         float64      `json:"score"` // 0-100, lower is better
+	StrategyClass string       `json:"strategy_class"`
+	Factors       []RiskFactor `json:"factors"`
+}
+
+// RiskFactor is one factor's contribution to a risk score
+type RiskFactor struct {
+	Name   string  `json:"name"`
+	Input  float64 `json:"input"`  // The trade's POP, % of the size limit, profit/loss ratio or strategy risk
+	Risk   float64 `json:"risk"`   // 0 (safest) to 1
+	Weight float64 `json:"weight"` // Share of the score, summing to 100 across factors
+	Points float64 `json:"points"`
+}
+
+// DefaultScoringConfig returns the built-in weights: 30 points each for
+// POP and position size and 20 each for risk/reward and strategy
+func DefaultScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		Weights: ScoringWeights{POP: 30, PositionSize: 30, RiskReward: 20, Strategy: 20},
+		StrategyRisk: map[string]float64{
+			ClassCoveredCall:    0.25,
+			ClassCashSecuredPut: 0.35,
+			ClassDebitSpread:    0.40,
+			ClassCreditSpread:   0.50,
+			ClassLongOption:     0.50,
+			ClassStock:          0.50,
+			ClassIronCondor:     0.60,
+			ClassCalendar:       0.60,
+			ClassIronButterfly:  0.70,
+			ClassButterfly:      0.75,
+			ClassLongStraddle:   0.75,
+			ClassOther:          0.75,
+			ClassShortStraddle:  0.90,
+			ClassNakedOption:    1.00,
+		},
+	}
+}
+
+// RiskScorer computes transparent, configurable risk scores
+type RiskScorer struct {
+	config ScoringConfig
+}
+
+// NewRiskScorer creates a scorer with config; strategy classes it leaves
+// out keep their default risk
+func NewRiskScorer(config ScoringConfig) *RiskScorer {
+	merged := DefaultScoringConfig()
+	if config.Weights.total() > 0 {
+		merged.Weights = config.Weights
+	}
+	for class, risk := range config.StrategyRisk {
+		merged.StrategyRisk[class] = risk
+	}
+	return &RiskScorer{config: merged}
+}
+
+// Config returns the weights and strategy risks the scorer uses
+func (s *RiskScorer) Config() ScoringConfig {
+	return s.config
+}
+
+// Score rates trade from 0 (safest) to 100. positionRiskPercent is the
+// trade's risk as % of NAV and maxPositionSize the limit it is measured
+// against.
+func (s *RiskScorer) Score(trade *TradeRecommendation, positionRiskPercent, maxPositionSize float64) *RiskScoreBreakdown {
+	class := ClassifyStrategy(trade.Strategy, trade.LegDetails)
+	risks := s.factorRisks(trade, class, positionRiskPercent, maxPositionSize)
+	return s.score(class, risks)
+}
+
+// factorInputs holds a trade's raw value and risk for each factor, in
+// factorNames order
+type factorInputs struct {
+	input [4]float64
+	risk  [4]float64
+}
+
+var factorNames = [4]string{FactorPOP, FactorPositionSize, FactorRiskReward, FactorStrategy}
+
+func (s *RiskScorer) factorRisks(trade *TradeRecommendation, class string, positionRiskPercent, maxPositionSize float64) factorInputs {
+	var f factorInputs
+
+	// Higher POP is lower risk
+	f.input[0] = trade.POP
+	f.risk[0] = clamp01(1 - trade.POP)
+
+	// Risk relative to the position size limit, full at the limit
+	sizeUsed := 1.0
+	if maxPositionSize > 0 {
+		sizeUsed = positionRiskPercent / maxPositionSize
+	}
+	f.input[1] = sizeUsed * 100
+	f.risk[1] = clamp01(sizeUsed)
+
+	// A 1:1 payoff is half risk; richer payoffs approach zero
+	rr := 0.0
+	if trade.MaxLoss != 0 {
+		rr = math.Max(trade.MaxProfit/math.Abs(trade.MaxLoss), 0)
+	}
+	f.input[2] = rr
+	f.risk[2] = 1 / (rr + 1)
+
+	f.input[3] = s.strategyRisk(class)
+	f.risk[3] = clamp01(f.input[3])
+	return f
+}
+
+func (s *RiskScorer) score(class string, f factorInputs) *RiskScoreBreakdown {
+	weights := s.config.Weights.shares()
+	breakdown := &RiskScoreBreakdown{StrategyClass: class}
+	for i, name := range factorNames {
+		points := weights[i] * f.risk[i]
+		breakdown.Factors = append(breakdown.Factors, RiskFactor{
+			Name:   name,
+			Input:  round2(f.input[i]),
+			Risk:   round2(f.risk[i]),
+			Weight: round2(weights[i]),
+			Points: round2(points),
+		})
+		breakdown.Score += points
+	}
+	breakdown.Score = round2(math.Min(breakdown.Score, 100))
+	return breakdown
+}
+
+func (s *RiskScorer) strategyRisk(class string) float64 {
+	if risk, ok := s.config.StrategyRisk[class]; ok {
+		return risk
+	}
+	return s.config.StrategyRisk[ClassOther]
+}
+
+func (w ScoringWeights) total() float64 {
+	return w.POP + w.PositionSize + w.RiskReward + w.Strategy
+}
+
+// shares scales the weights to sum to 100, in factorNames order
+func (w ScoringWeights) shares() [4]float64 {
+	total := w.total()
+	if total <= 0 {
+		return [4]float64{25, 25, 25, 25}
+	}
+	return [4]float64{w.POP / total * 100, w.PositionSize / total * 100, w.RiskReward / total * 100, w.Strategy / total * 100}
+}
+
+func weightsFromShares(shares [4]float64) ScoringWeights {
+	return ScoringWeights{POP: round2(shares[0]), PositionSize: round2(shares[1]), RiskReward: round2(shares[2]), Strategy: round2(shares[3])}
+}
+
+// normalize canonicalizes strategy class keys and checks the config
+func (c *ScoringConfig) normalize() error {
+	for _, w := range []float64{c.Weights.POP, c.Weights.PositionSize, c.Weights.RiskReward, c.Weights.Strategy} {
+		if w < 0 {
+			return fmt.Errorf("risk policy scoring weights must not be negative")
+		}
+	}
+	if c.Weights.total() == 0 {
+		return fmt.Errorf("risk policy scoring weights must not all be zero")
+	}
+
+	known := DefaultScoringConfig().StrategyRisk
+	risks := make(map[string]float64, len(c.StrategyRisk))
+	for key, risk := range c.StrategyRisk {
+		class := strings.ReplaceAll(normalizeStrategyName(key), " ", "_")
+		if _, ok := known[class]; !ok {
+			return fmt.Errorf("risk policy scoring has unknown strategy class %q", key)
+		}
+		if risk < 0 || risk > 1 {
+			return fmt.Errorf("risk policy scoring strategy_risk.%s must be between 0 and 1", class)
+		}
+		risks[class] = risk
+	}
+	c.StrategyRisk = risks
+	return nil
+}
+
+// ScoringCalibration is the result of fitting the scoring model to closed
+// trades
+type ScoringCalibration struct {
+	Samples int `json:"samples"`
+	Losses  int `json:"losses"`
+	// Config holds the fitted weights and strategy risks, ready to use as
+	// a policy's scoring section
+	Config ScoringConfig `json:"config"`
+	// AUC is how often a losing trade scored riskier than a winning one,
+	// for the current and the fitted config; 0.5 is no better than chance
+	BaselineAUC   float64                  `json:"baseline_auc"`
+	CalibratedAUC float64                  `json:"calibrated_auc"`
+	ByClass       map[string]*ClassOutcome `json:"by_class"`
+}
+
+// ClassOutcome counts the closed trades of one strategy class
+type ClassOutcome struct {
+	Trades   int     `json:"trades"`
+	Losses   int     `json:"losses"`
+	LossRate float64 `json:"loss_rate"`
+}
+
+// MinCalibrationSamples is the fewest closed trades CalibrateScoring fits
+const MinCalibrationSamples = 10
+
+// calibrationPrior is how many trades' worth of weight the current config
+// keeps when blended with the fit, so small samples move it little
+const calibrationPrior = 30.0
+
+// CalibrateScoring fits the scoring weights to closed trades, e.g. from
+// PerformanceTracker.ClosedRecords. A trade is a loss when its actual
+// profit is negative. The weights come from a logistic regression of
+// losses on the factor risks, constrained to be non-negative; each strategy
+// class's risk is scaled by how its loss rate compares to the overall rate.
+// Both are blended with base in proportion to the number of trades.
+// maxPositionSize is the limit position sizes are measured against.
+func CalibrateScoring(records []*RecommendationRecord, base ScoringConfig, maxPositionSize float64) (*ScoringCalibration, error) {
+	scorer := NewRiskScorer(base)
+
+	var samples []factorInputs
+	var classes []string
+	var losses []bool
+	for _, rec := range records {
+		if rec.ActualProfit == nil {
+			continue
+		}
+		trade := rec.Recommendation
+		class := ClassifyStrategy(trade.Strategy, trade.LegDetails)
+		samples = append(samples, scorer.factorRisks(&trade, class, recordRiskPercent(rec, maxPositionSize), maxPositionSize))
+		classes = append(classes, class)
+		losses = append(losses, *rec.ActualProfit < 0)
+	}
+
+	result := &ScoringCalibration{Samples: len(samples), ByClass: make(map[string]*ClassOutcome)}
+	if len(samples) < MinCalibrationSamples {
+		return nil, fmt.Errorf("need at least %d closed trades to calibrate, have %d", MinCalibrationSamples, len(samples))
+	}
+	for i, class := range classes {
+		outcome := result.ByClass[class]
+		if outcome == nil {
+			outcome = &ClassOutcome{}
+			result.ByClass[class] = outcome
+		}
+		outcome.Trades++
+		if losses[i] {
+			outcome.Losses++
+			result.Losses++
+		}
+	}
+	if result.Losses == 0 || result.Losses == result.Samples {
+		return nil, fmt.Errorf("need both winning and losing trades to calibrate")
+	}
+
+	// Strategy risk: the base risk scaled by the class's smoothed loss rate
+	// relative to the overall loss rate
+	overall := float64(result.Losses) / float64(result.Samples)
+	calibrated := ScoringConfig{StrategyRisk: make(map[string]float64, len(scorer.config.StrategyRisk))}
+	for class, risk := range scorer.config.StrategyRisk {
+		calibrated.StrategyRisk[class] = risk
+	}
+	for class, outcome := range result.ByClass {
+		outcome.LossRate = round2(float64(outcome.Losses) / float64(outcome.Trades))
+		smoothed := (float64(outcome.Losses) + 10*overall) / (float64(outcome.Trades) + 10)
+		calibrated.StrategyRisk[class] = round2(clamp01(scorer.strategyRisk(class) * smoothed / overall))
+	}
+	for i, class := range classes {
+		samples[i].risk[3] = calibrated.StrategyRisk[class]
+	}
+
+	// Weights: fit, then blend with the current shares
+	fitted := fitLossWeights(samples, losses)
+	baseShares := scorer.config.Weights.shares()
+	n := float64(result.Samples)
+	var blended [4]float64
+	for i := range blended {
+		blended[i] = (n*fitted[i] + calibrationPrior*baseShares[i]) / (n + calibrationPrior)
+	}
+	calibrated.Weights = weightsFromShares(blended)
+	result.Config = calibrated
+
+	calibratedScorer := NewRiskScorer(calibrated)
+	baseline := make([]float64, len(samples))
+	fittedScores := make([]float64, len(samples))
+	for i, f := range samples {
+		original := f
+		original.risk[3] = clamp01(scorer.strategyRisk(classes[i]))
+		baseline[i] = scorer.score(classes[i], original).Score
+		fittedScores[i] = calibratedScorer.score(classes[i], f).Score
+	}
+	result.BaselineAUC = round2(rankAUC(baseline, losses))
+	result.CalibratedAUC = round2(rankAUC(fittedScores, losses))
+	return result, nil
+}
+
+// recordRiskPercent returns the % of NAV a recorded trade risked: from its
+// sizing decision, else its recorded score, else the position size limit
+// that trades are sized to by default
+func recordRiskPercent(rec *RecommendationRecord, maxPositionSize float64) float64 {
+	trade := rec.Recommendation
+	if trade.Sizing != nil && trade.Sizing.RiskPercent > 0 {
+		return trade.Sizing.RiskPercent
+	}
+	if trade.Validation != nil && trade.Validation.ScoreBreakdown != nil {
+		for _, factor := range trade.Validation.ScoreBreakdown.Factors {
+			if factor.Name == FactorPositionSize {
+				return factor.Input / 100 * maxPositionSize
+			}
+		}
+	}
+	return maxPositionSize
+}
+
+// fitLossWeights fits P(loss) = σ(b + Σ wᵢ·riskᵢ) by projected gradient
+// descent with wᵢ ≥ 0 and returns the weights as shares of 100. With no
+// signal in any factor the shares are equal.
+func fitLossWeights(samples []factorInputs, losses []bool) [4]float64 {
+	var w [4]float64
+	b := 0.0
+	const rate, ridge, iterations = 0.5, 0.001, 5000
+	n := float64(len(samples))
+
+	for iter := 0; iter < iterations; iter++ {
+		var gradW [4]float64
+		gradB := 0.0
+		for i, f := range samples {
+			z := b
+			for j := range w {
+				z += w[j] * f.risk[j]
+			}
+			diff := 1 / (1 + math.Exp(-z))
+			if losses[i] {
+				diff--
+			}
+			gradB += diff
+			for j := range w {
+				gradW[j] += diff * f.risk[j]
+			}
+		}
+		b -= rate * gradB / n
+		for j := range w {
+			w[j] = math.Max(w[j]-rate*(gradW[j]/n+ridge*w[j]), 0)
+		}
+	}
+
+	var total float64
+	for _, v := range w {
+		total += v
+	}
+	var shares [4]float64
+	for j := range shares {
+		if total > 0 {
+			shares[j] = w[j] / total * 100
+		} else {
+			shares[j] = 25
+		}
+	}
+	return shares
+}
+
+// rankAUC is the probability that a random loss has a higher score than a
+// random win, counting ties as half
+func rankAUC(scores []float64, losses []bool) float64 {
+	type scored struct {
+		score float64
+		loss  bool
+	}
+	items := make([]scored, len(scores))
+	for i := range scores {
+		items[i] = scored{scores[i], losses[i]}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].score < items[j].score })
+
+	// Sum the average ranks of the losses
+	var rankSum, lossCount float64
+	for i := 0; i < len(items); {
+		j := i
+		for j < len(items) && items[j].score == items[i].score {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if items[k].loss {
+				rankSum += rank
+				lossCount++
+			}
+		}
+		i = j
+	}
+	winCount := float64(len(items)) - lossCount
+	if lossCount == 0 || winCount == 0 {
+		return 0.5
+	}
+	return (rankSum - lossCount*(lossCount+1)/2) / (lossCount * winCount)
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(v, 1))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
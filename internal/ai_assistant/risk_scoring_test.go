@@ -0,0 +1,73 @@
+package ai_assistant
+
+import "testing"
+
+func TestClassifyStrategyByName(t *testing.T) {
+	tests := []struct {
+		strategy string
+		class    string
+	}{
+		{"Short Put Spread", ClassCreditSpread},
+		{"Short Call Spread", ClassCreditSpread},
+		{"Short Put Vertical", ClassCreditSpread},
+		{"Bull Put Spread", ClassCreditSpread},
+		{"Bear Call Credit Spread", ClassCreditSpread},
+		{"Long Call Spread", ClassDebitSpread},
+		{"Bear Put Spread", ClassDebitSpread},
+		{"Covered Strangle", ClassShortStraddle},
+		{"Covered Call", ClassCoveredCall},
+		{"Buy-Write", ClassCoveredCall},
+		{"Cash-Secured Put", ClassCashSecuredPut},
+		{"Short Put", ClassNakedOption},
+		{"Short Call", ClassNakedOption},
+		{"Naked Put", ClassNakedOption},
+		{"Short Strangle", ClassShortStraddle},
+		{"Long Straddle", ClassLongStraddle},
+		{"Iron-Condor", ClassIronCondor},
+		{"Iron Fly", ClassIronButterfly},
+		{"Broken Wing Butterfly", ClassButterfly},
+		{"Poor Man's Covered Call Diagonal", ClassCalendar},
+		{"Long Call", ClassLongOption},
+		{"Vertical Spread", ClassCreditSpread},
+		{"Something Else", ClassOther},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyStrategy(tt.strategy, nil); got != tt.class {
+			t.Errorf("ClassifyStrategy(%q) = %s, want %s", tt.strategy, got, tt.class)
+		}
+	}
+}
+
+func TestClassifyStrategyByLegs(t *testing.T) {
+	put := func(action string, strike float64) OptionLeg {
+		return OptionLeg{Action: action, Type: PositionPut, Strike: strike, Expiration: "2026-12-18", Quantity: 1}
+	}
+	call := func(action string, strike float64) OptionLeg {
+		return OptionLeg{Action: action, Type: PositionCall, Strike: strike, Expiration: "2026-12-18", Quantity: 1}
+	}
+	stock := OptionLeg{Action: LegBuy, Type: PositionStock, Quantity: 100}
+
+	tests := []struct {
+		name     string
+		strategy string
+		legs     []OptionLeg
+		class    string
+	}{
+		{"sold put named a spread", "Short Put Spread", []OptionLeg{put(LegSell, 400)}, ClassNakedOption},
+		{"credit put vertical named short put", "Short Put", []OptionLeg{put(LegSell, 400), put(LegBuy, 390)}, ClassCreditSpread},
+		{"debit call vertical", "Income Trade", []OptionLeg{call(LegBuy, 400), call(LegSell, 410)}, ClassDebitSpread},
+		{"iron condor named a strangle", "Short Strangle", []OptionLeg{put(LegBuy, 380), put(LegSell, 390), call(LegSell, 410), call(LegBuy, 420)}, ClassIronCondor},
+		{"covered call", "Covered Strangle", []OptionLeg{stock, call(LegSell, 410)}, ClassCoveredCall},
+		{"covered strangle", "Covered Strangle", []OptionLeg{stock, call(LegSell, 410), put(LegSell, 390)}, ClassShortStraddle},
+		{"calendar", "Put Calendar", []OptionLeg{put(LegSell, 400), {Action: LegBuy, Type: PositionPut, Strike: 400, Expiration: "2027-01-15", Quantity: 1}}, ClassCalendar},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyStrategy(tt.strategy, tt.legs); got != tt.class {
+				t.Errorf("ClassifyStrategy(%q, legs) = %s, want %s", tt.strategy, got, tt.class)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package ai_assistant
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Classification is a symbol's GICS-style sector and industry
+type Classification struct {
+	Symbol   string `json:"symbol"`
+	Sector   string `json:"sector"`
+	Industry string `json:"industry,omitempty"`
+}
+
+// SectorClassification maps symbols to their sector and industry
+type SectorClassification struct {
+	bySymbol map[string]Classification
+}
+
+// NewSectorClassification builds a classification from entries
+func NewSectorClassification(entries []Classification) *SectorClassification {
+	sc := &SectorClassification{bySymbol: make(map[string]Classification, len(entries))}
+	for _, entry := range entries {
+		entry.Symbol = strings.ToUpper(strings.TrimSpace(entry.Symbol))
+		entry.Sector = strings.TrimSpace(entry.Sector)
+		entry.Industry = strings.TrimSpace(entry.Industry)
+		if entry.Symbol == "" || entry.Sector == "" {
+			continue
+		}
+		sc.bySymbol[entry.Symbol] = entry
+	}
+	return sc
+}
+
+// LoadSectorFile reads a classification from a .csv file with a
+// symbol,sector,industry header or a .json array of Classification
+func LoadSectorFile(path string) (*SectorClassification, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sector file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Classification
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readSectorCSV(file)
+	case ".json":
+		err = json.NewDecoder(file).Decode(&entries)
+	default:
+		return nil, fmt.Errorf("unsupported sector file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sector file: %w", err)
+	}
+
+	return NewSectorClassification(entries), nil
+}
+
+func readSectorCSV(r io.Reader) ([]Classification, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	symbolCol, ok := columns["symbol"]
+	if !ok {
+		return nil, fmt.Errorf("missing symbol column")
+	}
+	sectorCol, ok := columns["sector"]
+	if !ok {
+		return nil, fmt.Errorf("missing sector column")
+	}
+	industryCol, hasIndustry := columns["industry"]
+
+	var entries []Classification
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		entry := Classification{}
+		if symbolCol < len(record) {
+			entry.Symbol = record[symbolCol]
+		}
+		if sectorCol < len(record) {
+			entry.Sector = record[sectorCol]
+		}
+		if hasIndustry && industryCol < len(record) {
+			entry.Industry = record[industryCol]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Lookup returns the classification of symbol
+func (sc *SectorClassification) Lookup(symbol string) (Classification, bool) {
+	if sc == nil {
+		return Classification{}, false
+	}
+	c, ok := sc.bySymbol[strings.ToUpper(symbol)]
+	return c, ok
+}
+
+// Sector returns the sector of symbol, or "" if it is not classified
+func (sc *SectorClassification) Sector(symbol string) string {
+	c, _ := sc.Lookup(symbol)
+	return c.Sector
+}
+
+// Len returns the number of classified symbols
+func (sc *SectorClassification) Len() int {
+	if sc == nil {
+		return 0
+	}
+	return len(sc.bySymbol)
+}
@@ -0,0 +1,250 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Sizing methods
+const (
+	SizingFixedFractional = "fixed_fractional"
+	SizingKelly           = "kelly"
+	SizingVolTarget       = "volatility_target"
+)
+
+// SizingConfig selects how many contracts of a validated trade to take.
+// Whatever the method, the size never exceeds the position size limits, the
+// remaining portfolio risk budget, buying power or MaxContracts.
+type SizingConfig struct {
+	Method string `json:"method" yaml:"method"` // SizingFixedFractional, SizingKelly or SizingVolTarget; "" leaves trades unsized
+	// RiskPercent is the % of NAV a fixed-fractional trade risks; 0 uses
+	// the position size limit
+	RiskPercent float64 `json:"risk_percent" yaml:"risk_percent"`
+	// KellyFraction scales the full Kelly stake, e.g. 0.25 for quarter Kelly
+	KellyFraction float64 `json:"kelly_fraction" yaml:"kelly_fraction"`
+	// TargetVolatility is each trade's annualized P&L volatility as % of NAV
+	TargetVolatility float64 `json:"target_volatility" yaml:"target_volatility"`
+	MaxContracts     int     `json:"max_contracts" yaml:"max_contracts"`
+}
+
+// SizingDecision is the contract count chosen for a trade and why
+type SizingDecision struct {
+	Method          string   `json:"method"`
+	Contracts       int      `json:"contracts"`
+	RiskPerContract float64  `json:"risk_per_contract"`
+	TotalRisk       float64  `json:"total_risk"`
+	RiskPercent     float64  `json:"risk_percent"`     // Of NAV
+	Limits          []string `json:"limits,omitempty"` // Caps that bound the size below the method's choice
+	Rationale       string   `json:"rationale"`
+}
+
+// units returns how many of the trade's structure are traded; an unsized
+// trade counts as one
+func (t *TradeRecommendation) units() float64 {
+	if t.Quantity <= 0 {
+		return 1
+	}
+	return float64(t.Quantity)
+}
+
+// totalRisk is the trade's max loss across all its contracts
+func (t *TradeRecommendation) totalRisk() float64 {
+	return math.Abs(t.MaxLoss) * t.units()
+}
+
+// SizeTrade picks a contract count for trade given the account's NAV and
+// buying power, using the policy's sizing method and the risk limits. Buying
+// power is only a cap when known; a known buying power of zero or less sizes
+// to no contracts.
+func (rm *RiskManager) SizeTrade(trade *TradeRecommendation, nav, buyingPower float64, known bool) *SizingDecision {
+	margin := rm.MarginRequirement(trade, nil).PerContract
+	return rm.sizeTrade(trade, nav, buyingPower, known, margin, rm.policy.Limits.MaxPortfolioRisk/100*nav)
+}
+
+// sizeTrade sizes trade against the given buying power, at margin per
+// contract, and the remaining portfolio risk budget
+func (rm *RiskManager) sizeTrade(trade *TradeRecommendation, nav, buyingPower float64, known bool, margin, riskBudget float64) *SizingDecision {
+	config := rm.policy.Sizing
+	decision := &SizingDecision{
+		Method:          config.Method,
+		RiskPerContract: math.Abs(trade.MaxLoss),
+	}
+	if decision.RiskPerContract <= 0 {
+		decision.Rationale = "Max loss unknown; cannot size the trade"
+		return decision
+	}
+
+	// The method's choice, as a fractional number of contracts
+	var target float64
+	var reason string
+	switch config.Method {
+	case SizingKelly:
+		target, reason = kellyContracts(trade, nav, config.KellyFraction)
+	case SizingVolTarget:
+		target, reason = rm.volTargetContracts(trade, nav, config.TargetVolatility)
+	default:
+		decision.Method = SizingFixedFractional
+		riskPercent := config.RiskPercent
+		if riskPercent <= 0 {
+			riskPercent = rm.maxPositionPercent(trade)
+		}
+		target = nav * riskPercent / 100 / decision.RiskPerContract
+		reason = fmt.Sprintf("Fixed fractional: risk %.2f%% of $%.0f NAV at $%.0f max loss per contract", riskPercent, nav, decision.RiskPerContract)
+	}
+	contracts := math.Floor(target + 1e-9)
+
+	// Apply the caps, noting each that binds
+	limit := func(name string, max float64) {
+		max = math.Floor(math.Max(max, 0) + 1e-9)
+		if max < contracts {
+			contracts = max
+			decision.Limits = append(decision.Limits, name)
+		}
+	}
+	limit("position size limit", nav*rm.maxPositionPercent(trade)/100/decision.RiskPerContract)
+	limit("portfolio risk budget", riskBudget/decision.RiskPerContract)
+	switch {
+	case !known:
+	case buyingPower <= 0:
+		limit("buying power", 0)
+	case margin > 0:
+		limit("buying power", buyingPower/margin)
+	}
+	if config.MaxContracts > 0 {
+		limit("max contracts", float64(config.MaxContracts))
+	}
+
+	decision.Contracts = int(contracts)
+	decision.TotalRisk = contracts * decision.RiskPerContract
+	if nav > 0 {
+		decision.RiskPercent = decision.TotalRisk / nav * 100
+	}
+
+	decision.Rationale = reason
+	if !math.IsInf(target, 1) {
+		decision.Rationale += fmt.Sprintf(" → %.2f contracts", target)
+	}
+	if len(decision.Limits) > 0 {
+		decision.Rationale += "; capped by " + strings.Join(decision.Limits, ", ")
+	}
+	if decision.Contracts == 0 {
+		decision.Rationale += "; not even one contract fits"
+	} else {
+		decision.Rationale += fmt.Sprintf("; %s risk $%.0f (%.2f%% of NAV)", contractCount(decision.Contracts), decision.TotalRisk, decision.RiskPercent)
+	}
+	return decision
+}
+
+// resize records that the trade was cut to contracts to satisfy limit
+func (d *SizingDecision) resize(contracts int, nav float64, limit string) {
+	d.Contracts = contracts
+	d.TotalRisk = float64(contracts) * d.RiskPerContract
+	if nav > 0 {
+		d.RiskPercent = d.TotalRisk / nav * 100
+	}
+	d.Limits = append(d.Limits, limit)
+	d.Rationale += fmt.Sprintf("; cut to %s by %s, risking $%.0f (%.2f%% of NAV)", contractCount(contracts), limit, d.TotalRisk, d.RiskPercent)
+}
+
+func contractCount(n int) string {
+	if n == 1 {
+		return "1 contract"
+	}
+	return fmt.Sprintf("%d contracts", n)
+}
+
+// kellyContracts stakes fraction of the Kelly criterion f* = p - (1-p)/b,
+// where p is the POP and b the max profit / max loss payoff ratio, treating
+// the max loss as the amount staked
+func kellyContracts(trade *TradeRecommendation, nav, fraction float64) (float64, string) {
+	if fraction <= 0 {
+		fraction = 0.25
+	}
+	p := trade.POP
+	b := trade.MaxProfit / math.Abs(trade.MaxLoss)
+	if b <= 0 {
+		return 0, "Kelly: no payoff"
+	}
+	kelly := p - (1-p)/b
+	if kelly <= 0 {
+		return 0, fmt.Sprintf("Kelly: no edge at POP %.2f and payoff %.2f (f* = %.3f)", p, b, kelly)
+	}
+	stake := kelly * fraction * nav
+	return stake / math.Abs(trade.MaxLoss), fmt.Sprintf("Kelly ×%.2f: f* = %.3f at POP %.2f and payoff %.2f, staking $%.0f", fraction, kelly, p, b, stake)
+}
+
+// volTargetContracts sizes the trade so the annualized volatility of its
+// P&L, estimated from its dollar delta and the underlying's volatility, is
+// target % of NAV. Trades without delta are left to the other caps.
+func (rm *RiskManager) volTargetContracts(trade *TradeRecommendation, nav, target float64) (float64, string) {
+	if target <= 0 {
+		target = 1.0
+	}
+	unit := *trade
+	unit.Quantity = 1
+	greeks, _ := rm.tradeGreeks(&unit, rm.clock())
+	spot := rm.spotPrice(trade.Ticker)
+	vol := rm.annualVolatility(trade.Ticker, spot)
+
+	dollarVol := math.Abs(greeks.Delta) * 100 * spot * vol
+	budget := nav * target / 100
+	if dollarVol <= 0 {
+		return math.Inf(1), fmt.Sprintf("Volatility target %.2f%%: no delta exposure to size against", target)
+	}
+	return budget / dollarVol, fmt.Sprintf("Volatility target %.2f%%: $%.0f budget, $%.0f annual P&L volatility per contract (%.2f delta, %.0f%% vol)",
+		target, budget, dollarVol, greeks.Delta, vol*100)
+}
+
+// maxPositionPercent returns the tightest position size limit on trade
+func (rm *RiskManager) maxPositionPercent(trade *TradeRecommendation) float64 {
+	limit := rm.policy.Limits.MaxPositionSize
+	if _, rule := rm.policy.strategyRule(trade); rule != nil && rule.MaxPositionSize != nil {
+		limit = math.Min(limit, *rule.MaxPositionSize)
+	}
+	if rule := rm.policy.Symbols[strings.ToUpper(trade.Ticker)]; rule != nil && rule.MaxPositionSize != nil {
+		limit = math.Min(limit, *rule.MaxPositionSize)
+	}
+	return limit
+}
+
+// annualVolatility estimates symbol's annualized volatility from its daily
+// closes, falling back to the chain's implied volatility nearest spot
+func (rm *RiskManager) annualVolatility(symbol string, spot float64) float64 {
+	symbol = strings.ToUpper(symbol)
+	if bars := rm.history[symbol]; len(bars) > 20 {
+		var returns []float64
+		for i := 1; i < len(bars); i++ {
+			if bars[i-1].Close > 0 && bars[i].Close > 0 {
+				returns = append(returns, math.Log(bars[i].Close/bars[i-1].Close))
+			}
+		}
+		if len(returns) > 1 {
+			variance := covariance([]string{symbol}, map[string][]float64{symbol: returns})[0][0]
+			return math.Sqrt(variance * 252)
+		}
+	}
+	if rm.market != nil {
+		var nearest *OptionChain
+		for _, chain := range rm.market.Options[symbol] {
+			if chain.IV > 0 && (nearest == nil || math.Abs(chain.Strike-spot) < math.Abs(nearest.Strike-spot)) {
+				nearest = chain
+			}
+		}
+		if nearest != nil {
+			return nearest.IV
+		}
+	}
+	return fallbackVolatility
+}
+
+// buyingPower reads buying_power from a portfolio, falling back to
+// cash_balance; known is false if neither is present
+func buyingPower(portfolio map[string]interface{}) (value float64, known bool) {
+	for _, key := range []string{"buying_power", "cash_balance"} {
+		if value, ok := portfolio[key].(float64); ok {
+			return value, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,92 @@
+package ai_assistant
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newSizingRiskManager sizes with config under limits loose enough not to
+// bind unless a test sets them
+func newSizingRiskManager(config SizingConfig) *RiskManager {
+	policy := DefaultRiskPolicy()
+	policy.Limits.MaxPositionSize = 50
+	policy.Limits.MaxPortfolioRisk = 100
+	policy.Sizing = config
+	return NewRiskManagerWithPolicy(policy).WithMarketData(&AggregatedMarketData{
+		Quotes: map[string]*Quote{"SPY": {Symbol: "SPY", Price: 500}},
+		Options: map[string][]*OptionChain{"SPY": {
+			{Symbol: "SPY", Strike: 500, Expiration: "2026-12-18", Type: PositionCall, Bid: 10, Ask: 10.5, IV: 0.2,
+				Greeks: &Greeks{Delta: 0.5, Gamma: 0.01, Theta: -0.2, Vega: 0.6}},
+		}},
+	})
+}
+
+func creditSpread(pop float64) *TradeRecommendation {
+	return &TradeRecommendation{Ticker: "SPY", Strategy: "Bull Put Spread", POP: pop, MaxLoss: -500, MaxProfit: 500}
+}
+
+func TestFixedFractionalSizing(t *testing.T) {
+	rm := newSizingRiskManager(SizingConfig{Method: SizingFixedFractional, RiskPercent: 2})
+	if d := rm.SizeTrade(creditSpread(0.7), 100000, 0, false); d.Contracts != 4 || d.TotalRisk != 2000 || d.RiskPercent != 2 {
+		t.Errorf("2%% of $100k at $500 = %+v, want 4 contracts risking $2000", d)
+	}
+
+	// Without a risk percent the position size limit is the target
+	rm = newSizingRiskManager(SizingConfig{Method: SizingFixedFractional})
+	rm.policy.Limits.MaxPositionSize = 1.5
+	if d := rm.SizeTrade(creditSpread(0.7), 100000, 0, false); d.Contracts != 3 || len(d.Limits) != 0 {
+		t.Errorf("1.5%% position limit = %+v, want 3 contracts", d)
+	}
+
+	if d := rm.SizeTrade(&TradeRecommendation{Ticker: "SPY", Strategy: "Bull Put Spread"}, 100000, 0, false); d.Contracts != 0 {
+		t.Errorf("unknown max loss = %+v, want unsized", d)
+	}
+}
+
+func TestKellySizing(t *testing.T) {
+	rm := newSizingRiskManager(SizingConfig{Method: SizingKelly, KellyFraction: 0.25})
+
+	// Even payoff at 70%: f* = 0.7 - 0.3 = 0.4, a quarter of which is $10k
+	if d := rm.SizeTrade(creditSpread(0.7), 100000, 0, false); d.Method != SizingKelly || d.Contracts != 20 {
+		t.Errorf("quarter Kelly = %+v, want 20 contracts", d)
+	}
+	if d := rm.SizeTrade(creditSpread(0.4), 100000, 0, false); d.Contracts != 0 {
+		t.Errorf("negative edge = %+v, want no contracts", d)
+	}
+}
+
+func TestVolTargetSizing(t *testing.T) {
+	rm := newSizingRiskManager(SizingConfig{Method: SizingVolTarget, TargetVolatility: 20})
+	trade := &TradeRecommendation{Ticker: "SPY", Strategy: "Long Call", POP: 0.4, MaxLoss: -1025, MaxProfit: 3000, LegDetails: []OptionLeg{
+		{Action: LegBuy, Type: PositionCall, Strike: 500, Expiration: "2026-12-18", Quantity: 1},
+	}}
+
+	// 0.5 delta × 100 shares × $500 × 20% IV = $5000 of annual P&L
+	// volatility per contract against a $20k budget
+	if d := rm.SizeTrade(trade, 100000, 0, false); d.Contracts != 4 {
+		t.Errorf("20%% vol target = %+v, want 4 contracts", d)
+	}
+
+	// Without delta the method has nothing to size against and the caps decide
+	rm.policy.Sizing.MaxContracts = 3
+	if d := rm.SizeTrade(creditSpread(0.7), 100000, 0, false); d.Contracts != 3 || !reflect.DeepEqual(d.Limits, []string{"position size limit", "max contracts"}) {
+		t.Errorf("no delta = %+v, want the caps to decide", d)
+	}
+}
+
+func TestSizingCapsBind(t *testing.T) {
+	rm := newSizingRiskManager(SizingConfig{Method: SizingKelly, KellyFraction: 0.25, MaxContracts: 10})
+	rm.policy.Limits.MaxPositionSize = 3
+
+	// Kelly wants 20, the 3% position limit allows 6
+	d := rm.SizeTrade(creditSpread(0.7), 100000, 0, false)
+	if d.Contracts != 6 || !reflect.DeepEqual(d.Limits, []string{"position size limit"}) {
+		t.Errorf("position limit = %+v, want 6 contracts", d)
+	}
+
+	// A smaller remaining risk budget binds below that
+	d = rm.sizeTrade(creditSpread(0.7), 100000, 0, false, 0, 1500)
+	if d.Contracts != 3 || !reflect.DeepEqual(d.Limits, []string{"position size limit", "portfolio risk budget"}) {
+		t.Errorf("risk budget = %+v, want 3 contracts", d)
+	}
+}
@@ -0,0 +1,121 @@
+package ai_assistant
+
+import "strings"
+
+// Strategy classes of the taxonomy. ClassifyStrategy maps the free-form
+// strategy names the model writes onto them.
+const (
+	ClassCoveredCall    = "covered_call"
+	ClassCashSecuredPut = "cash_secured_put"
+	ClassCreditSpread   = "credit_spread"
+	ClassDebitSpread    = "debit_spread"
+	ClassIronCondor     = "iron_condor"
+	ClassIronButterfly  = "iron_butterfly"
+	ClassButterfly      = "butterfly"
+	ClassCalendar       = "calendar"
+	ClassShortStraddle  = "short_straddle"
+	ClassLongStraddle   = "long_straddle"
+	ClassNakedOption    = "naked_option"
+	ClassLongOption     = "long_option"
+	ClassStock          = "stock"
+	ClassOther          = "other"
+)
+
+// strategyClasses lists every class of the taxonomy
+var strategyClasses = []string{
+	ClassCoveredCall, ClassCashSecuredPut, ClassCreditSpread, ClassDebitSpread,
+	ClassIronCondor, ClassIronButterfly, ClassButterfly, ClassCalendar,
+	ClassShortStraddle, ClassLongStraddle, ClassNakedOption, ClassLongOption,
+	ClassStock, ClassOther,
+}
+
+// isStrategyClass reports whether class is one of the taxonomy's classes
+func isStrategyClass(class string) bool {
+	for _, known := range strategyClasses {
+		if class == known {
+			return true
+		}
+	}
+	return false
+}
+
+// strategyPatterns map phrases in a normalized strategy name to a class.
+// They are tried in order, most specific first: "short put spread" is a
+// credit spread before it is a short put, and "covered strangle" sells an
+// uncovered put before it covers the call.
+var strategyPatterns = []struct {
+	class   string
+	phrases []string
+}{
+	{ClassIronButterfly, []string{"iron butterfly", "iron fly"}},
+	{ClassIronCondor, []string{"iron condor"}},
+	{ClassButterfly, []string{"butterfly", "broken wing", "condor"}},
+	{ClassCalendar, []string{"calendar", "diagonal", "time spread", "horizontal"}},
+	{ClassLongStraddle, []string{"long straddle", "long strangle"}},
+	{ClassShortStraddle, []string{"straddle", "strangle"}},
+	{ClassCreditSpread, []string{"credit", "bull put", "bear call", "short put spread", "short call spread", "short put vertical", "short call vertical", "put vertical", "call vertical", "short vertical"}},
+	{ClassDebitSpread, []string{"debit", "bull call", "bear put", "long call spread", "long put spread", "long vertical"}},
+	{ClassCoveredCall, []string{"covered call", "buy write", "covered"}},
+	{ClassCashSecuredPut, []string{"cash secured", "secured put", "csp"}},
+	{ClassNakedOption, []string{"naked", "uncovered", "short put", "short call"}},
+	{ClassLongOption, []string{"long call", "long put", "buy call", "buy put"}},
+	{ClassStock, []string{"stock", "shares", "equity"}},
+}
+
+// structureClasses map the margin calculator's structures to classes
+var structureClasses = map[string]string{
+	StructureLongOption:     ClassLongOption,
+	StructureNakedCall:      ClassNakedOption,
+	StructureNakedPut:       ClassNakedOption,
+	StructureCashSecuredPut: ClassCashSecuredPut,
+	StructureCoveredCall:    ClassCoveredCall,
+	StructureIronCondor:     ClassIronCondor,
+	StructureIronButterfly:  ClassIronButterfly,
+	StructureButterfly:      ClassButterfly,
+	StructureStock:          ClassStock,
+}
+
+// ClassifyStrategy maps a trade to a strategy class. Legs, if given, are
+// classified by their structure, since that is what the trade holds
+// whatever it is called; a strategy name, such as "Bull Put Spread" or
+// "Iron-Condor", classifies trades whose legs are missing or form no
+// structure the margin calculator knows.
+func ClassifyStrategy(strategy string, legs []OptionLeg) string {
+	if len(legs) > 0 {
+		trade := &TradeRecommendation{Strategy: strategy, LegDetails: legs}
+		structure := classifyStructure(trade)
+		if class, ok := structureClasses[structure]; ok {
+			return class
+		}
+		var options []OptionLeg
+		for _, leg := range legs {
+			if leg.Type != PositionStock {
+				options = append(options, leg)
+			}
+		}
+		// Legs at different expirations are a calendar or diagonal, which
+		// the name tells apart
+		if structure == StructureVertical && options[0].Expiration == options[1].Expiration {
+			if isCreditVertical(options) {
+				return ClassCreditSpread
+			}
+			return ClassDebitSpread
+		}
+	}
+
+	name := " " + normalizeStrategyName(strategy) + " "
+	for _, pattern := range strategyPatterns {
+		for _, phrase := range pattern.phrases {
+			if strings.Contains(name, " "+phrase+" ") {
+				return pattern.class
+			}
+		}
+	}
+
+	// A bare "spread" or "vertical" is most often the credit spreads the
+	// prompt asks for
+	if strings.Contains(name, " spread ") || strings.Contains(name, " vertical ") {
+		return ClassCreditSpread
+	}
+	return ClassOther
+}
@@ -0,0 +1,220 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Stress scenario kinds
+const (
+	StressGridScenario       = "grid"
+	StressHistoricalScenario = "historical"
+)
+
+// StressScenario is one set of shocks applied to every position. For
+// historical replays Shock.SpotReturn is the market's move, scaled per
+// underlying by its beta to the market.
+type StressScenario struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Description string `json:"description,omitempty"`
+	Shock       Shock  `json:"shock"`
+}
+
+// HistoricalReplays are market moves from past stress events, measured on
+// the S&P 500 from peak to trough with the accompanying rise in implied
+// volatility. They are applied as instantaneous shocks.
+var HistoricalReplays = []StressScenario{
+	{
+		Name:        "2020-03 COVID crash",
+		Kind:        StressHistoricalScenario,
+		Description: "S&P 500 fell 34% from 19 Feb to 23 Mar 2020 while VIX rose from 14 to over 60",
+		Shock:       Shock{SpotReturn: -0.34, VolPoints: 45},
+	},
+	{
+		Name:        "2022 rate shock",
+		Kind:        StressHistoricalScenario,
+		Description: "S&P 500 fell 25% from 3 Jan to 12 Oct 2022 as the Fed raised rates; VIX rose from 17 to over 30",
+		Shock:       Shock{SpotReturn: -0.25, VolPoints: 15},
+	},
+	{
+		Name:        "2018-02 volatility spike",
+		Kind:        StressHistoricalScenario,
+		Description: "S&P 500 fell 10% from 26 Jan to 8 Feb 2018 while VIX more than doubled",
+		Shock:       Shock{SpotReturn: -0.10, VolPoints: 25},
+	},
+	{
+		Name:        "2008-10 financial crisis",
+		Kind:        StressHistoricalScenario,
+		Description: "S&P 500 fell 30% in October 2008 with VIX peaking near 80",
+		Shock:       Shock{SpotReturn: -0.30, VolPoints: 50},
+	},
+}
+
+// StressGrid defines the grid scenarios: every combination of an
+// underlying move (percent), an implied volatility change (vol points) and
+// days of time decay
+type StressGrid struct {
+	SpotShocks []float64 `json:"spot_shocks"`
+	VolShocks  []float64 `json:"vol_shocks"`
+	DecayDays  []float64 `json:"decay_days"`
+}
+
+// DefaultStressGrid covers ±20% moves, ±10 vol points and a week of decay
+func DefaultStressGrid() StressGrid {
+	return StressGrid{
+		SpotShocks: []float64{-20, -10, -5, 0, 5, 10, 20},
+		VolShocks:  []float64{-10, 0, 10},
+		DecayDays:  []float64{0, 7},
+	}
+}
+
+// Scenarios expands the grid into individual scenarios
+func (g StressGrid) Scenarios() []StressScenario {
+	var scenarios []StressScenario
+	for _, days := range g.DecayDays {
+		for _, vol := range g.VolShocks {
+			for _, spot := range g.SpotShocks {
+				scenarios = append(scenarios, StressScenario{
+					Name:  fmt.Sprintf("spot %+g%% / vol %+g / %gd", spot, vol, days),
+					Kind:  StressGridScenario,
+					Shock: Shock{SpotReturn: spot / 100, VolPoints: vol, Days: days},
+				})
+			}
+		}
+	}
+	return scenarios
+}
+
+// StressedPosition identifies a row of the P&L grid
+type StressedPosition struct {
+	Symbol     string  `json:"symbol"`
+	Underlying string  `json:"underlying"`
+	Type       string  `json:"type"`
+	Quantity   float64 `json:"quantity"`
+	Proposed   bool    `json:"proposed"` // A recommended trade rather than a held position
+	Beta       float64 `json:"beta"`
+}
+
+// StressResult is the P&L of one scenario. PositionPnL is aligned with
+// StressReport.Positions.
+type StressResult struct {
+	Scenario    StressScenario `json:"scenario"`
+	PositionPnL []float64      `json:"position_pnl"`
+	CurrentPnL  float64        `json:"current_pnl"`
+	ProposedPnL float64        `json:"proposed_pnl"`
+	TotalPnL    float64        `json:"total_pnl"`
+}
+
+// StressReport is the P&L grid for all scenarios
+type StressReport struct {
+	Positions []StressedPosition `json:"positions"`
+	Results   []StressResult     `json:"results"`
+	Worst     *StressResult      `json:"worst,omitempty"`
+	Repricing RepricingMethod    `json:"repricing"`
+	Warnings  []string           `json:"warnings,omitempty"`
+}
+
+// StressEngine revalues positions and proposed trades under shocks
+type StressEngine struct {
+	Grid            StressGrid
+	Replays         []StressScenario
+	Repricing       RepricingMethod
+	RiskFreeRate    float64
+	BenchmarkSymbol string // Market proxy used to scale historical replays by beta
+	now             func() time.Time
+}
+
+// NewStressEngine creates an engine with the default grid and historical replays
+func NewStressEngine() *StressEngine {
+	return &StressEngine{
+		Grid:            DefaultStressGrid(),
+		Replays:         HistoricalReplays,
+		Repricing:       RepriceFull,
+		RiskFreeRate:    DefaultRiskFreeRate,
+		BenchmarkSymbol: "SPY",
+		now:             time.Now,
+	}
+}
+
+// Run prepares the positions and evaluates every grid scenario and replay.
+// history supplies prices for positions that lack one and the betas used
+// to scale historical replays; underlyings without history use a beta of 1.
+func (se *StressEngine) Run(current, proposed []*Position, history map[string][]DailyBar) *StressReport {
+	now := se.now()
+	all := append(append([]*Position(nil), current...), proposed...)
+
+	report := &StressReport{
+		Positions: make([]StressedPosition, 0, len(all)),
+		Results:   []StressResult{},
+		Repricing: se.Repricing,
+	}
+	report.Warnings = PreparePositions(all, history, now, se.RiskFreeRate)
+
+	betas := make(map[string]float64)
+	for i, p := range all {
+		beta, ok := betas[p.Underlying]
+		if !ok {
+			beta = se.beta(p.Underlying, history)
+			betas[p.Underlying] = beta
+		}
+		report.Positions = append(report.Positions, StressedPosition{
+			Symbol:     p.Symbol,
+			Underlying: p.Underlying,
+			Type:       p.Type,
+			Quantity:   p.Quantity,
+			Proposed:   i >= len(current),
+			Beta:       beta,
+		})
+	}
+
+	scenarios := append(se.Grid.Scenarios(), se.Replays...)
+	for _, scenario := range scenarios {
+		result := StressResult{
+			Scenario:    scenario,
+			PositionPnL: make([]float64, len(all)),
+		}
+		for i, p := range all {
+			shock := scenario.Shock
+			if scenario.Kind == StressHistoricalScenario {
+				// Beta-scale the market move, keeping the price above zero
+				shock.SpotReturn = math.Max(shock.SpotReturn*report.Positions[i].Beta, -0.99)
+			}
+			pnl := p.PnL(shock, se.Repricing, now, se.RiskFreeRate)
+			result.PositionPnL[i] = pnl
+			if report.Positions[i].Proposed {
+				result.ProposedPnL += pnl
+			} else {
+				result.CurrentPnL += pnl
+			}
+		}
+		result.TotalPnL = result.CurrentPnL + result.ProposedPnL
+		report.Results = append(report.Results, result)
+	}
+
+	for i := range report.Results {
+		if report.Worst == nil || report.Results[i].TotalPnL < report.Worst.TotalPnL {
+			report.Worst = &report.Results[i]
+		}
+	}
+
+	return report
+}
+
+// beta estimates symbol's beta to the benchmark from daily returns,
+// returning 1 when there is too little history
+func (se *StressEngine) beta(symbol string, history map[string][]DailyBar) float64 {
+	if symbol == se.BenchmarkSymbol {
+		return 1
+	}
+	_, returns := dailyReturns([]string{symbol, se.BenchmarkSymbol}, history)
+	if len(returns[symbol]) < 20 {
+		return 1
+	}
+	cov := covariance([]string{symbol, se.BenchmarkSymbol}, returns)
+	if cov[1][1] <= 0 {
+		return 1
+	}
+	return cov[0][1] / cov[1][1]
+}
@@ -0,0 +1,122 @@
+package ai_assistant
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// stressNow is the time the stress tests are run at
+var stressNow = time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+func newTestStressEngine() *StressEngine {
+	se := NewStressEngine()
+	se.now = func() time.Time { return stressNow }
+	return se
+}
+
+// leveredHistory is n days of SPY and of a stock whose daily log returns
+// are beta times the market's
+func leveredHistory(n int, beta float64) map[string][]DailyBar {
+	spy, stock := make([]DailyBar, n), make([]DailyBar, n)
+	logSPY := 0.0
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			logSPY += []float64{0.01, -0.006, 0.004, -0.012}[i%4]
+		}
+		day := stressNow.AddDate(0, 0, i-n)
+		spy[i] = DailyBar{Date: day, Close: 400 * math.Exp(logSPY)}
+		stock[i] = DailyBar{Date: day, Close: 100 * math.Exp(beta*logSPY)}
+	}
+	return map[string][]DailyBar{"SPY": spy, "XYZ": stock}
+}
+
+func stressResult(report *StressReport, name string) *StressResult {
+	for i := range report.Results {
+		if report.Results[i].Scenario.Name == name {
+			return &report.Results[i]
+		}
+	}
+	return nil
+}
+
+func TestStressGridExpandsEveryCombination(t *testing.T) {
+	scenarios := DefaultStressGrid().Scenarios()
+	if len(scenarios) != 7*3*2 {
+		t.Fatalf("%d grid scenarios, want 42", len(scenarios))
+	}
+	last := scenarios[len(scenarios)-1]
+	if last.Name != "spot +20% / vol +10 / 7d" || last.Shock != (Shock{SpotReturn: 0.2, VolPoints: 10, Days: 7}) {
+		t.Errorf("last scenario = %+v", last)
+	}
+}
+
+func TestStressStockMovesWithSpotOnly(t *testing.T) {
+	report := newTestStressEngine().Run(hundredShares(), nil, nil)
+	if len(report.Results) != 42+len(HistoricalReplays) {
+		t.Fatalf("%d results, want the grid and every replay", len(report.Results))
+	}
+
+	for name, want := range map[string]float64{
+		"spot -20% / vol +0 / 0d":  -2000,
+		"spot -20% / vol +10 / 7d": -2000,
+		"spot +5% / vol -10 / 0d":  500,
+		"2020-03 COVID crash":      -3400,
+		"2018-02 volatility spike": -1000,
+		"2008-10 financial crisis": -3000,
+	} {
+		if got := stressResult(report, name); got == nil || math.Abs(got.TotalPnL-want) > 1e-6 || got.CurrentPnL != got.TotalPnL {
+			t.Errorf("%s: %+v, want a P&L of %v on held positions", name, got, want)
+		}
+	}
+
+	// Without history the stock moves one for one with the market
+	if report.Positions[0].Beta != 1 || report.Worst == nil || report.Worst.Scenario.Name != "2020-03 COVID crash" {
+		t.Errorf("beta %v, worst %+v, want beta 1 and the COVID crash", report.Positions[0].Beta, report.Worst)
+	}
+}
+
+func TestStressReplaysScaleByBeta(t *testing.T) {
+	report := newTestStressEngine().Run(hundredShares(), nil, leveredHistory(60, 3))
+	if beta := report.Positions[0].Beta; math.Abs(beta-3) > 1e-9 {
+		t.Fatalf("beta = %v, want 3", beta)
+	}
+
+	// The 2018 drop triples; the COVID crash would take the price below
+	// zero, so it stops at -99%
+	if got := stressResult(report, "2018-02 volatility spike"); math.Abs(got.TotalPnL+3000) > 1e-6 {
+		t.Errorf("2018 replay = %v, want -3000", got.TotalPnL)
+	}
+	if got := stressResult(report, "2020-03 COVID crash"); math.Abs(got.TotalPnL+9900) > 1e-6 {
+		t.Errorf("COVID replay = %v, want -9900", got.TotalPnL)
+	}
+
+	// Grid shocks are applied as given, whatever the beta
+	if got := stressResult(report, "spot -10% / vol +0 / 0d"); math.Abs(got.TotalPnL+1000) > 1e-6 {
+		t.Errorf("grid -10%% = %v, want -1000", got.TotalPnL)
+	}
+}
+
+func TestStressSeparatesProposedTrades(t *testing.T) {
+	put := &Position{Symbol: "XYZ261120P00095000", Underlying: "XYZ", Type: PositionPut, Quantity: 1, Multiplier: 100,
+		Strike: 95, Expiration: stressNow.AddDate(0, 0, 32), UnderlyingPrice: 100, IV: 0.3}
+	report := newTestStressEngine().Run(hundredShares(), []*Position{put}, nil)
+	if !report.Positions[1].Proposed || report.Positions[0].Proposed {
+		t.Fatalf("positions = %+v, want only the put proposed", report.Positions)
+	}
+
+	// A protective put gains in a crash, loses to time and gains from vol
+	crash := stressResult(report, "spot -20% / vol +0 / 0d")
+	if crash.ProposedPnL <= 0 || crash.CurrentPnL != -2000 || crash.TotalPnL != crash.CurrentPnL+crash.ProposedPnL {
+		t.Errorf("crash = %+v, want the put offsetting part of the stock's loss", crash)
+	}
+	if decay := stressResult(report, "spot +0% / vol +0 / 7d"); decay.ProposedPnL >= 0 {
+		t.Errorf("a week of decay = %v, want a loss on the long put", decay.ProposedPnL)
+	}
+	if vol := stressResult(report, "spot +0% / vol +10 / 0d"); vol.ProposedPnL <= 0 {
+		t.Errorf("vol +10 = %v, want a gain on the long put", vol.ProposedPnL)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("warnings = %v, want none with the IV given", report.Warnings)
+	}
+}
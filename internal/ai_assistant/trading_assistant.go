@@ -9,37 +9,58 @@ import (
 )
 
 type TradingAssistant struct {
-	claudeClient *ClaudeClient
-	prompts      *PromptTemplates
+	llm     LLMProvider
+	prompts *PromptTemplates
 }
 
-type TradeRecommendation struct {
-	Ticker    string  `json:"ticker"`
-	Strategy  string  `json:"strategy"`
-	Legs      string  `json:"legs"`
-	Thesis    string  `json:"thesis"`
-	POP       float64 `json:"pop"`       // Probability of Profit
-	MaxLoss   float64 `json:"max_loss"`
-	MaxProfit float64 `json:"max_profit"`
-	Score     float64 `json:"score"`
+// LLMProvider completes a system prompt and user message. ClaudeClient is
+// the live provider; backtests replay recorded or canned responses.
+type LLMProvider interface {
+	SendMessage(ctx context.Context, systemPrompt string, userMessage string) (string, error)
 }
 
-type MarketData struct {
-	Timestamp time.Time              `json:"timestamp"`
-	Quotes    map[string]interface{} `json:"quotes"`
-	Options   map[string]interface{} `json:"options"`
-	IV        map[string]float64     `json:"iv"`
-	Greeks    map[string]interface{} `json:"greeks"`
+type TradeRecommendation struct {
+	Ticker     string             `json:"ticker"`
+	Strategy   string             `json:"strategy"`
+	Legs       string             `json:"legs"`
+	LegDetails []OptionLeg        `json:"leg_details,omitempty"` // Parsed from Legs when the model omits them
+	Thesis     string             `json:"thesis"`
+	POP        float64            `json:"pop"`                   // Probability of Profit
+	MaxLoss    float64            `json:"max_loss"`
+	MaxProfit  float64            `json:"max_profit"`
+	Score      float64            `json:"score"`
+	Quantity   int                `json:"quantity,omitempty"`    // Contracts of the whole structure; 0 means unsized (one)
+	Sizing     *SizingDecision    `json:"sizing,omitempty"`
+	Margin     *MarginRequirement `json:"margin,omitempty"`      // Buying power the trade uses
+	ProposalID string             `json:"proposal_id,omitempty"` // Set once the trade is proposed for approval
+	RecordID   string             `json:"record_id,omitempty"`   // Set once recorded by a PerformanceTracker
+
+	Validation *TradeValidation `json:"validation,omitempty"` // Set once screened by a RiskManager
 }
 
 func NewTradingAssistant(apiKey string) *TradingAssistant {
 	return &TradingAssistant{
-		claudeClient: NewClaudeClient(apiKey),
-		prompts:      NewPromptTemplates(),
+		llm:     NewClaudeClient(apiKey),
+		prompts: NewPromptTemplates(),
+	}
+}
+
+// newKeylessTradingAssistant creates an assistant whose Claude client has no
+// key of its own; every request must carry one via WithAPIKey
+func newKeylessTradingAssistant() *TradingAssistant {
+	return NewTradingAssistantWithProvider(newClaudeClient(""), nil)
+}
+
+// NewTradingAssistantWithProvider creates an assistant that asks provider
+// for completions using prompts, or the default prompts if nil
+func NewTradingAssistantWithProvider(provider LLMProvider, prompts *PromptTemplates) *TradingAssistant {
+	if prompts == nil {
+		prompts = NewPromptTemplates()
 	}
+	return &TradingAssistant{llm: provider, prompts: prompts}
 }
 
-func (ta *TradingAssistant) AnalyzeTrades(ctx context.Context, marketData *MarketData, portfolio map[string]interface{}) ([]TradeRecommendation, error) {
+func (ta *TradingAssistant) AnalyzeTrades(ctx context.Context, marketData *AggregatedMarketData, portfolio map[string]interface{}) ([]TradeRecommendation, error) {
 	// Format market data for the prompt
 	dataJSON, err := json.MarshalIndent(marketData, "", "  ")
 	if err != nil {
@@ -60,7 +81,7 @@ func (ta *TradingAssistant) AnalyzeTrades(ctx context.Context, marketData *Marke
 	)
 
 	// Get recommendations from Claude
-	response, err := ta.claudeClient.SendMessage(ctx, ta.prompts.TradingSystemPrompt, userMessage)
+	response, err := ta.llm.SendMessage(ctx, ta.prompts.TradingSystemPrompt, userMessage)
 	if err != nil {
 		return nil, fmt.Errorf("error getting AI recommendations: %w", err)
 	}
@@ -85,13 +106,26 @@ func (ta *TradingAssistant) parseRecommendations(response string) ([]TradeRecomm
 		jsonStr := response[startIdx : endIdx+1]
 		if err := json.Unmarshal([]byte(jsonStr), &recommendations); err != nil {
 			// If JSON parsing fails, try to parse table format
-			return ta.parseTableFormat(response)
+			recommendations, err = ta.parseTableFormat(response)
+			return withLegDetails(recommendations), err
 		}
-		return recommendations, nil
+		return withLegDetails(recommendations), nil
 	}
 
 	// Fall back to table parsing
-	return ta.parseTableFormat(response)
+	recommendations, err := ta.parseTableFormat(response)
+	return withLegDetails(recommendations), err
+}
+
+// withLegDetails fills LegDetails from the Legs text where the model gave
+// no structured legs
+func withLegDetails(recommendations []TradeRecommendation) []TradeRecommendation {
+	for i := range recommendations {
+		if len(recommendations[i].LegDetails) == 0 {
+			recommendations[i].LegDetails = ParseLegs(recommendations[i].Legs)
+		}
+	}
+	return recommendations
 }
 
 func (ta *TradingAssistant) parseTableFormat(response string) ([]TradeRecommendation, error) {
@@ -147,16 +181,31 @@ func (ta *TradingAssistant) parseTableFormat(response string) ([]TradeRecommenda
 func (ta *TradingAssistant) ExplainStrategy(ctx context.Context, strategy string) (string, error) {
 	prompt := fmt.Sprintf("Explain the following options trading strategy in simple terms: %s\n\nInclude risk/reward profile and when to use it.", strategy)
 	
-	return ta.claudeClient.SendMessage(ctx, ta.prompts.EducationalPrompt, prompt)
+	return ta.llm.SendMessage(ctx, ta.prompts.EducationalPrompt, prompt)
 }
 
-func (ta *TradingAssistant) AnalyzeRisk(ctx context.Context, positions []map[string]interface{}) (string, error) {
+// RiskAnalysisInput carries the figures computed in Go so the model
+// explains them rather than estimating its own
+type RiskAnalysisInput struct {
+	Metrics *PortfolioRiskMetrics `json:"metrics,omitempty"`
+	Stress  *StressReport         `json:"stress,omitempty"`
+}
+
+func (ta *TradingAssistant) AnalyzeRisk(ctx context.Context, positions []map[string]interface{}, computed *RiskAnalysisInput) (string, error) {
 	positionsJSON, err := json.MarshalIndent(positions, "", "  ")
 	if err != nil {
 		return "", fmt.Errorf("error formatting positions: %w", err)
 	}
 	
-	prompt := fmt.Sprintf("Analyze the risk profile of these positions:\n\n%s\n\nProvide a comprehensive risk assessment.", string(positionsJSON))
+	prompt := fmt.Sprintf("Analyze the risk profile of these positions:\n\n%s\n\n", string(positionsJSON))
+	if computed != nil {
+		computedJSON, err := json.MarshalIndent(computed, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("error formatting risk metrics: %w", err)
+		}
+		prompt += fmt.Sprintf("Computed risk metrics and stress test results (dollar P&L; use these figures, do not estimate your own):\n\n%s\n\n", string(computedJSON))
+	}
+	prompt += "Provide a comprehensive risk assessment."
 	
-	return ta.claudeClient.SendMessage(ctx, ta.prompts.RiskAnalysisPrompt, prompt)
+	return ta.llm.SendMessage(ctx, ta.prompts.RiskAnalysisPrompt, prompt)
 }
\ No newline at end of file
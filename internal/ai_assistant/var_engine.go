@@ -0,0 +1,413 @@
+package ai_assistant
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// VaR methods
+const (
+	VaRHistorical = "historical"
+	VaRMonteCarlo = "monte_carlo"
+)
+
+// VaRConfig controls the Value-at-Risk engine
+type VaRConfig struct {
+	ConfidenceLevels []float64       `json:"confidence_levels"` // e.g. 0.95, 0.99
+	HorizonDays      []int           `json:"horizon_days"`      // Trading days
+	Simulations      int             `json:"simulations"`       // Monte Carlo paths
+	Repricing        RepricingMethod `json:"repricing"`
+	RiskFreeRate     float64         `json:"risk_free_rate"`
+	BootstrapSamples int             `json:"bootstrap_samples"` // Resamples for the confidence intervals
+	IntervalLevel    float64         `json:"interval_level"`    // Coverage of the confidence intervals
+	Seed             int64           `json:"seed"`              // 0 seeds from the clock
+}
+
+// DefaultVaRConfig returns 95% and 99% VaR over 1 and 10 days with full revaluation
+func DefaultVaRConfig() VaRConfig {
+	return VaRConfig{
+		ConfidenceLevels: []float64{0.95, 0.99},
+		HorizonDays:      []int{1, 10},
+		Simulations:      5000,
+		Repricing:        RepriceFull,
+		RiskFreeRate:     DefaultRiskFreeRate,
+		BootstrapSamples: 200,
+		IntervalLevel:    0.95,
+	}
+}
+
+// Limits on the VaR settings a caller may ask for
+const (
+	MaxVaRConfidenceLevels = 5
+	MaxVaRHorizons         = 5
+	MaxVaRHorizonDays      = 60
+	MaxVaRSimulations      = 100000
+)
+
+// Validate checks caller-supplied settings: up to MaxVaRConfidenceLevels
+// distinct confidence levels between 0.5 and 1, up to MaxVaRHorizons
+// distinct horizons of 1 to MaxVaRHorizonDays days, at most
+// MaxVaRSimulations paths and a known repricing method. Empty settings
+// take their defaults.
+func (c VaRConfig) Validate() error {
+	if len(c.ConfidenceLevels) > MaxVaRConfidenceLevels {
+		return fmt.Errorf("at most %d confidenceLevels are allowed", MaxVaRConfidenceLevels)
+	}
+	seenLevels := make(map[float64]bool)
+	for _, confidence := range c.ConfidenceLevels {
+		if confidence <= 0.5 || confidence >= 1 {
+			return fmt.Errorf("confidenceLevels must be between 0.5 and 1")
+		}
+		if seenLevels[confidence] {
+			return fmt.Errorf("confidence level %v is repeated", confidence)
+		}
+		seenLevels[confidence] = true
+	}
+	if len(c.HorizonDays) > MaxVaRHorizons {
+		return fmt.Errorf("at most %d horizonDays are allowed", MaxVaRHorizons)
+	}
+	seenHorizons := make(map[int]bool)
+	for _, horizon := range c.HorizonDays {
+		if horizon < 1 || horizon > MaxVaRHorizonDays {
+			return fmt.Errorf("horizonDays must be between 1 and %d", MaxVaRHorizonDays)
+		}
+		if seenHorizons[horizon] {
+			return fmt.Errorf("horizon of %d days is repeated", horizon)
+		}
+		seenHorizons[horizon] = true
+	}
+	if c.Simulations < 0 || c.Simulations > MaxVaRSimulations {
+		return fmt.Errorf("simulations must be at most %d", MaxVaRSimulations)
+	}
+	if c.Repricing != "" && c.Repricing != RepriceFull && c.Repricing != RepriceGreeks {
+		return fmt.Errorf(`repricing must be "full" or "greeks"`)
+	}
+	return nil
+}
+
+// Interval is a confidence interval around an estimate
+type Interval struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// VaRResult is the loss estimate for one method, confidence and horizon.
+// VaR and CVaR are reported as positive dollar losses.
+type VaRResult struct {
+	Method       string   `json:"method"`
+	Confidence   float64  `json:"confidence"`
+	HorizonDays  int      `json:"horizon_days"`
+	VaR          float64  `json:"var"`
+	CVaR         float64  `json:"cvar"`
+	VaRInterval  Interval `json:"var_interval"`
+	CVaRInterval Interval `json:"cvar_interval"`
+	Scenarios    int      `json:"scenarios"`
+}
+
+// VaRReport holds the results of both methods
+type VaRReport struct {
+	Historical    []VaRResult     `json:"historical"`
+	MonteCarlo    []VaRResult     `json:"monte_carlo"`
+	Repricing     RepricingMethod `json:"repricing"`
+	IntervalLevel float64         `json:"interval_level"`
+	HistoryStart  time.Time       `json:"history_start"`
+	HistoryEnd    time.Time       `json:"history_end"`
+	MaxDrawdown   float64         `json:"max_drawdown"` // Worst peak-to-trough P&L over the history
+	Warnings      []string        `json:"warnings,omitempty"`
+}
+
+// VaREngine estimates Value-at-Risk and Conditional VaR for a set of
+// positions from the daily closes of their underlyings
+type VaREngine struct {
+	config VaRConfig
+	now    func() time.Time
+}
+
+// NewVaREngine creates an engine; zero fields of config take their defaults
+func NewVaREngine(config VaRConfig) *VaREngine {
+	defaults := DefaultVaRConfig()
+	if len(config.ConfidenceLevels) == 0 {
+		config.ConfidenceLevels = defaults.ConfidenceLevels
+	}
+	if len(config.HorizonDays) == 0 {
+		config.HorizonDays = defaults.HorizonDays
+	}
+	if config.Simulations <= 0 {
+		config.Simulations = defaults.Simulations
+	}
+	if config.Repricing == "" {
+		config.Repricing = defaults.Repricing
+	}
+	if config.RiskFreeRate == 0 {
+		config.RiskFreeRate = defaults.RiskFreeRate
+	}
+	if config.BootstrapSamples <= 0 {
+		config.BootstrapSamples = defaults.BootstrapSamples
+	}
+	if config.IntervalLevel <= 0 || config.IntervalLevel >= 1 {
+		config.IntervalLevel = defaults.IntervalLevel
+	}
+	return &VaREngine{config: config, now: time.Now}
+}
+
+// Config returns the engine's effective configuration
+func (e *VaREngine) Config() VaRConfig {
+	return e.config
+}
+
+// Run prepares the positions and computes historical-simulation and Monte
+// Carlo VaR for every configured confidence level and horizon
+func (e *VaREngine) Run(positions []*Position, history map[string][]DailyBar) (*VaRReport, error) {
+	now := e.now()
+	report := &VaRReport{
+		Historical:    []VaRResult{},
+		MonteCarlo:    []VaRResult{},
+		Repricing:     e.config.Repricing,
+		IntervalLevel: e.config.IntervalLevel,
+	}
+	report.Warnings = PreparePositions(positions, history, now, e.config.RiskFreeRate)
+
+	symbols := underlyings(positions)
+	dates, returns := dailyReturns(symbols, history)
+	if len(dates) < 20 {
+		return report, fmt.Errorf("need at least 20 days of common history for %v, have %d", symbols, len(dates))
+	}
+	report.HistoryStart, report.HistoryEnd = dates[0], dates[len(dates)-1]
+
+	seed := e.config.Seed
+	if seed == 0 {
+		seed = now.UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	for _, horizon := range e.config.HorizonDays {
+		if horizon <= 0 || horizon >= len(dates) {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("horizon of %d days skipped", horizon))
+			continue
+		}
+		historical := e.historicalPnL(positions, symbols, returns, horizon, now)
+		report.Historical = append(report.Historical, e.tailResults(VaRHistorical, horizon, historical, rng)...)
+
+		simulated, err := e.monteCarloPnL(positions, symbols, returns, horizon, now, rng)
+		if err != nil {
+			report.Warnings = append(report.Warnings, err.Error())
+			continue
+		}
+		report.MonteCarlo = append(report.MonteCarlo, e.tailResults(VaRMonteCarlo, horizon, simulated, rng)...)
+	}
+
+	report.MaxDrawdown = e.historicalDrawdown(positions, symbols, returns, now)
+	return report, nil
+}
+
+// historicalPnL replays each overlapping window of horizon days from the
+// history against today's positions
+func (e *VaREngine) historicalPnL(positions []*Position, symbols []string, returns map[string][]float64, horizon int, now time.Time) []float64 {
+	n := len(returns[symbols[0]]) - horizon + 1
+	pnl := make([]float64, n)
+	moves := make(map[string]float64, len(symbols))
+	for k := 0; k < n; k++ {
+		for _, symbol := range symbols {
+			sum := 0.0
+			for _, r := range returns[symbol][k : k+horizon] {
+				sum += r
+			}
+			moves[symbol] = math.Exp(sum) - 1
+		}
+		pnl[k] = e.portfolioPnL(positions, moves, horizon, now)
+	}
+	return pnl
+}
+
+// monteCarloPnL draws correlated normal log returns with the covariance of
+// the history, scaled to the horizon
+func (e *VaREngine) monteCarloPnL(positions []*Position, symbols []string, returns map[string][]float64, horizon int, now time.Time, rng *rand.Rand) ([]float64, error) {
+	cov := covariance(symbols, returns)
+	chol, err := cholesky(cov)
+	if err != nil {
+		return nil, fmt.Errorf("monte carlo skipped: %w", err)
+	}
+
+	scale := math.Sqrt(float64(horizon))
+	z := make([]float64, len(symbols))
+	moves := make(map[string]float64, len(symbols))
+	pnl := make([]float64, e.config.Simulations)
+	for s := range pnl {
+		for i := range z {
+			z[i] = rng.NormFloat64()
+		}
+		for i, symbol := range symbols {
+			x := 0.0
+			for j := 0; j <= i; j++ {
+				x += chol[i][j] * z[j]
+			}
+			x *= scale
+			moves[symbol] = math.Exp(x-0.5*cov[i][i]*float64(horizon)) - 1
+		}
+		pnl[s] = e.portfolioPnL(positions, moves, horizon, now)
+	}
+	return pnl, nil
+}
+
+// historicalDrawdown walks today's positions through the history and
+// returns the largest peak-to-trough fall in their value
+func (e *VaREngine) historicalDrawdown(positions []*Position, symbols []string, returns map[string][]float64, now time.Time) float64 {
+	cumulative := make(map[string]float64, len(symbols))
+	moves := make(map[string]float64, len(symbols))
+	peak, maxDrawdown := 0.0, 0.0
+	for k := range returns[symbols[0]] {
+		for _, symbol := range symbols {
+			cumulative[symbol] += returns[symbol][k]
+			moves[symbol] = math.Exp(cumulative[symbol]) - 1
+		}
+		pnl := e.portfolioPnL(positions, moves, 0, now)
+		peak = math.Max(peak, pnl)
+		maxDrawdown = math.Max(maxDrawdown, peak-pnl)
+	}
+	return maxDrawdown
+}
+
+func (e *VaREngine) portfolioPnL(positions []*Position, moves map[string]float64, horizon int, now time.Time) float64 {
+	shock := Shock{Days: float64(horizon) * 7 / 5} // Trading days to calendar days
+	total := 0.0
+	for _, p := range positions {
+		move, ok := moves[p.Underlying]
+		if !ok {
+			continue
+		}
+		shock.SpotReturn = move
+		total += p.PnL(shock, e.config.Repricing, now, e.config.RiskFreeRate)
+	}
+	return total
+}
+
+// tailResults computes VaR and CVaR at each confidence level, with
+// bootstrap confidence intervals
+func (e *VaREngine) tailResults(method string, horizon int, pnl []float64, rng *rand.Rand) []VaRResult {
+	sorted := append([]float64(nil), pnl...)
+	sort.Float64s(sorted)
+
+	levels := e.config.ConfidenceLevels
+	bootVaR := make([][]float64, len(levels))
+	bootCVaR := make([][]float64, len(levels))
+	sample := make([]float64, len(pnl))
+	for b := 0; b < e.config.BootstrapSamples; b++ {
+		for i := range sample {
+			sample[i] = pnl[rng.Intn(len(pnl))]
+		}
+		sort.Float64s(sample)
+		for i, confidence := range levels {
+			v, c := tailLoss(sample, confidence)
+			bootVaR[i] = append(bootVaR[i], v)
+			bootCVaR[i] = append(bootCVaR[i], c)
+		}
+	}
+
+	alpha := (1 - e.config.IntervalLevel) / 2
+	results := make([]VaRResult, 0, len(levels))
+	for i, confidence := range levels {
+		v, c := tailLoss(sorted, confidence)
+		results = append(results, VaRResult{
+			Method:       method,
+			Confidence:   confidence,
+			HorizonDays:  horizon,
+			VaR:          v,
+			CVaR:         c,
+			VaRInterval:  percentileInterval(bootVaR[i], alpha),
+			CVaRInterval: percentileInterval(bootCVaR[i], alpha),
+			Scenarios:    len(pnl),
+		})
+	}
+	return results
+}
+
+// tailLoss returns VaR and CVaR as positive losses from ascending P&L
+func tailLoss(sorted []float64, confidence float64) (float64, float64) {
+	idx := int(math.Floor((1 - confidence) * float64(len(sorted))))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	sum := 0.0
+	for _, v := range sorted[:idx+1] {
+		sum += v
+	}
+	return math.Max(-sorted[idx], 0), math.Max(-sum/float64(idx+1), 0)
+}
+
+func percentileInterval(values []float64, alpha float64) Interval {
+	if len(values) == 0 {
+		return Interval{}
+	}
+	sort.Float64s(values)
+	lower := int(alpha * float64(len(values)-1))
+	upper := int(math.Ceil((1 - alpha) * float64(len(values)-1)))
+	return Interval{Lower: values[lower], Upper: values[upper]}
+}
+
+func covariance(symbols []string, returns map[string][]float64) [][]float64 {
+	n := len(returns[symbols[0]])
+	means := make([]float64, len(symbols))
+	for i, symbol := range symbols {
+		for _, r := range returns[symbol] {
+			means[i] += r
+		}
+		means[i] /= float64(n)
+	}
+
+	cov := make([][]float64, len(symbols))
+	for i := range symbols {
+		cov[i] = make([]float64, len(symbols))
+		for j := 0; j <= i; j++ {
+			sum := 0.0
+			ri, rj := returns[symbols[i]], returns[symbols[j]]
+			for k := 0; k < n; k++ {
+				sum += (ri[k] - means[i]) * (rj[k] - means[j])
+			}
+			cov[i][j] = sum / float64(n-1)
+			cov[j][i] = cov[i][j]
+		}
+	}
+	return cov
+}
+
+// cholesky returns the lower-triangular factor of m, adding a small ridge
+// to the diagonal if m is only positive semi-definite
+func cholesky(m [][]float64) ([][]float64, error) {
+	for _, ridge := range []float64{0, 1e-10, 1e-8, 1e-6} {
+		if l, ok := tryCholesky(m, ridge); ok {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("return covariance is not positive definite")
+}
+
+func tryCholesky(m [][]float64, ridge float64) ([][]float64, bool) {
+	n := len(m)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := m[i][j]
+			if i == j {
+				sum += ridge
+			}
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, false
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, true
+}
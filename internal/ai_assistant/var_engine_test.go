@@ -0,0 +1,134 @@
+package ai_assistant
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// alternatingHistory is n daily closes of a stock that rises 1% and falls
+// 2% on alternate days
+func alternatingHistory(n int) []DailyBar {
+	bars := make([]DailyBar, n)
+	price := 100.0
+	day := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i := range bars {
+		if i > 0 {
+			if i%2 == 1 {
+				price *= 1.01
+			} else {
+				price *= 0.98
+			}
+		}
+		bars[i] = DailyBar{Date: day.AddDate(0, 0, i), Close: price}
+	}
+	return bars
+}
+
+// hundredShares is 100 shares of XYZ at $100
+func hundredShares() []*Position {
+	return []*Position{{Symbol: "XYZ", Underlying: "XYZ", Type: PositionStock, Quantity: 100, Multiplier: 1, UnderlyingPrice: 100}}
+}
+
+func varResult(results []VaRResult, confidence float64, horizon int) *VaRResult {
+	for i := range results {
+		if results[i].Confidence == confidence && results[i].HorizonDays == horizon {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+func TestHistoricalVaRReplaysWorstDays(t *testing.T) {
+	engine := NewVaREngine(VaRConfig{ConfidenceLevels: []float64{0.95}, HorizonDays: []int{1, 2}, Seed: 1})
+	report, err := engine.Run(hundredShares(), map[string][]DailyBar{"XYZ": alternatingHistory(101)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Half of the 100 days lose 2% of $10,000
+	oneDay := varResult(report.Historical, 0.95, 1)
+	if oneDay == nil || math.Abs(oneDay.VaR-200) > 1e-6 || math.Abs(oneDay.CVaR-200) > 1e-6 || oneDay.Scenarios != 100 {
+		t.Fatalf("1-day historical = %+v, want VaR and CVaR of $200 over 100 days", oneDay)
+	}
+	if oneDay.VaRInterval.Lower > oneDay.VaR || oneDay.VaRInterval.Upper < oneDay.VaR {
+		t.Errorf("VaR interval %+v does not contain %v", oneDay.VaRInterval, oneDay.VaR)
+	}
+
+	// Every two-day window nets 1.01 × 0.98 - 1 = -1.02%
+	twoDay := varResult(report.Historical, 0.95, 2)
+	if twoDay == nil || math.Abs(twoDay.VaR-102) > 1e-6 || twoDay.Scenarios != 99 {
+		t.Fatalf("2-day historical = %+v, want VaR of $102 over 99 windows", twoDay)
+	}
+	if report.MaxDrawdown <= 0 {
+		t.Errorf("max drawdown = %v, want the steady decline", report.MaxDrawdown)
+	}
+}
+
+func TestMonteCarloVaRScalesWithHorizon(t *testing.T) {
+	engine := NewVaREngine(VaRConfig{ConfidenceLevels: []float64{0.95, 0.99}, HorizonDays: []int{1, 10}, Simulations: 20000, BootstrapSamples: 20, Seed: 42})
+	report, err := engine.Run(hundredShares(), map[string][]DailyBar{"XYZ": alternatingHistory(101)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Daily volatility of the alternating returns is about 1.5%, so 95%
+	// VaR of a normal over one day is about 1.645 × 1.5% of $10,000
+	oneDay := varResult(report.MonteCarlo, 0.95, 1)
+	if oneDay == nil || oneDay.Scenarios != 20000 {
+		t.Fatalf("1-day Monte Carlo = %+v, want 20000 paths", oneDay)
+	}
+	if oneDay.VaR < 220 || oneDay.VaR > 270 {
+		t.Errorf("1-day 95%% VaR = %.2f, want about 246", oneDay.VaR)
+	}
+	if worse := varResult(report.MonteCarlo, 0.99, 1); worse.VaR <= oneDay.VaR || oneDay.CVaR <= oneDay.VaR {
+		t.Errorf("99%% VaR %.2f and 95%% CVaR %.2f, want both above 95%% VaR %.2f", worse.VaR, oneDay.CVaR, oneDay.VaR)
+	}
+
+	tenDay := varResult(report.MonteCarlo, 0.95, 10)
+	if ratio := tenDay.VaR / oneDay.VaR; ratio < 2.8 || ratio > 3.4 {
+		t.Errorf("10-day VaR is %.2f× the 1-day, want about √10", ratio)
+	}
+}
+
+func TestVaRNeedsHistory(t *testing.T) {
+	engine := NewVaREngine(VaRConfig{HorizonDays: []int{1, 30}})
+	if _, err := engine.Run(hundredShares(), map[string][]DailyBar{"XYZ": alternatingHistory(10)}); err == nil {
+		t.Fatal("expected an error with 9 days of history")
+	}
+
+	report, err := engine.Run(hundredShares(), map[string][]DailyBar{"XYZ": alternatingHistory(25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if varResult(report.Historical, 0.95, 30) != nil || len(report.Warnings) != 1 {
+		t.Errorf("30-day horizon on 24 days: warnings %v, want it skipped", report.Warnings)
+	}
+}
+
+func TestVaRConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config VaRConfig
+		valid  bool
+	}{
+		{"defaults", VaRConfig{}, true},
+		{"five of each", VaRConfig{ConfidenceLevels: []float64{0.9, 0.95, 0.975, 0.99, 0.995}, HorizonDays: []int{1, 5, 10, 20, 60}}, true},
+		{"six confidence levels", VaRConfig{ConfidenceLevels: []float64{0.9, 0.95, 0.96, 0.975, 0.99, 0.995}}, false},
+		{"six horizons", VaRConfig{HorizonDays: []int{1, 2, 3, 4, 5, 6}}, false},
+		{"repeated confidence level", VaRConfig{ConfidenceLevels: []float64{0.95, 0.95}}, false},
+		{"repeated horizon", VaRConfig{HorizonDays: []int{10, 10}}, false},
+		{"confidence of one", VaRConfig{ConfidenceLevels: []float64{1}}, false},
+		{"confidence at a half", VaRConfig{ConfidenceLevels: []float64{0.5}}, false},
+		{"horizon of zero", VaRConfig{HorizonDays: []int{0}}, false},
+		{"horizon past 60", VaRConfig{HorizonDays: []int{61}}, false},
+		{"too many simulations", VaRConfig{Simulations: MaxVaRSimulations + 1}, false},
+		{"unknown repricing", VaRConfig{Repricing: "delta"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
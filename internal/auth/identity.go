@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Identity is the authenticated caller extracted from a validated token
+type Identity struct {
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	Issuer    string    `json:"issuer"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// HasRole reports whether the identity was granted role
+func (id *Identity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// identityKey is the context key for the request's Identity; being an
+// unexported type it cannot collide with keys from other packages
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity stored by the auth middleware
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok && id != nil
+}
+
+// UserIDFromContext returns the authenticated user ID, or "" if the request
+// was not authenticated
+func UserIDFromContext(ctx context.Context) string {
+	if id, ok := IdentityFromContext(ctx); ok {
+		return id.UserID
+	}
+	return ""
+}
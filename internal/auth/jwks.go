@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// minRefreshInterval bounds how often an unknown key ID can force a reload
+const minRefreshInterval = 30 * time.Second
+
+// KeySet holds the RSA public keys published in a JWKS document. Keys are
+// reloaded periodically and whenever a token names a key ID we have not seen,
+// so signing keys can be rotated without a restart.
+type KeySet struct {
+	mu          sync.RWMutex
+	source      string
+	httpClient  *http.Client
+	keys        map[string]*rsa.PublicKey
+	lastRefresh time.Time
+	logger      *logrus.Logger
+}
+
+type jwksDocument struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// NewKeySet loads a JWKS from source, which is either an http(s) URL or a
+// local file path
+func NewKeySet(source string, logger *logrus.Logger) (*KeySet, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	ks := &KeySet{
+		source:     source,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		keys:       make(map[string]*rsa.PublicKey),
+		logger:     logger,
+	}
+
+	if err := ks.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// Key returns the public key for kid, reloading the key set once if the ID
+// is unknown
+func (ks *KeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	stale := time.Since(ks.lastRefresh) >= minRefreshInterval
+	ks.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+
+	if stale {
+		if err := ks.Refresh(ctx); err != nil {
+			ks.logger.WithError(err).Warn("Failed to refresh JWKS")
+		}
+		ks.mu.RLock()
+		key, ok = ks.keys[kid]
+		ks.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// Refresh reloads the key set from its source
+func (ks *KeySet) Refresh(ctx context.Context) error {
+	data, err := ks.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	var doc jwksDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, jwk := range doc.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.rsaPublicKey()
+		if err != nil {
+			return fmt.Errorf("invalid key %q in JWKS: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS from %s contains no RSA signing keys", ks.source)
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.lastRefresh = time.Now()
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// StartRefresh reloads the key set every interval until ctx is cancelled
+func (ks *KeySet) StartRefresh(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ks.Refresh(ctx); err != nil {
+					ks.logger.WithError(err).Warn("Failed to refresh JWKS")
+				}
+			}
+		}
+	}()
+}
+
+func (ks *KeySet) fetch(ctx context.Context) ([]byte, error) {
+	if !isURL(ks.source) {
+		data, err := os.ReadFile(ks.source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ks.source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := ks.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint returned status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+func (jwk jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exponent: %w", err)
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
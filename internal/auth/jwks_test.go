@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newStaticKeySet builds a KeySet that never needs to reach its source
+func newStaticKeySet(keys map[string]*rsa.PublicKey) *KeySet {
+	return &KeySet{
+		source:      "static",
+		keys:        keys,
+		lastRefresh: time.Now(),
+		logger:      logrus.New(),
+	}
+}
+
+// jwksServer publishes whichever keys it currently holds
+type jwksServer struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetches atomic.Int32
+}
+
+func (s *jwksServer) set(keys map[string]*rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.fetches.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var doc jwksDocument
+	for kid, key := range s.keys {
+		doc.Keys = append(doc.Keys, jsonWebKey{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(doc)
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeySetRefreshesOnUnknownKid(t *testing.T) {
+	oldKey, newKey := generateKey(t), generateKey(t)
+
+	jwks := &jwksServer{keys: map[string]*rsa.PublicKey{"old": &oldKey.PublicKey}}
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	ks, err := NewKeySet(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	v := newTestValidator(t, ValidatorConfig{KeySet: ks})
+
+	// The issuer rotates to a new signing key
+	jwks.set(map[string]*rsa.PublicKey{"new": &newKey.PublicKey})
+	token := signRS256(t, newKey, "new", validClaims())
+
+	// Within minRefreshInterval of the last load the unknown kid is refused
+	// without hitting the JWKS endpoint again
+	if _, err := v.Validate(context.Background(), token); err == nil {
+		t.Fatal("expected unknown kid to be rejected before the refresh interval")
+	}
+	if n := jwks.fetches.Load(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+
+	ks.mu.Lock()
+	ks.lastRefresh = time.Now().Add(-minRefreshInterval)
+	ks.mu.Unlock()
+
+	if _, err := v.Validate(context.Background(), token); err != nil {
+		t.Fatalf("expected token signed with the rotated key to validate: %v", err)
+	}
+	if n := jwks.fetches.Load(); n != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", n)
+	}
+
+	// The retired key is gone after the reload
+	if _, err := v.Validate(context.Background(), signRS256(t, oldKey, "old", validClaims())); err == nil {
+		t.Fatal("expected token signed with the retired key to be rejected")
+	}
+}
+
+func TestKeySetRejectsUnknownKidAfterRefresh(t *testing.T) {
+	key := generateKey(t)
+	jwks := &jwksServer{keys: map[string]*rsa.PublicKey{"k1": &key.PublicKey}}
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	ks, err := NewKeySet(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	ks.lastRefresh = time.Now().Add(-minRefreshInterval)
+
+	if _, err := ks.Key(context.Background(), "missing"); err == nil {
+		t.Fatal("expected unknown kid to be rejected")
+	}
+	if n := jwks.fetches.Load(); n != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+func TestNewKeySetRequiresSigningKeys(t *testing.T) {
+	jwks := &jwksServer{keys: map[string]*rsa.PublicKey{}}
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	if _, err := NewKeySet(srv.URL, nil); err == nil {
+		t.Fatal("expected an empty JWKS to be rejected")
+	}
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+	ErrMissingSubject   = errors.New("token has no subject")
+)
+
+// ValidatorConfig controls which tokens a Validator accepts
+type ValidatorConfig struct {
+	Issuer     string        // Required "iss" value; empty disables the check
+	Audience   string        // Value that must appear in "aud"; empty disables the check
+	HMACSecret []byte        // Shared secret for HS256 tokens
+	KeySet     *KeySet       // Public keys for RS256 tokens
+	Leeway     time.Duration // Allowed clock skew for exp/nbf
+}
+
+// Validator verifies HS256 and RS256 signed JWTs and maps their claims to an Identity
+type Validator struct {
+	config ValidatorConfig
+	now    func() time.Time
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	IssuedAt  *int64   `json:"iat"`
+	Email     string   `json:"email"`
+	Name      string   `json:"name"`
+	Roles     []string `json:"roles"`
+}
+
+// audience accepts the "aud" claim as either a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+// NewValidator creates a token validator; at least one of HMACSecret or
+// KeySet must be set
+func NewValidator(config ValidatorConfig) (*Validator, error) {
+	if len(config.HMACSecret) == 0 && config.KeySet == nil {
+		return nil, fmt.Errorf("an HMAC secret or a JWKS key set is required")
+	}
+
+	if config.Leeway == 0 {
+		config.Leeway = 30 * time.Second
+	}
+
+	return &Validator{
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// Validate checks the token's signature and registered claims and returns
+// the caller's identity
+func (v *Validator) Validate(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	if err := v.verifySignature(ctx, header, signingInput, signature); err != nil {
+		return nil, err
+	}
+
+	var claims tokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if err := v.validateClaims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		UserID:    claims.Subject,
+		Email:     claims.Email,
+		Name:      claims.Name,
+		Roles:     claims.Roles,
+		Issuer:    claims.Issuer,
+		ExpiresAt: time.Unix(*claims.ExpiresAt, 0),
+	}, nil
+}
+
+func (v *Validator) verifySignature(ctx context.Context, header tokenHeader, signingInput string, signature []byte) error {
+	switch header.Alg {
+	case "HS256":
+		if len(v.config.HMACSecret) == 0 {
+			return ErrUnsupportedAlg
+		}
+		mac := hmac.New(sha256.New, v.config.HMACSecret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	case "RS256":
+		if v.config.KeySet == nil {
+			return ErrUnsupportedAlg
+		}
+		key, err := v.config.KeySet.Key(ctx, header.Kid)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	default:
+		// Rejects "none" and any algorithm we have no key material for
+		return ErrUnsupportedAlg
+	}
+}
+
+func (v *Validator) validateClaims(claims *tokenClaims) error {
+	now := v.now()
+
+	if claims.ExpiresAt == nil {
+		return ErrTokenExpired
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(v.config.Leeway)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != nil && now.Add(v.config.Leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return ErrTokenNotYetValid
+	}
+
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return ErrInvalidIssuer
+	}
+
+	if v.config.Audience != "" {
+		found := false
+		for _, aud := range claims.Audience {
+			if aud == v.config.Audience {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrInvalidAudience
+		}
+	}
+
+	if claims.Subject == "" {
+		return ErrMissingSubject
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
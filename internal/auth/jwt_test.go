@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testSecret = []byte("test-hs256-secret")
+	testNow    = time.Unix(1_700_000_000, 0)
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret []byte, header, claims map[string]interface{}) string {
+	t.Helper()
+	input := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	input := encodeSegment(t, map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(input))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "user-1",
+		"iss":   "https://issuer.example",
+		"aud":   []string{"other", "vibetrade"},
+		"exp":   testNow.Add(time.Hour).Unix(),
+		"email": "user@example.com",
+		"roles": []string{"admin"},
+	}
+}
+
+func newTestValidator(t *testing.T, config ValidatorConfig) *Validator {
+	t.Helper()
+	config.Issuer = "https://issuer.example"
+	config.Audience = "vibetrade"
+	v, err := NewValidator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+func TestValidatorAcceptsValidHS256Token(t *testing.T) {
+	v := newTestValidator(t, ValidatorConfig{HMACSecret: testSecret})
+
+	token := signHS256(t, testSecret, map[string]interface{}{"alg": "HS256", "typ": "JWT"}, validClaims())
+	identity, err := v.Validate(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if identity.UserID != "user-1" || identity.Email != "user@example.com" || !identity.HasRole("admin") {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestValidatorRejectsClaims(t *testing.T) {
+	v := newTestValidator(t, ValidatorConfig{HMACSecret: testSecret})
+
+	tests := []struct {
+		name   string
+		modify func(claims map[string]interface{})
+		want   error
+	}{
+		{"missing exp", func(c map[string]interface{}) { delete(c, "exp") }, ErrTokenExpired},
+		{"expired", func(c map[string]interface{}) { c["exp"] = testNow.Add(-time.Minute).Unix() }, ErrTokenExpired},
+		{"not yet valid", func(c map[string]interface{}) { c["nbf"] = testNow.Add(time.Minute).Unix() }, ErrTokenNotYetValid},
+		{"wrong issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example" }, ErrInvalidIssuer},
+		{"missing issuer", func(c map[string]interface{}) { delete(c, "iss") }, ErrInvalidIssuer},
+		{"wrong audience", func(c map[string]interface{}) { c["aud"] = "someone-else" }, ErrInvalidAudience},
+		{"missing audience", func(c map[string]interface{}) { delete(c, "aud") }, ErrInvalidAudience},
+		{"missing subject", func(c map[string]interface{}) { delete(c, "sub") }, ErrMissingSubject},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(claims)
+			token := signHS256(t, testSecret, map[string]interface{}{"alg": "HS256"}, claims)
+			if _, err := v.Validate(context.Background(), token); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatorAllowsClockSkewWithinLeeway(t *testing.T) {
+	v := newTestValidator(t, ValidatorConfig{HMACSecret: testSecret})
+
+	claims := validClaims()
+	claims["exp"] = testNow.Add(-10 * time.Second).Unix()
+	token := signHS256(t, testSecret, map[string]interface{}{"alg": "HS256"}, claims)
+	if _, err := v.Validate(context.Background(), token); err != nil {
+		t.Fatalf("expected a token 10s past exp to pass the default leeway, got %v", err)
+	}
+}
+
+func TestValidatorRejectsAlgorithmAttacks(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet := newStaticKeySet(map[string]*rsa.PublicKey{"k1": &rsaKey.PublicKey})
+	publicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacOnly := newTestValidator(t, ValidatorConfig{HMACSecret: testSecret})
+	rsaOnly := newTestValidator(t, ValidatorConfig{KeySet: keySet})
+	both := newTestValidator(t, ValidatorConfig{HMACSecret: testSecret, KeySet: keySet})
+
+	unsigned := encodeSegment(t, map[string]interface{}{"alg": "none"}) + "." + encodeSegment(t, validClaims()) + "."
+
+	tests := []struct {
+		name      string
+		validator *Validator
+		token     string
+		want      error
+	}{
+		{"alg none", both, unsigned, ErrUnsupportedAlg},
+		{"alg None", both, signHS256(t, nil, map[string]interface{}{"alg": "None"}, validClaims()), ErrUnsupportedAlg},
+		// The classic confusion attack: HMAC-sign with the RSA public key
+		{"HS256 with public key, RSA configured", rsaOnly, signHS256(t, publicDER, map[string]interface{}{"alg": "HS256", "kid": "k1"}, validClaims()), ErrUnsupportedAlg},
+		{"HS256 with public key, both configured", both, signHS256(t, publicDER, map[string]interface{}{"alg": "HS256", "kid": "k1"}, validClaims()), ErrInvalidSignature},
+		{"RS256 without key set", hmacOnly, signRS256(t, rsaKey, "k1", validClaims()), ErrUnsupportedAlg},
+		{"HS384", both, signHS256(t, testSecret, map[string]interface{}{"alg": "HS384"}, validClaims()), ErrUnsupportedAlg},
+		{"wrong secret", hmacOnly, signHS256(t, []byte("other"), map[string]interface{}{"alg": "HS256"}, validClaims()), ErrInvalidSignature},
+		{"malformed", both, "not-a-jwt", ErrMalformedToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.validator.Validate(context.Background(), tt.token); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := rsaOnly.Validate(context.Background(), signRS256(t, rsaKey, "k1", validClaims())); err != nil {
+		t.Fatalf("valid RS256 token rejected: %v", err)
+	}
+}
+
+func TestValidatorRejectsTamperedPayload(t *testing.T) {
+	v := newTestValidator(t, ValidatorConfig{HMACSecret: testSecret})
+
+	token := signHS256(t, testSecret, map[string]interface{}{"alg": "HS256"}, validClaims())
+	claims := validClaims()
+	claims["sub"] = "someone-else"
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + encodeSegment(t, claims) + "." + parts[2]
+
+	if _, err := v.Validate(context.Background(), tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Authenticator is the HTTP middleware shared by every authenticated route
+type Authenticator struct {
+	validator *Validator
+	logger    *logrus.Logger
+}
+
+// NewAuthenticator creates middleware that validates bearer tokens with validator
+func NewAuthenticator(validator *Validator, logger *logrus.Logger) *Authenticator {
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	return &Authenticator{
+		validator: validator,
+		logger:    logger,
+	}
+}
+
+// Middleware rejects requests without a valid bearer token and stores the
+// caller's Identity in the request context
+func (a *Authenticator) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			writeError(w, "Missing authorization header")
+			return
+		}
+
+		const bearerPrefix = "Bearer "
+		if !strings.HasPrefix(authHeader, bearerPrefix) {
+			writeError(w, "Invalid authorization format")
+			return
+		}
+
+		identity, err := a.validator.Validate(r.Context(), strings.TrimSpace(authHeader[len(bearerPrefix):]))
+		if err != nil {
+			a.logger.WithError(err).Debug("Rejected bearer token")
+			writeError(w, "Invalid token")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+	}
+}
+
+// RequireRole wraps next so it only runs for callers whose Identity has role.
+// It must be chained inside Middleware.
+func RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok || !identity.HasRole(role) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Forbidden"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func writeError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="vibetrade-claude"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
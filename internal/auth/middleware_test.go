@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	v := newTestValidator(t, ValidatorConfig{HMACSecret: testSecret})
+	authenticator := NewAuthenticator(v, nil)
+
+	var seen *Identity
+	handler := authenticator.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = IdentityFromContext(r.Context())
+	})
+
+	expired := validClaims()
+	expired["exp"] = testNow.Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"missing header", "", http.StatusUnauthorized},
+		{"not bearer", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"invalid token", "Bearer not-a-jwt", http.StatusUnauthorized},
+		{"expired token", "Bearer " + signHS256(t, testSecret, map[string]interface{}{"alg": "HS256"}, expired), http.StatusUnauthorized},
+		{"valid token", "Bearer " + signHS256(t, testSecret, map[string]interface{}{"alg": "HS256"}, validClaims()), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK {
+				if seen == nil || seen.UserID != "user-1" {
+					t.Fatalf("handler saw identity %+v", seen)
+				}
+			} else {
+				if seen != nil {
+					t.Fatal("handler ran for a rejected request")
+				}
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Fatal("expected a WWW-Authenticate challenge")
+				}
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	called := false
+	handler := RequireRole("admin", func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		want     int
+	}{
+		{"no identity", nil, http.StatusForbidden},
+		{"missing role", &Identity{UserID: "u1", Roles: []string{"trader"}}, http.StatusForbidden},
+		{"has role", &Identity{UserID: "u1", Roles: []string{"trader", "admin"}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.identity != nil {
+				req = req.WithContext(WithIdentity(req.Context(), tt.identity))
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d", rec.Code, tt.want)
+			}
+			if called != (tt.want == http.StatusOK) {
+				t.Fatalf("handler called = %v", called)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package backtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/snapshots"
+)
+
+// ArchiveStore replays the snapshots the server archived, taking each
+// day's last snapshot of a symbol as its close. Loaded snapshots and bars
+// are cached since a run revisits them every day.
+type ArchiveStore struct {
+	archive   *snapshots.Store
+	snapshots map[string]*snapshots.Snapshot
+	bars      map[string][]ai_assistant.DailyBar
+}
+
+// NewArchiveStore creates an ArchiveStore reading from archive
+func NewArchiveStore(archive *snapshots.Store) *ArchiveStore {
+	return &ArchiveStore{
+		archive:   archive,
+		snapshots: make(map[string]*snapshots.Snapshot),
+		bars:      make(map[string][]ai_assistant.DailyBar),
+	}
+}
+
+func (s *ArchiveStore) Dates(from, to time.Time) ([]time.Time, error) {
+	start, _ := tradingDay(from)
+	_, end := tradingDay(to)
+
+	var dates []time.Time
+	seen := make(map[string]bool)
+	for _, entry := range s.archive.List(start, end, nil) {
+		date := entry.Date()
+		if seen[date] {
+			continue
+		}
+		seen[date] = true
+		parsed, err := time.Parse(dateFormat, date)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, parsed)
+	}
+	return dates, nil
+}
+
+func (s *ArchiveStore) Snapshot(date time.Time, symbol string) (*Snapshot, error) {
+	symbol = strings.ToUpper(symbol)
+	start, end := tradingDay(date)
+	entries := s.archive.List(start, end, []string{symbol})
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w for %s on %s", ErrNoSnapshot, symbol, date.Format(dateFormat))
+	}
+
+	snapshot, err := s.load(entries[len(entries)-1].ID)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Quote: snapshot.Data.Quotes[symbol], Chains: snapshot.Data.Options[symbol]}, nil
+}
+
+// Bars merges symbol's history from the last snapshot of each month, which
+// between them cover the archive given the six months each one carries
+func (s *ArchiveStore) Bars(symbol string) ([]ai_assistant.DailyBar, error) {
+	symbol = strings.ToUpper(symbol)
+	if bars, ok := s.bars[symbol]; ok {
+		return bars, nil
+	}
+
+	lastOfMonth := make(map[string]string)
+	for _, entry := range s.archive.List(time.Time{}, time.Now(), []string{symbol}) {
+		lastOfMonth[entry.Date()[:7]] = entry.ID
+	}
+
+	byDate := make(map[string]ai_assistant.DailyBar)
+	for _, id := range lastOfMonth {
+		snapshot, err := s.load(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, bar := range snapshot.History[symbol] {
+			byDate[bar.Date.Format(dateFormat)] = bar
+		}
+	}
+
+	bars := make([]ai_assistant.DailyBar, 0, len(byDate))
+	for _, bar := range byDate {
+		bars = append(bars, bar)
+	}
+	sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	s.bars[symbol] = bars
+	return bars, nil
+}
+
+func (s *ArchiveStore) load(id string) (*snapshots.Snapshot, error) {
+	if snapshot, ok := s.snapshots[id]; ok {
+		return snapshot, nil
+	}
+	snapshot, _, err := s.archive.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	s.snapshots[id] = snapshot
+	return snapshot, nil
+}
+
+// tradingDay returns the bounds of date's New York trading day
+func tradingDay(date time.Time) (time.Time, time.Time) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, newYork)
+	return start, start.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
@@ -0,0 +1,82 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Comparison is the backtests of several variants over the same days
+type Comparison struct {
+	Results []*Result `json:"results"`
+}
+
+// Compare backtests each variant in turn. Every variant sees the same
+// snapshots, so with a deterministic model the differences come only from
+// the prompts and policies.
+func (r *Runner) Compare(ctx context.Context, variants ...Variant) (*Comparison, error) {
+	comparison := &Comparison{}
+	for _, variant := range variants {
+		result, err := r.Run(ctx, variant)
+		if err != nil {
+			return nil, err
+		}
+		comparison.Results = append(comparison.Results, result)
+	}
+	return comparison, nil
+}
+
+// comparisonRows are the figures Table lines up, in order
+var comparisonRows = []struct {
+	label string
+	value func(*Result) string
+}{
+	{"Days", func(r *Result) string { return fmt.Sprint(r.Days) }},
+	{"Recommendations", func(r *Result) string { return fmt.Sprint(r.Recommendations) }},
+	{"Approved", func(r *Result) string { return fmt.Sprint(r.Approved) }},
+	{"Trades", func(r *Result) string { return fmt.Sprint(r.Metrics.ExecutedTrades) }},
+	{"Win rate %", func(r *Result) string { return fmt.Sprintf("%.1f", r.Metrics.WinRate) }},
+	{"Total P&L $", func(r *Result) string { return fmt.Sprintf("%.2f", r.Metrics.TotalReturn) }},
+	{"Average P&L $", func(r *Result) string { return fmt.Sprintf("%.2f", r.Metrics.AverageReturn) }},
+	{"Sharpe", func(r *Result) string { return fmt.Sprintf("%.2f", r.Metrics.SharpeRatio) }},
+	{"Return %", func(r *Result) string { return fmt.Sprintf("%.2f", r.Return) }},
+	{"Max drawdown %", func(r *Result) string { return fmt.Sprintf("%.2f", r.MaxDrawdown) }},
+	{"Ending equity $", func(r *Result) string { return fmt.Sprintf("%.2f", r.EndingEquity) }},
+	{"Top rejection", func(r *Result) string { return topCount(r.Rejections) }},
+	{"LLM errors", func(r *Result) string { return fmt.Sprint(r.LLMErrors) }},
+}
+
+// Table renders the results side by side, one column per variant
+func (c *Comparison) Table() string {
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := []string{""}
+	for _, result := range c.Results {
+		header = append(header, result.Variant)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	for _, row := range comparisonRows {
+		cells := []string{row.label}
+		for _, result := range c.Results {
+			cells = append(cells, row.value(result))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t")+"\t")
+	}
+	w.Flush()
+	return out.String()
+}
+
+// topCount returns the key with the largest count, and the count
+func topCount(counts map[string]int) string {
+	top := ""
+	for _, key := range sortedKeys(counts) {
+		if top == "" || counts[key] > counts[top] {
+			top = key
+		}
+	}
+	if top == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%d)", top, counts[top])
+}
@@ -0,0 +1,271 @@
+package backtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// ErrNotRecorded is returned by a RecordedLLM without a live provider for
+// a prompt it has no response to
+var ErrNotRecorded = errors.New("no recorded response for prompt")
+
+// RecordedLLM replays responses recorded from a live provider, keyed by a
+// hash of the system prompt and user message. With a live provider it
+// records the prompts it has not seen, so a first run against the real
+// model can be replayed for free and deterministically afterwards.
+type RecordedLLM struct {
+	path string
+	live ai_assistant.LLMProvider // Nil to replay only
+
+	mu        sync.Mutex
+	responses map[string]string
+	dirty     bool
+}
+
+// NewRecordedLLM loads the recording at path, if it exists yet
+func NewRecordedLLM(path string, live ai_assistant.LLMProvider) (*RecordedLLM, error) {
+	r := &RecordedLLM{path: path, live: live, responses: make(map[string]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LLM recording: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.responses); err != nil {
+		return nil, fmt.Errorf("failed to parse LLM recording: %w", err)
+	}
+	return r, nil
+}
+
+func (r *RecordedLLM) SendMessage(ctx context.Context, systemPrompt string, userMessage string) (string, error) {
+	key := promptKey(systemPrompt, userMessage)
+	r.mu.Lock()
+	response, ok := r.responses[key]
+	r.mu.Unlock()
+	if ok {
+		return response, nil
+	}
+	if r.live == nil {
+		return "", fmt.Errorf("%w %s", ErrNotRecorded, key[:12])
+	}
+
+	response, err := r.live.SendMessage(ctx, systemPrompt, userMessage)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	r.responses[key] = response
+	r.dirty = true
+	r.mu.Unlock()
+	return response, nil
+}
+
+// Save writes any newly recorded responses back to the recording
+func (r *RecordedLLM) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.responses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode LLM recording: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create LLM recording directory: %w", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write LLM recording: %w", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to replace LLM recording: %w", err)
+	}
+	r.dirty = false
+	return nil
+}
+
+func promptKey(systemPrompt, userMessage string) string {
+	sum := sha256.Sum256([]byte(systemPrompt + "\x00" + userMessage))
+	return hex.EncodeToString(sum[:])
+}
+
+// Baseline trade parameters: a short put near this delta, or this far out
+// of the money without Greeks, expiring nearest this many days out. The
+// trading prompt asks for a POP of at least 0.65 and a credit of a third
+// of the risk, which a 30-delta put one strike wide usually meets.
+const (
+	baselineDelta    = 0.30
+	baselineOTM      = 0.03
+	baselineDTE      = 35
+	baselineMaxTrade = 5
+)
+
+// BaselineLLM stands in for the model with a fixed rule: a put credit
+// spread on each symbol with an option chain, over the next listed strike
+// down. It reads the market data
+// from the user message the assistant builds, so it exercises the same
+// parsing, screening and management as the real model, and gives prompt
+// and policy changes a mechanical benchmark to beat.
+type BaselineLLM struct{}
+
+func (BaselineLLM) SendMessage(ctx context.Context, systemPrompt string, userMessage string) (string, error) {
+	data, err := marketDataFromMessage(userMessage)
+	if err != nil {
+		return "", err
+	}
+
+	trades := []ai_assistant.TradeRecommendation{}
+	for _, symbol := range sortedKeys(data.Quotes) {
+		if trade, ok := baselineSpread(symbol, data); ok {
+			trades = append(trades, trade)
+		}
+		if len(trades) == baselineMaxTrade {
+			break
+		}
+	}
+
+	response, err := json.Marshal(trades)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode baseline trades: %w", err)
+	}
+	return string(response), nil
+}
+
+// marketDataFromMessage extracts the market data JSON from the user
+// message TradingAssistant.AnalyzeTrades sends
+func marketDataFromMessage(message string) (*ai_assistant.AggregatedMarketData, error) {
+	const header = "Market Data:\n"
+	start := strings.Index(message, header)
+	if start < 0 {
+		return nil, fmt.Errorf("baseline LLM found no market data in the prompt")
+	}
+	body := message[start+len(header):]
+	if end := strings.LastIndex(body, "\n\n"); end >= 0 {
+		body = body[:end]
+	}
+
+	var data ai_assistant.AggregatedMarketData
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		return nil, fmt.Errorf("baseline LLM failed to parse market data: %w", err)
+	}
+	return &data, nil
+}
+
+// baselineSpread builds the baseline put credit spread on symbol from its
+// listed puts
+func baselineSpread(symbol string, data *ai_assistant.AggregatedMarketData) (ai_assistant.TradeRecommendation, bool) {
+	quote := data.Quotes[symbol]
+	if quote == nil || quote.Price <= 0 {
+		return ai_assistant.TradeRecommendation{}, false
+	}
+	spot := quote.Price
+
+	// Puts with a two-sided market in the expiration nearest the target
+	byExpiration := make(map[string][]*ai_assistant.OptionChain)
+	for _, chain := range data.Options[symbol] {
+		if chain.Type == ai_assistant.PositionPut && chain.Bid > 0 && chain.Ask >= chain.Bid {
+			byExpiration[chain.Expiration] = append(byExpiration[chain.Expiration], chain)
+		}
+	}
+	expiration, bestGap := "", math.MaxFloat64
+	for exp := range byExpiration {
+		expiry, err := time.Parse(dateFormat, exp)
+		if err != nil {
+			continue
+		}
+		gap := math.Abs(expiry.Sub(day(data.Timestamp)).Hours()/24 - baselineDTE)
+		if gap < bestGap || (gap == bestGap && exp < expiration) {
+			expiration, bestGap = exp, gap
+		}
+	}
+	puts := byExpiration[expiration]
+	if len(puts) < 2 {
+		return ai_assistant.TradeRecommendation{}, false
+	}
+	sort.Slice(puts, func(i, j int) bool { return puts[i].Strike < puts[j].Strike })
+
+	short := nearest(puts, func(p *ai_assistant.OptionChain) float64 {
+		if p.Greeks != nil && p.Greeks.Delta != 0 {
+			return math.Abs(math.Abs(p.Greeks.Delta) - baselineDelta)
+		}
+		return math.Abs(p.Strike - spot*(1-baselineOTM))
+	}, func(p *ai_assistant.OptionChain) bool { return p.Strike < spot })
+	if short == nil {
+		return ai_assistant.TradeRecommendation{}, false
+	}
+	long := nearest(puts, func(p *ai_assistant.OptionChain) float64 {
+		return short.Strike - p.Strike
+	}, func(p *ai_assistant.OptionChain) bool { return p.Strike < short.Strike })
+	if long == nil {
+		return ai_assistant.TradeRecommendation{}, false
+	}
+
+	credit := (short.Bid+short.Ask)/2 - (long.Bid+long.Ask)/2
+	width := short.Strike - long.Strike
+	if credit <= 0 || credit >= width {
+		return ai_assistant.TradeRecommendation{}, false
+	}
+
+	pop := 1 - baselineDelta
+	if short.Greeks != nil && short.Greeks.Delta != 0 {
+		pop = 1 - math.Abs(short.Greeks.Delta)
+	}
+	return ai_assistant.TradeRecommendation{
+		Ticker:   symbol,
+		Strategy: "Put Credit Spread",
+		Legs:     fmt.Sprintf("Sell 1 %s %s %gP / Buy 1 %s %s %gP", symbol, expiration, short.Strike, symbol, expiration, long.Strike),
+		LegDetails: []ai_assistant.OptionLeg{
+			{Action: ai_assistant.LegSell, Type: ai_assistant.PositionPut, Strike: short.Strike, Expiration: expiration, Quantity: 1},
+			{Action: ai_assistant.LegBuy, Type: ai_assistant.PositionPut, Strike: long.Strike, Expiration: expiration, Quantity: 1},
+		},
+		Thesis:    fmt.Sprintf("Baseline: sell the %.0f-delta put %s, defined risk", baselineDelta*100, expiration),
+		POP:       round2(pop),
+		MaxProfit: round2(credit * 100),
+		MaxLoss:   round2((width - credit) * 100),
+		Score:     round2(pop * 10),
+	}, true
+}
+
+// nearest returns the chain entry allowed by ok that minimizes distance
+func nearest(chains []*ai_assistant.OptionChain, distance func(*ai_assistant.OptionChain) float64, ok func(*ai_assistant.OptionChain) bool) *ai_assistant.OptionChain {
+	var best *ai_assistant.OptionChain
+	bestDistance := math.MaxFloat64
+	for _, chain := range chains {
+		if !ok(chain) {
+			continue
+		}
+		if d := distance(chain); d < bestDistance {
+			best, bestDistance = chain, d
+		}
+	}
+	return best
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,693 @@
+// Package backtest replays stored market snapshots through the AI
+// recommendation pipeline: the market data aggregator, the trading
+// assistant with a recorded or stand-in model, and the risk manager. The
+// approved trades are entered at the replayed prices and managed to an
+// exit rule, and the results are summarized with the same metrics the
+// live performance tracker reports.
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/events"
+)
+
+// Exit reasons
+const (
+	ExitProfitTarget = "profit_target"
+	ExitDTE          = "dte"
+	ExitStopLoss     = "stop_loss"
+	ExitExpiration   = "expiration"
+	ExitEndOfTest    = "end_of_test"
+)
+
+// Statuses of recommendations the backtest did not trade, alongside the
+// tracker's RecordClosed and RecordExpired
+const (
+	StatusRejected = "rejected" // Failed the risk screen
+	StatusSkipped  = "skipped"  // Approved but not entered; see Result.Skipped
+)
+
+// Reasons approved trades are not entered
+const (
+	SkipUnstructured = "unstructured_legs"
+	SkipDuplicate    = "symbol_already_open"
+	SkipUnpriced     = "unpriced"
+	SkipBuyingPower  = "buying_power"
+)
+
+// Management is the rule open trades are closed by. Trades still open at
+// the last leg's expiration settle at intrinsic value.
+type Management struct {
+	// ProfitTarget closes a trade once its profit reaches this fraction
+	// of its maximum profit; 0 disables it
+	ProfitTarget float64 `json:"profit_target" yaml:"profit_target"`
+	// CloseDTE closes a trade at this many days to expiration, unless it
+	// was opened closer than that; 0 holds to expiration
+	CloseDTE int `json:"close_dte" yaml:"close_dte"`
+	// StopLoss closes a trade once its loss reaches this multiple of its
+	// maximum profit; 0 disables it
+	StopLoss float64 `json:"stop_loss" yaml:"stop_loss"`
+}
+
+// DefaultManagement closes at 50% of max profit or 21 days to expiration
+func DefaultManagement() Management {
+	return Management{ProfitTarget: 0.5, CloseDTE: 21}
+}
+
+// Config is what a backtest replays and how it trades
+type Config struct {
+	Symbols      []string
+	From, To     time.Time
+	StartingCash float64
+	Slippage     float64 // Per share per leg, paid away from the mid on entry and exit
+	Commission   float64 // Per contract per leg, on entry and exit
+	Management   Management
+}
+
+// DefaultConfig returns a $100,000 account with a cent of slippage and
+// $0.65 commissions, managed by DefaultManagement
+func DefaultConfig() Config {
+	return Config{
+		StartingCash: 100000,
+		Slippage:     0.01,
+		Commission:   0.65,
+		Management:   DefaultManagement(),
+	}
+}
+
+// Variant is one configuration of the pipeline under test
+type Variant struct {
+	Name    string                        `json:"name"`
+	Prompts *ai_assistant.PromptTemplates `json:"-"` // Nil for the default prompts
+	Policy  *ai_assistant.RiskPolicy      `json:"-"` // Nil for the default policy
+}
+
+// LoadVariant builds a variant whose trading system prompt is read from
+// promptFile and whose risk policy is loaded from policyFile; either may
+// be empty to keep the default
+func LoadVariant(name, promptFile, policyFile string) (Variant, error) {
+	variant := Variant{Name: name}
+	if promptFile != "" {
+		prompt, err := os.ReadFile(promptFile)
+		if err != nil {
+			return Variant{}, fmt.Errorf("failed to read prompt for %s: %w", name, err)
+		}
+		variant.Prompts = ai_assistant.NewPromptTemplates()
+		variant.Prompts.TradingSystemPrompt = string(prompt)
+	}
+	if policyFile != "" {
+		policy, err := ai_assistant.LoadRiskPolicy(policyFile)
+		if err != nil {
+			return Variant{}, fmt.Errorf("failed to load risk policy for %s: %w", name, err)
+		}
+		variant.Policy = policy
+	}
+	return variant, nil
+}
+
+// Trade is a position the backtest entered. Values are in dollars for one
+// of the trade's structures, negative when it is a net credit.
+type Trade struct {
+	RecordID   string                   `json:"record_id"`
+	Ticker     string                   `json:"ticker"`
+	Strategy   string                   `json:"strategy"`
+	Legs       []ai_assistant.OptionLeg `json:"legs"`
+	Quantity   int                      `json:"quantity"`
+	EntryDate  time.Time                `json:"entry_date"`
+	EntryCost  float64                  `json:"entry_cost"`
+	MaxProfit  float64                  `json:"max_profit"` // Whole position, at the entry cost
+	Reserved   float64                  `json:"reserved"`   // Buying power held while open
+	Value      float64                  `json:"value"`      // At the latest close
+	ExitDate   *time.Time               `json:"exit_date,omitempty"`
+	ExitValue  float64                  `json:"exit_value,omitempty"`
+	ExitReason string                   `json:"exit_reason,omitempty"`
+	Commission float64                  `json:"commission"`
+	PnL        float64                  `json:"pnl"` // After commissions; unrealized at Value while open
+
+	expiration time.Time
+	entryDTE   int
+	record     *ai_assistant.RecommendationRecord
+}
+
+// EquityPoint is the account at one replayed close
+type EquityPoint struct {
+	Date        time.Time `json:"date"`
+	Equity      float64   `json:"equity"`
+	Cash        float64   `json:"cash"`
+	BuyingPower float64   `json:"buying_power"`
+	OpenTrades  int       `json:"open_trades"`
+}
+
+// Result is a variant's backtest. Metrics are computed from Records as the
+// live tracker computes them, with each trade's P&L as its return;
+// MaxDrawdown and Return here are of the equity curve instead.
+type Result struct {
+	Variant         string                               `json:"variant"`
+	From            time.Time                            `json:"from"`
+	To              time.Time                            `json:"to"`
+	Days            int                                  `json:"days"`
+	Metrics         *ai_assistant.PerformanceMetrics     `json:"metrics"`
+	StartingCash    float64                              `json:"starting_cash"`
+	EndingEquity    float64                              `json:"ending_equity"`
+	Return          float64                              `json:"return"`       // % of starting cash
+	MaxDrawdown     float64                              `json:"max_drawdown"` // % from the equity peak
+	Recommendations int                                  `json:"recommendations"`
+	Approved        int                                  `json:"approved"`
+	Rejections      map[string]int                       `json:"rejections"` // By rule ID
+	Skipped         map[string]int                       `json:"skipped"`
+	Exits           map[string]int                       `json:"exits"`
+	LLMErrors       int                                  `json:"llm_errors"`
+	Trades          []*Trade                             `json:"trades"`
+	Equity          []EquityPoint                        `json:"equity"`
+	Records         []*ai_assistant.RecommendationRecord `json:"records"`
+}
+
+// Runner backtests variants of the pipeline over a Store
+type Runner struct {
+	store         Store
+	llm           ai_assistant.LLMProvider
+	eventProvider events.Provider
+	config        Config
+	logger        *logrus.Logger
+}
+
+// NewRunner creates a Runner that asks llm for recommendations
+func NewRunner(store Store, llm ai_assistant.LLMProvider, config Config, logger *logrus.Logger) *Runner {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &Runner{store: store, llm: llm, config: config, logger: logger}
+}
+
+// SetEventProvider sets where the replayed earnings, dividend and macro
+// events come from
+func (r *Runner) SetEventProvider(provider events.Provider) {
+	r.eventProvider = provider
+}
+
+// run is one variant's backtest in progress
+type run struct {
+	*Runner
+	variant   Variant
+	assistant *ai_assistant.TradingAssistant
+	result    *Result
+	cash      float64
+	open      []*Trade
+	lastValue float64
+	nextID    int
+}
+
+// Run backtests variant over the configured dates
+func (r *Runner) Run(ctx context.Context, variant Variant) (*Result, error) {
+	dates, err := r.store.Dates(r.config.From, r.config.To)
+	if err != nil {
+		return nil, err
+	}
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("no snapshots stored from %s to %s", r.config.From.Format(dateFormat), r.config.To.Format(dateFormat))
+	}
+
+	b := &run{
+		Runner:    r,
+		variant:   variant,
+		assistant: ai_assistant.NewTradingAssistantWithProvider(r.llm, variant.Prompts),
+		cash:      r.config.StartingCash,
+		lastValue: r.config.StartingCash,
+		result: &Result{
+			Variant:      variant.Name,
+			From:         dates[0],
+			To:           dates[len(dates)-1],
+			Days:         len(dates),
+			StartingCash: r.config.StartingCash,
+			Rejections:   make(map[string]int),
+			Skipped:      make(map[string]int),
+			Exits:        make(map[string]int),
+			Trades:       []*Trade{},
+			Records:      []*ai_assistant.RecommendationRecord{},
+		},
+	}
+
+	var last *ai_assistant.AggregatedMarketData
+	for i, date := range dates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := b.day(ctx, date, i == len(dates)-1)
+		if err != nil {
+			return nil, fmt.Errorf("backtest of %s failed on %s: %w", variant.Name, date.Format(dateFormat), err)
+		}
+		last = data
+	}
+
+	result := b.result
+	result.Metrics = ai_assistant.CalculateMetrics(result.Records, last.Timestamp)
+	result.EndingEquity = round2(b.cash)
+	if result.StartingCash > 0 {
+		result.Return = round2((result.EndingEquity - result.StartingCash) / result.StartingCash * 100)
+	}
+	result.MaxDrawdown = equityDrawdown(result.Equity)
+	return result, nil
+}
+
+// day replays one close: manage the open trades, ask for recommendations,
+// screen them and enter the approved ones. On the last day every trade
+// still open is closed.
+func (b *run) day(ctx context.Context, date time.Time, last bool) (*ai_assistant.AggregatedMarketData, error) {
+	source := &replay{store: b.store, date: date}
+	aggregator := ai_assistant.NewMarketDataAggregatorFromSource(source)
+	if b.eventProvider != nil {
+		aggregator.SetEventProvider(b.eventProvider)
+	}
+	data, err := aggregator.AggregateDataForSymbols(ctx, b.config.Symbols)
+	if err != nil {
+		return nil, err
+	}
+	pricer := ai_assistant.NewRiskManager().WithMarketData(data).WithClock(source.Now)
+
+	b.manage(pricer, date, data)
+	if !last {
+		if err := b.enter(ctx, pricer, source, data); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, trade := range append([]*Trade(nil), b.open...) {
+			b.exit(trade, date, ExitEndOfTest, trade.Value-b.slippage(trade), true)
+		}
+	}
+
+	equity, buyingPower := b.account()
+	b.result.Equity = append(b.result.Equity, EquityPoint{
+		Date:        date,
+		Equity:      round2(equity),
+		Cash:        round2(b.cash),
+		BuyingPower: round2(buyingPower),
+		OpenTrades:  len(b.open),
+	})
+	b.lastValue = equity
+	return data, nil
+}
+
+// manage marks the open trades to the close and exits those the
+// management rule or expiration closes
+func (b *run) manage(pricer *ai_assistant.RiskManager, date time.Time, data *ai_assistant.AggregatedMarketData) {
+	rule := b.config.Management
+	for _, trade := range append([]*Trade(nil), b.open...) {
+		value, err := structureValue(pricer, trade.Ticker, trade.Legs, date, spot(data, trade.Ticker))
+		if err != nil {
+			b.logger.WithError(err).Warnf("Backtest could not mark %s on %s; keeping the last mark", trade.Ticker, date.Format(dateFormat))
+			value = trade.Value
+		}
+		trade.Value = value
+
+		if !date.Before(trade.expiration) {
+			b.exit(trade, date, ExitExpiration, value, false)
+			continue
+		}
+
+		exitValue := value - b.slippage(trade)
+		profit := (exitValue - trade.EntryCost) * float64(trade.Quantity)
+		dte := int(trade.expiration.Sub(date).Hours() / 24)
+		switch {
+		case rule.ProfitTarget > 0 && trade.MaxProfit > 0 && profit >= rule.ProfitTarget*trade.MaxProfit:
+			b.exit(trade, date, ExitProfitTarget, exitValue, true)
+		case rule.StopLoss > 0 && trade.MaxProfit > 0 && -profit >= rule.StopLoss*trade.MaxProfit:
+			b.exit(trade, date, ExitStopLoss, exitValue, true)
+		case rule.CloseDTE > 0 && dte <= rule.CloseDTE && trade.entryDTE > rule.CloseDTE:
+			b.exit(trade, date, ExitDTE, exitValue, true)
+		default:
+			trade.PnL = round2(profit - trade.Commission)
+		}
+	}
+}
+
+// enter asks the assistant for the day's recommendations, screens them as
+// the live handler does and enters the approved trades it can
+func (b *run) enter(ctx context.Context, pricer *ai_assistant.RiskManager, source *replay, data *ai_assistant.AggregatedMarketData) error {
+	date := source.date
+	portfolio := b.portfolio(date, data)
+	recommendations, err := b.assistant.AnalyzeTrades(ctx, data, portfolio)
+	if errors.Is(err, ErrNotRecorded) {
+		return err
+	}
+	if err != nil {
+		b.result.LLMErrors++
+		b.logger.WithError(err).Warnf("Backtest got no recommendations on %s", date.Format(dateFormat))
+		return nil
+	}
+	b.result.Recommendations += len(recommendations)
+
+	risk := ai_assistant.NewRiskManagerWithPolicy(b.variant.Policy).WithMarketData(data).WithClock(source.Now)
+	approved, rejected := risk.Screen(recommendations, portfolio)
+	b.result.Approved += len(approved)
+
+	for _, trade := range rejected {
+		for _, rule := range ruleIDs(trade.Validation) {
+			b.result.Rejections[rule]++
+		}
+		b.record(trade, date, StatusRejected)
+	}
+
+	_, buyingPower := b.account()
+	for _, trade := range approved {
+		reason := b.openTrade(pricer, trade, date, data, &buyingPower)
+		if reason != "" {
+			b.result.Skipped[reason]++
+			b.record(trade, date, StatusSkipped)
+		}
+	}
+	return nil
+}
+
+// openTrade enters trade at the close, returning why it was not entered
+// if not
+func (b *run) openTrade(pricer *ai_assistant.RiskManager, trade ai_assistant.TradeRecommendation, date time.Time, data *ai_assistant.AggregatedMarketData, buyingPower *float64) string {
+	if len(trade.LegDetails) == 0 {
+		return SkipUnstructured
+	}
+	for _, open := range b.open {
+		if strings.EqualFold(open.Ticker, trade.Ticker) {
+			return SkipDuplicate
+		}
+	}
+	expiration, ok := lastExpiration(trade.LegDetails)
+	if !ok {
+		return SkipUnstructured
+	}
+	value, err := structureValue(pricer, trade.Ticker, trade.LegDetails, date, spot(data, trade.Ticker))
+	if err != nil {
+		return SkipUnpriced
+	}
+
+	quantity := max(trade.Quantity, 1)
+	t := &Trade{
+		Ticker:     strings.ToUpper(trade.Ticker),
+		Strategy:   trade.Strategy,
+		Legs:       trade.LegDetails,
+		Quantity:   quantity,
+		EntryDate:  date,
+		Value:      value,
+		expiration: expiration,
+		entryDTE:   int(expiration.Sub(date).Hours() / 24),
+	}
+	t.EntryCost = round2(value + b.slippage(t))
+	t.Commission = b.commission(t)
+
+	margin := 0.0
+	if trade.Margin != nil {
+		margin = trade.Margin.Total
+	}
+	t.Reserved = round2(math.Max(margin, t.EntryCost*float64(quantity)))
+	if t.Reserved+t.Commission > *buyingPower {
+		return SkipBuyingPower
+	}
+	*buyingPower -= t.Reserved + t.Commission
+
+	if profit, bounded := maxProfitAtExpiry(t.Legs, t.EntryCost); bounded {
+		t.MaxProfit = round2(profit * float64(quantity))
+	} else if trade.MaxProfit > 0 {
+		t.MaxProfit = round2(trade.MaxProfit * float64(quantity))
+	}
+	t.PnL = round2((value-t.EntryCost)*float64(quantity) - t.Commission)
+
+	b.cash -= t.EntryCost*float64(quantity) + t.Commission
+	t.record = b.record(trade, date, ai_assistant.RecordExecuted)
+	executed := t.record.Timestamp
+	t.record.Executed = true
+	t.record.ExecutionTime = &executed
+	t.RecordID = t.record.ID
+	b.open = append(b.open, t)
+	b.result.Trades = append(b.result.Trades, t)
+	return ""
+}
+
+// exit closes trade for exitValue, paying commission unless it settled at
+// expiration
+func (b *run) exit(trade *Trade, date time.Time, reason string, exitValue float64, commission bool) {
+	fee := 0.0
+	if commission {
+		fee = b.commission(trade)
+		trade.Commission = round2(trade.Commission + fee)
+	}
+	b.cash += exitValue*float64(trade.Quantity) - fee
+
+	exitDate := date
+	trade.ExitDate = &exitDate
+	trade.ExitValue = round2(exitValue)
+	trade.ExitReason = reason
+	trade.Value = exitValue
+	trade.PnL = round2((exitValue-trade.EntryCost)*float64(trade.Quantity) - trade.Commission)
+	b.result.Exits[reason]++
+
+	profit := trade.PnL
+	trade.record.ExitTime = &exitDate
+	trade.record.ActualProfit = &profit
+	trade.record.Status = ai_assistant.RecordClosed
+	if reason == ExitExpiration {
+		trade.record.Status = ai_assistant.RecordExpired
+	}
+
+	for i, open := range b.open {
+		if open == trade {
+			b.open = append(b.open[:i], b.open[i+1:]...)
+			break
+		}
+	}
+}
+
+// record adds a recommendation record dated at the replayed close
+func (b *run) record(trade ai_assistant.TradeRecommendation, date time.Time, status string) *ai_assistant.RecommendationRecord {
+	b.nextID++
+	record := &ai_assistant.RecommendationRecord{
+		ID:             fmt.Sprintf("bt_%s_%d", date.Format("20060102"), b.nextID),
+		Timestamp:      (&replay{date: date}).Now(),
+		Recommendation: trade,
+		Status:         status,
+	}
+	record.Recommendation.RecordID = record.ID
+	b.result.Records = append(b.result.Records, record)
+	return record
+}
+
+// account returns the account's equity at the open trades' latest values
+// and its buying power: equity less what the open trades reserve
+func (b *run) account() (equity, buyingPower float64) {
+	equity = b.cash
+	reserved := 0.0
+	for _, trade := range b.open {
+		equity += trade.Value * float64(trade.Quantity)
+		reserved += trade.Reserved
+	}
+	return equity, equity - reserved
+}
+
+// portfolio describes the simulated account in the form the assistant and
+// risk manager take it live
+func (b *run) portfolio(date time.Time, data *ai_assistant.AggregatedMarketData) map[string]interface{} {
+	equity, buyingPower := b.account()
+	positions := []map[string]interface{}{}
+	for _, trade := range b.open {
+		for _, leg := range trade.Legs {
+			quantity := math.Max(leg.Quantity, 1) * float64(trade.Quantity)
+			side := "long"
+			if leg.Action == ai_assistant.LegSell {
+				side = "short"
+			}
+			position := map[string]interface{}{
+				"symbol":           trade.Ticker,
+				"underlying":       trade.Ticker,
+				"type":             leg.Type,
+				"side":             side,
+				"quantity":         quantity,
+				"underlying_price": spot(data, trade.Ticker),
+			}
+			if leg.Type != ai_assistant.PositionStock {
+				if symbol, err := ai_assistant.OCCSymbol(trade.Ticker, leg); err == nil {
+					position["symbol"] = symbol
+				}
+				position["strike"] = leg.Strike
+				position["expiration"] = leg.Expiration
+			}
+			positions = append(positions, position)
+		}
+	}
+	return map[string]interface{}{
+		"total_value":  round2(equity),
+		"cash_balance": round2(b.cash),
+		"buying_power": round2(buyingPower),
+		"daily_pnl":    round2(equity - b.lastValue),
+		"positions":    positions,
+	}
+}
+
+// slippage is what trade gives up crossing from the mid, per structure
+func (b *run) slippage(trade *Trade) float64 {
+	return b.config.Slippage * structureShares(trade.Legs)
+}
+
+// commission is trade's commission for one side, entry or exit
+func (b *run) commission(trade *Trade) float64 {
+	contracts := 0.0
+	for _, leg := range trade.Legs {
+		if leg.Type != ai_assistant.PositionStock {
+			contracts += math.Max(leg.Quantity, 1)
+		}
+	}
+	return round2(b.config.Commission * contracts * float64(trade.Quantity))
+}
+
+// structureValue is the dollar value of one of a structure's legs at the
+// close: listed mids or model prices, and intrinsic value for legs at or
+// past expiration
+func structureValue(pricer *ai_assistant.RiskManager, ticker string, legs []ai_assistant.OptionLeg, date time.Time, underlying float64) (float64, error) {
+	value := 0.0
+	for _, leg := range legs {
+		price := 0.0
+		expiry, err := time.Parse(dateFormat, leg.Expiration)
+		switch {
+		case leg.Type != ai_assistant.PositionStock && err == nil && !date.Before(expiry):
+			if underlying <= 0 {
+				return 0, fmt.Errorf("no price for %s to settle the %g %s", ticker, leg.Strike, leg.Type)
+			}
+			price = intrinsic(leg, underlying)
+		default:
+			price, err = pricer.LegPrice(ticker, leg)
+			if err != nil {
+				return 0, err
+			}
+		}
+		value += legSign(leg) * price * legShares(leg)
+	}
+	return value, nil
+}
+
+// maxProfitAtExpiry is the most one structure can make at expiration over
+// entryCost. Payoffs are piecewise linear between strikes, so it is the
+// best of the strikes and zero, unless the payoff keeps rising above the
+// top strike; then, or when legs expire on different dates, it is not
+// bounded.
+func maxProfitAtExpiry(legs []ai_assistant.OptionLeg, entryCost float64) (float64, bool) {
+	points := []float64{0}
+	slope := 0.0
+	expirations := make(map[string]bool)
+	for _, leg := range legs {
+		if leg.Type == ai_assistant.PositionStock || leg.Type == ai_assistant.PositionCall {
+			slope += legSign(leg) * legShares(leg)
+		}
+		if leg.Type != ai_assistant.PositionStock {
+			points = append(points, leg.Strike)
+			expirations[leg.Expiration] = true
+		}
+	}
+	if slope > 0 || len(expirations) > 1 {
+		return 0, false
+	}
+
+	best := math.Inf(-1)
+	for _, spot := range points {
+		payoff := 0.0
+		for _, leg := range legs {
+			payoff += legSign(leg) * intrinsic(leg, spot) * legShares(leg)
+		}
+		best = math.Max(best, payoff-entryCost)
+	}
+	return best, true
+}
+
+// lastExpiration is when the structure's last option leg expires
+func lastExpiration(legs []ai_assistant.OptionLeg) (time.Time, bool) {
+	var last time.Time
+	for _, leg := range legs {
+		if leg.Type == ai_assistant.PositionStock {
+			continue
+		}
+		expiry, err := time.Parse(dateFormat, leg.Expiration)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if expiry.After(last) {
+			last = expiry
+		}
+	}
+	return last, !last.IsZero()
+}
+
+func intrinsic(leg ai_assistant.OptionLeg, spot float64) float64 {
+	switch leg.Type {
+	case ai_assistant.PositionCall:
+		return math.Max(spot-leg.Strike, 0)
+	case ai_assistant.PositionPut:
+		return math.Max(leg.Strike-spot, 0)
+	}
+	return spot
+}
+
+func legSign(leg ai_assistant.OptionLeg) float64 {
+	if leg.Action == ai_assistant.LegSell {
+		return -1
+	}
+	return 1
+}
+
+// legShares is the number of shares a leg controls
+func legShares(leg ai_assistant.OptionLeg) float64 {
+	shares := math.Max(leg.Quantity, 1)
+	if leg.Type != ai_assistant.PositionStock {
+		shares *= 100
+	}
+	return shares
+}
+
+func structureShares(legs []ai_assistant.OptionLeg) float64 {
+	shares := 0.0
+	for _, leg := range legs {
+		shares += legShares(leg)
+	}
+	return shares
+}
+
+func spot(data *ai_assistant.AggregatedMarketData, symbol string) float64 {
+	if quote := data.Quotes[strings.ToUpper(symbol)]; quote != nil {
+		return quote.Price
+	}
+	return 0
+}
+
+// ruleIDs returns the distinct rules a screened trade violated
+func ruleIDs(validation *ai_assistant.TradeValidation) []string {
+	if validation == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var rules []string
+	for _, violation := range validation.RuleViolations {
+		if !seen[violation.RuleID] {
+			seen[violation.RuleID] = true
+			rules = append(rules, violation.RuleID)
+		}
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// equityDrawdown is the largest fall of the equity curve from its peak, in
+// percent
+func equityDrawdown(curve []EquityPoint) float64 {
+	peak, drawdown := 0.0, 0.0
+	for _, point := range curve {
+		peak = math.Max(peak, point.Equity)
+		if peak > 0 {
+			drawdown = math.Max(drawdown, (peak-point.Equity)/peak*100)
+		}
+	}
+	return round2(drawdown)
+}
@@ -0,0 +1,223 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// memStore is a Store over snapshots held in memory
+type memStore struct {
+	snapshots map[string]map[string]*Snapshot // By date, then symbol
+	bars      map[string][]ai_assistant.DailyBar
+}
+
+func (s *memStore) Dates(from, to time.Time) ([]time.Time, error) {
+	var dates []time.Time
+	for key := range s.snapshots {
+		date, _ := time.Parse(dateFormat, key)
+		if !date.Before(day(from)) && !date.After(day(to)) {
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates, nil
+}
+
+func (s *memStore) Snapshot(date time.Time, symbol string) (*Snapshot, error) {
+	if snapshot := s.snapshots[date.Format(dateFormat)][symbol]; snapshot != nil {
+		return snapshot, nil
+	}
+	return nil, ErrNoSnapshot
+}
+
+func (s *memStore) Bars(symbol string) ([]ai_assistant.DailyBar, error) {
+	return s.bars[symbol], nil
+}
+
+// scriptedLLM recommends the same trades every day
+type scriptedLLM struct {
+	trades []ai_assistant.TradeRecommendation
+	calls  int
+}
+
+func (l *scriptedLLM) SendMessage(ctx context.Context, systemPrompt string, userMessage string) (string, error) {
+	l.calls++
+	response, err := json.Marshal(l.trades)
+	return string(response), err
+}
+
+var backtestStart = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+// spreadStore holds SPY at $500 on consecutive days with the 470 and 467
+// puts at the given mids, and two months of flat closes before that
+func spreadStore(expiration string, mids ...[2]float64) *memStore {
+	store := &memStore{snapshots: make(map[string]map[string]*Snapshot), bars: make(map[string][]ai_assistant.DailyBar)}
+	for date := backtestStart.AddDate(0, -2, 0); date.Before(backtestStart.AddDate(0, 0, len(mids))); date = date.AddDate(0, 0, 1) {
+		store.bars["SPY"] = append(store.bars["SPY"], ai_assistant.DailyBar{Date: date, Close: 500})
+	}
+	for i, mid := range mids {
+		put := func(strike, mid float64) *ai_assistant.OptionChain {
+			return &ai_assistant.OptionChain{Symbol: "SPY", Strike: strike, Expiration: expiration, Type: ai_assistant.PositionPut,
+				Bid: mid - 0.05, Ask: mid + 0.05, IV: 0.2}
+		}
+		store.snapshots[backtestStart.AddDate(0, 0, i).Format(dateFormat)] = map[string]*Snapshot{"SPY": {
+			Quote:  &ai_assistant.Quote{Symbol: "SPY", Price: 500, Bid: 499.95, Ask: 500.05},
+			Chains: []*ai_assistant.OptionChain{put(470, mid[0]), put(467, mid[1])},
+		}}
+	}
+	return store
+}
+
+func putSpread(expiration string) *scriptedLLM {
+	return &scriptedLLM{trades: []ai_assistant.TradeRecommendation{{
+		Ticker:    "SPY",
+		Strategy:  "Put Credit Spread",
+		POP:       0.8,
+		MaxProfit: 100,
+		MaxLoss:   200,
+		LegDetails: []ai_assistant.OptionLeg{
+			{Action: ai_assistant.LegSell, Type: ai_assistant.PositionPut, Strike: 470, Expiration: expiration, Quantity: 1},
+			{Action: ai_assistant.LegBuy, Type: ai_assistant.PositionPut, Strike: 467, Expiration: expiration, Quantity: 1},
+		},
+	}}}
+}
+
+func newTestRunner(store Store, llm ai_assistant.LLMProvider, days int, management Management) *Runner {
+	config := DefaultConfig()
+	config.Symbols = []string{"SPY"}
+	config.From, config.To = backtestStart, backtestStart.AddDate(0, 0, days-1)
+	config.Management = management
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return NewRunner(store, llm, config, logger)
+}
+
+// noGreeksBands is the default policy without the net Greeks bands, which
+// a second copy of the spread would breach before the runner sees it
+func noGreeksBands() Variant {
+	policy := ai_assistant.DefaultRiskPolicy()
+	policy.Greeks = ai_assistant.GreeksLimits{}
+	return Variant{Name: "test", Policy: policy}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+func TestRunnerTakesProfitAndClosesAtEnd(t *testing.T) {
+	// The spread is sold for $100 and decays to $50, then $20
+	store := spreadStore("2026-04-17", [2]float64{2, 1}, [2]float64{1.2, 0.7}, [2]float64{0.5, 0.3}, [2]float64{0.5, 0.3})
+	llm := putSpread("2026-04-17")
+	result, err := newTestRunner(store, llm, 4, DefaultManagement()).Run(context.Background(), noGreeksBands())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Days != 4 || len(result.Equity) != 4 || llm.calls != 3 {
+		t.Fatalf("%d days, %d equity points and %d model calls, want 4, 4 and 3 (none on the last day)", result.Days, len(result.Equity), llm.calls)
+	}
+	if len(result.Trades) != 2 || result.Approved != 3 || result.Skipped[SkipDuplicate] != 1 {
+		t.Fatalf("trades %d, approved %d, skipped %v: want two trades and the second day's repeat skipped (rejections %v)",
+			len(result.Trades), result.Approved, result.Skipped, result.Rejections)
+	}
+
+	// Sized to two spreads, each entered at the $100 credit less two legs
+	// of a cent of slippage, and exited once $76 of the $98 maximum was in
+	// hand on each
+	first := result.Trades[0]
+	if first.Quantity != 2 || !near(first.EntryCost, -98) || !near(first.MaxProfit, 196) {
+		t.Errorf("%d at entry cost %v, max profit %v, want 2 at -98 for 196", first.Quantity, first.EntryCost, first.MaxProfit)
+	}
+	if first.ExitReason != ExitProfitTarget || !first.ExitDate.Equal(backtestStart.AddDate(0, 0, 2)) || !near(first.ExitValue, -22) {
+		t.Errorf("exit %s on %v at %v, want the profit target on day 3 at -22", first.ExitReason, first.ExitDate, first.ExitValue)
+	}
+	if !near(first.Commission, 5.2) || !near(first.PnL, 146.8) {
+		t.Errorf("commission %v, P&L %v, want 5.2 and 146.8", first.Commission, first.PnL)
+	}
+	if first.record.Status != ai_assistant.RecordClosed || *first.record.ActualProfit != first.PnL {
+		t.Errorf("record = %+v, want closed at the trade's P&L", first.record)
+	}
+
+	// Re-entered the day it closed and closed again when the test ends
+	second := result.Trades[1]
+	if second.ExitReason != ExitEndOfTest || !near(second.PnL, -13.2) {
+		t.Errorf("second trade %s with P&L %v, want end of test at -13.2", second.ExitReason, second.PnL)
+	}
+	if !near(result.EndingEquity, 100000+146.8-13.2) || result.Exits[ExitProfitTarget] != 1 || result.Exits[ExitEndOfTest] != 1 {
+		t.Errorf("ending equity %v, exits %v", result.EndingEquity, result.Exits)
+	}
+
+	// While open the first trade was marked at the close
+	if point := result.Equity[1]; point.OpenTrades != 1 || !near(point.Equity, 100000+196-2.6-100) {
+		t.Errorf("day 2 = %+v, want the spread marked at -50", point)
+	}
+}
+
+func TestRunnerSettlesAtExpiration(t *testing.T) {
+	// Expires on the third day with SPY far above the short strike
+	expiration := backtestStart.AddDate(0, 0, 2).Format(dateFormat)
+	store := spreadStore(expiration, [2]float64{2, 1}, [2]float64{1, 0.5}, [2]float64{0.1, 0.05})
+	result, err := newTestRunner(store, putSpread(expiration), 3, Management{}).Run(context.Background(), noGreeksBands())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := result.Trades[0]
+	if first.ExitReason != ExitExpiration || first.ExitValue != 0 {
+		t.Fatalf("exit %s at %v, want settled worthless at expiration", first.ExitReason, first.ExitValue)
+	}
+	// No commission to let it expire
+	if !near(first.Commission, 2.6) || !near(first.PnL, 193.4) || first.record.Status != ai_assistant.RecordExpired {
+		t.Errorf("commission %v, P&L %v, status %s, want 2.6, 193.4 and expired", first.Commission, first.PnL, first.record.Status)
+	}
+	if result.Metrics == nil || result.Metrics.WinningTrades < 1 {
+		t.Errorf("metrics = %+v, want the expired spread counted as a win", result.Metrics)
+	}
+}
+
+func TestRunnerNeedsSnapshots(t *testing.T) {
+	runner := newTestRunner(spreadStore("2026-04-17"), putSpread("2026-04-17"), 3, DefaultManagement())
+	if _, err := runner.Run(context.Background(), Variant{Name: "empty"}); err == nil {
+		t.Error("expected an error with no stored dates")
+	}
+}
+
+func TestRecordedLLMReplaysWithoutTheModel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.json")
+	live := putSpread("2026-04-17")
+	recorder, err := NewRecordedLLM(path, live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := recorder.SendMessage(context.Background(), "system", "prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewRecordedLLM(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := replayer.SendMessage(context.Background(), "system", "prompt"); err != nil || got != want {
+		t.Errorf("replayed %q, %v, want the recorded response", got, err)
+	}
+	if _, err := replayer.SendMessage(context.Background(), "system", "another prompt"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded prompt = %v, want %v", err, ErrNotRecorded)
+	}
+	if live.calls != 1 {
+		t.Errorf("model called %d times, want once", live.calls)
+	}
+}
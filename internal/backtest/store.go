@@ -0,0 +1,202 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// ErrNoSnapshot is returned for a symbol with no stored data on a date
+var ErrNoSnapshot = errors.New("no snapshot stored")
+
+// dateFormat is how snapshot dates are written
+const dateFormat = "2006-01-02"
+
+// Snapshot is a symbol's quote and option chain at one day's close
+type Snapshot struct {
+	Quote  *ai_assistant.Quote         `json:"quote"`
+	Chains []*ai_assistant.OptionChain `json:"chains"`
+}
+
+// Store holds the historical data a backtest replays
+type Store interface {
+	// Dates returns the days from from to to inclusive with snapshots
+	Dates(from, to time.Time) ([]time.Time, error)
+	// Snapshot returns symbol's snapshot on date, or ErrNoSnapshot
+	Snapshot(date time.Time, symbol string) (*Snapshot, error)
+	// Bars returns symbol's daily closes, oldest first
+	Bars(symbol string) ([]ai_assistant.DailyBar, error)
+}
+
+// DirStore reads snapshots laid out as
+//
+//	<dir>/bars/<SYMBOL>.json                  []ai_assistant.DailyBar
+//	<dir>/chains/<YYYY-MM-DD>/<SYMBOL>.json   Snapshot
+//
+// Bars are cached once read since every replayed day needs them.
+type DirStore struct {
+	dir  string
+	bars map[string][]ai_assistant.DailyBar
+}
+
+// NewDirStore creates a DirStore reading from dir
+func NewDirStore(dir string) (*DirStore, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to open snapshot directory: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("snapshot path %s is not a directory", dir)
+	}
+	return &DirStore{dir: dir, bars: make(map[string][]ai_assistant.DailyBar)}, nil
+}
+
+func (s *DirStore) Dates(from, to time.Time) ([]time.Time, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "chains"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot dates: %w", err)
+	}
+	from, to = day(from), day(to)
+
+	var dates []time.Time
+	for _, entry := range entries {
+		date, err := time.Parse(dateFormat, entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		if !date.Before(from) && !date.After(to) {
+			dates = append(dates, date)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates, nil
+}
+
+func (s *DirStore) Snapshot(date time.Time, symbol string) (*Snapshot, error) {
+	path := filepath.Join(s.dir, "chains", date.Format(dateFormat), strings.ToUpper(symbol)+".json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w for %s on %s", ErrNoSnapshot, symbol, date.Format(dateFormat))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+func (s *DirStore) Bars(symbol string) ([]ai_assistant.DailyBar, error) {
+	symbol = strings.ToUpper(symbol)
+	if bars, ok := s.bars[symbol]; ok {
+		return bars, nil
+	}
+
+	var bars []ai_assistant.DailyBar
+	data, err := os.ReadFile(filepath.Join(s.dir, "bars", symbol+".json"))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read %s bars: %w", symbol, err)
+	default:
+		if err := json.Unmarshal(data, &bars); err != nil {
+			return nil, fmt.Errorf("failed to parse %s bars: %w", symbol, err)
+		}
+		sort.Slice(bars, func(i, j int) bool { return bars[i].Date.Before(bars[j].Date) })
+	}
+	s.bars[symbol] = bars
+	return bars, nil
+}
+
+// historyMonths is how much history the replay hands the aggregator,
+// matching what FetchDailyBars fetches live
+const historyMonths = 6
+
+// replay serves a Store's data as of one day's close, so the aggregator,
+// assistant and risk manager see only what was known then
+type replay struct {
+	store Store
+	date  time.Time
+}
+
+var newYork = loadNewYork()
+
+func loadNewYork() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// Now is the close of the replayed day
+func (r *replay) Now() time.Time {
+	return time.Date(r.date.Year(), r.date.Month(), r.date.Day(), 16, 0, 0, 0, newYork)
+}
+
+// Quote returns symbol's stored quote, or failing that its close that day
+func (r *replay) Quote(ctx context.Context, symbol string) (*ai_assistant.Quote, error) {
+	snapshot, err := r.store.Snapshot(r.date, symbol)
+	if err != nil && !errors.Is(err, ErrNoSnapshot) {
+		return nil, err
+	}
+	if snapshot != nil && snapshot.Quote != nil {
+		quote := *snapshot.Quote
+		quote.Symbol = strings.ToUpper(symbol)
+		if quote.Price == 0 {
+			quote.Price = (quote.Bid + quote.Ask) / 2
+		}
+		return &quote, nil
+	}
+
+	bars, barsErr := r.DailyBars(ctx, symbol)
+	if barsErr != nil {
+		return nil, barsErr
+	}
+	if len(bars) == 0 || !day(bars[len(bars)-1].Date).Equal(r.date) {
+		return nil, fmt.Errorf("%w for %s on %s", ErrNoSnapshot, symbol, r.date.Format(dateFormat))
+	}
+	price := bars[len(bars)-1].Close
+	return &ai_assistant.Quote{Symbol: strings.ToUpper(symbol), Price: price, Bid: price, Ask: price, Timestamp: r.Now()}, nil
+}
+
+// OptionChains returns symbol's stored chain, empty if none was stored
+func (r *replay) OptionChains(ctx context.Context, symbol string) ([]*ai_assistant.OptionChain, error) {
+	snapshot, err := r.store.Snapshot(r.date, symbol)
+	if errors.Is(err, ErrNoSnapshot) {
+		return []*ai_assistant.OptionChain{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.Chains, nil
+}
+
+// DailyBars returns the last six months of closes up to the replayed day
+func (r *replay) DailyBars(ctx context.Context, symbol string) ([]ai_assistant.DailyBar, error) {
+	bars, err := r.store.Bars(symbol)
+	if err != nil {
+		return nil, err
+	}
+	start := r.date.AddDate(0, -historyMonths, 0)
+	first := sort.Search(len(bars), func(i int) bool { return !day(bars[i].Date).Before(start) })
+	last := sort.Search(len(bars), func(i int) bool { return day(bars[i].Date).After(r.date) })
+	if first >= last {
+		return nil, nil
+	}
+	return bars[first:last], nil
+}
+
+// day truncates t to its calendar date in UTC
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event kinds
+const (
+	KindEarnings   = "earnings"
+	KindExDividend = "ex_dividend"
+	KindFOMC       = "fomc"
+	KindCPI        = "cpi"
+)
+
+// Event is a dated corporate or macro event. Macro events such as FOMC
+// decisions and CPI releases have no Symbol and apply to every symbol.
+type Event struct {
+	Symbol      string    `json:"symbol,omitempty"`
+	Kind        string    `json:"kind"`
+	Date        time.Time `json:"date"`             // Calendar date, midnight UTC
+	Timing      string    `json:"timing,omitempty"` // e.g. "bmo" or "amc" for earnings
+	Amount      float64   `json:"amount,omitempty"` // Dividend per share
+	Description string    `json:"description,omitempty"`
+}
+
+// IsMacro reports whether the event applies to all symbols
+func (e Event) IsMacro() bool {
+	return e.Symbol == ""
+}
+
+// Provider supplies events for a set of symbols. Macro events between from
+// and to are always included.
+type Provider interface {
+	Events(ctx context.Context, symbols []string, from, to time.Time) ([]Event, error)
+}
+
+// Upcoming is an event and how many calendar days away it is; negative
+// for events that have passed
+type Upcoming struct {
+	Event
+	DaysAway int `json:"days_away"`
+}
+
+// Proximity lists the events near one symbol, soonest first
+type Proximity struct {
+	Symbol string     `json:"symbol"`
+	Events []Upcoming `json:"events"`
+}
+
+// Nearest returns the nearest event of kind, if any
+func (p *Proximity) Nearest(kind string) (Upcoming, bool) {
+	for _, e := range p.Events {
+		if e.Kind == kind {
+			return e, true
+		}
+	}
+	return Upcoming{}, false
+}
+
+// Today returns now's calendar date in New York as midnight UTC, the form
+// event dates take
+func Today(now time.Time) time.Time {
+	if location, err := time.LoadLocation("America/New_York"); err == nil {
+		now = now.In(location)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// DaysBetween returns the whole calendar days from a to b
+func DaysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// ProximityFor returns the events that apply to symbol, including macro
+// events, with their distance from now
+func ProximityFor(symbol string, all []Event, now time.Time) *Proximity {
+	today := Today(now)
+	p := &Proximity{Symbol: strings.ToUpper(symbol), Events: []Upcoming{}}
+	for _, e := range all {
+		if e.IsMacro() || strings.EqualFold(e.Symbol, symbol) {
+			p.Events = append(p.Events, Upcoming{Event: e, DaysAway: DaysBetween(today, e.Date)})
+		}
+	}
+	sort.SliceStable(p.Events, func(i, j int) bool { return p.Events[i].Date.Before(p.Events[j].Date) })
+	return p
+}
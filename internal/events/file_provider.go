@@ -0,0 +1,210 @@
+package events
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileProvider serves events from local CSV or JSON files. A CSV file has
+// a header naming its date, kind, symbol, timing, amount and description
+// columns; only date and kind are required. A JSON file is an array of
+// Event with dates as YYYY-MM-DD. Files are re-read when they change.
+type FileProvider struct {
+	paths []string
+
+	mu      sync.Mutex
+	events  []Event
+	modTime map[string]time.Time
+}
+
+// NewFileProvider loads events from paths
+func NewFileProvider(paths ...string) (*FileProvider, error) {
+	fp := &FileProvider{paths: paths}
+	if err := fp.reload(); err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+// Events returns the events for symbols, and all macro events, dated from
+// from to to inclusive
+func (fp *FileProvider) Events(ctx context.Context, symbols []string, from, to time.Time) ([]Event, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+
+	if fp.changed() {
+		if err := fp.reload(); err != nil {
+			return nil, err
+		}
+	}
+
+	wanted := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		wanted[strings.ToUpper(symbol)] = true
+	}
+	from, to = Today(from), Today(to)
+
+	var out []Event
+	for _, e := range fp.events {
+		if e.Date.Before(from) || e.Date.After(to) {
+			continue
+		}
+		if e.IsMacro() || wanted[e.Symbol] {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// changed reports whether any file has been modified since it was loaded
+func (fp *FileProvider) changed() bool {
+	for _, path := range fp.paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(fp.modTime[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fp *FileProvider) reload() error {
+	var all []Event
+	modTime := make(map[string]time.Time, len(fp.paths))
+	for _, path := range fp.paths {
+		loaded, info, err := loadEventFile(path)
+		if err != nil {
+			return err
+		}
+		all = append(all, loaded...)
+		modTime[path] = info.ModTime()
+	}
+	fp.events = all
+	fp.modTime = modTime
+	return nil
+}
+
+func loadEventFile(path string) ([]Event, os.FileInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat event file: %w", err)
+	}
+
+	var loaded []Event
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		loaded, err = readEventCSV(file)
+	case ".json":
+		loaded, err = readEventJSON(file)
+	default:
+		return nil, nil, fmt.Errorf("unsupported event file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse event file %s: %w", path, err)
+	}
+	return loaded, info, nil
+}
+
+func readEventCSV(r io.Reader) ([]Event, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"date", "kind"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %s column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var loaded []Event
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		e, err := newEvent(field(record, "symbol"), field(record, "kind"), field(record, "date"))
+		if err != nil {
+			return nil, err
+		}
+		e.Timing = strings.ToLower(field(record, "timing"))
+		e.Description = field(record, "description")
+		if amount := field(record, "amount"); amount != "" {
+			if e.Amount, err = strconv.ParseFloat(amount, 64); err != nil {
+				return nil, fmt.Errorf("invalid amount %q: %w", amount, err)
+			}
+		}
+		loaded = append(loaded, e)
+	}
+	return loaded, nil
+}
+
+func readEventJSON(r io.Reader) ([]Event, error) {
+	var raw []struct {
+		Symbol      string  `json:"symbol"`
+		Kind        string  `json:"kind"`
+		Date        string  `json:"date"`
+		Timing      string  `json:"timing"`
+		Amount      float64 `json:"amount"`
+		Description string  `json:"description"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	loaded := make([]Event, 0, len(raw))
+	for _, item := range raw {
+		e, err := newEvent(item.Symbol, item.Kind, item.Date)
+		if err != nil {
+			return nil, err
+		}
+		e.Timing = strings.ToLower(item.Timing)
+		e.Amount = item.Amount
+		e.Description = item.Description
+		loaded = append(loaded, e)
+	}
+	return loaded, nil
+}
+
+func newEvent(symbol, kind, date string) (Event, error) {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return Event{}, fmt.Errorf("invalid event date %q: %w", date, err)
+	}
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	if kind == "" {
+		return Event{}, fmt.Errorf("event on %s has no kind", date)
+	}
+	return Event{Symbol: strings.ToUpper(strings.TrimSpace(symbol)), Kind: kind, Date: d}, nil
+}
@@ -0,0 +1,269 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/alpaca-trade-api-go/v3/alpaca"
+	"github.com/shopspring/decimal"
+)
+
+// AlpacaPaperURL is Alpaca's paper trading API
+const AlpacaPaperURL = "https://paper-api.alpaca.markets"
+
+// alpacaMaxLegs is the most legs Alpaca accepts in a multi-leg order
+const alpacaMaxLegs = 4
+
+// AlpacaConfig configures an AlpacaRouter. Empty keys are read from
+// APCA_API_KEY_ID and APCA_API_SECRET_KEY; an empty BaseURL is paper
+// trading.
+type AlpacaConfig struct {
+	APIKey    string
+	APISecret string
+	BaseURL   string
+}
+
+// AlpacaRouter routes orders to Alpaca's trading API, or to anything that
+// speaks it such as the alpacamock server. Single-leg orders go through
+// the SDK; multi-leg orders, which the SDK cannot build, are posted
+// directly.
+type AlpacaRouter struct {
+	client     *alpaca.Client
+	httpClient *http.Client
+	config     AlpacaConfig
+}
+
+// NewAlpacaRouter creates an AlpacaRouter
+func NewAlpacaRouter(config AlpacaConfig) *AlpacaRouter {
+	if config.APIKey == "" {
+		config.APIKey = os.Getenv("APCA_API_KEY_ID")
+	}
+	if config.APISecret == "" {
+		config.APISecret = os.Getenv("APCA_API_SECRET_KEY")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = AlpacaPaperURL
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	return &AlpacaRouter{
+		client: alpaca.NewClient(alpaca.ClientOpts{
+			APIKey:    config.APIKey,
+			APISecret: config.APISecret,
+			BaseURL:   config.BaseURL,
+		}),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		config:     config,
+	}
+}
+
+func (r *AlpacaRouter) Name() string {
+	return "alpaca"
+}
+
+func (r *AlpacaRouter) MaxLegs() int {
+	return alpacaMaxLegs
+}
+
+// Submit places req as a simple limit order, or as an mleg order when it
+// has several legs
+func (r *AlpacaRouter) Submit(ctx context.Context, req OrderRequest) (*BrokerOrder, error) {
+	if len(req.Legs) == 0 || req.Quantity <= 0 {
+		return nil, fmt.Errorf("order needs at least one leg and a quantity")
+	}
+	tif := req.TimeInForce
+	if tif == "" {
+		tif = TimeInForceDay
+	}
+	limit := decimal.NewFromFloat(req.LimitPrice).Round(2)
+
+	if len(req.Legs) > 1 {
+		return r.submitMultiLeg(ctx, req, tif, limit)
+	}
+
+	leg := req.Legs[0]
+	qty := decimal.NewFromInt(int64(req.Quantity * leg.Ratio))
+	order, err := r.client.PlaceOrder(alpaca.PlaceOrderRequest{
+		Symbol:        leg.Symbol,
+		Qty:           &qty,
+		Side:          alpaca.Side(leg.Side),
+		Type:          alpaca.Limit,
+		TimeInForce:   alpaca.TimeInForce(tif),
+		LimitPrice:    &limit,
+		ClientOrderID: req.ClientOrderID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to place %s order: %w", leg.Symbol, err)
+	}
+	return fromAlpacaOrder(order), nil
+}
+
+// alpacaLeg is one leg of an mleg order request
+type alpacaLeg struct {
+	Symbol         string `json:"symbol"`
+	RatioQty       string `json:"ratio_qty"`
+	Side           string `json:"side"`
+	PositionIntent string `json:"position_intent"`
+}
+
+// alpacaMultiLegRequest is the body of an mleg order
+type alpacaMultiLegRequest struct {
+	OrderClass    string      `json:"order_class"`
+	Qty           string      `json:"qty"`
+	Type          string      `json:"type"`
+	LimitPrice    string      `json:"limit_price"`
+	TimeInForce   string      `json:"time_in_force"`
+	ClientOrderID string      `json:"client_order_id,omitempty"`
+	Legs          []alpacaLeg `json:"legs"`
+}
+
+func (r *AlpacaRouter) submitMultiLeg(ctx context.Context, req OrderRequest, tif string, limit decimal.Decimal) (*BrokerOrder, error) {
+	if len(req.Legs) > alpacaMaxLegs {
+		return nil, fmt.Errorf("alpaca accepts at most %d legs in one order, got %d", alpacaMaxLegs, len(req.Legs))
+	}
+	body := alpacaMultiLegRequest{
+		OrderClass:    "mleg",
+		Qty:           fmt.Sprint(req.Quantity),
+		Type:          string(alpaca.Limit),
+		LimitPrice:    limit.String(),
+		TimeInForce:   tif,
+		ClientOrderID: req.ClientOrderID,
+	}
+	for _, leg := range req.Legs {
+		body.Legs = append(body.Legs, alpacaLeg{
+			Symbol:         leg.Symbol,
+			RatioQty:       fmt.Sprint(leg.Ratio),
+			Side:           leg.Side,
+			PositionIntent: leg.Side + "_to_" + leg.Intent,
+		})
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.BaseURL+"/v2/orders", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("APCA-API-KEY-ID", r.config.APIKey)
+	httpReq.Header.Set("APCA-API-SECRET-KEY", r.config.APISecret)
+
+	resp, err := r.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to place multi-leg order: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("failed to place multi-leg order: %w", alpaca.APIErrorFromResponse(resp))
+	}
+
+	var order alpaca.Order
+	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
+		return nil, fmt.Errorf("failed to parse multi-leg order: %w", err)
+	}
+	return fromAlpacaOrder(&order), nil
+}
+
+func (r *AlpacaRouter) Get(ctx context.Context, id string) (*BrokerOrder, error) {
+	order, err := r.client.GetOrder(id)
+	if err != nil {
+		return nil, alpacaError("get", id, err)
+	}
+	return fromAlpacaOrder(order), nil
+}
+
+func (r *AlpacaRouter) Cancel(ctx context.Context, id string) error {
+	if err := r.client.CancelOrder(id); err != nil {
+		return alpacaError("cancel", id, err)
+	}
+	return nil
+}
+
+func (r *AlpacaRouter) Replace(ctx context.Context, id string, quantity int, limitPrice float64) (*BrokerOrder, error) {
+	qty := decimal.NewFromInt(int64(quantity))
+	limit := decimal.NewFromFloat(limitPrice).Round(2)
+	order, err := r.client.ReplaceOrder(id, alpaca.ReplaceOrderRequest{Qty: &qty, LimitPrice: &limit})
+	if err != nil {
+		return nil, alpacaError("replace", id, err)
+	}
+	return fromAlpacaOrder(order), nil
+}
+
+// alpacaError maps Alpaca's 404 and 422 responses to ErrOrderNotFound and
+// ErrNotCancelable
+func alpacaError(action, id string, err error) error {
+	var apiErr *alpaca.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusNotFound:
+			return fmt.Errorf("failed to %s order %s: %w", action, id, ErrOrderNotFound)
+		case http.StatusUnprocessableEntity:
+			return fmt.Errorf("failed to %s order %s: %w: %s", action, id, ErrNotCancelable, apiErr.Message)
+		}
+	}
+	return fmt.Errorf("failed to %s order %s: %w", action, id, err)
+}
+
+// fromAlpacaOrder converts an Alpaca order; orders in Alpaca's transitional
+// statuses are still working
+func fromAlpacaOrder(o *alpaca.Order) *BrokerOrder {
+	order := &BrokerOrder{
+		ID:            o.ID,
+		ClientOrderID: o.ClientOrderID,
+		Status:        alpacaStatus(o.Status),
+		FilledQty:     int(o.FilledQty.IntPart()),
+		UpdatedAt:     o.UpdatedAt,
+	}
+	if o.Qty != nil {
+		order.Quantity = int(o.Qty.IntPart())
+	}
+	if o.LimitPrice != nil {
+		order.LimitPrice = o.LimitPrice.InexactFloat64()
+	}
+	if o.FilledAvgPrice != nil {
+		order.AvgFillPrice = o.FilledAvgPrice.InexactFloat64()
+	}
+	if o.ReplacedBy != nil {
+		order.ReplacedBy = *o.ReplacedBy
+	}
+	if order.Status == StatusNew && order.FilledQty > 0 {
+		order.Status = StatusPartiallyFilled // e.g. pending_cancel after a partial fill
+	}
+	for _, l := range o.Legs {
+		leg := Leg{Symbol: l.Symbol, Side: string(l.Side), FilledQty: int(l.FilledQty.IntPart())}
+		if l.FilledAvgPrice != nil {
+			leg.AvgFillPrice = l.FilledAvgPrice.InexactFloat64()
+		}
+		order.Legs = append(order.Legs, leg)
+	}
+	return order
+}
+
+func alpacaStatus(status string) string {
+	switch status {
+	case "partially_filled":
+		return StatusPartiallyFilled
+	case "filled":
+		return StatusFilled
+	case "canceled":
+		return StatusCanceled
+	case "replaced":
+		return StatusReplaced
+	case "rejected":
+		return StatusRejected
+	case "expired", "done_for_day":
+		return StatusExpired
+	}
+	// new, accepted, pending_new, pending_cancel, pending_replace and the
+	// rest are still working
+	return StatusNew
+}
@@ -0,0 +1,376 @@
+// Package alpacamock serves the part of Alpaca's trading API that the
+// execution package uses, so orders can be exercised without an Alpaca
+// account. Orders are limit orders held in memory; they fill at their limit
+// price as they are polled.
+package alpacamock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// maxLegs is the most legs an mleg order may have
+const maxLegs = 4
+
+// Server is an in-memory Alpaca trading API. Each GET of a working order
+// fills another 1/FillSteps of it; with FillSteps 0 orders only fill
+// through Fill.
+type Server struct {
+	mu        sync.Mutex
+	fillSteps int
+	seq       int
+	orders    map[string]*order
+	byClient  map[string]string
+	now       func() time.Time
+}
+
+type order struct {
+	ID             string
+	ClientOrderID  string
+	Symbol         string
+	OrderClass     string
+	Side           string
+	TimeInForce    string
+	Status         string
+	Qty            int
+	FilledQty      int
+	LimitPrice     decimal.Decimal
+	FilledAvgPrice decimal.Decimal
+	Legs           []*leg
+	ReplacedBy     string
+	Replaces       string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type leg struct {
+	Symbol         string
+	Side           string
+	PositionIntent string
+	Ratio          int
+}
+
+// orderRequest is the body of POST /v2/orders and PATCH /v2/orders/{id}
+type orderRequest struct {
+	Symbol        string           `json:"symbol"`
+	Qty           *decimal.Decimal `json:"qty"`
+	Side          string           `json:"side"`
+	Type          string           `json:"type"`
+	TimeInForce   string           `json:"time_in_force"`
+	LimitPrice    *decimal.Decimal `json:"limit_price"`
+	ClientOrderID string           `json:"client_order_id"`
+	OrderClass    string           `json:"order_class"`
+	Legs          []struct {
+		Symbol         string          `json:"symbol"`
+		RatioQty       decimal.Decimal `json:"ratio_qty"`
+		Side           string          `json:"side"`
+		PositionIntent string          `json:"position_intent"`
+	} `json:"legs"`
+}
+
+// NewServer creates a Server filling orders over fillSteps polls
+func NewServer(fillSteps int) *Server {
+	return &Server{
+		fillSteps: fillSteps,
+		orders:    make(map[string]*order),
+		byClient:  make(map[string]string),
+		now:       time.Now,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("APCA-API-KEY-ID") == "" && r.Header.Get("Authorization") == "" {
+		writeError(w, http.StatusUnauthorized, 40110000, "request is not authorized")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case path == "orders" && r.Method == http.MethodPost:
+		s.place(w, r)
+	case path == "orders:by_client_order_id" && r.Method == http.MethodGet:
+		s.mu.Lock()
+		id := s.byClient[r.URL.Query().Get("client_order_id")]
+		s.mu.Unlock()
+		s.get(w, id)
+	case strings.HasPrefix(path, "orders/"):
+		id := strings.TrimPrefix(path, "orders/")
+		switch r.Method {
+		case http.MethodGet:
+			s.get(w, id)
+		case http.MethodDelete:
+			s.cancel(w, id)
+		case http.MethodPatch:
+			s.replace(w, r, id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		writeError(w, http.StatusNotFound, 40410000, "endpoint not found")
+	}
+}
+
+func (s *Server) place(w http.ResponseWriter, r *http.Request) {
+	var req orderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, 40010000, "invalid order: "+err.Error())
+		return
+	}
+	if req.Type != "limit" || req.LimitPrice == nil {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "only limit orders are supported")
+		return
+	}
+	if req.Qty == nil || !req.Qty.IsInteger() || !req.Qty.IsPositive() {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "qty must be a positive whole number")
+		return
+	}
+
+	o := &order{
+		ClientOrderID: req.ClientOrderID,
+		Symbol:        req.Symbol,
+		OrderClass:    "simple",
+		Side:          req.Side,
+		TimeInForce:   req.TimeInForce,
+		Qty:           int(req.Qty.IntPart()),
+		LimitPrice:    *req.LimitPrice,
+	}
+	if req.OrderClass == "mleg" {
+		if len(req.Legs) < 2 || len(req.Legs) > maxLegs {
+			writeError(w, http.StatusUnprocessableEntity, 42210000, fmt.Sprintf("mleg orders need 2 to %d legs", maxLegs))
+			return
+		}
+		o.OrderClass, o.Symbol, o.Side = "mleg", "", ""
+		for _, l := range req.Legs {
+			if l.Symbol == "" || (l.Side != "buy" && l.Side != "sell") || !l.RatioQty.IsPositive() {
+				writeError(w, http.StatusUnprocessableEntity, 42210000, "each leg needs a symbol, side and ratio_qty")
+				return
+			}
+			o.Legs = append(o.Legs, &leg{Symbol: l.Symbol, Side: l.Side, PositionIntent: l.PositionIntent, Ratio: int(l.RatioQty.IntPart())})
+		}
+	} else if req.Symbol == "" || (req.Side != "buy" && req.Side != "sell") {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "symbol and side are required")
+		return
+	} else if req.LimitPrice.IsNegative() {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "limit_price must be positive")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o.ClientOrderID != "" {
+		if _, ok := s.byClient[o.ClientOrderID]; ok {
+			writeError(w, http.StatusUnprocessableEntity, 42210000, "client_order_id must be unique")
+			return
+		}
+	}
+	s.add(o)
+	writeJSON(w, http.StatusOK, o.view())
+}
+
+// add assigns o an ID and stores it; callers hold s.mu
+func (s *Server) add(o *order) {
+	s.seq++
+	o.ID = fmt.Sprintf("mock-%06d", s.seq)
+	if o.ClientOrderID == "" {
+		o.ClientOrderID = o.ID
+	}
+	o.Status = "new"
+	o.CreatedAt = s.now()
+	o.UpdatedAt = o.CreatedAt
+	s.orders[o.ID] = o
+	s.byClient[o.ClientOrderID] = o.ID
+}
+
+func (s *Server) get(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, 40410000, "order not found")
+		return
+	}
+	if s.fillSteps > 0 && o.working() {
+		step := (o.Qty + s.fillSteps - 1) / s.fillSteps
+		o.fill(min(step, o.Qty-o.FilledQty), o.LimitPrice, s.now())
+	}
+	writeJSON(w, http.StatusOK, o.view())
+}
+
+func (s *Server) cancel(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, 40410000, "order not found")
+		return
+	}
+	if !o.working() {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "order is not cancelable")
+		return
+	}
+	o.Status = "canceled"
+	o.UpdatedAt = s.now()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) replace(w http.ResponseWriter, r *http.Request, id string) {
+	var req orderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, 40010000, "invalid replacement: "+err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.orders[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, 40410000, "order not found")
+		return
+	}
+	if !old.working() {
+		writeError(w, http.StatusUnprocessableEntity, 42210000, "order is not replaceable")
+		return
+	}
+
+	next := &order{
+		ClientOrderID: req.ClientOrderID,
+		Symbol:        old.Symbol,
+		OrderClass:    old.OrderClass,
+		Side:          old.Side,
+		TimeInForce:   old.TimeInForce,
+		Qty:           old.Qty - old.FilledQty,
+		LimitPrice:    old.LimitPrice,
+		Legs:          old.Legs,
+		Replaces:      old.ID,
+	}
+	if req.Qty != nil && req.Qty.IsPositive() {
+		next.Qty = int(req.Qty.IntPart())
+	}
+	if req.LimitPrice != nil {
+		next.LimitPrice = *req.LimitPrice
+	}
+	if req.TimeInForce != "" {
+		next.TimeInForce = req.TimeInForce
+	}
+	s.add(next)
+	old.Status = "replaced"
+	old.ReplacedBy = next.ID
+	old.UpdatedAt = next.CreatedAt
+	writeJSON(w, http.StatusOK, next.view())
+}
+
+// Fill fills qty more of working order id at price, as a broker would
+func (s *Server) Fill(id string, qty int, price float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return fmt.Errorf("order %s not found", id)
+	}
+	if !o.working() || qty <= 0 || qty > o.Qty-o.FilledQty {
+		return fmt.Errorf("order %s cannot fill %d more", id, qty)
+	}
+	o.fill(qty, decimal.NewFromFloat(price), s.now())
+	return nil
+}
+
+// Reject rejects working order id
+func (s *Server) Reject(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok || !o.working() {
+		return fmt.Errorf("order %s is not working", id)
+	}
+	o.Status = "rejected"
+	o.UpdatedAt = s.now()
+	return nil
+}
+
+func (o *order) working() bool {
+	return o.Status == "new" || o.Status == "partially_filled"
+}
+
+func (o *order) fill(qty int, price decimal.Decimal, now time.Time) {
+	filled := decimal.NewFromInt(int64(o.FilledQty))
+	total := filled.Add(decimal.NewFromInt(int64(qty)))
+	o.FilledAvgPrice = o.FilledAvgPrice.Mul(filled).Add(price.Mul(decimal.NewFromInt(int64(qty)))).Div(total).Round(4)
+	o.FilledQty += qty
+	o.Status = "partially_filled"
+	if o.FilledQty >= o.Qty {
+		o.Status = "filled"
+	}
+	o.UpdatedAt = now
+}
+
+// view renders o as Alpaca does, with quantities and prices as strings.
+// Legs report their fills but, unlike Alpaca, not their prices.
+func (o *order) view() map[string]interface{} {
+	v := map[string]interface{}{
+		"id":               o.ID,
+		"client_order_id":  o.ClientOrderID,
+		"created_at":       o.CreatedAt,
+		"updated_at":       o.UpdatedAt,
+		"submitted_at":     o.CreatedAt,
+		"symbol":           o.Symbol,
+		"asset_class":      "us_option",
+		"order_class":      o.OrderClass,
+		"type":             "limit",
+		"side":             o.Side,
+		"time_in_force":    o.TimeInForce,
+		"status":           o.Status,
+		"qty":              fmt.Sprint(o.Qty),
+		"filled_qty":       fmt.Sprint(o.FilledQty),
+		"limit_price":      o.LimitPrice.String(),
+		"filled_avg_price": nil,
+		"replaced_by":      nil,
+		"replaces":         nil,
+	}
+	if o.FilledQty > 0 {
+		v["filled_avg_price"] = o.FilledAvgPrice.String()
+	}
+	if o.ReplacedBy != "" {
+		v["replaced_by"] = o.ReplacedBy
+	}
+	if o.Replaces != "" {
+		v["replaces"] = o.Replaces
+	}
+	if len(o.Legs) > 0 {
+		legs := make([]map[string]interface{}, len(o.Legs))
+		for i, l := range o.Legs {
+			legs[i] = map[string]interface{}{
+				"symbol":          l.Symbol,
+				"side":            l.Side,
+				"position_intent": l.PositionIntent,
+				"ratio_qty":       fmt.Sprint(l.Ratio),
+				"qty":             fmt.Sprint(l.Ratio * o.Qty),
+				"filled_qty":      fmt.Sprint(l.Ratio * o.FilledQty),
+				"status":          o.Status,
+			}
+		}
+		v["legs"] = legs
+	}
+	return v
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	writeJSON(w, status, map[string]interface{}{"code": code, "message": message})
+}
@@ -0,0 +1,121 @@
+// Package execution routes approved AI trades to a broker as limit orders
+// and tracks them through fills, cancels and replacements.
+package execution
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Order sides
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// Position intents
+const (
+	IntentOpen  = "open"
+	IntentClose = "close"
+)
+
+// Order statuses, shared by broker orders and the orders built from them
+const (
+	StatusNew             = "new" // Accepted and working
+	StatusPartiallyFilled = "partially_filled"
+	StatusFilled          = "filled"
+	StatusCanceled        = "canceled"
+	StatusReplaced        = "replaced"
+	StatusRejected        = "rejected"
+	StatusExpired         = "expired"
+)
+
+// Time in force values
+const (
+	TimeInForceDay = "day"
+	TimeInForceGTC = "gtc"
+)
+
+var (
+	ErrOrderNotFound    = errors.New("order not found")
+	ErrNotApproved      = errors.New("proposal is not approved")
+	ErrAlreadyExecuted  = errors.New("proposal already has an order")
+	ErrNotCancelable    = errors.New("order is no longer working")
+	ErrUnstructuredLegs = errors.New("trade has no structured legs")
+	ErrInvalidOrder     = errors.New("trade cannot be ordered")
+)
+
+// IsTerminal reports whether an order in status can no longer fill
+func IsTerminal(status string) bool {
+	switch status {
+	case StatusFilled, StatusCanceled, StatusReplaced, StatusRejected, StatusExpired:
+		return true
+	}
+	return false
+}
+
+// Leg is one leg of an order. Symbol is an OCC option symbol or a stock
+// symbol; Ratio is the contracts (or shares) per unit of the order.
+type Leg struct {
+	Symbol       string  `json:"symbol"`
+	Side         string  `json:"side"`
+	Intent       string  `json:"intent"`
+	Ratio        int     `json:"ratio"`
+	Multiplier   int     `json:"multiplier"`            // 100 for options, 1 for stock
+	LimitPrice   float64 `json:"limit_price,omitempty"` // Per share
+	FilledQty    int     `json:"filled_qty"`            // Contracts or shares
+	AvgFillPrice float64 `json:"avg_fill_price,omitempty"`
+}
+
+// sign is +1 for bought legs and -1 for sold legs
+func (l Leg) sign() float64 {
+	if l.Side == SideSell {
+		return -1
+	}
+	return 1
+}
+
+// OrderRequest is a limit order for a broker. LimitPrice is per share; for
+// an order with several legs it is the net price, positive for a debit and
+// negative for a credit. UserID names the account for brokers that keep one
+// per user; the rest trade a single account and ignore it.
+type OrderRequest struct {
+	ClientOrderID string
+	UserID        string
+	Legs          []Leg
+	Quantity      int
+	LimitPrice    float64
+	TimeInForce   string
+}
+
+// BrokerOrder is a broker's view of one order. Leg fills are in contracts
+// or shares; an order with one leg may report its fills on the order alone.
+type BrokerOrder struct {
+	ID            string    `json:"id"`
+	ClientOrderID string    `json:"client_order_id"`
+	Status        string    `json:"status"`
+	Quantity      int       `json:"quantity"`
+	FilledQty     int       `json:"filled_qty"`
+	LimitPrice    float64   `json:"limit_price"`
+	AvgFillPrice  float64   `json:"avg_fill_price,omitempty"`
+	Legs          []Leg     `json:"legs,omitempty"`
+	ReplacedBy    string    `json:"replaced_by,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// OrderRouter sends limit orders to a broker
+type OrderRouter interface {
+	// Name identifies the broker, e.g. "alpaca"
+	Name() string
+	// MaxLegs is the most legs Submit accepts in one order; brokers
+	// without multi-leg orders return 1
+	MaxLegs() int
+	Submit(ctx context.Context, req OrderRequest) (*BrokerOrder, error)
+	Get(ctx context.Context, id string) (*BrokerOrder, error)
+	Cancel(ctx context.Context, id string) error
+	// Replace replaces a working order with one for quantity at
+	// limitPrice, returning the new order
+	Replace(ctx context.Context, id string, quantity int, limitPrice float64) (*BrokerOrder, error)
+}
@@ -0,0 +1,633 @@
+package execution
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// RecordUpdater marks a recommendation executed once its order first
+// fills; *ai_assistant.PerformanceTracker is one
+type RecordUpdater interface {
+	UpdateExecution(recordID string, executed bool) error
+}
+
+// PnLRecorder is told the P&L each closing fill realizes, with the
+// account's value when known or 0; *ai_assistant.CircuitBreaker is one
+type PnLRecorder interface {
+	RecordRealizedPnL(userID string, accountValue, amount float64) (*ai_assistant.DailyPnL, error)
+}
+
+// Config controls how orders are worked
+type Config struct {
+	TimeInForce string
+	// RepriceAfter cancels and replaces a working ticket that has not
+	// filled for this long, RepriceStep per share closer to the other side
+	// of the market, up to MaxReprices times; 0 never reprices
+	RepriceAfter time.Duration
+	RepriceStep  float64
+	MaxReprices  int
+}
+
+// DefaultConfig works day orders, repricing a nickel every 30 seconds up
+// to three times
+func DefaultConfig() Config {
+	return Config{
+		TimeInForce:  TimeInForceDay,
+		RepriceAfter: 30 * time.Second,
+		RepriceStep:  0.05,
+		MaxReprices:  3,
+	}
+}
+
+// ExecuteRequest asks for an approved proposal's trade to be sent
+type ExecuteRequest struct {
+	Proposal  *ai_assistant.Proposal
+	LegPrices []float64 // Per share limit for each of the trade's legs, usually the mid
+	Legged    bool      // One order per leg even if the broker takes multi-leg orders
+}
+
+// Manager sends approved trades through an OrderRouter and follows them
+// until they fill or stop working. Orders and their fills are persisted to
+// a JSON file.
+type Manager struct {
+	mu      sync.Mutex
+	router  OrderRouter
+	path    string
+	records RecordUpdater
+	pnl     PnLRecorder
+	config  Config
+	logger  *logrus.Logger
+	now     func() time.Time
+	orders  map[string]*Order
+}
+
+// NewManager loads orders from path, starting empty if the file does not
+// exist. An empty path keeps them in memory only. records and pnl may be
+// nil; pnl should be for routers that do not report realized P&L
+// themselves.
+func NewManager(router OrderRouter, path string, records RecordUpdater, pnl PnLRecorder, config Config, logger *logrus.Logger) (*Manager, error) {
+	if config.TimeInForce == "" {
+		config.TimeInForce = TimeInForceDay
+	}
+	m := &Manager{
+		router:  router,
+		path:    path,
+		records: records,
+		pnl:     pnl,
+		config:  config,
+		logger:  logger,
+		now:     time.Now,
+		orders:  make(map[string]*Order),
+	}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read orders: %w", err)
+	}
+	var orders []*Order
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, fmt.Errorf("failed to parse orders: %w", err)
+	}
+	for _, o := range orders {
+		m.orders[o.ID] = o
+	}
+	return m, nil
+}
+
+// Broker names the broker orders are routed to
+func (m *Manager) Broker() string {
+	return m.router.Name()
+}
+
+// Execute sends the trade of an approved proposal as limit orders at the
+// requested leg prices. A proposal gets one order unless an earlier one
+// ended without filling. If a legged order cannot be fully submitted, the
+// legs already sent are canceled.
+func (m *Manager) Execute(ctx context.Context, req ExecuteRequest) (*Order, error) {
+	p := req.Proposal
+	if p.State != ai_assistant.ProposalApproved {
+		return nil, fmt.Errorf("%w: %s", ErrNotApproved, p.State)
+	}
+	o, err := newOrder(newOrderID(), p, req.LegPrices, req.Legged, m.router.Name(), m.router.MaxLegs(), m.now())
+	if errors.Is(err, ErrUnstructuredLegs) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+
+	m.mu.Lock()
+	for _, existing := range m.orders {
+		if existing.ProposalID == p.ID && (!IsTerminal(existing.Status) || len(existing.Fills) > 0) {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyExecuted, existing.ID)
+		}
+	}
+	// Holding the order reserves the proposal while its tickets are sent
+	m.orders[o.ID] = o
+	m.mu.Unlock()
+
+	tickets := o.openingTickets()
+	submitted := make([]*BrokerOrder, 0, len(tickets))
+	var submitErr error
+	for _, t := range tickets {
+		bo, err := m.router.Submit(ctx, o.request(t, m.config.TimeInForce))
+		if err != nil {
+			submitErr = err
+			break
+		}
+		submitted = append(submitted, bo)
+	}
+	if submitErr != nil {
+		for _, bo := range submitted {
+			if err := m.router.Cancel(ctx, bo.ID); err != nil && !errors.Is(err, ErrNotCancelable) {
+				m.logger.WithError(err).WithField("order_id", o.ID).Error("Failed to cancel leg of partly submitted order")
+			}
+		}
+	}
+
+	m.mu.Lock()
+	now := m.now()
+	executed := false
+	for i, bo := range submitted {
+		t := tickets[i]
+		t.BrokerOrderID = bo.ID
+		t.SubmittedAt = now
+		o.Tickets = append(o.Tickets, t)
+		executed = m.update(o, t, bo, now) || executed
+	}
+	o.refresh(now)
+	if submitErr != nil {
+		o.Message = fmt.Sprintf("Failed to submit: %v", submitErr)
+		if len(submitted) > 0 {
+			o.Message += fmt.Sprintf("; %d submitted legs canceled", len(submitted))
+		}
+	}
+	snapshot := o.snapshot()
+	saveErr := m.save()
+	m.mu.Unlock()
+
+	if executed {
+		m.markExecuted(snapshot)
+	}
+	if submitErr != nil {
+		return snapshot, fmt.Errorf("failed to submit order %s: %w", o.ID, submitErr)
+	}
+	return snapshot, saveErr
+}
+
+// repriceJob replaces one working ticket
+type repriceJob struct {
+	orderID  string
+	brokerID string
+	quantity int
+	limit    float64
+}
+
+// Poll refreshes every working ticket from the broker, records new fills,
+// cancels the rest of a legged order when one of its legs is rejected, and
+// reprices tickets that have stopped filling
+func (m *Manager) Poll(ctx context.Context) error {
+	m.mu.Lock()
+	owners := make(map[string]string)
+	for _, o := range m.orders {
+		for _, t := range o.liveTickets() {
+			owners[t.BrokerOrderID] = o.ID
+		}
+	}
+	m.mu.Unlock()
+	if len(owners) == 0 {
+		return nil
+	}
+
+	var errs []error
+	updates := make(map[string]*BrokerOrder, len(owners))
+	for id := range owners {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		bo, err := m.router.Get(ctx, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		updates[id] = bo
+	}
+
+	m.mu.Lock()
+	now := m.now()
+	touched := make(map[string]*Order)
+	var executed []*Order
+	for id, bo := range updates {
+		o := m.orders[owners[id]]
+		t := o.ticket(id)
+		if t == nil {
+			continue
+		}
+		if m.update(o, t, bo, now) {
+			executed = append(executed, o)
+		}
+		touched[o.ID] = o
+	}
+
+	var cancels []string
+	var reprices []repriceJob
+	for _, o := range touched {
+		o.refresh(now)
+		if ids := o.unwindRejectedLegs(); len(ids) > 0 {
+			cancels = append(cancels, ids...)
+			continue
+		}
+		if o.Message != "" {
+			continue
+		}
+		reprices = append(reprices, m.dueReprices(o, now)...)
+	}
+	if err := m.save(); err != nil {
+		errs = append(errs, err)
+	}
+	snapshots := make([]*Order, len(executed))
+	for i, o := range executed {
+		snapshots[i] = o.snapshot()
+	}
+	m.mu.Unlock()
+
+	for _, o := range snapshots {
+		m.markExecuted(o)
+	}
+	for _, id := range cancels {
+		if err := m.router.Cancel(ctx, id); err != nil && !errors.Is(err, ErrNotCancelable) {
+			errs = append(errs, err)
+		}
+	}
+	for _, job := range reprices {
+		if err := m.replace(ctx, job); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// unwindRejectedLegs returns the working tickets of a legged order to
+// cancel once one of its legs is rejected, noting why on the order
+func (o *Order) unwindRejectedLegs() []string {
+	if !o.Legged || o.Message != "" {
+		return nil
+	}
+	for _, t := range o.Tickets {
+		if t.Status != StatusRejected {
+			continue
+		}
+		var ids []string
+		for _, live := range o.liveTickets() {
+			ids = append(ids, live.BrokerOrderID)
+		}
+		o.Message = fmt.Sprintf("%s leg rejected; remaining legs canceled", o.Legs[t.Legs[0]].Symbol)
+		if len(o.Fills) > 0 {
+			o.Message += "; filled legs are open without the rest of the structure"
+		}
+		return ids
+	}
+	return nil
+}
+
+// dueReprices returns the repricing due on o's working tickets
+func (m *Manager) dueReprices(o *Order, now time.Time) []repriceJob {
+	if m.config.RepriceAfter <= 0 || m.config.RepriceStep <= 0 {
+		return nil
+	}
+	var jobs []repriceJob
+	for _, t := range o.liveTickets() {
+		last := t.SubmittedAt
+		if t.LastFillAt != nil && t.LastFillAt.After(last) {
+			last = *t.LastFillAt
+		}
+		remaining := o.remaining(t)
+		if t.Reprices >= m.config.MaxReprices || now.Sub(last) < m.config.RepriceAfter || t.Quantity == 0 || remaining <= 0 {
+			continue
+		}
+		if limit, ok := o.reprice(t, m.config.RepriceStep); ok {
+			jobs = append(jobs, repriceJob{orderID: o.ID, brokerID: t.BrokerOrderID, quantity: remaining, limit: limit})
+		}
+	}
+	return jobs
+}
+
+// replace cancels and replaces a ticket for its remaining quantity at a
+// new limit, then collects any fills the old ticket got before it went
+func (m *Manager) replace(ctx context.Context, job repriceJob) error {
+	bo, err := m.router.Replace(ctx, job.brokerID, job.quantity, job.limit)
+	if err != nil {
+		if errors.Is(err, ErrNotCancelable) {
+			return nil // Filled or canceled since it was polled
+		}
+		return err
+	}
+	final, finalErr := m.router.Get(ctx, job.brokerID)
+
+	m.mu.Lock()
+	o := m.orders[job.orderID]
+	old := o.ticket(job.brokerID)
+	now := m.now()
+
+	t := o.newTicket(len(o.Tickets)+1, old.Legs, job.quantity, job.limit)
+	t.ClientOrderID = bo.ClientOrderID
+	t.BrokerOrderID = bo.ID
+	t.Reprices = old.Reprices + 1
+	t.Replaces = old.BrokerOrderID
+	t.SubmittedAt = now
+	o.Tickets = append(o.Tickets, t)
+	executed := m.update(o, t, bo, now)
+
+	if finalErr == nil {
+		executed = m.update(o, old, final, now) || executed
+	}
+	old.Status = StatusReplaced
+	if len(t.Legs) > 1 {
+		o.LimitPrice = job.limit
+	} else {
+		o.Legs[t.Legs[0]].LimitPrice = job.limit
+		o.LimitPrice = o.netPrice(func(l Leg) float64 { return l.LimitPrice })
+	}
+	o.refresh(now)
+	snapshot := o.snapshot()
+	err = m.save()
+	m.mu.Unlock()
+
+	m.logger.WithFields(logrus.Fields{
+		"order_id":    o.ID,
+		"replaced":    job.brokerID,
+		"replacement": bo.ID,
+		"limit":       job.limit,
+		"quantity":    job.quantity,
+	}).Info("Repriced working order")
+
+	if executed {
+		m.markExecuted(snapshot)
+	}
+	return err
+}
+
+// update applies the broker's view of ticket t, following a replacement
+// made outside the manager, and reports whether o filled for the first
+// time; callers hold m.mu
+func (m *Manager) update(o *Order, t *Ticket, bo *BrokerOrder, now time.Time) bool {
+	fills := o.apply(t, bo, now)
+	if bo.Status == StatusReplaced && bo.ReplacedBy != "" && o.ticket(bo.ReplacedBy) == nil {
+		// Quantity and limit are taken from the broker on the next poll
+		next := o.newTicket(len(o.Tickets)+1, t.Legs, 0, 0)
+		next.ClientOrderID = ""
+		next.BrokerOrderID = bo.ReplacedBy
+		next.Status = StatusNew
+		next.Reprices = t.Reprices
+		next.Replaces = t.BrokerOrderID
+		next.SubmittedAt = now
+		o.Tickets = append(o.Tickets, next)
+	}
+	for _, fill := range fills {
+		m.logger.WithFields(logrus.Fields{
+			"order_id":  o.ID,
+			"record_id": o.RecordID,
+			"symbol":    fill.Symbol,
+			"side":      fill.Side,
+			"quantity":  fill.Quantity,
+			"price":     fill.Price,
+		}).Info("Order filled")
+		if fill.Intent == IntentClose {
+			m.recordRealized(o, fill)
+		}
+	}
+	if len(fills) == 0 || o.ExecutedAt != nil {
+		return false
+	}
+	o.ExecutedAt = &now
+	return true
+}
+
+// recordRealized reports the P&L of closing fill of o against the average
+// price of its user's opening fills in the symbol. Fills closing positions
+// opened elsewhere have no cost to realize against. Callers hold m.mu.
+func (m *Manager) recordRealized(o *Order, fill Fill) {
+	if m.pnl == nil {
+		return
+	}
+	cost, opened := 0.0, 0
+	for _, other := range m.orders {
+		if other.UserID != o.UserID {
+			continue
+		}
+		for _, f := range other.Fills {
+			if f.Intent == IntentOpen && f.Symbol == fill.Symbol && f.Side != fill.Side {
+				cost += f.Price * float64(f.Quantity)
+				opened += f.Quantity
+			}
+		}
+	}
+	if opened == 0 {
+		m.logger.WithFields(logrus.Fields{"order_id": o.ID, "symbol": fill.Symbol}).Debug("Closing fill has no opening fill to realize against")
+		return
+	}
+
+	multiplier := 100
+	for _, leg := range o.Legs {
+		if leg.Symbol == fill.Symbol {
+			multiplier = leg.Multiplier
+		}
+	}
+	realized := float64(fill.Quantity*multiplier) * (fill.Price - cost/float64(opened))
+	if fill.Side == SideBuy {
+		realized = -realized
+	}
+	if _, err := m.pnl.RecordRealizedPnL(o.UserID, 0, round2(realized)); err != nil {
+		m.logger.WithError(err).WithField("user_id", o.UserID).Error("Failed to record realized P&L")
+	}
+}
+
+// markExecuted marks o's recommendation record executed
+func (m *Manager) markExecuted(o *Order) {
+	if m.records == nil || o.RecordID == "" {
+		return
+	}
+	if err := m.records.UpdateExecution(o.RecordID, true); err != nil {
+		m.logger.WithError(err).WithField("record_id", o.RecordID).Warn("Failed to mark recommendation executed")
+	}
+}
+
+// Cancel cancels the working tickets of order id, which must belong to
+// userID unless userID is empty
+func (m *Manager) Cancel(ctx context.Context, id, userID string) (*Order, error) {
+	m.mu.Lock()
+	o, ok := m.orders[id]
+	if !ok || (userID != "" && o.UserID != userID) {
+		m.mu.Unlock()
+		return nil, ErrOrderNotFound
+	}
+	var live []string
+	for _, t := range o.liveTickets() {
+		live = append(live, t.BrokerOrderID)
+	}
+	m.mu.Unlock()
+	if len(live) == 0 {
+		return nil, ErrNotCancelable
+	}
+
+	var errs []error
+	updates := make(map[string]*BrokerOrder, len(live))
+	for _, brokerID := range live {
+		if err := m.router.Cancel(ctx, brokerID); err != nil && !errors.Is(err, ErrNotCancelable) {
+			errs = append(errs, err)
+			continue
+		}
+		if bo, err := m.router.Get(ctx, brokerID); err == nil {
+			updates[brokerID] = bo
+		}
+	}
+
+	m.mu.Lock()
+	now := m.now()
+	executed := false
+	for brokerID, bo := range updates {
+		if t := o.ticket(brokerID); t != nil {
+			executed = m.update(o, t, bo, now) || executed
+		}
+	}
+	if len(errs) == 0 && o.Message == "" {
+		o.Message = "Canceled by user"
+	}
+	o.refresh(now)
+	snapshot := o.snapshot()
+	if err := m.save(); err != nil {
+		errs = append(errs, err)
+	}
+	m.mu.Unlock()
+
+	if executed {
+		m.markExecuted(snapshot)
+	}
+	return snapshot, errors.Join(errs...)
+}
+
+// Get returns order id, which must belong to userID unless userID is empty
+func (m *Manager) Get(id, userID string) (*Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	o, ok := m.orders[id]
+	if !ok || (userID != "" && o.UserID != userID) {
+		return nil, ErrOrderNotFound
+	}
+	return o.snapshot(), nil
+}
+
+// List returns userID's orders, or everyone's if userID is empty, newest
+// first
+func (m *Manager) List(userID string) []*Order {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var orders []*Order
+	for _, o := range m.orders {
+		if userID == "" || o.UserID == userID {
+			orders = append(orders, o.snapshot())
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.After(orders[j].CreatedAt) })
+	return orders
+}
+
+// Fills returns the fills of userID's orders with intent, oldest first
+func (m *Manager) Fills(userID, intent string) []Fill {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var fills []Fill
+	for _, o := range m.orders {
+		if o.UserID != userID {
+			continue
+		}
+		for _, fill := range o.Fills {
+			if fill.Intent == intent {
+				fills = append(fills, fill)
+			}
+		}
+	}
+	sort.SliceStable(fills, func(i, j int) bool { return fills[i].At.Before(fills[j].At) })
+	return fills
+}
+
+// Start runs Poll every interval until ctx is cancelled
+func (m *Manager) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := m.Poll(ctx); err != nil && ctx.Err() == nil {
+					m.logger.WithError(err).Warn("Failed to refresh orders")
+				}
+			}
+		}
+	}()
+}
+
+// save writes the orders atomically; callers hold m.mu
+func (m *Manager) save() error {
+	if m.path == "" {
+		return nil
+	}
+	orders := make([]*Order, 0, len(m.orders))
+	for _, o := range m.orders {
+		orders = append(orders, o)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.Before(orders[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(orders, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return fmt.Errorf("failed to create orders directory: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write orders: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to replace orders: %w", err)
+	}
+	return nil
+}
+
+func newOrderID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("ord_%d", time.Now().UnixNano())
+	}
+	return "ord_" + hex.EncodeToString(buf)
+}
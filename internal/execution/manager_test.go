@@ -0,0 +1,219 @@
+package execution
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/execution/alpacamock"
+)
+
+type executedRecords []string
+
+func (r *executedRecords) UpdateExecution(recordID string, executed bool) error {
+	*r = append(*r, recordID)
+	return nil
+}
+
+// newMockManager returns a Manager routing to an alpacamock server that
+// fills orders over fillSteps polls, with its clock at the returned time
+func newMockManager(t *testing.T, fillSteps int, config Config) (*Manager, *alpacamock.Server, *executedRecords, *time.Time) {
+	t.Helper()
+	mock := alpacamock.NewServer(fillSteps)
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	records := &executedRecords{}
+	m, err := NewManager(NewAlpacaRouter(AlpacaConfig{APIKey: "key", APISecret: "secret", BaseURL: server.URL}), "", records, nil, config, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, mock, records, &now
+}
+
+func execute(t *testing.T, m *Manager, quantity int, legged bool) *Order {
+	t.Helper()
+	p := bullCallProposal("prop_"+t.Name(), quantity)
+	p.Trade.RecordID = "rec_1"
+	o, err := m.Execute(context.Background(), ExecuteRequest{Proposal: p, LegPrices: legPrices, Legged: legged})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func poll(t *testing.T, m *Manager, id string) *Order {
+	t.Helper()
+	if err := m.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	o, err := m.Get(id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func TestManagerFillsMultiLegOrder(t *testing.T) {
+	m, _, records, _ := newMockManager(t, 2, Config{})
+	o := execute(t, m, 2, false)
+	if len(o.Tickets) != 1 || o.Status != StatusNew {
+		t.Fatalf("order = %s with %d tickets, want one working ticket", o.Status, len(o.Tickets))
+	}
+
+	o = poll(t, m, o.ID)
+	if o.Status != StatusPartiallyFilled || o.FilledQty != 1 || o.ExecutedAt == nil {
+		t.Fatalf("order = %s with %d filled, want partially filled 1", o.Status, o.FilledQty)
+	}
+	if len(*records) != 1 || (*records)[0] != "rec_1" {
+		t.Errorf("executed records = %v, want rec_1 once", *records)
+	}
+
+	o = poll(t, m, o.ID)
+	if o.Status != StatusFilled || o.FilledQty != 2 || o.AvgFillPrice != 6 {
+		t.Fatalf("order = %s with %d filled at %v, want filled 2 at 6", o.Status, o.FilledQty, o.AvgFillPrice)
+	}
+	if len(o.Fills) != 4 || len(*records) != 1 {
+		t.Errorf("fills = %d and executed records = %v, want 4 fills and rec_1 once", len(o.Fills), *records)
+	}
+	if fills := m.Fills("alice", IntentOpen); len(fills) != 4 {
+		t.Errorf("opening fills = %d, want 4", len(fills))
+	}
+
+	// Nothing left working
+	if err := m.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManagerRepricesStaleTicket(t *testing.T) {
+	m, mock, _, now := newMockManager(t, 0, Config{RepriceAfter: 30 * time.Second, RepriceStep: 0.05, MaxReprices: 1})
+	o := execute(t, m, 3, false)
+	first := o.Tickets[0].BrokerOrderID
+
+	if err := mock.Fill(first, 1, 6); err != nil {
+		t.Fatal(err)
+	}
+	o = poll(t, m, o.ID)
+	if o.FilledQty != 1 || len(o.Tickets) != 1 {
+		t.Fatalf("order filled %d with %d tickets, want 1 filled on the first ticket", o.FilledQty, len(o.Tickets))
+	}
+
+	// Thirty seconds without a fill replaces the rest a nickel higher
+	*now = now.Add(31 * time.Second)
+	o = poll(t, m, o.ID)
+	if len(o.Tickets) != 2 {
+		t.Fatalf("tickets = %d, want the first replaced by a second", len(o.Tickets))
+	}
+	old, next := o.Tickets[0], o.Tickets[1]
+	if old.Status != StatusReplaced || next.Replaces != first || next.Reprices != 1 || next.Quantity != 2 || next.LimitPrice != 6.05 {
+		t.Fatalf("old = %s, new = %+v, want a replacement for 2 at 6.05", old.Status, next)
+	}
+	if o.LimitPrice != 6.05 || o.Status != StatusPartiallyFilled || o.FilledQty != 1 {
+		t.Fatalf("order = %s with %d filled at limit %v, want partially filled 1 at 6.05", o.Status, o.FilledQty, o.LimitPrice)
+	}
+
+	// The replacement has used its reprices
+	*now = now.Add(time.Minute)
+	if o = poll(t, m, o.ID); len(o.Tickets) != 2 {
+		t.Fatalf("tickets = %d, want no more reprices", len(o.Tickets))
+	}
+
+	if err := mock.Fill(next.BrokerOrderID, 2, 6.05); err != nil {
+		t.Fatal(err)
+	}
+	o = poll(t, m, o.ID)
+	if o.Status != StatusFilled || o.FilledQty != 3 || math.Abs(o.AvgFillPrice-6.0333) > 0.001 {
+		t.Fatalf("order = %s with %d filled at %v, want filled 3 at 6.0333", o.Status, o.FilledQty, o.AvgFillPrice)
+	}
+}
+
+func TestManagerFollowsBrokerReplacement(t *testing.T) {
+	m, _, _, _ := newMockManager(t, 0, Config{})
+	o := execute(t, m, 2, false)
+	first := o.Tickets[0].BrokerOrderID
+
+	// Replaced at the broker, e.g. by hand, rather than by the manager
+	bo, err := m.router.Replace(context.Background(), first, 2, 6.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o = poll(t, m, o.ID)
+	if len(o.Tickets) != 2 || o.Tickets[1].BrokerOrderID != bo.ID || o.Tickets[1].Replaces != first {
+		t.Fatalf("tickets = %+v, want the replacement followed", o.Tickets)
+	}
+	if o.Status != StatusNew {
+		t.Errorf("status = %s, want %s while the replacement works", o.Status, StatusNew)
+	}
+
+	// Its quantity and limit come from the broker on the next poll
+	o = poll(t, m, o.ID)
+	if next := o.Tickets[1]; next.Quantity != 2 || next.LimitPrice != 6.1 {
+		t.Errorf("replacement = %d at %v, want 2 at 6.1", next.Quantity, next.LimitPrice)
+	}
+}
+
+func TestManagerUnwindsLeggedOrderOnRejectedLeg(t *testing.T) {
+	m, mock, records, _ := newMockManager(t, 0, Config{})
+	o := execute(t, m, 2, true)
+	if len(o.Tickets) != 2 {
+		t.Fatalf("tickets = %d, want one per leg", len(o.Tickets))
+	}
+	buy, sell := o.Tickets[0].BrokerOrderID, o.Tickets[1].BrokerOrderID
+
+	if err := mock.Fill(buy, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.Reject(sell); err != nil {
+		t.Fatal(err)
+	}
+	o = poll(t, m, o.ID)
+	if !strings.Contains(o.Message, "leg rejected") || !strings.Contains(o.Message, "filled legs are open") {
+		t.Fatalf("message = %q, want the rejection and the open filled leg", o.Message)
+	}
+	if len(*records) != 1 {
+		t.Errorf("executed records = %v, want the filled leg to execute the record", *records)
+	}
+
+	// The bought leg was canceled at the broker; the next poll sees it
+	o = poll(t, m, o.ID)
+	if o.Status != StatusCanceled || o.Legs[0].FilledQty != 1 || o.Legs[1].FilledQty != 0 {
+		t.Fatalf("order = %s with legs filled %d and %d, want canceled with 1 and 0", o.Status, o.Legs[0].FilledQty, o.Legs[1].FilledQty)
+	}
+	if err := mock.Fill(buy, 1, 10); err == nil {
+		t.Error("bought leg still working at the broker")
+	}
+}
+
+func TestManagerExecutesProposalOnce(t *testing.T) {
+	m, _, _, _ := newMockManager(t, 0, Config{})
+	p := bullCallProposal("prop_once", 1)
+	req := ExecuteRequest{Proposal: p, LegPrices: legPrices}
+
+	o, err := m.Execute(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Execute(context.Background(), req); !errors.Is(err, ErrAlreadyExecuted) {
+		t.Fatalf("second execution error = %v, want %v", err, ErrAlreadyExecuted)
+	}
+
+	// An order canceled before filling frees the proposal
+	if _, err := m.Cancel(context.Background(), o.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Execute(context.Background(), req); err != nil {
+		t.Errorf("execution after cancel error = %v, want none", err)
+	}
+}
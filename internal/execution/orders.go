@@ -0,0 +1,381 @@
+package execution
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// Order is an approved proposal's trade as sent to a broker. It works
+// through tickets: one multi-leg broker order, or one broker order per leg
+// when legged in, each replaced by a new ticket when it is repriced.
+type Order struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	ProposalID   string     `json:"proposal_id"`
+	RecordID     string     `json:"record_id,omitempty"` // The trade's RecommendationRecord
+	Underlying   string     `json:"underlying"`
+	Strategy     string     `json:"strategy"`
+	Broker       string     `json:"broker"`
+	Legs         []Leg      `json:"legs"`
+	Quantity     int        `json:"quantity"`    // Units of the structure
+	LimitPrice   float64    `json:"limit_price"` // Net per share as now working; positive debit, negative credit
+	Legged       bool       `json:"legged"`
+	Status       string     `json:"status"`
+	FilledQty    int        `json:"filled_qty"` // Complete units filled
+	AvgFillPrice float64    `json:"avg_fill_price,omitempty"`
+	Message      string     `json:"message,omitempty"`
+	Tickets      []*Ticket  `json:"tickets"`
+	Fills        []Fill     `json:"fills"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ExecutedAt   *time.Time `json:"executed_at,omitempty"` // First fill
+}
+
+// Ticket is one broker order working some of an Order's legs. Filled and
+// AvgPrice are per leg in Legs, in contracts or shares.
+type Ticket struct {
+	BrokerOrderID string     `json:"broker_order_id"`
+	ClientOrderID string     `json:"client_order_id"`
+	Legs          []int      `json:"legs"`     // Indexes into Order.Legs
+	Quantity      int        `json:"quantity"` // Units for a multi-leg ticket, contracts or shares for one leg
+	LimitPrice    float64    `json:"limit_price"`
+	Status        string     `json:"status"`
+	Filled        []int      `json:"filled"`
+	AvgPrice      []float64  `json:"avg_price"`
+	Reprices      int        `json:"reprices"`
+	Replaces      string     `json:"replaces,omitempty"`
+	SubmittedAt   time.Time  `json:"submitted_at"`
+	LastFillAt    *time.Time `json:"last_fill_at,omitempty"`
+}
+
+// Fill is an execution of one leg, linked to its order's recommendation
+type Fill struct {
+	OrderID       string    `json:"order_id"`
+	RecordID      string    `json:"record_id,omitempty"`
+	BrokerOrderID string    `json:"broker_order_id"`
+	Symbol        string    `json:"symbol"`
+	Side          string    `json:"side"`
+	Intent        string    `json:"intent"`
+	Quantity      int       `json:"quantity"` // Contracts or shares
+	Price         float64   `json:"price"`    // Per share
+	At            time.Time `json:"at"`
+}
+
+// newOrder builds the order for an approved proposal, pricing each leg at
+// legPrices
+func newOrder(id string, p *ai_assistant.Proposal, legPrices []float64, legged bool, broker string, maxLegs int, now time.Time) (*Order, error) {
+	trade := p.Trade
+	if len(trade.LegDetails) == 0 {
+		return nil, ErrUnstructuredLegs
+	}
+	if len(legPrices) != len(trade.LegDetails) {
+		return nil, fmt.Errorf("%d leg prices given for %d legs", len(legPrices), len(trade.LegDetails))
+	}
+
+	o := &Order{
+		ID:         id,
+		UserID:     p.UserID,
+		ProposalID: p.ID,
+		RecordID:   trade.RecordID,
+		Underlying: strings.ToUpper(trade.Ticker),
+		Strategy:   trade.Strategy,
+		Broker:     broker,
+		Quantity:   trade.Quantity,
+		Status:     StatusNew,
+		Tickets:    []*Ticket{},
+		Fills:      []Fill{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if o.Quantity <= 0 {
+		o.Quantity = 1
+	}
+
+	options := true
+	for i, detail := range trade.LegDetails {
+		if legPrices[i] <= 0 {
+			return nil, fmt.Errorf("leg %d of %s has no price", i+1, trade.Ticker)
+		}
+		leg := Leg{
+			Side:       SideBuy,
+			Intent:     IntentOpen,
+			Ratio:      int(math.Max(math.Round(detail.Quantity), 1)),
+			Multiplier: 100,
+			LimitPrice: round2(legPrices[i]),
+		}
+		if detail.Action == ai_assistant.LegSell {
+			leg.Side = SideSell
+		}
+		if detail.Type == ai_assistant.PositionStock {
+			leg.Symbol = o.Underlying
+			leg.Multiplier = 1
+			options = false
+		} else {
+			symbol, err := ai_assistant.OCCSymbol(trade.Ticker, detail)
+			if err != nil {
+				return nil, err
+			}
+			leg.Symbol = symbol
+		}
+		o.Legs = append(o.Legs, leg)
+	}
+	o.Legged = legged || len(o.Legs) == 1 || len(o.Legs) > maxLegs || !options
+	o.LimitPrice = o.netPrice(func(l Leg) float64 { return l.LimitPrice })
+	return o, nil
+}
+
+// openingTickets returns the broker orders that open o: one multi-leg
+// order, or one per leg with bought legs first so each hedge is working
+// before the legs it covers
+func (o *Order) openingTickets() []*Ticket {
+	if !o.Legged {
+		legs := make([]int, len(o.Legs))
+		for i := range legs {
+			legs[i] = i
+		}
+		return []*Ticket{o.newTicket(1, legs, o.Quantity, o.LimitPrice)}
+	}
+
+	var order []int
+	for _, side := range []string{SideBuy, SideSell} {
+		for i, leg := range o.Legs {
+			if leg.Side == side {
+				order = append(order, i)
+			}
+		}
+	}
+	tickets := make([]*Ticket, len(order))
+	for n, i := range order {
+		tickets[n] = o.newTicket(n+1, []int{i}, o.Quantity*o.Legs[i].Ratio, o.Legs[i].LimitPrice)
+	}
+	return tickets
+}
+
+// newTicket creates the n'th ticket of o
+func (o *Order) newTicket(n int, legs []int, quantity int, limit float64) *Ticket {
+	return &Ticket{
+		ClientOrderID: fmt.Sprintf("%s-%d", o.ID, n),
+		Legs:          legs,
+		Quantity:      quantity,
+		LimitPrice:    limit,
+		Filled:        make([]int, len(legs)),
+		AvgPrice:      make([]float64, len(legs)),
+	}
+}
+
+// request is the OrderRequest for t, whose legs keep their ratios only on
+// a multi-leg ticket
+func (o *Order) request(t *Ticket, timeInForce string) OrderRequest {
+	req := OrderRequest{
+		ClientOrderID: t.ClientOrderID,
+		UserID:        o.UserID,
+		Quantity:      t.Quantity,
+		LimitPrice:    t.LimitPrice,
+		TimeInForce:   timeInForce,
+	}
+	for _, i := range t.Legs {
+		leg := o.Legs[i]
+		leg.FilledQty, leg.AvgFillPrice = 0, 0
+		if len(t.Legs) == 1 {
+			leg.Ratio = 1
+		}
+		req.Legs = append(req.Legs, leg)
+	}
+	return req
+}
+
+// ratio is the contracts of leg k of t per unit of t
+func (o *Order) ratio(t *Ticket, k int) int {
+	if len(t.Legs) == 1 {
+		return 1
+	}
+	return o.Legs[t.Legs[k]].Ratio
+}
+
+// remaining is the units of t not yet filled
+func (o *Order) remaining(t *Ticket) int {
+	filled := t.Quantity
+	for k := range t.Legs {
+		if units := t.Filled[k] / o.ratio(t, k); units < filled {
+			filled = units
+		}
+	}
+	return t.Quantity - filled
+}
+
+// apply updates t from the broker's view of it and returns the new fills.
+// When the broker does not price a multi-leg order's legs, the net fill
+// price is spread over them in proportion to their limit prices.
+func (o *Order) apply(t *Ticket, bo *BrokerOrder, now time.Time) []Fill {
+	t.Status = bo.Status
+	if t.Quantity == 0 {
+		t.Quantity = bo.Quantity
+	}
+	if t.LimitPrice == 0 {
+		t.LimitPrice = bo.LimitPrice
+	}
+
+	var fills []Fill
+	for k, i := range t.Legs {
+		leg := o.Legs[i]
+		filled, price := bo.FilledQty*o.ratio(t, k), bo.AvgFillPrice
+		if len(t.Legs) > 1 {
+			price = 0
+			if base := o.netPrice(func(l Leg) float64 { return l.LimitPrice }); base != 0 {
+				price = leg.LimitPrice * bo.AvgFillPrice / base
+			}
+		}
+		for _, bl := range bo.Legs {
+			if strings.EqualFold(bl.Symbol, leg.Symbol) {
+				filled = bl.FilledQty
+				if bl.AvgFillPrice > 0 {
+					price = bl.AvgFillPrice
+				}
+				break
+			}
+		}
+
+		delta := filled - t.Filled[k]
+		if delta <= 0 {
+			continue
+		}
+		fillPrice := (price*float64(filled) - t.AvgPrice[k]*float64(t.Filled[k])) / float64(delta)
+		t.Filled[k], t.AvgPrice[k] = filled, price
+		t.LastFillAt = &now
+		fills = append(fills, Fill{
+			OrderID:       o.ID,
+			RecordID:      o.RecordID,
+			BrokerOrderID: t.BrokerOrderID,
+			Symbol:        leg.Symbol,
+			Side:          leg.Side,
+			Intent:        leg.Intent,
+			Quantity:      delta,
+			Price:         round4(fillPrice),
+			At:            now,
+		})
+	}
+	o.Fills = append(o.Fills, fills...)
+	return fills
+}
+
+// refresh recomputes o's legs, fills and status from its tickets
+func (o *Order) refresh(now time.Time) {
+	for i := range o.Legs {
+		o.Legs[i].FilledQty, o.Legs[i].AvgFillPrice = 0, 0
+	}
+	cost := make([]float64, len(o.Legs))
+	live, rejected, expired := false, 0, false
+	for _, t := range o.Tickets {
+		for k, i := range t.Legs {
+			o.Legs[i].FilledQty += t.Filled[k]
+			cost[i] += t.AvgPrice[k] * float64(t.Filled[k])
+		}
+		switch {
+		case !IsTerminal(t.Status):
+			live = true
+		case t.Status == StatusRejected:
+			rejected++
+		case t.Status == StatusExpired:
+			expired = true
+		}
+	}
+
+	o.FilledQty = o.Quantity
+	anyFill := false
+	for i, leg := range o.Legs {
+		if leg.FilledQty > 0 {
+			anyFill = true
+			o.Legs[i].AvgFillPrice = round4(cost[i] / float64(leg.FilledQty))
+		}
+		if units := leg.FilledQty / leg.Ratio; units < o.FilledQty {
+			o.FilledQty = units
+		}
+	}
+	o.AvgFillPrice = 0
+	if anyFill {
+		o.AvgFillPrice = o.netPrice(func(l Leg) float64 { return l.AvgFillPrice })
+	}
+
+	switch {
+	case o.FilledQty >= o.Quantity:
+		o.Status = StatusFilled
+	case live && anyFill:
+		o.Status = StatusPartiallyFilled
+	case live:
+		o.Status = StatusNew
+	case !anyFill && rejected == len(o.Tickets):
+		o.Status = StatusRejected
+	case expired:
+		o.Status = StatusExpired
+	default:
+		o.Status = StatusCanceled
+	}
+	o.UpdatedAt = now
+}
+
+// netPrice sums price over o's legs, per share of one unit, positive for a
+// debit
+func (o *Order) netPrice(price func(Leg) float64) float64 {
+	var net float64
+	for _, leg := range o.Legs {
+		net += leg.sign() * float64(leg.Ratio*leg.Multiplier) / 100 * price(leg)
+	}
+	return round4(net)
+}
+
+// liveTickets returns the tickets still working
+func (o *Order) liveTickets() []*Ticket {
+	var live []*Ticket
+	for _, t := range o.Tickets {
+		if t.BrokerOrderID != "" && !IsTerminal(t.Status) {
+			live = append(live, t)
+		}
+	}
+	return live
+}
+
+func (o *Order) ticket(brokerOrderID string) *Ticket {
+	for _, t := range o.Tickets {
+		if t.BrokerOrderID == brokerOrderID {
+			return t
+		}
+	}
+	return nil
+}
+
+// reprice returns t's limit moved step toward the other side of the
+// market: up for a multi-leg ticket or a bought leg, down for a sold leg
+func (o *Order) reprice(t *Ticket, step float64) (float64, bool) {
+	if len(t.Legs) > 1 || o.Legs[t.Legs[0]].Side == SideBuy {
+		return round2(t.LimitPrice + step), true
+	}
+	limit := round2(t.LimitPrice - step)
+	return limit, limit >= 0.01
+}
+
+func (o *Order) snapshot() *Order {
+	cp := *o
+	cp.Legs = append([]Leg{}, o.Legs...)
+	cp.Fills = append([]Fill{}, o.Fills...)
+	cp.Tickets = make([]*Ticket, len(o.Tickets))
+	for i, t := range o.Tickets {
+		tc := *t
+		tc.Legs = append([]int{}, t.Legs...)
+		tc.Filled = append([]int{}, t.Filled...)
+		tc.AvgPrice = append([]float64{}, t.AvgPrice...)
+		cp.Tickets[i] = &tc
+	}
+	return &cp
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+func round4(x float64) float64 {
+	return math.Round(x*10000) / 10000
+}
@@ -0,0 +1,153 @@
+package execution
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// bullCallProposal is an approved SPY 500/510 call spread for quantity
+// units, for leg prices of 10 and 4
+func bullCallProposal(id string, quantity int) *ai_assistant.Proposal {
+	return &ai_assistant.Proposal{
+		ID:     id,
+		UserID: "alice",
+		State:  ai_assistant.ProposalApproved,
+		Trade: ai_assistant.TradeRecommendation{
+			Ticker:   "SPY",
+			Strategy: "Bull Call Spread",
+			Quantity: quantity,
+			LegDetails: []ai_assistant.OptionLeg{
+				{Action: ai_assistant.LegBuy, Type: ai_assistant.PositionCall, Strike: 500, Expiration: "2026-12-18", Quantity: 1},
+				{Action: ai_assistant.LegSell, Type: ai_assistant.PositionCall, Strike: 510, Expiration: "2026-12-18", Quantity: 1},
+			},
+		},
+	}
+}
+
+var legPrices = []float64{10, 4}
+
+func testOrder(t *testing.T, quantity int, legged bool) *Order {
+	t.Helper()
+	o, err := newOrder("ord_test", bullCallProposal("prop_test", quantity), legPrices, legged, "test", 4, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, ticket := range o.openingTickets() {
+		ticket.BrokerOrderID = fmt.Sprintf("broker-%d", n+1)
+		o.Tickets = append(o.Tickets, ticket)
+	}
+	return o
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-4
+}
+
+func TestOrderAppliesPartialFillsOfMultiLegTicket(t *testing.T) {
+	o := testOrder(t, 3, false)
+	if len(o.Tickets) != 1 || o.LimitPrice != 6 {
+		t.Fatalf("tickets = %d at %v, want one multi-leg ticket at 6", len(o.Tickets), o.LimitPrice)
+	}
+	ticket := o.Tickets[0]
+	now := time.Now()
+
+	// The broker reports only the net price, which is spread over the legs
+	// in proportion to their limits
+	fills := o.apply(ticket, &BrokerOrder{Status: StatusPartiallyFilled, Quantity: 3, FilledQty: 1, AvgFillPrice: 6}, now)
+	o.refresh(now)
+	if len(fills) != 2 || fills[0].Price != 10 || fills[1].Price != 4 || fills[0].Quantity != 1 {
+		t.Fatalf("first fills = %+v, want one of each leg at 10 and 4", fills)
+	}
+	if o.Status != StatusPartiallyFilled || o.FilledQty != 1 || o.AvgFillPrice != 6 {
+		t.Fatalf("order = %s with %d filled at %v, want partially filled 1 at 6", o.Status, o.FilledQty, o.AvgFillPrice)
+	}
+	if got := o.remaining(ticket); got != 2 {
+		t.Errorf("remaining = %d, want 2", got)
+	}
+
+	// The cumulative average moves to 5.5, so the next two units filled at
+	// 8.75 and 3.50
+	fills = o.apply(ticket, &BrokerOrder{Status: StatusFilled, Quantity: 3, FilledQty: 3, AvgFillPrice: 5.5}, now)
+	o.refresh(now)
+	if len(fills) != 2 || fills[0].Quantity != 2 || fills[0].Price != 8.75 || fills[1].Price != 3.5 {
+		t.Fatalf("second fills = %+v, want two of each leg at 8.75 and 3.50", fills)
+	}
+	if o.Status != StatusFilled || o.FilledQty != 3 || !approxEqual(o.AvgFillPrice, 5.5) {
+		t.Fatalf("order = %s with %d filled at %v, want filled 3 at 5.5", o.Status, o.FilledQty, o.AvgFillPrice)
+	}
+	if len(o.Fills) != 4 {
+		t.Errorf("fills = %d, want 4", len(o.Fills))
+	}
+
+	// Seeing the same state again fills nothing
+	if fills := o.apply(ticket, &BrokerOrder{Status: StatusFilled, Quantity: 3, FilledQty: 3, AvgFillPrice: 5.5}, now); len(fills) != 0 {
+		t.Errorf("repeated fills = %+v, want none", fills)
+	}
+}
+
+func TestOrderAppliesLegFillsOfLeggedTickets(t *testing.T) {
+	o := testOrder(t, 2, true)
+	if len(o.Tickets) != 2 || o.Legs[o.Tickets[0].Legs[0]].Side != SideBuy {
+		t.Fatalf("tickets = %d, want the bought leg first of two", len(o.Tickets))
+	}
+	buy, sell := o.Tickets[0], o.Tickets[1]
+	now := time.Now()
+
+	o.apply(buy, &BrokerOrder{Status: StatusFilled, Quantity: 2, FilledQty: 2, AvgFillPrice: 9.9}, now)
+	o.apply(sell, &BrokerOrder{Status: StatusPartiallyFilled, Quantity: 2, FilledQty: 1, AvgFillPrice: 4.1}, now)
+	o.refresh(now)
+
+	if o.Status != StatusPartiallyFilled || o.FilledQty != 1 {
+		t.Fatalf("order = %s with %d filled, want partially filled 1", o.Status, o.FilledQty)
+	}
+	if o.Legs[0].FilledQty != 2 || o.Legs[1].FilledQty != 1 || !approxEqual(o.AvgFillPrice, 5.8) {
+		t.Fatalf("legs filled %d and %d at net %v, want 2 and 1 at 5.8", o.Legs[0].FilledQty, o.Legs[1].FilledQty, o.AvgFillPrice)
+	}
+	if jobs := (&Manager{config: Config{RepriceAfter: time.Second, RepriceStep: 0.05, MaxReprices: 1}}).dueReprices(o, now.Add(time.Minute)); len(jobs) != 1 || jobs[0].quantity != 1 || jobs[0].limit != 3.95 {
+		t.Errorf("reprices = %+v, want the sold leg's last contract at 3.95", jobs)
+	}
+}
+
+func TestOrderUnwindsRejectedLeg(t *testing.T) {
+	o := testOrder(t, 2, true)
+	buy, sell := o.Tickets[0], o.Tickets[1]
+	now := time.Now()
+
+	o.apply(buy, &BrokerOrder{Status: StatusPartiallyFilled, Quantity: 2, FilledQty: 1, AvgFillPrice: 10}, now)
+	o.apply(sell, &BrokerOrder{Status: StatusRejected, Quantity: 2}, now)
+	o.refresh(now)
+
+	ids := o.unwindRejectedLegs()
+	if len(ids) != 1 || ids[0] != buy.BrokerOrderID {
+		t.Fatalf("canceled = %v, want the working bought leg %s", ids, buy.BrokerOrderID)
+	}
+	if !strings.Contains(o.Message, "leg rejected") || !strings.Contains(o.Message, "filled legs are open") {
+		t.Errorf("message = %q, want the rejection and the open filled leg", o.Message)
+	}
+	if again := o.unwindRejectedLegs(); again != nil {
+		t.Errorf("second unwind = %v, want nothing once noted", again)
+	}
+
+	o.apply(buy, &BrokerOrder{Status: StatusCanceled, Quantity: 2, FilledQty: 1, AvgFillPrice: 10}, now)
+	o.refresh(now)
+	if o.Status != StatusCanceled {
+		t.Errorf("status = %s, want %s", o.Status, StatusCanceled)
+	}
+}
+
+func TestOrderRejectedWithoutFills(t *testing.T) {
+	o := testOrder(t, 1, true)
+	now := time.Now()
+	for _, ticket := range o.Tickets {
+		o.apply(ticket, &BrokerOrder{Status: StatusRejected, Quantity: 1}, now)
+	}
+	o.refresh(now)
+	if o.Status != StatusRejected {
+		t.Errorf("status = %s, want %s", o.Status, StatusRejected)
+	}
+}
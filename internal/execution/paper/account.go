@@ -0,0 +1,302 @@
+package paper
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/execution"
+)
+
+// Activity kinds
+const (
+	ActivityFill     = "fill"
+	ActivityExpire   = "expire"   // Expired worthless
+	ActivityExercise = "exercise" // Long option exercised at expiration
+	ActivityAssign   = "assign"   // Short option assigned at expiration
+)
+
+// maxActivity is how much account activity is kept
+const maxActivity = 5000
+
+// Position is a holding in the paper account. Quantity is in contracts or
+// shares and negative when short; prices are per share.
+type Position struct {
+	Symbol     string    `json:"symbol"`
+	Underlying string    `json:"underlying"`
+	Type       string    `json:"type"` // ai_assistant.PositionStock, PositionCall or PositionPut
+	Strike     float64   `json:"strike,omitempty"`
+	Expiration string    `json:"expiration,omitempty"` // YYYY-MM-DD
+	Quantity   int       `json:"quantity"`
+	Multiplier int       `json:"multiplier"`
+	AvgPrice   float64   `json:"avg_price"`
+	Mark       float64   `json:"mark"`
+	MarkedAt   time.Time `json:"marked_at"`
+	OpenedAt   time.Time `json:"opened_at"`
+}
+
+// newPosition creates an empty position in an OCC option symbol or a stock
+func newPosition(symbol string, now time.Time) *Position {
+	p := &Position{Symbol: symbol, Underlying: symbol, Type: ai_assistant.PositionStock, Multiplier: 1, OpenedAt: now}
+	if underlying, leg, ok := ai_assistant.ParseOCCSymbol(symbol); ok {
+		p.Underlying, p.Type, p.Strike, p.Expiration, p.Multiplier = underlying, leg.Type, leg.Strike, leg.Expiration, 100
+	}
+	return p
+}
+
+// IsOption reports whether the position is a call or put
+func (p *Position) IsOption() bool {
+	return p.Type != ai_assistant.PositionStock
+}
+
+// MarketValue is the position's value at its mark, negative when short
+func (p *Position) MarketValue() float64 {
+	return float64(p.Quantity*p.Multiplier) * p.Mark
+}
+
+// UnrealizedPnL is the position's gain at its mark over its cost
+func (p *Position) UnrealizedPnL() float64 {
+	return float64(p.Quantity*p.Multiplier) * (p.Mark - p.AvgPrice)
+}
+
+// intrinsic is the option's value at expiration with the underlying at spot
+func (p *Position) intrinsic(spot float64) float64 {
+	if p.Type == ai_assistant.PositionCall {
+		return math.Max(spot-p.Strike, 0)
+	}
+	return math.Max(p.Strike-spot, 0)
+}
+
+// Activity is one change to the account: a fill, or an option expiring,
+// exercised or assigned. Cash is the change in cash, after commission;
+// Closed is how much of a position it closed.
+type Activity struct {
+	Kind       string    `json:"kind"`
+	Symbol     string    `json:"symbol"`
+	OrderID    string    `json:"order_id,omitempty"`
+	Side       string    `json:"side"`
+	Quantity   int       `json:"quantity"`
+	Price      float64   `json:"price"`
+	Commission float64   `json:"commission,omitempty"`
+	Cash       float64   `json:"cash"`
+	Realized   float64   `json:"realized_pnl,omitempty"`
+	Closed     int       `json:"closed,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// account is the simulated cash and positions
+type account struct {
+	StartingCash float64              `json:"starting_cash"`
+	Cash         float64              `json:"cash"`
+	RealizedPnL  float64              `json:"realized_pnl"` // Before commissions
+	Commissions  float64              `json:"commissions"`
+	Positions    map[string]*Position `json:"positions"`
+	Activity     []Activity           `json:"activity"`
+}
+
+func newAccount(cash float64) *account {
+	return &account{
+		StartingCash: cash,
+		Cash:         cash,
+		Positions:    make(map[string]*Position),
+	}
+}
+
+// clone copies the account's cash and positions to try trades on
+func (a *account) clone() *account {
+	c := *a
+	c.Positions = make(map[string]*Position, len(a.Positions))
+	for symbol, p := range a.Positions {
+		copied := *p
+		c.Positions[symbol] = &copied
+	}
+	c.Activity = nil
+	return &c
+}
+
+// trade buys or sells quantity of symbol at price, updating the position's
+// average price, and returns the P&L realized on whatever it closes
+func (a *account) trade(kind, orderID, symbol, side string, quantity int, price, commission float64, now time.Time) float64 {
+	p := a.Positions[symbol]
+	if p == nil {
+		p = newPosition(symbol, now)
+		p.Mark, p.MarkedAt = price, now
+		a.Positions[symbol] = p
+	}
+	delta := quantity
+	if side == execution.SideSell {
+		delta = -quantity
+	}
+	multiplier := float64(p.Multiplier)
+
+	realized, closed := 0.0, 0
+	if p.Quantity != 0 && (p.Quantity > 0) != (delta > 0) {
+		closed = min(abs(p.Quantity), quantity)
+		realized = float64(closed) * multiplier * (price - p.AvgPrice)
+		if p.Quantity < 0 {
+			realized = -realized
+		}
+	}
+
+	held := p.Quantity + delta
+	switch {
+	case held == 0:
+		delete(a.Positions, symbol)
+	case p.Quantity == 0 || (p.Quantity > 0) != (held > 0):
+		p.AvgPrice, p.OpenedAt = price, now // Opened, or closed and reversed
+	case abs(held) > abs(p.Quantity):
+		p.AvgPrice = (p.AvgPrice*float64(abs(p.Quantity)) + price*float64(quantity)) / float64(abs(held))
+	}
+	p.Quantity = held
+
+	cash := -float64(delta)*multiplier*price - commission
+	a.Cash += cash
+	a.RealizedPnL += realized
+	a.Commissions += commission
+
+	a.Activity = append(a.Activity, Activity{
+		Kind:       kind,
+		Symbol:     symbol,
+		OrderID:    orderID,
+		Side:       side,
+		Quantity:   quantity,
+		Price:      price,
+		Commission: commission,
+		Cash:       round2(cash),
+		Realized:   round2(realized),
+		Closed:     closed,
+		At:         now,
+	})
+	if len(a.Activity) > maxActivity {
+		a.Activity = a.Activity[len(a.Activity)-maxActivity:]
+	}
+	return realized
+}
+
+// buyingPower returns the account's Reg-T margin requirement and the cash
+// left after it. Stock needs half its value, borrowing the rest. Options
+// on each underlying and expiration need what the risk manager's margin
+// calculator puts on them before the premium they collect, since that is
+// already in cash; long options are paid for in full and need nothing.
+// Shares held cover short calls, nearest expiration first. Spots are the
+// underlyings' latest prices.
+func (a *account) buyingPower(spots map[string]float64) (requirement, buyingPower float64) {
+	stockValue := 0.0
+	type group struct {
+		underlying, expiration string
+		legs                   []ai_assistant.OptionLeg
+		chains                 []*ai_assistant.OptionChain
+		shortCalls             float64
+	}
+	groups := make(map[string]*group)
+	shares := make(map[string]float64)
+	for _, p := range a.Positions {
+		if !p.IsOption() {
+			stockValue += p.MarketValue()
+			requirement += 0.5 * math.Abs(p.MarketValue())
+			shares[p.Symbol] += float64(p.Quantity)
+			continue
+		}
+		key := p.Underlying + " " + p.Expiration
+		g := groups[key]
+		if g == nil {
+			g = &group{underlying: p.Underlying, expiration: p.Expiration}
+			groups[key] = g
+		}
+		action := ai_assistant.LegBuy
+		if p.Quantity < 0 {
+			action = ai_assistant.LegSell
+		}
+		g.legs = append(g.legs, ai_assistant.OptionLeg{Action: action, Type: p.Type, Strike: p.Strike, Expiration: p.Expiration, Quantity: float64(abs(p.Quantity))})
+		g.chains = append(g.chains, &ai_assistant.OptionChain{Symbol: p.Underlying, Strike: p.Strike, Expiration: p.Expiration, Type: p.Type, Bid: p.Mark, Ask: p.Mark, Last: p.Mark})
+		if p.Type == ai_assistant.PositionCall {
+			g.shortCalls -= float64(p.Quantity)
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return groups[keys[i]].expiration < groups[keys[j]].expiration })
+	for _, key := range keys {
+		g := groups[key]
+		data := &ai_assistant.AggregatedMarketData{
+			Quotes:  map[string]*ai_assistant.Quote{g.underlying: {Symbol: g.underlying, Price: spots[g.underlying]}},
+			Options: map[string][]*ai_assistant.OptionChain{g.underlying: g.chains},
+		}
+		portfolio := map[string]interface{}{
+			"positions": []map[string]interface{}{{"symbol": g.underlying, "quantity": shares[g.underlying]}},
+		}
+		trade := &ai_assistant.TradeRecommendation{Ticker: g.underlying, Strategy: "paper", LegDetails: g.legs}
+		margin := ai_assistant.NewRiskManager().WithMarketData(data).MarginRequirement(trade, portfolio)
+
+		gross := margin.Premium
+		for _, c := range margin.Components {
+			gross += c.Requirement
+		}
+		requirement += math.Max(gross, 0)
+		if g.shortCalls > 0 {
+			shares[g.underlying] = math.Max(shares[g.underlying]-100*g.shortCalls, 0)
+		}
+	}
+	return round2(requirement), round2(a.Cash + stockValue - requirement)
+}
+
+// equity is the account's cash plus its positions at their marks
+func (a *account) equity() float64 {
+	equity := a.Cash
+	for _, p := range a.Positions {
+		equity += p.MarketValue()
+	}
+	return equity
+}
+
+// positions returns the open positions by symbol
+func (a *account) positions() []Position {
+	positions := make([]Position, 0, len(a.Positions))
+	for _, p := range a.Positions {
+		positions = append(positions, *p)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions
+}
+
+// underlyings returns the symbols whose prices the account's positions
+// depend on
+func (a *account) underlyings() []string {
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, p := range a.Positions {
+		if !seen[p.Underlying] {
+			seen[p.Underlying] = true
+			symbols = append(symbols, p.Underlying)
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+func underlyingOf(symbol string) string {
+	if underlying, _, ok := ai_assistant.ParseOCCSymbol(symbol); ok {
+		return underlying
+	}
+	return strings.ToUpper(symbol)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,875 @@
+// Package paper is a simulated broker for running the assistant without
+// risking money. It implements execution.OrderRouter, filling limit orders
+// against live bid and ask with slippage, and keeps an account per user
+// with cash, positions and Reg-T margin that it marks to market and
+// settles at each expiration.
+package paper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/execution"
+)
+
+// maxLegs is the most legs the simulator accepts in one order, as Alpaca
+const maxLegs = 4
+
+// orderRetention is how long finished orders are kept
+const orderRetention = 30 * 24 * time.Hour
+
+var (
+	ErrInsufficientBuyingPower = errors.New("insufficient buying power")
+	ErrExceedsPosition         = errors.New("closes more than is held")
+)
+
+// Config sets up the simulated account and how it fills
+type Config struct {
+	StartingCash float64
+	Slippage     float64 // Per share past the bid or ask on every fill
+	Commission   float64 // Per option contract
+	AlwaysOpen   bool    // Fill outside regular trading hours too
+}
+
+// DefaultConfig starts with $100,000, a cent of slippage and $0.65 a
+// contract
+func DefaultConfig() Config {
+	return Config{
+		StartingCash: 100000,
+		Slippage:     0.01,
+		Commission:   0.65,
+	}
+}
+
+// order is a simulated order with what the broker needs to work it
+type order struct {
+	execution.BrokerOrder
+	UserID      string    `json:"user_id,omitempty"` // Account it trades
+	TimeInForce string    `json:"time_in_force"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	refusal     error     // Why fill rejected it
+}
+
+// view copies the broker's view of o
+func (o *order) view() *execution.BrokerOrder {
+	bo := o.BrokerOrder
+	bo.Legs = append([]execution.Leg(nil), o.Legs...)
+	return &bo
+}
+
+// state is everything the broker persists
+type state struct {
+	Accounts  map[string]*account `json:"accounts"` // By user ID
+	Spots     map[string]float64  `json:"spots"`    // Latest price of each underlying held or traded
+	Orders    map[string]*order   `json:"orders"`
+	LastClose string              `json:"last_close,omitempty"` // Last session settled, YYYY-MM-DD
+}
+
+// Broker is a paper trading broker. Each user trades their own account,
+// opened with the starting cash on their first order. Marketable orders
+// fill on submit and working orders fill once a tick or poll finds the
+// market at their limit; every order fills in full. Day orders expire, and
+// expiring options are exercised, assigned or expire worthless, at the
+// close. Holidays are treated as trading days. State is persisted to a
+// JSON file.
+type Broker struct {
+	mu       sync.Mutex
+	market   Market
+	path     string
+	config   Config
+	pnl      execution.PnLRecorder
+	logger   *logrus.Logger
+	location *time.Location
+	now      func() time.Time
+	state    state
+}
+
+// NewBroker loads the accounts from path, starting with none if the file
+// does not exist. An empty path keeps them in memory only. The P&L realized by fills and settlements is
+// reported to pnl, which may be nil.
+func NewBroker(market Market, path string, config Config, pnl execution.PnLRecorder, logger *logrus.Logger) (*Broker, error) {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		location = time.UTC
+	}
+	b := &Broker{
+		market:   market,
+		path:     path,
+		config:   config,
+		pnl:      pnl,
+		logger:   logger,
+		location: location,
+		now:      time.Now,
+		state: state{
+			Accounts: make(map[string]*account),
+			Spots:    make(map[string]float64),
+			Orders:   make(map[string]*order),
+		},
+	}
+	if path == "" {
+		return b, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read paper account: %w", err)
+	}
+	var loaded state
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("failed to parse paper account: %w", err)
+	}
+	if loaded.Accounts == nil {
+		loaded.Accounts = make(map[string]*account)
+	}
+	if loaded.Spots == nil {
+		loaded.Spots = make(map[string]float64)
+	}
+	for _, a := range loaded.Accounts {
+		if a.Positions == nil {
+			a.Positions = make(map[string]*Position)
+		}
+	}
+	if loaded.Orders == nil {
+		loaded.Orders = make(map[string]*order)
+	}
+	b.state = loaded
+	return b, nil
+}
+
+func (b *Broker) Name() string {
+	return "paper"
+}
+
+func (b *Broker) MaxLegs() int {
+	return maxLegs
+}
+
+// Submit accepts a limit order for the account of req.UserID and fills it
+// at once if it is marketable. Orders to close more than is held, or that
+// would leave the account short of buying power, are refused.
+func (b *Broker) Submit(ctx context.Context, req execution.OrderRequest) (*execution.BrokerOrder, error) {
+	if len(req.Legs) == 0 || len(req.Legs) > maxLegs || req.Quantity <= 0 {
+		return nil, fmt.Errorf("order needs 1 to %d legs and a quantity", maxLegs)
+	}
+	if len(req.Legs) == 1 && req.LimitPrice <= 0 {
+		return nil, fmt.Errorf("single-leg order needs a positive limit price")
+	}
+	tif := req.TimeInForce
+	if tif == "" {
+		tif = execution.TimeInForceDay
+	}
+
+	b.mu.Lock()
+	now := b.now()
+	for _, o := range b.state.Orders {
+		if req.ClientOrderID != "" && o.ClientOrderID == req.ClientOrderID {
+			b.mu.Unlock()
+			return nil, fmt.Errorf("client order id %s is already in use", req.ClientOrderID)
+		}
+	}
+	o := &order{
+		BrokerOrder: execution.BrokerOrder{
+			ID:            newOrderID(),
+			ClientOrderID: req.ClientOrderID,
+			Status:        execution.StatusNew,
+			Quantity:      req.Quantity,
+			LimitPrice:    req.LimitPrice,
+			UpdatedAt:     now,
+		},
+		UserID:      req.UserID,
+		TimeInForce: tif,
+		SubmittedAt: now,
+	}
+	for _, leg := range req.Legs {
+		if leg.Ratio <= 0 {
+			leg.Ratio = 1
+		}
+		if leg.Multiplier <= 0 {
+			leg.Multiplier = newPosition(leg.Symbol, now).Multiplier
+		}
+		leg.FilledQty, leg.AvgFillPrice = 0, 0
+		if leg.Intent == execution.IntentClose {
+			if err := b.checkClose(req.UserID, leg, req.Quantity*leg.Ratio); err != nil {
+				b.mu.Unlock()
+				return nil, err
+			}
+		}
+		o.Legs = append(o.Legs, leg)
+	}
+	if o.ClientOrderID == "" {
+		o.ClientOrderID = o.ID
+	}
+	b.state.Orders[o.ID] = o
+	err := b.save()
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.open(now) {
+		if err := b.match(ctx, o.ID); err != nil {
+			b.logger.WithError(err).WithField("order_id", o.ID).Debug("Paper order not matched on submit")
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if o.Status == execution.StatusRejected {
+		delete(b.state.Orders, o.ID)
+		if err := b.save(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("order %s refused: %w", o.ClientOrderID, o.refusal)
+	}
+	return o.view(), nil
+}
+
+// checkClose refuses a closing leg for more than the position it closes
+// in userID's account; callers hold b.mu
+func (b *Broker) checkClose(userID string, leg execution.Leg, quantity int) error {
+	held := 0
+	if a := b.state.Accounts[userID]; a != nil && a.Positions[leg.Symbol] != nil {
+		held = a.Positions[leg.Symbol].Quantity
+	}
+	if (leg.Side == execution.SideSell && held < quantity) || (leg.Side == execution.SideBuy && -held < quantity) {
+		return fmt.Errorf("%w: cannot %s to close %d %s, position is %d", ErrExceedsPosition, leg.Side, quantity, leg.Symbol, held)
+	}
+	return nil
+}
+
+// Get returns the order, first filling it if the market has reached its
+// limit
+func (b *Broker) Get(ctx context.Context, id string) (*execution.BrokerOrder, error) {
+	if b.open(b.now()) {
+		if err := b.match(ctx, id); err != nil {
+			b.logger.WithError(err).WithField("order_id", id).Debug("Paper order not matched")
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o := b.state.Orders[id]
+	if o == nil {
+		return nil, fmt.Errorf("%w: %s", execution.ErrOrderNotFound, id)
+	}
+	return o.view(), nil
+}
+
+func (b *Broker) Cancel(ctx context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o := b.state.Orders[id]
+	if o == nil {
+		return fmt.Errorf("%w: %s", execution.ErrOrderNotFound, id)
+	}
+	if execution.IsTerminal(o.Status) {
+		return fmt.Errorf("%w: %s is %s", execution.ErrNotCancelable, id, o.Status)
+	}
+	o.Status, o.UpdatedAt = execution.StatusCanceled, b.now()
+	return b.save()
+}
+
+// Replace replaces a working order with one for quantity at limitPrice,
+// which fills at once if it is marketable
+func (b *Broker) Replace(ctx context.Context, id string, quantity int, limitPrice float64) (*execution.BrokerOrder, error) {
+	b.mu.Lock()
+	old := b.state.Orders[id]
+	if old == nil {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", execution.ErrOrderNotFound, id)
+	}
+	if execution.IsTerminal(old.Status) {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s is %s", execution.ErrNotCancelable, id, old.Status)
+	}
+	now := b.now()
+	o := &order{
+		BrokerOrder: execution.BrokerOrder{
+			ID:         newOrderID(),
+			Status:     execution.StatusNew,
+			Quantity:   quantity,
+			LimitPrice: limitPrice,
+			UpdatedAt:  now,
+		},
+		UserID:      old.UserID,
+		TimeInForce: old.TimeInForce,
+		SubmittedAt: now,
+	}
+	o.ClientOrderID = o.ID
+	for _, leg := range old.Legs {
+		leg.FilledQty, leg.AvgFillPrice = 0, 0
+		o.Legs = append(o.Legs, leg)
+	}
+	old.Status, old.ReplacedBy, old.UpdatedAt = execution.StatusReplaced, o.ID, now
+	b.state.Orders[o.ID] = o
+	err := b.save()
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if b.open(now) {
+		if err := b.match(ctx, o.ID); err != nil {
+			b.logger.WithError(err).WithField("order_id", o.ID).Debug("Paper order not matched on replace")
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return o.view(), nil
+}
+
+// match fills the working orders among ids, or every working order when
+// none are given, whose limits the market has reached. Quotes are fetched
+// outside the lock; orders with a leg that cannot be quoted keep working.
+func (b *Broker) match(ctx context.Context, ids ...string) error {
+	b.mu.Lock()
+	var working []*order
+	var symbols []string
+	seen := make(map[string]bool)
+	add := func(symbol string) {
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	for _, id := range b.workingIDs(ids) {
+		o := b.state.Orders[id]
+		working = append(working, o)
+		for _, leg := range o.Legs {
+			add(leg.Symbol)
+			add(underlyingOf(leg.Symbol))
+		}
+	}
+	b.mu.Unlock()
+	if len(working) == 0 {
+		return nil
+	}
+
+	quotes, err := b.quotes(ctx, symbols)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.updateSpots(quotes)
+	now := b.now()
+	changed := false
+	for _, o := range working {
+		if !execution.IsTerminal(o.Status) && b.fill(o, quotes, now) {
+			changed = true
+		}
+	}
+	if changed {
+		if saveErr := b.save(); saveErr != nil {
+			return errors.Join(err, saveErr)
+		}
+	}
+	return err
+}
+
+// workingIDs returns the working orders among ids, or all of them oldest
+// first so earlier orders fill first; callers hold b.mu
+func (b *Broker) workingIDs(ids []string) []string {
+	if len(ids) == 0 {
+		for id := range b.state.Orders {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			oi, oj := b.state.Orders[ids[i]], b.state.Orders[ids[j]]
+			if !oi.SubmittedAt.Equal(oj.SubmittedAt) {
+				return oi.SubmittedAt.Before(oj.SubmittedAt)
+			}
+			return ids[i] < ids[j]
+		})
+	}
+	var working []string
+	for _, id := range ids {
+		if o := b.state.Orders[id]; o != nil && !execution.IsTerminal(o.Status) {
+			working = append(working, id)
+		}
+	}
+	return working
+}
+
+// fill fills o in full if buying its legs at the ask and selling them at
+// the bid, less slippage, is within its limit. It rejects o instead if the
+// account cannot afford it, or if a closing leg would close more than is
+// held now, e.g. because another close filled first; callers hold b.mu
+func (b *Broker) fill(o *order, quotes map[string]Quote, now time.Time) bool {
+	prices := make([]float64, len(o.Legs))
+	net := 0.0
+	for i, leg := range o.Legs {
+		q, ok := quotes[leg.Symbol]
+		if !ok {
+			return false
+		}
+		prices[i] = round4(q.Ask + b.config.Slippage)
+		if leg.Side == execution.SideSell {
+			prices[i] = round4(math.Max(q.Bid-b.config.Slippage, 0))
+			net -= float64(leg.Ratio) * prices[i]
+		} else {
+			net += float64(leg.Ratio) * prices[i]
+		}
+	}
+	// A single leg's limit is a price; a multi-leg limit is a signed net
+	limit := o.LimitPrice
+	if len(o.Legs) == 1 && o.Legs[0].Side == execution.SideSell {
+		limit = -limit
+	}
+	if net > limit+1e-9 {
+		return false
+	}
+
+	for _, leg := range o.Legs {
+		if leg.Intent != execution.IntentClose {
+			continue
+		}
+		if err := b.checkClose(o.UserID, leg, o.Quantity*leg.Ratio); err != nil {
+			b.reject(o, err, now)
+			return true
+		}
+	}
+
+	before := b.userAccount(o.UserID)
+	_, available := before.buyingPower(b.state.Spots)
+	trial := before.clone()
+	for i, leg := range o.Legs {
+		trial.trade(ActivityFill, o.ID, leg.Symbol, leg.Side, o.Quantity*leg.Ratio, prices[i], b.commission(leg, o.Quantity*leg.Ratio), now)
+	}
+	if _, after := trial.buyingPower(b.state.Spots); after < 0 && after < available {
+		b.reject(o, fmt.Errorf("%w: needs $%.2f more", ErrInsufficientBuyingPower, -after), now)
+		return true
+	}
+
+	realized := 0.0
+	for i, leg := range o.Legs {
+		quantity := o.Quantity * leg.Ratio
+		realized += before.trade(ActivityFill, o.ID, leg.Symbol, leg.Side, quantity, prices[i], b.commission(leg, quantity), now)
+		o.Legs[i].FilledQty, o.Legs[i].AvgFillPrice = quantity, prices[i]
+	}
+	b.recordPnL(o.UserID, realized)
+	o.Status, o.FilledQty, o.UpdatedAt = execution.StatusFilled, o.Quantity, now
+	o.AvgFillPrice = round4(net)
+	if len(o.Legs) == 1 {
+		o.AvgFillPrice = prices[0]
+	}
+	b.logger.WithFields(logrus.Fields{
+		"order_id": o.ID,
+		"quantity": o.Quantity,
+		"price":    o.AvgFillPrice,
+		"limit":    o.LimitPrice,
+	}).Info("Paper order filled")
+	return true
+}
+
+// reject rejects o for err; callers hold b.mu
+func (b *Broker) reject(o *order, err error, now time.Time) {
+	o.Status, o.UpdatedAt = execution.StatusRejected, now
+	o.Reason, o.refusal = err.Error(), err
+	b.logger.WithError(err).WithFields(logrus.Fields{"order_id": o.ID, "user_id": o.UserID}).Warn("Paper order rejected")
+}
+
+func (b *Broker) commission(leg execution.Leg, quantity int) float64 {
+	if newPosition(leg.Symbol, time.Time{}).IsOption() {
+		return round2(b.config.Commission * float64(quantity))
+	}
+	return 0
+}
+
+// Tick fills the working orders the market has reached, marks positions to
+// market and, once the close has passed, expires day orders and settles
+// the options expiring that day
+func (b *Broker) Tick(ctx context.Context) error {
+	var errs []error
+	if b.open(b.now()) {
+		if err := b.match(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := b.mark(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := b.close(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// mark prices every position in every account at its mid
+func (b *Broker) mark(ctx context.Context) error {
+	b.mu.Lock()
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, a := range b.state.Accounts {
+		for _, symbol := range a.underlyings() {
+			if !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+		for symbol, p := range a.Positions {
+			if p.IsOption() && !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	b.mu.Unlock()
+	if len(symbols) == 0 {
+		return nil
+	}
+
+	quotes, err := b.quotes(ctx, symbols)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.updateSpots(quotes)
+	now := b.now()
+	for _, a := range b.state.Accounts {
+		for symbol, p := range a.Positions {
+			if q, ok := quotes[symbol]; ok {
+				p.Mark, p.MarkedAt = round4(q.Mid()), now
+			}
+		}
+	}
+	if saveErr := b.save(); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// close expires day orders and settles expiring options once a session's
+// close has passed. An option whose underlying cannot be priced is left
+// for the next tick, and the session with it.
+func (b *Broker) close(ctx context.Context) error {
+	b.mu.Lock()
+	session, closedAt := b.lastSession(b.now())
+	if session <= b.state.LastClose {
+		b.mu.Unlock()
+		return nil
+	}
+	var underlyings []string
+	seen := make(map[string]bool)
+	for _, a := range b.state.Accounts {
+		for _, p := range a.Positions {
+			if p.IsOption() && p.Expiration <= session && !seen[p.Underlying] {
+				seen[p.Underlying] = true
+				underlyings = append(underlyings, p.Underlying)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	quotes, err := b.quotes(ctx, underlyings)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.updateSpots(quotes)
+	now := b.now()
+
+	changes := 0
+	for _, id := range b.workingIDs(nil) {
+		o := b.state.Orders[id]
+		if o.TimeInForce == execution.TimeInForceDay && !o.SubmittedAt.After(closedAt) || o.expiresBy(session) {
+			o.Status, o.UpdatedAt = execution.StatusExpired, now
+			changes++
+		}
+	}
+
+	settled := true
+	for _, userID := range b.userIDs() {
+		a := b.state.Accounts[userID]
+		symbols := make([]string, 0, len(a.Positions))
+		for symbol := range a.Positions {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		for _, symbol := range symbols {
+			p := a.Positions[symbol]
+			if p == nil || !p.IsOption() || p.Expiration > session {
+				continue
+			}
+			q, ok := quotes[p.Underlying]
+			if !ok {
+				settled = false
+				continue
+			}
+			b.settle(a, userID, p, q.Mid(), now)
+			changes++
+		}
+	}
+
+	for id, o := range b.state.Orders {
+		if execution.IsTerminal(o.Status) && now.Sub(o.UpdatedAt) > orderRetention {
+			delete(b.state.Orders, id)
+		}
+	}
+	if settled {
+		b.state.LastClose = session
+		entry := b.logger.WithFields(logrus.Fields{"session": session, "changes": changes})
+		if changes > 0 {
+			entry.Info("Paper account settled at the close")
+		} else {
+			entry.Debug("Paper account settled at the close")
+		}
+	}
+	if saveErr := b.save(); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// settle closes an expiring option in userID's account a with the
+// underlying at spot. Options at least a cent in the money are exercised or
+// assigned, closing at their intrinsic value and trading the shares at
+// spot, which nets to the strike. The rest expire worthless. Callers hold
+// b.mu.
+func (b *Broker) settle(a *account, userID string, p *Position, spot float64, now time.Time) {
+	quantity := abs(p.Quantity)
+	closing, kind := execution.SideSell, ActivityExercise
+	if p.Quantity < 0 {
+		closing, kind = execution.SideBuy, ActivityAssign
+	}
+	fields := logrus.Fields{"user_id": userID, "symbol": p.Symbol, "quantity": p.Quantity, "spot": spot}
+
+	intrinsic := round4(p.intrinsic(spot))
+	if intrinsic < 0.01 {
+		b.recordPnL(userID, a.trade(ActivityExpire, "", p.Symbol, closing, quantity, 0, 0, now))
+		b.logger.WithFields(fields).Info("Paper option expired worthless")
+		return
+	}
+
+	// Long calls and short puts take delivery; long puts and short calls
+	// deliver
+	stockSide := execution.SideBuy
+	if (p.Type == ai_assistant.PositionPut) == (p.Quantity > 0) {
+		stockSide = execution.SideSell
+	}
+	shares := quantity * p.Multiplier
+	realized := a.trade(kind, "", p.Symbol, closing, quantity, intrinsic, 0, now)
+	realized += a.trade(kind, "", p.Underlying, stockSide, shares, spot, 0, now)
+	b.recordPnL(userID, realized)
+	fields["kind"] = kind
+	b.logger.WithFields(fields).Info("Paper option settled in shares")
+}
+
+// recordPnL reports P&L realized in userID's account, with the account's
+// value after it; callers hold b.mu
+func (b *Broker) recordPnL(userID string, realized float64) {
+	if b.pnl == nil || math.Abs(realized) < 0.005 {
+		return
+	}
+	if _, err := b.pnl.RecordRealizedPnL(userID, round2(b.userAccount(userID).equity()), round2(realized)); err != nil {
+		b.logger.WithError(err).WithField("user_id", userID).Error("Failed to record paper realized P&L")
+	}
+}
+
+// expiresBy reports whether any of o's legs expires on or before session
+func (o *order) expiresBy(session string) bool {
+	for _, leg := range o.Legs {
+		if p := newPosition(leg.Symbol, time.Time{}); p.IsOption() && p.Expiration <= session {
+			return true
+		}
+	}
+	return false
+}
+
+// open reports whether now is in the regular session, 9:30 to 16:00 New
+// York time on weekdays
+func (b *Broker) open(now time.Time) bool {
+	if b.config.AlwaysOpen {
+		return true
+	}
+	ny := now.In(b.location)
+	if ny.Weekday() == time.Saturday || ny.Weekday() == time.Sunday {
+		return false
+	}
+	minutes := ny.Hour()*60 + ny.Minute()
+	return minutes >= 9*60+30 && minutes < 16*60
+}
+
+// lastSession returns the date of the latest weekday whose close has
+// passed, and when it closed
+func (b *Broker) lastSession(now time.Time) (string, time.Time) {
+	ny := now.In(b.location)
+	day := time.Date(ny.Year(), ny.Month(), ny.Day(), 16, 0, 0, 0, b.location)
+	if ny.Before(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day.Format("2006-01-02"), day
+}
+
+// quotes fetches each symbol's quote, returning those it could along with
+// the errors for the rest
+func (b *Broker) quotes(ctx context.Context, symbols []string) (map[string]Quote, error) {
+	quotes := make(map[string]Quote, len(symbols))
+	var errs []error
+	for _, symbol := range symbols {
+		if ctx.Err() != nil {
+			return quotes, ctx.Err()
+		}
+		q, err := b.market.Quote(ctx, symbol)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		quotes[symbol] = q
+	}
+	return quotes, errors.Join(errs...)
+}
+
+// updateSpots records the mid of each underlying quoted; callers hold b.mu
+func (b *Broker) updateSpots(quotes map[string]Quote) {
+	for symbol, q := range quotes {
+		if underlyingOf(symbol) == symbol && q.Mid() > 0 {
+			b.state.Spots[symbol] = round4(q.Mid())
+		}
+	}
+}
+
+// Account is a snapshot of the paper account
+type Account struct {
+	StartingCash      float64                  `json:"starting_cash"`
+	Cash              float64                  `json:"cash"`
+	Equity            float64                  `json:"equity"`
+	MarginRequirement float64                  `json:"margin_requirement"`
+	BuyingPower       float64                  `json:"buying_power"`
+	RealizedPnL       float64                  `json:"realized_pnl"`
+	UnrealizedPnL     float64                  `json:"unrealized_pnl"`
+	Commissions       float64                  `json:"commissions"`
+	Positions         []Position               `json:"positions"`
+	WorkingOrders     []*execution.BrokerOrder `json:"working_orders"`
+	Activity          []Activity               `json:"activity"` // Most recent last
+	LastClose         string                   `json:"last_close,omitempty"`
+}
+
+// Account returns userID's account as of its latest marks, with up to
+// activity of its most recent activity. A user who has not traded has the
+// starting cash.
+func (b *Broker) Account(userID string, activity int) *Account {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a := b.state.Accounts[userID]
+	if a == nil {
+		a = newAccount(b.config.StartingCash)
+	}
+	requirement, buyingPower := a.buyingPower(b.state.Spots)
+	snapshot := &Account{
+		StartingCash:      a.StartingCash,
+		Cash:              round2(a.Cash),
+		MarginRequirement: requirement,
+		BuyingPower:       buyingPower,
+		RealizedPnL:       round2(a.RealizedPnL),
+		Commissions:       round2(a.Commissions),
+		Positions:         a.positions(),
+		WorkingOrders:     []*execution.BrokerOrder{},
+		LastClose:         b.state.LastClose,
+	}
+	for _, p := range snapshot.Positions {
+		snapshot.UnrealizedPnL += p.UnrealizedPnL()
+	}
+	snapshot.Equity, snapshot.UnrealizedPnL = round2(a.equity()), round2(snapshot.UnrealizedPnL)
+	for _, id := range b.workingIDs(nil) {
+		if o := b.state.Orders[id]; o.UserID == userID {
+			snapshot.WorkingOrders = append(snapshot.WorkingOrders, o.view())
+		}
+	}
+	start := max(len(a.Activity)-activity, 0)
+	snapshot.Activity = append([]Activity{}, a.Activity[start:]...)
+	return snapshot
+}
+
+// Users returns the IDs of the users with an account, in order
+func (b *Broker) Users() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.userIDs()
+}
+
+// userIDs returns the IDs of the accounts in order; callers hold b.mu
+func (b *Broker) userIDs() []string {
+	ids := make([]string, 0, len(b.state.Accounts))
+	for id := range b.state.Accounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// userAccount returns userID's account, opening it with the starting cash
+// on first use; callers hold b.mu
+func (b *Broker) userAccount(userID string) *account {
+	a := b.state.Accounts[userID]
+	if a == nil {
+		a = newAccount(b.config.StartingCash)
+		b.state.Accounts[userID] = a
+	}
+	return a
+}
+
+// Start ticks the broker every interval until ctx is cancelled
+func (b *Broker) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.Tick(ctx); err != nil && ctx.Err() == nil {
+					b.logger.WithError(err).Warn("Failed to update paper account")
+				}
+			}
+		}
+	}()
+}
+
+// save writes the account atomically; callers hold b.mu
+func (b *Broker) save() error {
+	if b.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(b.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0700); err != nil {
+		return fmt.Errorf("failed to create paper account directory: %w", err)
+	}
+	tmp := b.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write paper account: %w", err)
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return fmt.Errorf("failed to replace paper account: %w", err)
+	}
+	return nil
+}
+
+func newOrderID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "paper_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,345 @@
+package paper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/execution"
+)
+
+// fakeMarket quotes from a map
+type fakeMarket map[string]Quote
+
+func (m fakeMarket) Quote(ctx context.Context, symbol string) (Quote, error) {
+	q, ok := m[symbol]
+	if !ok {
+		return Quote{}, fmt.Errorf("no quote for %s", symbol)
+	}
+	return q, nil
+}
+
+// realizedPnL sums the P&L reported for each user
+type realizedPnL map[string]float64
+
+func (r realizedPnL) RecordRealizedPnL(userID string, accountValue, amount float64) (*ai_assistant.DailyPnL, error) {
+	r[userID] += amount
+	return nil, nil
+}
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+func option(t *testing.T, optionType string, strike float64, expiration string) string {
+	t.Helper()
+	symbol, err := ai_assistant.OCCSymbol("SPY", ai_assistant.OptionLeg{Type: optionType, Strike: strike, Expiration: expiration})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return symbol
+}
+
+// newTestBroker returns a broker opening accounts with cash, a cent of
+// slippage and $0.65 a contract, its clock at the returned time
+func newTestBroker(t *testing.T, market Market, cash float64) (*Broker, realizedPnL, *time.Time) {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	pnl := realizedPnL{}
+	config := DefaultConfig()
+	config.StartingCash = cash
+	b, err := NewBroker(market, "", config, pnl, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 19, 11, 0, 0, 0, newYork)
+	b.now = func() time.Time { return now }
+	return b, pnl, &now
+}
+
+func leg(symbol, side, intent string) execution.Leg {
+	return execution.Leg{Symbol: symbol, Side: side, Intent: intent}
+}
+
+func submit(b *Broker, userID string, quantity int, limit float64, tif string, legs ...execution.Leg) (*execution.BrokerOrder, error) {
+	return b.Submit(context.Background(), execution.OrderRequest{
+		UserID:      userID,
+		Legs:        legs,
+		Quantity:    quantity,
+		LimitPrice:  limit,
+		TimeInForce: tif,
+	})
+}
+
+func TestBrokerFillsAtBidAskWithSlippage(t *testing.T) {
+	c500 := option(t, ai_assistant.PositionCall, 500, "2026-12-18")
+	c510 := option(t, ai_assistant.PositionCall, 510, "2026-12-18")
+	market := fakeMarket{
+		"SPY": {Bid: 504.99, Ask: 505.01},
+		c500:  {Bid: 9.9, Ask: 10.1},
+		c510:  {Bid: 3.9, Ask: 4.1},
+	}
+
+	tests := []struct {
+		name     string
+		quantity int
+		limit    float64
+		legs     []execution.Leg
+		status   string
+		price    float64 // Average fill price
+		cash     float64 // Change in cash
+	}{
+		{"bought call at the ask", 1, 10.2, []execution.Leg{leg(c500, execution.SideBuy, execution.IntentOpen)}, execution.StatusFilled, 10.11, -1011.65},
+		{"sold call at the bid", 1, 3.85, []execution.Leg{leg(c510, execution.SideSell, execution.IntentOpen)}, execution.StatusFilled, 3.89, 388.35},
+		{"debit spread", 2, 6.25, []execution.Leg{leg(c500, execution.SideBuy, execution.IntentOpen), leg(c510, execution.SideSell, execution.IntentOpen)}, execution.StatusFilled, 6.22, -1246.6},
+		{"credit spread", 1, -5.75, []execution.Leg{leg(c500, execution.SideSell, execution.IntentOpen), leg(c510, execution.SideBuy, execution.IntentOpen)}, execution.StatusFilled, -5.78, 576.7},
+		{"stock without commission", 10, 506, []execution.Leg{leg("SPY", execution.SideBuy, execution.IntentOpen)}, execution.StatusFilled, 505.02, -5050.2},
+		{"ask above the limit", 1, 10, []execution.Leg{leg(c500, execution.SideBuy, execution.IntentOpen)}, execution.StatusNew, 0, 0},
+		{"credit short of the limit", 1, -6, []execution.Leg{leg(c500, execution.SideSell, execution.IntentOpen), leg(c510, execution.SideBuy, execution.IntentOpen)}, execution.StatusNew, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _, _ := newTestBroker(t, market, 100000)
+			bo, err := submit(b, "alice", tt.quantity, tt.limit, execution.TimeInForceDay, tt.legs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bo.Status != tt.status || math.Abs(bo.AvgFillPrice-tt.price) > 1e-9 {
+				t.Fatalf("order = %s at %v, want %s at %v", bo.Status, bo.AvgFillPrice, tt.status, tt.price)
+			}
+			account := b.Account("alice", 0)
+			if cash := round2(account.Cash - account.StartingCash); cash != tt.cash {
+				t.Errorf("cash change = %v, want %v", cash, tt.cash)
+			}
+			if working := len(account.WorkingOrders); (working == 1) != (tt.status == execution.StatusNew) {
+				t.Errorf("working orders = %d with the order %s", working, tt.status)
+			}
+		})
+	}
+}
+
+func TestBrokerFillsWorkingOrderWhenMarketReachesLimit(t *testing.T) {
+	c500 := option(t, ai_assistant.PositionCall, 500, "2026-12-18")
+	market := fakeMarket{"SPY": {Bid: 504.99, Ask: 505.01}, c500: {Bid: 9.9, Ask: 10.1}}
+	b, _, _ := newTestBroker(t, market, 100000)
+
+	bo, err := submit(b, "alice", 1, 10, execution.TimeInForceDay, leg(c500, execution.SideBuy, execution.IntentOpen))
+	if err != nil || bo.Status != execution.StatusNew {
+		t.Fatalf("order = %v, %v, want working", bo, err)
+	}
+
+	market[c500] = Quote{Bid: 9.8, Ask: 9.95}
+	bo, err = b.Get(context.Background(), bo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bo.Status != execution.StatusFilled || bo.AvgFillPrice != 9.96 {
+		t.Errorf("order = %s at %v, want filled at 9.96", bo.Status, bo.AvgFillPrice)
+	}
+
+	replaced, err := b.Replace(context.Background(), bo.ID, 1, 10)
+	if !errors.Is(err, execution.ErrNotCancelable) {
+		t.Errorf("replace of a filled order = %v, %v, want %v", replaced, err, execution.ErrNotCancelable)
+	}
+}
+
+func TestBrokerRejectsOrderBeyondBuyingPower(t *testing.T) {
+	c500 := option(t, ai_assistant.PositionCall, 500, "2026-12-18")
+	market := fakeMarket{"SPY": {Bid: 504.99, Ask: 505.01}, c500: {Bid: 9.9, Ask: 10.1}}
+	b, _, _ := newTestBroker(t, market, 1500)
+
+	if _, err := submit(b, "alice", 2, 10.2, execution.TimeInForceDay, leg(c500, execution.SideBuy, execution.IntentOpen)); !errors.Is(err, ErrInsufficientBuyingPower) {
+		t.Fatalf("error = %v, want %v", err, ErrInsufficientBuyingPower)
+	}
+	account := b.Account("alice", 10)
+	if account.Cash != 1500 || len(account.Positions) != 0 || len(account.WorkingOrders) != 0 || len(account.Activity) != 0 {
+		t.Errorf("account = %+v, want it untouched", account)
+	}
+
+	// One contract is affordable
+	if bo, err := submit(b, "alice", 1, 10.2, execution.TimeInForceDay, leg(c500, execution.SideBuy, execution.IntentOpen)); err != nil || bo.Status != execution.StatusFilled {
+		t.Errorf("order = %v, %v, want filled", bo, err)
+	}
+}
+
+func TestBrokerRejectsWorkingCloseBeyondPosition(t *testing.T) {
+	c500 := option(t, ai_assistant.PositionCall, 500, "2026-12-18")
+	market := fakeMarket{"SPY": {Bid: 504.99, Ask: 505.01}, c500: {Bid: 9.9, Ask: 10.1}}
+	b, _, now := newTestBroker(t, market, 100000)
+	if _, err := submit(b, "alice", 1, 10.2, execution.TimeInForceDay, leg(c500, execution.SideBuy, execution.IntentOpen)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each close passes on its own while both are working
+	first, err := submit(b, "alice", 1, 11, execution.TimeInForceDay, leg(c500, execution.SideSell, execution.IntentClose))
+	if err != nil || first.Status != execution.StatusNew {
+		t.Fatalf("first close = %v, %v, want working", first, err)
+	}
+	*now = now.Add(time.Second)
+	second, err := submit(b, "alice", 1, 11, execution.TimeInForceDay, leg(c500, execution.SideSell, execution.IntentClose))
+	if err != nil || second.Status != execution.StatusNew {
+		t.Fatalf("second close = %v, %v, want working", second, err)
+	}
+	if _, err := submit(b, "alice", 2, 11, execution.TimeInForceDay, leg(c500, execution.SideSell, execution.IntentClose)); !errors.Is(err, ErrExceedsPosition) {
+		t.Fatalf("close of 2 = %v, want %v", err, ErrExceedsPosition)
+	}
+
+	// Once one fills, the other would leave the account short
+	market[c500] = Quote{Bid: 11.2, Ask: 11.4}
+	if err := b.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first, _ = b.Get(context.Background(), first.ID)
+	second, _ = b.Get(context.Background(), second.ID)
+	if first.Status != execution.StatusFilled || second.Status != execution.StatusRejected || !strings.Contains(second.Reason, "position is 0") {
+		t.Fatalf("closes = %s and %s (%s), want the first filled and the second rejected", first.Status, second.Status, second.Reason)
+	}
+	if positions := b.Account("alice", 0).Positions; len(positions) != 0 {
+		t.Errorf("positions = %+v, want none", positions)
+	}
+}
+
+func TestBrokerExpiresDayOrdersAtClose(t *testing.T) {
+	c500 := option(t, ai_assistant.PositionCall, 500, "2026-12-18")
+	market := fakeMarket{"SPY": {Bid: 504.99, Ask: 505.01}, c500: {Bid: 9.9, Ask: 10.1}}
+	b, _, now := newTestBroker(t, market, 100000)
+
+	day, err := submit(b, "alice", 1, 9, execution.TimeInForceDay, leg(c500, execution.SideBuy, execution.IntentOpen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gtc, err := submit(b, "alice", 1, 9, execution.TimeInForceGTC, leg(c500, execution.SideBuy, execution.IntentOpen))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing expires before the close
+	*now = time.Date(2026, 10, 19, 15, 59, 0, 0, newYork)
+	if err := b.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if bo, _ := b.Get(context.Background(), day.ID); bo.Status != execution.StatusNew {
+		t.Fatalf("day order = %s before the close, want working", bo.Status)
+	}
+
+	*now = time.Date(2026, 10, 19, 16, 5, 0, 0, newYork)
+	if err := b.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if bo, _ := b.Get(context.Background(), day.ID); bo.Status != execution.StatusExpired {
+		t.Errorf("day order = %s after the close, want %s", bo.Status, execution.StatusExpired)
+	}
+	if bo, _ := b.Get(context.Background(), gtc.ID); bo.Status != execution.StatusNew {
+		t.Errorf("GTC order = %s after the close, want working", bo.Status)
+	}
+	if last := b.Account("alice", 0).LastClose; last != "2026-10-19" {
+		t.Errorf("last close = %q, want 2026-10-19", last)
+	}
+
+	// Orders after the close wait for the next session
+	if bo, err := submit(b, "alice", 1, 10.2, execution.TimeInForceDay, leg(c500, execution.SideBuy, execution.IntentOpen)); err != nil || bo.Status != execution.StatusNew {
+		t.Errorf("order after the close = %v, %v, want working", bo, err)
+	}
+}
+
+func TestBrokerSettlesExpiringOptions(t *testing.T) {
+	const expiration = "2026-10-23"
+	c500 := option(t, ai_assistant.PositionCall, 500, expiration)
+	p490 := option(t, ai_assistant.PositionPut, 490, expiration)
+
+	tests := []struct {
+		name     string
+		open     execution.Leg
+		limit    float64
+		spot     float64
+		kind     string
+		realized float64
+		shares   int // SPY held afterwards
+	}{
+		{"long call exercised", leg(c500, execution.SideBuy, execution.IntentOpen), 10.2, 512, ActivityExercise, 189, 100},
+		{"short put expires worthless", leg(p490, execution.SideSell, execution.IntentOpen), 1.9, 512, ActivityExpire, 199, 0},
+		{"short put assigned", leg(p490, execution.SideSell, execution.IntentOpen), 1.9, 480, ActivityAssign, -801, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			market := fakeMarket{
+				"SPY": {Bid: 504.99, Ask: 505.01},
+				c500:  {Bid: 9.9, Ask: 10.1},
+				p490:  {Bid: 2, Ask: 2.1},
+			}
+			b, pnl, now := newTestBroker(t, market, 100000)
+			*now = time.Date(2026, 10, 23, 11, 0, 0, 0, newYork)
+			if bo, err := submit(b, "alice", 1, tt.limit, execution.TimeInForceDay, tt.open); err != nil || bo.Status != execution.StatusFilled {
+				t.Fatalf("opening order = %v, %v, want filled", bo, err)
+			}
+
+			market["SPY"] = Quote{Bid: tt.spot - 0.01, Ask: tt.spot + 0.01}
+			*now = time.Date(2026, 10, 23, 16, 30, 0, 0, newYork)
+			if err := b.Tick(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			account := b.Account("alice", 10)
+			shares := 0
+			for _, p := range account.Positions {
+				if p.IsOption() {
+					t.Errorf("%s still held after expiration", p.Symbol)
+				} else if p.Symbol == "SPY" {
+					shares = p.Quantity
+				}
+			}
+			if shares != tt.shares {
+				t.Errorf("SPY shares = %d, want %d", shares, tt.shares)
+			}
+			if last := account.Activity[len(account.Activity)-1]; last.Kind != tt.kind {
+				t.Errorf("last activity = %s, want %s", last.Kind, tt.kind)
+			}
+			if got := round2(pnl["alice"]); got != tt.realized {
+				t.Errorf("realized P&L reported = %v, want %v", got, tt.realized)
+			}
+			if account.RealizedPnL != tt.realized {
+				t.Errorf("account realized P&L = %v, want %v", account.RealizedPnL, tt.realized)
+			}
+		})
+	}
+}
+
+func TestBrokerKeepsAccountsPerUser(t *testing.T) {
+	c500 := option(t, ai_assistant.PositionCall, 500, "2026-12-18")
+	market := fakeMarket{"SPY": {Bid: 504.99, Ask: 505.01}, c500: {Bid: 9.9, Ask: 10.1}}
+	b, pnl, _ := newTestBroker(t, market, 100000)
+
+	if _, err := submit(b, "alice", 1, 10.2, execution.TimeInForceDay, leg(c500, execution.SideBuy, execution.IntentOpen)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := submit(b, "bob", 1, 9.8, execution.TimeInForceDay, leg(c500, execution.SideSell, execution.IntentClose)); err == nil {
+		t.Fatal("bob closed alice's position")
+	}
+
+	bob := b.Account("bob", 10)
+	if bob.Cash != 100000 || len(bob.Positions) != 0 {
+		t.Errorf("bob's account = %+v, want the starting cash only", bob)
+	}
+	if users := b.Users(); len(users) != 1 || users[0] != "alice" {
+		t.Errorf("users = %v, want alice", users)
+	}
+
+	// Alice closes at the bid less slippage: (9.89 - 10.11) x 100
+	if bo, err := submit(b, "alice", 1, 9.8, execution.TimeInForceDay, leg(c500, execution.SideSell, execution.IntentClose)); err != nil || bo.Status != execution.StatusFilled {
+		t.Fatalf("closing order = %v, %v, want filled", bo, err)
+	}
+	if got := round2(pnl["alice"]); got != -22 || pnl["bob"] != 0 {
+		t.Errorf("realized P&L = %v, want -22 for alice only", pnl)
+	}
+	if activity := b.Account("alice", 1).Activity; len(activity) != 1 || activity[0].Closed != 1 {
+		t.Errorf("closing activity = %+v, want one contract closed", activity)
+	}
+}
@@ -0,0 +1,132 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// Quote is the bid and ask of a stock or option contract, per share
+type Quote struct {
+	Bid float64 `json:"bid"`
+	Ask float64 `json:"ask"`
+}
+
+// Mid is halfway between the bid and ask
+func (q Quote) Mid() float64 {
+	return (q.Bid + q.Ask) / 2
+}
+
+// Market quotes the contracts a Broker fills orders against and marks
+// positions to
+type Market interface {
+	// Quote returns the bid and ask of a stock or an OCC option symbol
+	Quote(ctx context.Context, symbol string) (Quote, error)
+}
+
+// Unlisted contracts are quoted this fraction of their model value either
+// side of it, and at least this many dollars
+const (
+	modelHalfSpread    = 0.025
+	minModelHalfSpread = 0.025
+)
+
+// ChainMarket quotes stocks from their latest quote and options from the
+// underlying's option chain. Contracts missing from the chain are quoted
+// around their model value, as the order limits for them were. Quotes and
+// chains are cached for a short while since every leg of every working
+// order is quoted on each tick.
+type ChainMarket struct {
+	mu    sync.Mutex
+	data  *ai_assistant.MarketDataAggregator
+	ttl   time.Duration
+	now   func() time.Time
+	cache map[string]*chainSnapshot
+}
+
+// chainSnapshot is an underlying's quote and, once an option on it has been
+// quoted, its chain
+type chainSnapshot struct {
+	quote  *ai_assistant.Quote
+	chains []*ai_assistant.OptionChain
+	at     time.Time
+}
+
+// NewChainMarket creates a ChainMarket that caches what it fetches for ttl
+func NewChainMarket(data *ai_assistant.MarketDataAggregator, ttl time.Duration) *ChainMarket {
+	return &ChainMarket{
+		data:  data,
+		ttl:   ttl,
+		now:   time.Now,
+		cache: make(map[string]*chainSnapshot),
+	}
+}
+
+func (m *ChainMarket) Quote(ctx context.Context, symbol string) (Quote, error) {
+	underlying, leg, ok := ai_assistant.ParseOCCSymbol(symbol)
+	if !ok {
+		snap, err := m.snapshot(ctx, strings.ToUpper(symbol), false)
+		if err != nil {
+			return Quote{}, err
+		}
+		return Quote{Bid: snap.quote.Bid, Ask: snap.quote.Ask}, nil
+	}
+
+	snap, err := m.snapshot(ctx, underlying, true)
+	if err != nil {
+		return Quote{}, err
+	}
+	for _, chain := range snap.chains {
+		if chain.Type == leg.Type && chain.Expiration == leg.Expiration && math.Abs(chain.Strike-leg.Strike) < 0.005 && chain.Bid > 0 && chain.Ask >= chain.Bid {
+			return Quote{Bid: chain.Bid, Ask: chain.Ask}, nil
+		}
+	}
+
+	data := &ai_assistant.AggregatedMarketData{
+		Quotes:  map[string]*ai_assistant.Quote{underlying: snap.quote},
+		Options: map[string][]*ai_assistant.OptionChain{underlying: snap.chains},
+	}
+	price, err := ai_assistant.NewRiskManager().WithMarketData(data).LegPrice(underlying, leg)
+	if err != nil {
+		return Quote{}, fmt.Errorf("failed to quote %s: %w", symbol, err)
+	}
+	half := math.Max(price*modelHalfSpread, minModelHalfSpread)
+	return Quote{Bid: math.Max(price-half, 0), Ask: price + half}, nil
+}
+
+// snapshot returns symbol's cached quote, and chain if withChains, fetching
+// whatever is missing or stale
+func (m *ChainMarket) snapshot(ctx context.Context, symbol string, withChains bool) (*chainSnapshot, error) {
+	m.mu.Lock()
+	snap := m.cache[symbol]
+	m.mu.Unlock()
+	if snap != nil && m.now().Sub(snap.at) < m.ttl && (!withChains || snap.chains != nil) {
+		return snap, nil
+	}
+
+	quote, err := m.data.LatestQuote(ctx, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to quote %s: %w", symbol, err)
+	}
+	fresh := &chainSnapshot{quote: quote, at: m.now()}
+	if withChains {
+		chains, err := m.data.OptionChains(ctx, symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s option chain: %w", symbol, err)
+		}
+		fresh.chains = chains
+		if fresh.chains == nil {
+			fresh.chains = []*ai_assistant.OptionChain{}
+		}
+	}
+
+	m.mu.Lock()
+	m.cache[symbol] = fresh
+	m.mu.Unlock()
+	return fresh, nil
+}
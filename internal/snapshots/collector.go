@@ -0,0 +1,95 @@
+package snapshots
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+// The Collector takes its snapshot this long after the 16:00 close, once
+// closing quotes have settled
+const collectAfterClose = 15 * time.Minute
+
+// Collector captures an end-of-day snapshot of the watchlist every trading
+// day, so the archive has a complete history whether or not anyone asked
+// for recommendations. Holidays are treated as trading days.
+type Collector struct {
+	store     *Store
+	data      *ai_assistant.MarketDataAggregator
+	watchlist func() []string
+	logger    *logrus.Logger
+	now       func() time.Time
+}
+
+// NewCollector creates a Collector snapshotting the symbols watchlist
+// returns
+func NewCollector(store *Store, data *ai_assistant.MarketDataAggregator, watchlist func() []string, logger *logrus.Logger) *Collector {
+	return &Collector{
+		store:     store,
+		data:      data,
+		watchlist: watchlist,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Start checks every interval until ctx is done whether today's snapshot is
+// due, and prunes snapshots past the store's retention
+func (c *Collector) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if c.due() {
+					if _, err := c.Collect(ctx); err != nil {
+						c.logger.WithError(err).Error("Failed to collect end-of-day snapshot")
+					}
+				}
+				if _, err := c.store.Prune(c.now()); err != nil {
+					c.logger.WithError(err).Error("Failed to prune market snapshots")
+				}
+			}
+		}
+	}()
+}
+
+// Collect snapshots the watchlist now and returns the snapshot's ID
+func (c *Collector) Collect(ctx context.Context) (string, error) {
+	symbols := c.watchlist()
+	if len(symbols) == 0 {
+		return "", fmt.Errorf("watchlist is empty")
+	}
+	data, err := c.data.AggregateDataForSymbols(ctx, symbols)
+	if err != nil {
+		return "", fmt.Errorf("failed to aggregate market data: %w", err)
+	}
+	id, err := c.store.Save(data, SourceEndOfDay)
+	if err != nil {
+		return "", err
+	}
+	c.logger.WithFields(logrus.Fields{"snapshot_id": id, "symbols": len(symbols)}).Info("Collected end-of-day snapshot")
+	return id, nil
+}
+
+// due reports whether it is after today's close on a weekday and today's
+// snapshot has not been taken
+func (c *Collector) due() bool {
+	now := c.now().In(newYork)
+	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
+		return false
+	}
+	collectAt := time.Date(now.Year(), now.Month(), now.Day(), 16, 0, 0, 0, newYork).Add(collectAfterClose)
+	return !now.Before(collectAt) && !c.store.HasSource(now, SourceEndOfDay)
+}
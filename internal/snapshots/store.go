@@ -0,0 +1,448 @@
+// Package snapshots keeps the market data recommendations were made from.
+// Each AggregatedMarketData is stored once per New York trading date as
+// gzip-compressed JSON in a directory for that date. It is named by the
+// SHA-256 of its date and content, leaving out when it was fetched, so the
+// same market state fetched twice is stored once. Recommendation records
+// link to the snapshot they came from so they can be reproduced, and the
+// backtester can replay the archive.
+//
+// Snapshots are gzip rather than zstd compressed so the archive can be
+// written and read with the standard library alone.
+package snapshots
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+	"vibetrade-claude/internal/events"
+)
+
+// Sources of snapshots
+const (
+	SourceRecommendation = "recommendation" // Data a recommendation request was answered from
+	SourceEndOfDay       = "eod"            // Captured by the Collector after the close
+)
+
+// ErrNotFound is returned for a snapshot that is not stored
+var ErrNotFound = errors.New("snapshot not found")
+
+const (
+	dateFormat = "2006-01-02"
+	indexFile  = "index.json"
+	objectExt  = ".json.gz"
+)
+
+// Entry describes a stored snapshot
+type Entry struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Symbols   []string  `json:"symbols"`
+	Sources   []string  `json:"sources"`
+	Size      int64     `json:"size"` // Compressed bytes
+}
+
+// Date is the New York trading date the snapshot was taken on
+func (e *Entry) Date() string {
+	return e.Timestamp.In(newYork).Format(dateFormat)
+}
+
+// Covers reports whether the snapshot has data for every one of symbols
+func (e *Entry) Covers(symbols []string) bool {
+	for _, symbol := range symbols {
+		if !e.has(symbol) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Entry) has(symbol string) bool {
+	symbol = strings.ToUpper(symbol)
+	for _, s := range e.Symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) hasSource(source string) bool {
+	for _, s := range e.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot is the stored content: the market data as the assistant saw it,
+// with the daily history and event calendar that are left out of prompts
+type Snapshot struct {
+	Data     *ai_assistant.AggregatedMarketData `json:"data"`
+	History  map[string][]ai_assistant.DailyBar `json:"history,omitempty"`
+	Calendar []events.Event                     `json:"calendar,omitempty"`
+}
+
+// MarketData returns the snapshot as the AggregatedMarketData it was
+// taken from, with History and Calendar restored
+func (s *Snapshot) MarketData() *ai_assistant.AggregatedMarketData {
+	data := *s.Data
+	data.History = s.History
+	data.Calendar = s.Calendar
+	return &data
+}
+
+// Store is a directory of snapshots with an in-memory index of them.
+// Snapshots older than the retention period are removed by Prune.
+type Store struct {
+	dir       string
+	retention time.Duration // 0 keeps snapshots forever
+	logger    *logrus.Logger
+
+	mu      sync.RWMutex
+	entries map[string]*Entry
+	byDate  map[string][]*Entry // Sorted by Timestamp
+}
+
+var newYork = loadNewYork()
+
+func loadNewYork() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// NewStore opens the snapshot store in dir, creating it if needed, and
+// loads the index of every date partition
+func NewStore(dir string, retention time.Duration, logger *logrus.Logger) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	s := &Store{
+		dir:       dir,
+		retention: retention,
+		logger:    logger,
+		entries:   make(map[string]*Entry),
+		byDate:    make(map[string][]*Entry),
+	}
+
+	partitions, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot partitions: %w", err)
+	}
+	for _, partition := range partitions {
+		if _, err := time.Parse(dateFormat, partition.Name()); err != nil || !partition.IsDir() {
+			continue
+		}
+		entries, err := s.readIndex(partition.Name())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			s.entries[entry.ID] = entry
+		}
+		s.byDate[partition.Name()] = entries
+	}
+	return s, nil
+}
+
+// Save stores data taken for source and returns its ID. Data identical to
+// a snapshot already stored that day, apart from when it was fetched, is
+// not stored again; its entry just gains source and keeps the first
+// fetch's timestamp.
+func (s *Store) Save(data *ai_assistant.AggregatedMarketData, source string) (string, error) {
+	taken := data.Timestamp
+	if taken.IsZero() {
+		taken = time.Now()
+	}
+	content, err := json.Marshal(Snapshot{Data: data, History: data.History, Calendar: data.Calendar})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	id, err := contentID(data, taken.In(newYork).Format(dateFormat))
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.RLock()
+	existing := s.entries[id]
+	stored := existing != nil && existing.hasSource(source)
+	s.mu.RUnlock()
+	if stored {
+		return id, nil
+	}
+
+	var compressed bytes.Buffer
+	if existing == nil {
+		zw := gzip.NewWriter(&compressed)
+		if _, err := zw.Write(content); err != nil {
+			return "", fmt.Errorf("failed to compress snapshot: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return "", fmt.Errorf("failed to compress snapshot: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[id]
+	if entry == nil {
+		entry = &Entry{
+			ID:        id,
+			Timestamp: taken,
+			Symbols:   snapshotSymbols(data),
+			Size:      int64(compressed.Len()),
+		}
+		date := entry.Date()
+		if err := os.MkdirAll(filepath.Join(s.dir, date), 0755); err != nil {
+			return "", fmt.Errorf("failed to create snapshot partition: %w", err)
+		}
+		if err := writeFile(s.objectPath(date, id), compressed.Bytes()); err != nil {
+			return "", err
+		}
+		s.entries[id] = entry
+		s.byDate[date] = insertSorted(s.byDate[date], entry)
+	}
+	if !entry.hasSource(source) {
+		entry.Sources = append(entry.Sources, source)
+	}
+	if err := s.writeIndex(entry.Date()); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// contentID is the SHA-256 of data on date without its fetch time
+func contentID(data *ai_assistant.AggregatedMarketData, date string) (string, error) {
+	unstamped := *data
+	unstamped.Timestamp = time.Time{}
+	content, err := json.Marshal(Snapshot{Data: &unstamped, History: data.History, Calendar: data.Calendar})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(date + "\x00"))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get returns the entry for id
+func (s *Store) Get(id string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry := s.entries[id]
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	copied := *entry
+	return &copied, nil
+}
+
+// Load reads the snapshot with id
+func (s *Store) Load(id string) (*Snapshot, *Entry, error) {
+	entry, err := s.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(s.objectPath(entry.Date(), id))
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress snapshot %s: %w", id, err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress snapshot %s: %w", id, err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse snapshot %s: %w", id, err)
+	}
+	if snapshot.Data == nil {
+		return nil, nil, fmt.Errorf("snapshot %s has no market data", id)
+	}
+	return &snapshot, entry, nil
+}
+
+// Find returns the latest snapshot taken at or before at that covers every
+// one of symbols
+func (s *Store) Find(at time.Time, symbols []string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dates := s.dates()
+	for i := len(dates) - 1; i >= 0; i-- {
+		entries := s.byDate[dates[i]]
+		for j := len(entries) - 1; j >= 0; j-- {
+			if entry := entries[j]; !entry.Timestamp.After(at) && entry.Covers(symbols) {
+				copied := *entry
+				return &copied, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w at or before %s for %s", ErrNotFound, at.Format(time.RFC3339), strings.Join(symbols, ","))
+}
+
+// List returns the snapshots taken from from to to inclusive that cover
+// every one of symbols, oldest first
+func (s *Store) List(from, to time.Time, symbols []string) []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []Entry
+	for _, date := range s.dates() {
+		for _, entry := range s.byDate[date] {
+			if !entry.Timestamp.Before(from) && !entry.Timestamp.After(to) && entry.Covers(symbols) {
+				out = append(out, *entry)
+			}
+		}
+	}
+	return out
+}
+
+// HasSource reports whether a snapshot from source was taken on the New
+// York trading date of day
+func (s *Store) HasSource(day time.Time, source string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.byDate[day.In(newYork).Format(dateFormat)] {
+		if entry.hasSource(source) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune removes the date partitions older than the retention period,
+// returning how many snapshots were removed
+func (s *Store) Prune(now time.Time) (int, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-s.retention).In(newYork).Format(dateFormat)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, date := range s.dates() {
+		if date >= cutoff {
+			break
+		}
+		if err := os.RemoveAll(filepath.Join(s.dir, date)); err != nil {
+			return removed, fmt.Errorf("failed to remove snapshots of %s: %w", date, err)
+		}
+		for _, entry := range s.byDate[date] {
+			delete(s.entries, entry.ID)
+			removed++
+		}
+		delete(s.byDate, date)
+	}
+	if removed > 0 {
+		s.logger.WithFields(logrus.Fields{"removed": removed, "before": cutoff}).Info("Pruned market snapshots")
+	}
+	return removed, nil
+}
+
+// dates returns the partition dates in order; the caller holds the lock
+func (s *Store) dates() []string {
+	dates := make([]string, 0, len(s.byDate))
+	for date := range s.byDate {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+func (s *Store) objectPath(date, id string) string {
+	return filepath.Join(s.dir, date, id+objectExt)
+}
+
+func (s *Store) readIndex(date string) ([]*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, date, indexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot index for %s: %w", date, err)
+	}
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot index for %s: %w", date, err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	return entries, nil
+}
+
+// writeIndex saves a partition's index; the caller holds the lock
+func (s *Store) writeIndex(date string) error {
+	data, err := json.MarshalIndent(s.byDate[date], "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot index: %w", err)
+	}
+	return writeFile(filepath.Join(s.dir, date, indexFile), data)
+}
+
+// writeFile replaces path atomically
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+func insertSorted(entries []*Entry, entry *Entry) []*Entry {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].Timestamp.After(entry.Timestamp) })
+	entries = append(entries, nil)
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	return entries
+}
+
+// snapshotSymbols returns the symbols data has a quote or option chain for
+func snapshotSymbols(data *ai_assistant.AggregatedMarketData) []string {
+	seen := make(map[string]bool)
+	for symbol := range data.Quotes {
+		seen[strings.ToUpper(symbol)] = true
+	}
+	for symbol, chains := range data.Options {
+		if len(chains) > 0 {
+			seen[strings.ToUpper(symbol)] = true
+		}
+	}
+	symbols := make([]string, 0, len(seen))
+	for symbol := range seen {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
@@ -0,0 +1,84 @@
+package snapshots
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"vibetrade-claude/internal/ai_assistant"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	s, err := NewStore(t.TempDir(), 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func marketData(at time.Time, price float64) *ai_assistant.AggregatedMarketData {
+	return &ai_assistant.AggregatedMarketData{
+		Timestamp: at,
+		Quotes: map[string]*ai_assistant.Quote{
+			"SPY": {Symbol: "SPY", Price: price, Bid: price - 0.01, Ask: price + 0.01, Timestamp: time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)},
+		},
+	}
+}
+
+func TestSaveDedupesSameMarketStateWithinDay(t *testing.T) {
+	s := newTestStore(t)
+	first := time.Date(2026, 10, 19, 14, 0, 5, 0, time.UTC)
+
+	id, err := s.Save(marketData(first, 505), SourceRecommendation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.Save(marketData(first.Add(time.Minute), 505), SourceEndOfDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id {
+		t.Fatalf("refetched snapshot = %s, want %s", again, id)
+	}
+
+	entries := s.List(first.Add(-time.Hour), first.Add(time.Hour), nil)
+	if len(entries) != 1 || !entries[0].Timestamp.Equal(first) || len(entries[0].Sources) != 2 {
+		t.Fatalf("entries = %+v, want one from the first fetch with both sources", entries)
+	}
+
+	snapshot, _, err := s.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.Data.Timestamp.Equal(first) || snapshot.Data.Quotes["SPY"].Price != 505 {
+		t.Errorf("loaded = %+v, want the first fetch", snapshot.Data)
+	}
+}
+
+func TestSaveKeepsChangedStateAndOtherDaysApart(t *testing.T) {
+	s := newTestStore(t)
+	at := time.Date(2026, 10, 19, 14, 0, 0, 0, time.UTC)
+
+	id, err := s.Save(marketData(at, 505), SourceRecommendation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := s.Save(marketData(at.Add(time.Minute), 505.5), SourceRecommendation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextDay, err := s.Save(marketData(at.AddDate(0, 0, 1), 505), SourceRecommendation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved == id || nextDay == id || nextDay == moved {
+		t.Fatalf("ids = %s, %s, %s, want three snapshots", id, moved, nextDay)
+	}
+	if entry, err := s.Get(nextDay); err != nil || entry.Date() != "2026-10-20" {
+		t.Errorf("next day's entry = %+v, %v, want it dated 2026-10-20", entry, err)
+	}
+}
@@ -0,0 +1,91 @@
+package turnstile
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// CloudflareRanges are the address ranges Cloudflare's edge connects from,
+// as published at https://www.cloudflare.com/ips/
+var CloudflareRanges = []string{
+	"173.245.48.0/20",
+	"103.21.244.0/22",
+	"103.22.200.0/22",
+	"103.31.4.0/22",
+	"141.101.64.0/18",
+	"108.162.192.0/18",
+	"190.93.240.0/20",
+	"188.114.96.0/20",
+	"197.234.240.0/22",
+	"198.41.128.0/17",
+	"162.158.0.0/15",
+	"104.16.0.0/13",
+	"104.24.0.0/14",
+	"172.64.0.0/13",
+	"131.0.72.0/22",
+	"2400:cb00::/32",
+	"2606:4700::/32",
+	"2803:f800::/32",
+	"2405:b500::/32",
+	"2405:8100::/32",
+	"2a06:98c0::/29",
+	"2c0f:f248::/32",
+}
+
+// ParseTrustedProxies parses a comma-separated list of CIDR ranges and
+// addresses. The word "cloudflare" stands for CloudflareRanges.
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if strings.EqualFold(entry, "cloudflare") {
+			entries = append(entries, CloudflareRanges...)
+		} else if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	var proxies []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// ClientIP returns the address of r's caller. The CF-Connecting-IP header
+// is only believed when the connection comes from one of the trusted
+// proxies; anyone else could set it to whatever they like.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if header := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); header != "" && net.ParseIP(header) != nil {
+		if peer := net.ParseIP(host); peer != nil {
+			for _, network := range trusted {
+				if network.Contains(peer) {
+					return header
+				}
+			}
+		}
+	}
+	return host
+}
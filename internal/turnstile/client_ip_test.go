@@ -0,0 +1,66 @@
+package turnstile
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPTrustsHeaderOnlyFromProxies(t *testing.T) {
+	trusted, err := ParseTrustedProxies("cloudflare, 10.0.0.0/8, 192.168.1.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		want       string
+	}{
+		{"direct caller spoofing the header", "203.0.113.7:4321", "198.51.100.1", "203.0.113.7"},
+		{"cloudflare edge", "172.64.1.2:443", "198.51.100.1", "198.51.100.1"},
+		{"cloudflare IPv6 edge", "[2606:4700::1]:443", "2001:db8::1", "2001:db8::1"},
+		{"configured range", "10.1.2.3:80", "198.51.100.1", "198.51.100.1"},
+		{"configured address", "192.168.1.5:80", "198.51.100.1", "198.51.100.1"},
+		{"neighbour of configured address", "192.168.1.6:80", "198.51.100.1", "192.168.1.6"},
+		{"trusted proxy without the header", "10.1.2.3:80", "", "10.1.2.3"},
+		{"trusted proxy with a malformed header", "10.1.2.3:80", "not-an-ip", "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/claude-code/connect", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set("CF-Connecting-IP", tt.header)
+			}
+			if got := ClientIP(r, trusted); got != tt.want {
+				t.Errorf("ClientIP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPIgnoresHeaderWithoutProxies(t *testing.T) {
+	v, err := NewVerifier(Config{Disabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/api/claude-code/connect", nil)
+	r.RemoteAddr = "172.64.1.2:443"
+	r.Header.Set("CF-Connecting-IP", "198.51.100.1")
+	if got := v.RemoteIP(r); got != "172.64.1.2" {
+		t.Errorf("RemoteIP = %s, want the connection's address", got)
+	}
+}
+
+func TestParseTrustedProxiesRejectsGarbage(t *testing.T) {
+	for _, list := range []string{"10.0.0.0/33", "proxy.internal", "10.0.0"} {
+		if _, err := ParseTrustedProxies(list); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded", list)
+		}
+	}
+	if proxies, err := ParseTrustedProxies(" , "); err != nil || len(proxies) != 0 {
+		t.Errorf("empty list = %v, %v, want none", proxies, err)
+	}
+}
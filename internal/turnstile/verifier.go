@@ -0,0 +1,251 @@
+package turnstile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultVerifyURL is Cloudflare's siteverify endpoint
+const DefaultVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+// Cloudflare's dummy secret keys for local development. TestSecretPass
+// accepts every token (including the widget's dummy XXXX.DUMMY.TOKEN.XXXX)
+// and TestSecretFail rejects every token.
+const (
+	TestSecretPass = "1x0000000000000000000000000000000AA"
+	TestSecretFail = "2x0000000000000000000000000000000AA"
+)
+
+// Error codes returned to clients so the frontend can decide whether to
+// re-render the widget, ask the user to retry later, or give up
+const (
+	CodeMissingToken     = "turnstile_missing"
+	CodeInvalidToken     = "turnstile_invalid"
+	CodeExpiredToken     = "turnstile_expired"
+	CodeTokenReused      = "turnstile_reused"
+	CodeHostnameMismatch = "turnstile_hostname_mismatch"
+	CodeActionMismatch   = "turnstile_action_mismatch"
+	CodeUnavailable      = "turnstile_unavailable"
+)
+
+// VerificationError describes why a Turnstile token was rejected
+type VerificationError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *VerificationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// Config controls how tokens are verified
+type Config struct {
+	Secret    string
+	Disabled  bool          // Accept every request without a token; local development only
+	VerifyURL string        // Defaults to DefaultVerifyURL; point at a stub server in tests
+	Hostnames []string      // Accepted hostnames; empty accepts any
+	Action    string        // Expected widget action; empty accepts any
+	Timeout   time.Duration // HTTP timeout for siteverify calls
+	TokenTTL  time.Duration // How long a seen token is remembered to block reuse
+	// TrustedProxies are the proxies whose CF-Connecting-IP header is
+	// believed by RemoteIP; empty ignores the header
+	TrustedProxies []*net.IPNet
+}
+
+// Verifier validates Turnstile tokens against the siteverify API
+type Verifier struct {
+	config     Config
+	httpClient *http.Client
+	mu         sync.Mutex
+	seen       map[[sha256.Size]byte]time.Time
+	now        func() time.Time
+}
+
+type siteverifyResponse struct {
+	Success     bool     `json:"success"`
+	ChallengeTS string   `json:"challenge_ts"`
+	Hostname    string   `json:"hostname"`
+	ErrorCodes  []string `json:"error-codes"`
+	Action      string   `json:"action"`
+	CData       string   `json:"cdata"`
+}
+
+// NewVerifier creates a verifier for the given secret key
+func NewVerifier(config Config) (*Verifier, error) {
+	if config.Secret == "" && !config.Disabled {
+		return nil, fmt.Errorf("turnstile secret key is required")
+	}
+	if config.VerifyURL == "" {
+		config.VerifyURL = DefaultVerifyURL
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.TokenTTL == 0 {
+		// Turnstile tokens are valid for 300 seconds
+		config.TokenTTL = 5 * time.Minute
+	}
+
+	return &Verifier{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.Timeout},
+		seen:       make(map[[sha256.Size]byte]time.Time),
+		now:        time.Now,
+	}, nil
+}
+
+// RemoteIP returns the address of r's caller to verify its token against
+func (v *Verifier) RemoteIP(r *http.Request) string {
+	return ClientIP(r, v.config.TrustedProxies)
+}
+
+// Verify checks token with Cloudflare. Each token is accepted at most once;
+// a second attempt with the same token fails with CodeTokenReused without a
+// network call. A token whose check never reached Cloudflare is not
+// consumed, so the user can retry it.
+func (v *Verifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if v.config.Disabled {
+		return nil
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return &VerificationError{Code: CodeMissingToken, Message: "Turnstile verification required"}
+	}
+
+	if !v.claim(token) {
+		return &VerificationError{Code: CodeTokenReused, Message: "Turnstile token has already been used"}
+	}
+
+	result, err := v.siteverify(ctx, token, remoteIP)
+	if err != nil {
+		v.release(token)
+		return &VerificationError{Code: CodeUnavailable, Message: "Turnstile verification is unavailable", Err: err}
+	}
+
+	if !result.Success {
+		return classifyFailure(result.ErrorCodes)
+	}
+
+	if len(v.config.Hostnames) > 0 && !containsFold(v.config.Hostnames, result.Hostname) {
+		return &VerificationError{
+			Code:    CodeHostnameMismatch,
+			Message: fmt.Sprintf("Turnstile token was issued for unexpected hostname %q", result.Hostname),
+		}
+	}
+
+	if v.config.Action != "" && result.Action != v.config.Action {
+		return &VerificationError{
+			Code:    CodeActionMismatch,
+			Message: fmt.Sprintf("Turnstile token was issued for unexpected action %q", result.Action),
+		}
+	}
+
+	return nil
+}
+
+// claim records token as seen and reports whether this is its first use
+func (v *Verifier) claim(token string) bool {
+	key := sha256.Sum256([]byte(token))
+	now := v.now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for k, expires := range v.seen {
+		if now.After(expires) {
+			delete(v.seen, k)
+		}
+	}
+
+	if _, ok := v.seen[key]; ok {
+		return false
+	}
+	v.seen[key] = now.Add(v.config.TokenTTL)
+	return true
+}
+
+// release forgets a claimed token so it can be verified again
+func (v *Verifier) release(token string) {
+	key := sha256.Sum256([]byte(token))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delete(v.seen, key)
+}
+
+func (v *Verifier) siteverify(ctx context.Context, token, remoteIP string) (*siteverifyResponse, error) {
+	form := url.Values{
+		"secret":   {v.config.Secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", v.config.VerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("siteverify returned status %d", resp.StatusCode)
+	}
+
+	var result siteverifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+func classifyFailure(errorCodes []string) *VerificationError {
+	for _, code := range errorCodes {
+		switch code {
+		case "timeout-or-duplicate":
+			return &VerificationError{Code: CodeExpiredToken, Message: "Turnstile token expired or was already used"}
+		case "missing-input-secret", "invalid-input-secret", "internal-error":
+			return &VerificationError{Code: CodeUnavailable, Message: "Turnstile verification is unavailable", Err: fmt.Errorf("siteverify error %s", code)}
+		}
+	}
+
+	return &VerificationError{
+		Code:    CodeInvalidToken,
+		Message: "Turnstile verification failed",
+		Err:     fmt.Errorf("siteverify errors: %s", strings.Join(errorCodes, ", ")),
+	}
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package turnstile
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestVerifyReleasesTokenOnTransportError(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"success":true,"hostname":"app.example.com"}`))
+	}))
+	defer srv.Close()
+
+	v, err := NewVerifier(Config{Secret: TestSecretPass, VerifyURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var verr *VerificationError
+	if err := v.Verify(context.Background(), "token-1", ""); !errors.As(err, &verr) || verr.Code != CodeUnavailable {
+		t.Fatalf("got %v, want %s", err, CodeUnavailable)
+	}
+
+	// The same token succeeds once siteverify is reachable again
+	failing.Store(false)
+	if err := v.Verify(context.Background(), "token-1", ""); err != nil {
+		t.Fatalf("retry after outage: %v", err)
+	}
+
+	// and is then spent
+	if err := v.Verify(context.Background(), "token-1", ""); !errors.As(err, &verr) || verr.Code != CodeTokenReused {
+		t.Fatalf("got %v, want %s", err, CodeTokenReused)
+	}
+}
+
+func TestVerifyKeepsRejectedTokensClaimed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer srv.Close()
+
+	v, err := NewVerifier(Config{Secret: TestSecretFail, VerifyURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var verr *VerificationError
+	if err := v.Verify(context.Background(), "token-1", ""); !errors.As(err, &verr) || verr.Code != CodeInvalidToken {
+		t.Fatalf("got %v, want %s", err, CodeInvalidToken)
+	}
+	if err := v.Verify(context.Background(), "token-1", ""); !errors.As(err, &verr) || verr.Code != CodeTokenReused {
+		t.Fatalf("got %v, want %s", err, CodeTokenReused)
+	}
+}
+
+func TestDisabledVerifierAcceptsEverything(t *testing.T) {
+	if _, err := NewVerifier(Config{}); err == nil {
+		t.Fatal("expected a secret to be required")
+	}
+
+	v, err := NewVerifier(Config{Disabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(context.Background(), "", ""); err != nil {
+		t.Fatalf("disabled verifier rejected a request: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package userstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var usersBucket = []byte("users")
+
+// BoltUserStore keeps users in an embedded bbolt database, one JSON document
+// per user keyed by ID
+type BoltUserStore struct {
+	db *bolt.DB
+}
+
+// NewBoltUserStore opens or creates the database at path
+func NewBoltUserStore(path string) (*BoltUserStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create user store directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open user store: %w", err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(usersBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize user store: %w", err)
+	}
+
+	return &BoltUserStore{db: db}, nil
+}
+
+func (s *BoltUserStore) GetUser(userID string) (*User, error) {
+	var user *User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		user, err = getUser(tx, userID)
+		return err
+	})
+	return user, err
+}
+
+func (s *BoltUserStore) CreateUser(user *User) error {
+	if user == nil || user.ID == "" {
+		return fmt.Errorf("user ID is required")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(usersBucket).Get([]byte(user.ID)) != nil {
+			return ErrUserExists
+		}
+
+		now := time.Now()
+		stored := user.Clone()
+		if stored.CreatedAt.IsZero() {
+			stored.CreatedAt = now
+		}
+		stored.UpdatedAt = now
+		stored.Version = 1
+
+		if err := putUser(tx, stored); err != nil {
+			return err
+		}
+		*user = *stored
+		return nil
+	})
+}
+
+func (s *BoltUserStore) UpdateUser(user *User) error {
+	if user == nil || user.ID == "" {
+		return fmt.Errorf("user ID is required")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getUser(tx, user.ID)
+		if err != nil {
+			return err
+		}
+		if existing.Version != user.Version {
+			return ErrVersionConflict
+		}
+
+		stored := user.Clone()
+		stored.CreatedAt = existing.CreatedAt
+		stored.UpdatedAt = time.Now()
+		stored.Version = existing.Version + 1
+
+		if err := putUser(tx, stored); err != nil {
+			return err
+		}
+		user.UpdatedAt = stored.UpdatedAt
+		user.Version = stored.Version
+		return nil
+	})
+}
+
+func (s *BoltUserStore) ListUsers() ([]*User, error) {
+	var users []*User
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(usersBucket).ForEach(func(k, v []byte) error {
+			var user User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return fmt.Errorf("failed to decode user %s: %w", k, err)
+			}
+			users = append(users, &user)
+			return nil
+		})
+	})
+	return users, err
+}
+
+func (s *BoltUserStore) Close() error {
+	return s.db.Close()
+}
+
+func getUser(tx *bolt.Tx, userID string) (*User, error) {
+	data := tx.Bucket(usersBucket).Get([]byte(userID))
+	if data == nil {
+		return nil, ErrUserNotFound
+	}
+
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("failed to decode user %s: %w", userID, err)
+	}
+	return &user, nil
+}
+
+func putUser(tx *bolt.Tx, user *User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fmt.Errorf("failed to encode user %s: %w", user.ID, err)
+	}
+	return tx.Bucket(usersBucket).Put([]byte(user.ID), data)
+}
@@ -0,0 +1,107 @@
+package userstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// legacyUser is the record format of the original JSON file store, where
+// all Claude state lived in an untyped metadata map
+type legacyUser struct {
+	ID        string                 `json:"id"`
+	Email     string                 `json:"email,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// ImportReport summarizes a legacy import
+type ImportReport struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// ImportLegacyFile copies users from a legacy users.json into dst. Users that
+// already exist in dst are skipped unless overwrite is set. With dryRun the
+// file is parsed and converted but dst is not touched, and may be nil.
+func ImportLegacyFile(path string, dst UserStore, overwrite, dryRun bool) (*ImportReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legacy user file: %w", err)
+	}
+
+	var legacy map[string]*legacyUser
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, fmt.Errorf("failed to parse legacy user file: %w", err)
+	}
+
+	report := &ImportReport{}
+	for id, lu := range legacy {
+		if lu.ID == "" {
+			lu.ID = id
+		}
+
+		user := convertLegacyUser(lu)
+		if dryRun {
+			report.Imported++
+			continue
+		}
+
+		err := dst.CreateUser(user)
+		if errors.Is(err, ErrUserExists) {
+			if !overwrite {
+				report.Skipped++
+				continue
+			}
+			_, err = Modify(dst, user.ID, func(existing *User) error {
+				version := existing.Version
+				*existing = *user.Clone()
+				existing.Version = version
+				return nil
+			})
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", user.ID, err))
+			continue
+		}
+		report.Imported++
+	}
+
+	return report, nil
+}
+
+func convertLegacyUser(lu *legacyUser) *User {
+	user := &User{
+		ID:        lu.ID,
+		Email:     lu.Email,
+		CreatedAt: lu.CreatedAt,
+	}
+
+	md := lu.Metadata
+	user.Claude.EncryptedAPIKey, _ = md["claude_api_key"].(string)
+	user.Claude.ConnectedAt = legacyTime(md["claude_connected_at"])
+	user.Claude.LastUsedAt = legacyTime(md["claude_last_used_at"])
+	user.Claude.KeyHealth, _ = md["claude_key_health"].(string)
+	user.Claude.LastValidatedAt = legacyTime(md["claude_key_validated_at"])
+	user.Claude.LastValidationError, _ = md["claude_key_last_error"].(string)
+
+	return user
+}
+
+// legacyTime recovers timestamps that were stored as time.Time but came
+// back from JSON as RFC 3339 strings
+func legacyTime(v interface{}) *time.Time {
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,115 @@
+package userstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const legacyFile = `{
+  "alice": {
+    "id": "alice",
+    "email": "alice@example.com",
+    "created_at": "2025-03-01T12:00:00Z",
+    "metadata": {
+      "claude_api_key": "sealed-key",
+      "claude_connected_at": "2025-03-02T09:30:00Z",
+      "claude_key_health": "healthy",
+      "claude_key_validated_at": "not a time"
+    }
+  },
+  "bob": {
+    "email": "bob@example.com",
+    "created_at": "2025-04-01T12:00:00Z"
+  }
+}`
+
+func writeLegacyFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, []byte(legacyFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportLegacyFileConvertsMetadata(t *testing.T) {
+	store := NewMemoryUserStore()
+	report, err := ImportLegacyFile(writeLegacyFile(t), store, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || report.Skipped != 0 || len(report.Errors) != 0 {
+		t.Fatalf("report = %+v, want 2 imported", report)
+	}
+
+	alice, err := store.GetUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Claude.EncryptedAPIKey != "sealed-key" || alice.Claude.KeyHealth != "healthy" || !alice.Claude.IsConnected() {
+		t.Errorf("alice's connection = %+v, want the sealed key and its health", alice.Claude)
+	}
+	if alice.Claude.ConnectedAt == nil || alice.Claude.ConnectedAt.Format("2006-01-02 15:04") != "2025-03-02 09:30" {
+		t.Errorf("connected at %v, want 2025-03-02 09:30", alice.Claude.ConnectedAt)
+	}
+	if alice.Claude.LastValidatedAt != nil {
+		t.Errorf("unparseable validation time became %v, want nil", alice.Claude.LastValidatedAt)
+	}
+	if alice.CreatedAt.Year() != 2025 {
+		t.Errorf("created at %v, want the legacy creation time", alice.CreatedAt)
+	}
+
+	// A record without an ID takes its key
+	if bob, err := store.GetUser("bob"); err != nil || bob.Email != "bob@example.com" || bob.Claude.IsConnected() {
+		t.Errorf("bob = %+v, %v, want imported without a connection", bob, err)
+	}
+}
+
+func TestImportLegacyFileSkipsOrOverwritesExisting(t *testing.T) {
+	path := writeLegacyFile(t)
+	store := NewMemoryUserStore()
+	if err := store.CreateUser(&User{ID: "alice", Email: "new@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := ImportLegacyFile(path, store, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 1 || report.Skipped != 1 {
+		t.Fatalf("report = %+v, want bob imported and alice skipped", report)
+	}
+	if alice, _ := store.GetUser("alice"); alice.Email != "new@example.com" {
+		t.Errorf("alice's email = %q, want the existing user kept", alice.Email)
+	}
+
+	report, err = ImportLegacyFile(path, store, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || report.Skipped != 0 {
+		t.Fatalf("overwrite report = %+v, want both imported", report)
+	}
+	if alice, _ := store.GetUser("alice"); alice.Email != "alice@example.com" || alice.Version != 2 {
+		t.Errorf("alice = %s at version %d, want the legacy record as version 2", alice.Email, alice.Version)
+	}
+}
+
+func TestImportLegacyFileDryRunNeedsNoStore(t *testing.T) {
+	report, err := ImportLegacyFile(writeLegacyFile(t), nil, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 {
+		t.Errorf("report = %+v, want 2 to import", report)
+	}
+
+	broken := filepath.Join(t.TempDir(), "broken.json")
+	if err := os.WriteFile(broken, []byte(`{"alice": [`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportLegacyFile(broken, nil, false, true); err == nil {
+		t.Error("dry run accepted a malformed file")
+	}
+}
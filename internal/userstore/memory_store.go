@@ -0,0 +1,88 @@
+package userstore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryUserStore is a non-persistent UserStore for development and tests
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]*User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: make(map[string]*User)}
+}
+
+func (s *MemoryUserStore) GetUser(userID string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return user.Clone(), nil
+}
+
+func (s *MemoryUserStore) CreateUser(user *User) error {
+	if user == nil || user.ID == "" {
+		return fmt.Errorf("user ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; ok {
+		return ErrUserExists
+	}
+
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	user.Version = 1
+	s.users[user.ID] = user.Clone()
+	return nil
+}
+
+func (s *MemoryUserStore) UpdateUser(user *User) error {
+	if user == nil || user.ID == "" {
+		return fmt.Errorf("user ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[user.ID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if existing.Version != user.Version {
+		return ErrVersionConflict
+	}
+
+	user.CreatedAt = existing.CreatedAt
+	user.UpdatedAt = time.Now()
+	user.Version = existing.Version + 1
+	s.users[user.ID] = user.Clone()
+	return nil
+}
+
+func (s *MemoryUserStore) ListUsers() ([]*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user.Clone())
+	}
+	return users, nil
+}
+
+func (s *MemoryUserStore) Close() error {
+	return nil
+}
@@ -0,0 +1,60 @@
+package userstore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UserStore persists users. Implementations must be safe for concurrent use.
+//
+// Updates use optimistic concurrency: UpdateUser only succeeds if the
+// stored user still has the Version the caller read, and bumps Version on
+// the passed user when it does.
+type UserStore interface {
+	GetUser(userID string) (*User, error)
+	CreateUser(user *User) error
+	UpdateUser(user *User) error
+	ListUsers() ([]*User, error)
+	Close() error
+}
+
+// maxModifyAttempts bounds Modify's retries under contention
+const maxModifyAttempts = 5
+
+// Modify loads the user, applies fn and saves the result, re-reading and
+// re-applying fn if another writer updated the user in between
+func Modify(store UserStore, userID string, fn func(*User) error) (*User, error) {
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		user, err := store.GetUser(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := fn(user); err != nil {
+			return nil, err
+		}
+
+		err = store.UpdateUser(user)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("%w: gave up after %d attempts", ErrVersionConflict, maxModifyAttempts)
+}
+
+// GetOrCreate returns the user, creating an empty one if none exists
+func GetOrCreate(store UserStore, userID string) (*User, error) {
+	user, err := store.GetUser(userID)
+	if !errors.Is(err, ErrUserNotFound) {
+		return user, err
+	}
+
+	if err := store.CreateUser(&User{ID: userID}); err != nil && !errors.Is(err, ErrUserExists) {
+		return nil, err
+	}
+	return store.GetUser(userID)
+}
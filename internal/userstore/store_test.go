@@ -0,0 +1,148 @@
+package userstore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// stores returns a fresh store of each kind
+func stores(t *testing.T) map[string]UserStore {
+	t.Helper()
+	bolt, err := NewBoltUserStore(filepath.Join(t.TempDir(), "data", "users.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+	return map[string]UserStore{"memory": NewMemoryUserStore(), "bolt": bolt}
+}
+
+func TestStoreCreateAndGet(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			user := &User{ID: "alice", Email: "alice@example.com", Preferences: Preferences{Watchlist: []string{"SPY"}}}
+			if err := store.CreateUser(user); err != nil {
+				t.Fatal(err)
+			}
+			if user.Version != 1 || user.CreatedAt.IsZero() {
+				t.Errorf("created user version %d at %v, want version 1 and a creation time", user.Version, user.CreatedAt)
+			}
+			if err := store.CreateUser(&User{ID: "alice"}); !errors.Is(err, ErrUserExists) {
+				t.Errorf("second create = %v, want %v", err, ErrUserExists)
+			}
+			if err := store.CreateUser(&User{}); err == nil {
+				t.Error("created a user without an ID")
+			}
+
+			got, err := store.GetUser("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Email != "alice@example.com" || len(got.Preferences.Watchlist) != 1 || got.Version != 1 {
+				t.Errorf("got %+v, want the created user", got)
+			}
+			if _, err := store.GetUser("bob"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("missing user = %v, want %v", err, ErrUserNotFound)
+			}
+			if users, err := store.ListUsers(); err != nil || len(users) != 1 {
+				t.Errorf("list = %d users, %v, want 1", len(users), err)
+			}
+		})
+	}
+}
+
+func TestStoreUpdateChecksVersion(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if err := store.CreateUser(&User{ID: "alice"}); err != nil {
+				t.Fatal(err)
+			}
+			first, _ := store.GetUser("alice")
+			second, _ := store.GetUser("alice")
+
+			first.Email = "first@example.com"
+			if err := store.UpdateUser(first); err != nil {
+				t.Fatal(err)
+			}
+			if first.Version != 2 {
+				t.Errorf("version after update = %d, want 2", first.Version)
+			}
+
+			// The second reader's copy is stale
+			second.Email = "second@example.com"
+			if err := store.UpdateUser(second); !errors.Is(err, ErrVersionConflict) {
+				t.Fatalf("stale update = %v, want %v", err, ErrVersionConflict)
+			}
+			if got, _ := store.GetUser("alice"); got.Email != "first@example.com" {
+				t.Errorf("email = %q, want the first update kept", got.Email)
+			}
+			if err := store.UpdateUser(&User{ID: "bob"}); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("update of a missing user = %v, want %v", err, ErrUserNotFound)
+			}
+		})
+	}
+}
+
+// racingStore lets another writer update the user between Modify's read
+// and its write, once
+type racingStore struct {
+	UserStore
+	raced bool
+}
+
+func (s *racingStore) UpdateUser(user *User) error {
+	if !s.raced {
+		s.raced = true
+		other, _ := s.UserStore.GetUser(user.ID)
+		other.Preferences.Timezone = "America/New_York"
+		if err := s.UserStore.UpdateUser(other); err != nil {
+			return err
+		}
+	}
+	return s.UserStore.UpdateUser(user)
+}
+
+func TestModifyReappliesAfterConflict(t *testing.T) {
+	store := &racingStore{UserStore: NewMemoryUserStore()}
+	if err := store.CreateUser(&User{ID: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	user, err := Modify(store, "alice", func(u *User) error {
+		calls++
+		u.Preferences.Watchlist = append(u.Preferences.Watchlist, "QQQ")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || user.Version != 3 {
+		t.Errorf("fn called %d times, version %d; want 2 and 3", calls, user.Version)
+	}
+	if got, _ := store.GetUser("alice"); got.Preferences.Timezone != "America/New_York" || len(got.Preferences.Watchlist) != 1 {
+		t.Errorf("user = %+v, want both writers' changes", got.Preferences)
+	}
+}
+
+func TestBoltStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	store, err := NewBoltUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateUser(&User{ID: "alice", RiskLimits: &RiskLimits{MaxDailyLoss: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	reopened, err := NewBoltUserStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	user, err := reopened.GetUser("alice")
+	if err != nil || user.RiskLimits == nil || user.RiskLimits.MaxDailyLoss != 3 {
+		t.Errorf("reopened user = %+v, %v, want its risk limits", user, err)
+	}
+}
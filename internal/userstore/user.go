@@ -0,0 +1,83 @@
+package userstore
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned when no user exists for the requested ID
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned by CreateUser when the ID is already taken
+	ErrUserExists = errors.New("user already exists")
+	// ErrVersionConflict is returned by UpdateUser when the stored user was
+	// modified after the caller read it
+	ErrVersionConflict = errors.New("user was modified concurrently")
+)
+
+// User is a platform user and the settings attached to them
+type User struct {
+	ID          string           `json:"id"`
+	Email       string           `json:"email,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	Version     int64            `json:"version"`
+	Claude      ClaudeConnection `json:"claude"`
+	RiskLimits  *RiskLimits      `json:"risk_limits,omitempty"`
+	Preferences Preferences      `json:"preferences"`
+}
+
+// ClaudeConnection is the state of the user's linked Claude API key
+type ClaudeConnection struct {
+	EncryptedAPIKey     string     `json:"encrypted_api_key,omitempty"`
+	ConnectedAt         *time.Time `json:"connected_at,omitempty"`
+	LastUsedAt          *time.Time `json:"last_used_at,omitempty"`
+	KeyHealth           string     `json:"key_health,omitempty"`
+	LastValidatedAt     *time.Time `json:"last_validated_at,omitempty"`
+	LastValidationError string     `json:"last_validation_error,omitempty"`
+}
+
+// IsConnected reports whether the user has a stored API key
+func (c *ClaudeConnection) IsConnected() bool {
+	return c.EncryptedAPIKey != ""
+}
+
+// RiskLimits are the user's overrides of the default risk parameters; zero
+// fields fall back to the server defaults
+type RiskLimits struct {
+	MaxPortfolioRisk float64 `json:"max_portfolio_risk"` // Max % of portfolio at risk
+	MaxPositionSize  float64 `json:"max_position_size"`  // Max % per position
+	MaxDailyLoss     float64 `json:"max_daily_loss"`     // Max daily loss %
+	MinPOP           float64 `json:"min_pop"`            // Minimum probability of profit
+	MaxConcentration float64 `json:"max_concentration"`  // Max % in single symbol
+}
+
+// Preferences are user-facing settings for the AI assistant
+type Preferences struct {
+	Watchlist           []string `json:"watchlist,omitempty"`
+	PreferredStrategies []string `json:"preferred_strategies,omitempty"`
+	Timezone            string   `json:"timezone,omitempty"`
+}
+
+// Clone returns a deep copy of the user
+func (u *User) Clone() *User {
+	cp := *u
+	cp.Claude.ConnectedAt = cloneTime(u.Claude.ConnectedAt)
+	cp.Claude.LastUsedAt = cloneTime(u.Claude.LastUsedAt)
+	cp.Claude.LastValidatedAt = cloneTime(u.Claude.LastValidatedAt)
+	if u.RiskLimits != nil {
+		limits := *u.RiskLimits
+		cp.RiskLimits = &limits
+	}
+	cp.Preferences.Watchlist = append([]string(nil), u.Preferences.Watchlist...)
+	cp.Preferences.PreferredStrategies = append([]string(nil), u.Preferences.PreferredStrategies...)
+	return &cp
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	cp := *t
+	return &cp
+}
@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEvent records one access to a sealed secret
+type AuditEvent struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"` // "open" or "rewrap"
+	RecordID string    `json:"record_id"`
+	Actor    string    `json:"actor"`
+	Purpose  string    `json:"purpose"`
+	KeyID    string    `json:"key_id,omitempty"`
+	Success  bool      `json:"success"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// AuditLog receives an event for every decrypt performed by the vault
+type AuditLog interface {
+	Record(event AuditEvent) error
+}
+
+// FileAuditLog appends audit events as JSON lines to a file
+type FileAuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileAuditLog opens path for appending, creating it if needed
+func NewFileAuditLog(path string) (*FileAuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &FileAuditLog{file: file}, nil
+}
+
+// Record writes event and syncs it to disk
+func (l *FileAuditLog) Record(event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+	return l.file.Sync()
+}
+
+// Close closes the underlying file
+func (l *FileAuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Keyring holds the master keys used to wrap per-record data keys. The first
+// key loaded is the active key used for new records; the rest are kept so
+// records sealed before a rotation can still be opened and re-wrapped.
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+	legacy   []byte // key for values written by the pre-vault encryptor, if any
+}
+
+// ParseKeyring parses a comma or newline separated list of "id:hexkey"
+// entries. The first entry becomes the active key.
+func ParseKeyring(spec string) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string][]byte)}
+
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+
+		id, keyHex, ok := strings.Cut(field, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("master key entry must be id:hexkey")
+		}
+
+		key, err := hex.DecodeString(strings.TrimSpace(keyHex))
+		if err != nil {
+			return nil, fmt.Errorf("master key %q is not hex encoded: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master key %q must be 32 bytes, got %d", id, len(key))
+		}
+		if _, dup := kr.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate master key ID %q", id)
+		}
+
+		kr.keys[id] = key
+		if kr.activeID == "" {
+			kr.activeID = id
+		}
+	}
+
+	if kr.activeID == "" {
+		return nil, fmt.Errorf("no master keys configured")
+	}
+
+	return kr, nil
+}
+
+// LoadKeyringFile reads a keyring from path, creating it with a single fresh
+// key if the file does not exist. To rotate, prepend a new entry to the file.
+func LoadKeyringFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ParseKeyring(string(data))
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read master key file: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+	spec := "k1:" + hex.EncodeToString(key) + "\n"
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create master key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(spec), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write master key file: %w", err)
+	}
+
+	return ParseKeyring(spec)
+}
+
+// SetLegacyKey registers the key used by the single-key encryptor that
+// predates the vault so existing values can be opened and re-sealed
+func (kr *Keyring) SetLegacyKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("legacy key must be 32 bytes, got %d", len(key))
+	}
+	kr.legacy = key
+	return nil
+}
+
+// ActiveID returns the ID of the key used to seal new records
+func (kr *Keyring) ActiveID() string {
+	return kr.activeID
+}
+
+func (kr *Keyring) key(id string) ([]byte, error) {
+	key, ok := kr.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", id)
+	}
+	return key, nil
+}
@@ -0,0 +1,331 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// sealedPrefix marks values produced by Seal; anything else is treated as a
+// legacy single-key ciphertext
+const sealedPrefix = "vault:v1:"
+
+// ErrAuditFailed is returned by Open when the decrypt could not be audited;
+// the plaintext is withheld in that case
+var ErrAuditFailed = errors.New("failed to record audit event")
+
+// Vault encrypts secrets with envelope encryption: each record gets its own
+// random data key, which is itself encrypted ("wrapped") with a master key
+// from the Keyring. Rotating the master key only requires re-wrapping the
+// data keys, never exposing the secrets themselves.
+type Vault struct {
+	keyring *Keyring
+	audit   AuditLog
+	now     func() time.Time
+}
+
+// AccessInfo describes who is opening a secret and why, for the audit trail
+type AccessInfo struct {
+	Actor   string
+	Purpose string
+}
+
+// Secret is a decrypted value. Call Destroy as soon as it is no longer
+// needed to zero the plaintext buffer. Copies made with String are ordinary
+// Go strings and cannot be wiped, so keep their lifetime short.
+type Secret struct {
+	data []byte
+}
+
+// String returns the plaintext as a string
+func (s *Secret) String() string {
+	return string(s.data)
+}
+
+// Destroy overwrites the plaintext with zeros
+func (s *Secret) Destroy() {
+	zero(s.data)
+	s.data = nil
+}
+
+type envelope struct {
+	KeyID      string `json:"kid"`
+	WrappedKey []byte `json:"wk"`
+	KeyNonce   []byte `json:"kn"`
+	Nonce      []byte `json:"n"`
+	Ciphertext []byte `json:"ct"`
+}
+
+// New creates a vault over keyring that reports every decrypt to audit
+func New(keyring *Keyring, audit AuditLog) (*Vault, error) {
+	if keyring == nil {
+		return nil, fmt.Errorf("keyring is required")
+	}
+	if audit == nil {
+		return nil, fmt.Errorf("audit log is required")
+	}
+
+	return &Vault{
+		keyring: keyring,
+		audit:   audit,
+		now:     time.Now,
+	}, nil
+}
+
+// Seal encrypts plaintext for recordID. The record ID is bound into the
+// ciphertext so a sealed value copied onto another record will not open.
+func (v *Vault) Seal(recordID string, plaintext []byte) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer zero(dataKey)
+
+	env := &envelope{KeyID: v.keyring.activeID}
+
+	var err error
+	env.Nonce, env.Ciphertext, err = gcmSeal(dataKey, plaintext, []byte(recordID))
+	if err != nil {
+		return "", err
+	}
+
+	if err := v.wrap(env, dataKey, recordID); err != nil {
+		return "", err
+	}
+
+	return encodeEnvelope(env)
+}
+
+// Open decrypts a sealed value and records the access in the audit log
+func (v *Vault) Open(recordID, sealed string, access AccessInfo) (*Secret, error) {
+	event := AuditEvent{
+		Time:     v.now(),
+		Action:   "open",
+		RecordID: recordID,
+		Actor:    access.Actor,
+		Purpose:  access.Purpose,
+	}
+
+	plaintext, keyID, err := v.open(recordID, sealed)
+	event.KeyID = keyID
+	event.Success = err == nil
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if auditErr := v.audit.Record(event); auditErr != nil {
+		zero(plaintext)
+		return nil, fmt.Errorf("%w: %v", ErrAuditFailed, auditErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Secret{data: plaintext}, nil
+}
+
+// NeedsRewrap reports whether sealed was not wrapped with the active master key
+func (v *Vault) NeedsRewrap(sealed string) bool {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		return true
+	}
+	env, err := decodeEnvelope(sealed)
+	if err != nil {
+		return false
+	}
+	return env.KeyID != v.keyring.activeID
+}
+
+// Rewrap re-encrypts the record's data key under the active master key.
+// Legacy single-key values are fully re-sealed into the envelope format.
+func (v *Vault) Rewrap(recordID, sealed string) (string, error) {
+	event := AuditEvent{
+		Time:     v.now(),
+		Action:   "rewrap",
+		RecordID: recordID,
+		Actor:    "system",
+		Purpose:  "key_rotation",
+		KeyID:    v.keyring.activeID,
+	}
+
+	result, err := v.rewrap(recordID, sealed)
+	event.Success = err == nil
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if auditErr := v.audit.Record(event); auditErr != nil {
+		return "", fmt.Errorf("%w: %v", ErrAuditFailed, auditErr)
+	}
+	return result, err
+}
+
+func (v *Vault) rewrap(recordID, sealed string) (string, error) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		plaintext, err := v.openLegacy(sealed)
+		if err != nil {
+			return "", err
+		}
+		defer zero(plaintext)
+		return v.Seal(recordID, plaintext)
+	}
+
+	env, err := decodeEnvelope(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := v.unwrap(env, recordID)
+	if err != nil {
+		return "", err
+	}
+	defer zero(dataKey)
+
+	env.KeyID = v.keyring.activeID
+	if err := v.wrap(env, dataKey, recordID); err != nil {
+		return "", err
+	}
+
+	return encodeEnvelope(env)
+}
+
+func (v *Vault) open(recordID, sealed string) ([]byte, string, error) {
+	if !strings.HasPrefix(sealed, sealedPrefix) {
+		plaintext, err := v.openLegacy(sealed)
+		return plaintext, "legacy", err
+	}
+
+	env, err := decodeEnvelope(sealed)
+	if err != nil {
+		return nil, "", err
+	}
+
+	dataKey, err := v.unwrap(env, recordID)
+	if err != nil {
+		return nil, env.KeyID, err
+	}
+	defer zero(dataKey)
+
+	plaintext, err := gcmOpen(dataKey, env.Nonce, env.Ciphertext, []byte(recordID))
+	return plaintext, env.KeyID, err
+}
+
+// openLegacy decrypts values written as base64(nonce||ciphertext) with a
+// single static key, the format used before the vault existed
+func (v *Vault) openLegacy(sealed string) ([]byte, error) {
+	if v.keyring.legacy == nil {
+		return nil, fmt.Errorf("value is not vault sealed and no legacy key is configured")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode legacy ciphertext: %w", err)
+	}
+	if len(data) < 12 {
+		return nil, fmt.Errorf("legacy ciphertext too short")
+	}
+
+	return gcmOpen(v.keyring.legacy, data[:12], data[12:], nil)
+}
+
+func (v *Vault) wrap(env *envelope, dataKey []byte, recordID string) error {
+	masterKey, err := v.keyring.key(env.KeyID)
+	if err != nil {
+		return err
+	}
+
+	env.KeyNonce, env.WrappedKey, err = gcmSeal(masterKey, dataKey, wrapAAD(env.KeyID, recordID))
+	if err != nil {
+		return fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return nil
+}
+
+func (v *Vault) unwrap(env *envelope, recordID string) ([]byte, error) {
+	masterKey, err := v.keyring.key(env.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := gcmOpen(masterKey, env.KeyNonce, env.WrappedKey, wrapAAD(env.KeyID, recordID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+func wrapAAD(keyID, recordID string) []byte {
+	return []byte(keyID + "\x00" + recordID)
+}
+
+func gcmSeal(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return nonce, aead.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length")
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func encodeEnvelope(env *envelope) (string, error) {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode envelope: %w", err)
+	}
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeEnvelope(sealed string) (*envelope, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed value: %w", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse sealed value: %w", err)
+	}
+	return &env, nil
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
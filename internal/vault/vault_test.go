@@ -0,0 +1,262 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	keyA = "k1:" + "1111111111111111111111111111111111111111111111111111111111111111"
+	keyB = "k2:" + "2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// memoryAudit collects events and fails every Record once failing is set
+type memoryAudit struct {
+	mu      sync.Mutex
+	events  []AuditEvent
+	failing bool
+}
+
+func (a *memoryAudit) Record(event AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.failing {
+		return errors.New("disk full")
+	}
+	a.events = append(a.events, event)
+	return nil
+}
+
+func newTestVault(t *testing.T, spec string) (*Vault, *memoryAudit) {
+	t.Helper()
+	keyring, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := &memoryAudit{}
+	v, err := New(keyring, audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, audit
+}
+
+func openString(t *testing.T, v *Vault, recordID, sealed string) (string, error) {
+	t.Helper()
+	secret, err := v.Open(recordID, sealed, AccessInfo{Actor: "test", Purpose: "test"})
+	if err != nil {
+		return "", err
+	}
+	defer secret.Destroy()
+	return secret.String(), nil
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	v, audit := newTestVault(t, keyA)
+
+	sealed, err := v.Seal("user/alice/claude_api_key", []byte("sk-ant-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, sealedPrefix) || strings.Contains(sealed, "sk-ant-secret") {
+		t.Fatalf("unexpected sealed value %q", sealed)
+	}
+
+	got, err := openString(t, v, "user/alice/claude_api_key", sealed)
+	if err != nil || got != "sk-ant-secret" {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	if len(audit.events) != 1 || !audit.events[0].Success || audit.events[0].KeyID != "k1" {
+		t.Fatalf("unexpected audit trail %+v", audit.events)
+	}
+
+	// Sealing the same value twice must not produce the same ciphertext
+	again, _ := v.Seal("user/alice/claude_api_key", []byte("sk-ant-secret"))
+	if again == sealed {
+		t.Fatal("expected a fresh data key and nonce per seal")
+	}
+}
+
+func TestOpenRejectsCiphertextMovedToAnotherRecord(t *testing.T) {
+	v, audit := newTestVault(t, keyA)
+
+	sealed, err := v.Seal("user/alice/claude_api_key", []byte("sk-ant-alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openString(t, v, "user/mallory/claude_api_key", sealed); err == nil {
+		t.Fatal("expected a sealed value copied to another record to fail")
+	}
+	if len(audit.events) != 1 || audit.events[0].Success || audit.events[0].RecordID != "user/mallory/claude_api_key" {
+		t.Fatalf("failed open not audited: %+v", audit.events)
+	}
+}
+
+func TestDestroyZeroesPlaintext(t *testing.T) {
+	v, _ := newTestVault(t, keyA)
+
+	sealed, _ := v.Seal("r1", []byte("sk-ant-secret"))
+	secret, err := v.Open("r1", sealed, AccessInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := secret.data
+	secret.Destroy()
+
+	for _, b := range buf {
+		if b != 0 {
+			t.Fatal("plaintext buffer not zeroed")
+		}
+	}
+}
+
+func TestOpenWithholdsPlaintextWhenAuditFails(t *testing.T) {
+	v, audit := newTestVault(t, keyA)
+
+	sealed, err := v.Seal("r1", []byte("sk-ant-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	audit.failing = true
+	secret, err := v.Open("r1", sealed, AccessInfo{Actor: "alice", Purpose: "recommendations"})
+	if !errors.Is(err, ErrAuditFailed) {
+		t.Fatalf("got %v, want %v", err, ErrAuditFailed)
+	}
+	if secret != nil {
+		t.Fatal("plaintext returned despite audit failure")
+	}
+
+	if _, err := v.Rewrap("r1", sealed); !errors.Is(err, ErrAuditFailed) {
+		t.Fatalf("Rewrap got %v, want %v", err, ErrAuditFailed)
+	}
+}
+
+// sealLegacy encrypts plaintext the way the pre-vault encryptor did
+func sealLegacy(t *testing.T, key, plaintext []byte) string {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil))
+}
+
+func TestLegacyValues(t *testing.T) {
+	legacyKey := make([]byte, 32)
+	rand.Read(legacyKey)
+	legacy := sealLegacy(t, legacyKey, []byte("sk-ant-legacy"))
+
+	v, audit := newTestVault(t, keyA)
+	if _, err := openString(t, v, "r1", legacy); err == nil {
+		t.Fatal("expected legacy value to fail without a legacy key")
+	}
+
+	if err := v.keyring.SetLegacyKey(legacyKey); err != nil {
+		t.Fatal(err)
+	}
+	got, err := openString(t, v, "r1", legacy)
+	if err != nil || got != "sk-ant-legacy" {
+		t.Fatalf("Open legacy = %q, %v", got, err)
+	}
+	if last := audit.events[len(audit.events)-1]; last.KeyID != "legacy" {
+		t.Fatalf("legacy open audited with key %q", last.KeyID)
+	}
+
+	if !v.NeedsRewrap(legacy) {
+		t.Fatal("legacy values must need a rewrap")
+	}
+	resealed, err := v.Rewrap("r1", legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.NeedsRewrap(resealed) {
+		t.Fatal("rewrapped legacy value still needs a rewrap")
+	}
+	if got, err := openString(t, v, "r1", resealed); err != nil || got != "sk-ant-legacy" {
+		t.Fatalf("Open resealed = %q, %v", got, err)
+	}
+}
+
+func TestRewrapMovesRecordsToTheActiveKey(t *testing.T) {
+	before, _ := newTestVault(t, keyA)
+	sealed, err := before.Seal("r1", []byte("sk-ant-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate: k2 becomes active, k1 is kept for reading
+	after, audit := newTestVault(t, keyB+","+keyA)
+	if !after.NeedsRewrap(sealed) {
+		t.Fatal("value sealed under k1 must need a rewrap")
+	}
+
+	rewrapped, err := after.Rewrap("r1", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := decodeEnvelope(rewrapped)
+	if err != nil || env.KeyID != "k2" {
+		t.Fatalf("rewrapped under %q, %v", env.KeyID, err)
+	}
+	if after.NeedsRewrap(rewrapped) {
+		t.Fatal("rewrapped value still needs a rewrap")
+	}
+	if len(audit.events) != 1 || audit.events[0].Action != "rewrap" || !audit.events[0].Success {
+		t.Fatalf("unexpected audit trail %+v", audit.events)
+	}
+
+	// Once k1 is retired only the rewrapped value opens
+	retired, _ := newTestVault(t, keyB)
+	if got, err := openString(t, retired, "r1", rewrapped); err != nil || got != "sk-ant-secret" {
+		t.Fatalf("Open rewrapped = %q, %v", got, err)
+	}
+	if _, err := openString(t, retired, "r1", sealed); err == nil {
+		t.Fatal("expected value sealed under the retired key to fail")
+	}
+
+	// A rewrap cannot be used to rebind a record
+	if _, err := after.Rewrap("r2", sealed); err == nil {
+		t.Fatal("expected rewrap under another record ID to fail")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		ok   bool
+	}{
+		{"single", keyA, true},
+		{"comments and newlines", "# rotated 2026-01\n" + keyB + "\n" + keyA + "\n", true},
+		{"empty", "", false},
+		{"missing id", ":" + strings.Repeat("11", 32), false},
+		{"short key", "k1:1111", false},
+		{"not hex", "k1:" + strings.Repeat("zz", 32), false},
+		{"duplicate id", keyA + "," + keyA, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeyring(tt.spec)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseKeyring err = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
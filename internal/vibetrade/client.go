@@ -96,6 +96,13 @@ type OptionPosition struct {
 	LastUpdated    time.Time       `json:"last_updated"`
 }
 
+// Account represents an account's balances
+type Account struct {
+	Cash        decimal.Decimal `json:"cash"`
+	BuyingPower decimal.Decimal `json:"buying_power"`
+	Equity      decimal.Decimal `json:"equity"`
+}
+
 // GetOptionsChain retrieves the options chain for a symbol
 func (c *Client) GetOptionsChain(ctx context.Context, symbol string, daysToExpiry int) (*OptionChain, error) {
 	params := url.Values{
@@ -227,6 +234,37 @@ func (c *Client) GetOptionsPositions(ctx context.Context, accountID string) ([]O
 	return positions, nil
 }
 
+// GetAccount retrieves an account's cash, buying power and equity
+func (c *Client) GetAccount(ctx context.Context, accountID string) (*Account, error) {
+	params := url.Values{
+		"account_id": {accountID},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/account?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("X-User-ID", c.userID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error: status %d", resp.StatusCode)
+	}
+
+	var account Account
+	if err := json.NewDecoder(resp.Body).Decode(&account); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &account, nil
+}
+
 // HealthCheck verifies the API is accessible
 func (c *Client) HealthCheck(ctx context.Context) error {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/status", nil)